@@ -0,0 +1,89 @@
+package diodes
+
+import "math"
+
+// maxAdvisedSize bounds the size recommended by Advise.
+const maxAdvisedSize = 1 << 24
+
+// Workload describes the traffic through a diode. The rates can be taken
+// from the Rates of InstrumentationDetailed and the burst size from the
+// HighWatermark.
+type Workload struct {
+	// ArrivalRate is the number of values written per second.
+	ArrivalRate float64
+
+	// ServiceRate is the number of values per second the reader can handle
+	// when it is busy. This is typically higher than the observed read rate.
+	ServiceRate float64
+
+	// BurstSize is the largest number of values written in a burst while the
+	// reader is busy.
+	BurstSize int
+}
+
+// Advice is a recommended diode size for a Workload.
+type Advice struct {
+	// Size is the recommended size of the diode.
+	Size int
+
+	// DropProbability is the expected fraction of values that a diode of the
+	// recommended size drops.
+	DropProbability float64
+}
+
+// DropProbability returns the expected fraction of values a diode of the
+// given size drops under the workload. The diode is modeled as a queue with
+// random (Poisson) arrivals and service times that holds size values
+// (M/M/1/K). Bursts are not part of the model.
+func (w Workload) DropProbability(size int) float64 {
+	if size <= 0 || w.ArrivalRate <= 0 {
+		return 0
+	}
+	if w.ServiceRate <= 0 {
+		return 1
+	}
+
+	rho := w.ArrivalRate / w.ServiceRate
+	k := float64(size)
+
+	switch {
+	case rho == 1:
+		return 1 / (k + 1)
+	case rho < 1:
+		return (1 - rho) * math.Pow(rho, k) / (1 - math.Pow(rho, k+1))
+	default:
+		// Divided by rho^(k+1) to avoid overflowing for large sizes.
+		return (rho - 1) / rho / (1 - math.Pow(rho, -(k+1)))
+	}
+}
+
+// Advise recommends the smallest size for which the diode is expected to
+// drop at most the target fraction of values, and that holds a whole burst.
+// When the reader cannot keep up with the writers, no size reaches a small
+// target and the size is capped at 2^24.
+func Advise(w Workload, targetDropProbability float64) Advice {
+	lo, hi := 1, maxAdvisedSize
+	if w.DropProbability(hi) > targetDropProbability {
+		lo = hi
+	}
+
+	// The drop probability shrinks as the size grows.
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if w.DropProbability(mid) <= targetDropProbability {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	size := lo
+	if w.BurstSize > size {
+		size = w.BurstSize
+	}
+
+	return Advice{
+		Size:            size,
+		DropProbability: w.DropProbability(size),
+	}
+}
@@ -0,0 +1,57 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Workload", func() {
+	Describe("DropProbability()", func() {
+		It("follows the M/M/1/K model", func() {
+			w := diodes.Workload{ArrivalRate: 50, ServiceRate: 100}
+
+			// (1 - 0.5) * 0.5^2 / (1 - 0.5^3)
+			Expect(w.DropProbability(2)).To(BeNumerically("~", 1.0/7, 1e-9))
+		})
+
+		It("handles a reader that exactly keeps up", func() {
+			w := diodes.Workload{ArrivalRate: 100, ServiceRate: 100}
+			Expect(w.DropProbability(9)).To(BeNumerically("~", 0.1, 1e-9))
+		})
+
+		It("approaches the excess arrivals when the reader cannot keep up", func() {
+			w := diodes.Workload{ArrivalRate: 200, ServiceRate: 100}
+			Expect(w.DropProbability(1 << 20)).To(BeNumerically("~", 0.5, 1e-9))
+		})
+
+		It("drops nothing without arrivals and everything without a reader", func() {
+			Expect(diodes.Workload{ServiceRate: 100}.DropProbability(4)).To(BeZero())
+			Expect(diodes.Workload{ArrivalRate: 100}.DropProbability(4)).To(Equal(1.0))
+		})
+	})
+})
+
+var _ = Describe("Advise()", func() {
+	It("recommends the smallest size that meets the target", func() {
+		w := diodes.Workload{ArrivalRate: 50, ServiceRate: 100}
+		a := diodes.Advise(w, 0.001)
+
+		Expect(a.DropProbability).To(BeNumerically("<=", 0.001))
+		Expect(w.DropProbability(a.Size - 1)).To(BeNumerically(">", 0.001))
+		Expect(a.Size).To(Equal(9))
+	})
+
+	It("holds a whole burst", func() {
+		a := diodes.Advise(diodes.Workload{ArrivalRate: 50, ServiceRate: 100, BurstSize: 64}, 0.001)
+		Expect(a.Size).To(Equal(64))
+	})
+
+	It("caps the size when the target can't be met", func() {
+		a := diodes.Advise(diodes.Workload{ArrivalRate: 200, ServiceRate: 100}, 0.001)
+
+		Expect(a.Size).To(Equal(1 << 24))
+		Expect(a.DropProbability).To(BeNumerically("~", 0.5, 1e-9))
+	})
+})
@@ -0,0 +1,76 @@
+package diodes
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+var (
+	byteType = reflect.TypeOf(byte(0))
+
+	blockTypesMu sync.RWMutex
+	blockTypes   = make(map[blockKey]block)
+)
+
+type blockKey struct {
+	diode reflect.Type
+	slot  reflect.Type
+}
+
+// block is the block type of the size that was last allocated for a diode
+// and slot type.
+type block struct {
+	size int
+	t    reflect.Type
+}
+
+// allocBlock allocates a value of the given diode type together with a ring
+// of size slots of the given slot type in a single block of memory. It
+// returns a pointer to the (zeroed) diode value and to the first slot of the
+// ring that trails it.
+func allocBlock(diode, slot reflect.Type, size int) (unsafe.Pointer, unsafe.Pointer) {
+	t := blockType(diode, slot, size)
+	p := unsafe.Pointer(reflect.New(t).Pointer())
+
+	return p, unsafe.Add(p, t.Field(2).Offset)
+}
+
+// blockType returns a struct type with the diode as its first field (which
+// keeps the diode's 64-bit fields aligned) followed by an array of size
+// slots. The array is padded to start at a multiple of 8 bytes as 64-bit
+// fields are only 4 byte aligned on 32-bit platforms. As reflect.StructOf
+// allocates on every call, the type of the last size is cached for each
+// diode and slot type. This keeps the cache bounded for processes that
+// create diodes of many sizes, while diodes of the same size are allocated
+// in a single allocation.
+func blockType(diode, slot reflect.Type, size int) reflect.Type {
+	key := blockKey{diode: diode, slot: slot}
+
+	blockTypesMu.RLock()
+	b, ok := blockTypes[key]
+	blockTypesMu.RUnlock()
+	if ok && b.size == size {
+		return b.t
+	}
+
+	pad := (8 - diode.Size()%8) % 8
+	t := reflect.StructOf([]reflect.StructField{
+		{Name: "Diode", Type: diode},
+		{Name: "Pad", Type: reflect.ArrayOf(int(pad), byteType)},
+		{Name: "Ring", Type: reflect.ArrayOf(size, slot)},
+	})
+
+	blockTypesMu.Lock()
+	blockTypes[key] = block{size: size, t: t}
+	blockTypesMu.Unlock()
+
+	return t
+}
+
+// clearRing resets every slot of a caller provided buffer.
+func clearRing(ring []unsafe.Pointer) {
+	for i := range ring {
+		ring[i] = nil
+	}
+}
@@ -0,0 +1,75 @@
+package diodes
+
+import (
+	"time"
+)
+
+// BatchWriter is a Batcher that is set through, with a default batch size and
+// flush interval. It accumulates the data set on a diode and flushes it to a
+// sink in batches once a batch is full or the flush interval has passed since
+// its first value. Writers never block on the sink: while it is slow, the
+// diode drops data.
+//
+// The diode may also be set directly, such as by the writers of an existing
+// diode, so a BatchWriter can batch any diode for an exporter.
+type BatchWriter struct {
+	*Batcher
+
+	size     int
+	interval time.Duration
+	polling  time.Duration
+}
+
+// BatchWriterOption can be used to setup the batch writer.
+type BatchWriterOption func(*BatchWriter)
+
+// WithBatchSize sets the number of items at which a batch is flushed. The
+// default is 100.
+func WithBatchSize(size int) BatchWriterOption {
+	return BatchWriterOption(func(w *BatchWriter) {
+		w.size = size
+	})
+}
+
+// WithFlushInterval sets the longest time a partial batch waits for more data
+// after its first value before it is flushed. The default is 1s.
+func WithFlushInterval(interval time.Duration) BatchWriterOption {
+	return BatchWriterOption(func(w *BatchWriter) {
+		w.interval = interval
+	})
+}
+
+// WithBatchPollingInterval sets the interval at which the diode is queried
+// for new data while it is empty. The default is 10ms.
+func WithBatchPollingInterval(interval time.Duration) BatchWriterOption {
+	return BatchWriterOption(func(w *BatchWriter) {
+		w.polling = interval
+	})
+}
+
+// NewBatchWriter returns a new BatchWriter that stores the data in the given
+// diode and flushes it to the sink. Every batch is a new slice, so the sink
+// may retain it. It panics if the batch size is less than 1.
+func NewBatchWriter(d Diode, sink func([]GenericDataType), opts ...BatchWriterOption) *BatchWriter {
+	w := &BatchWriter{
+		size:     100,
+		interval: time.Second,
+	}
+
+	for _, o := range opts {
+		o(w)
+	}
+
+	var bopts []BatcherOption
+	if w.polling > 0 {
+		bopts = append(bopts, WithBatcherPollingInterval(w.polling))
+	}
+	w.Batcher = NewBatcher(d, w.size, w.interval, sink, bopts...)
+
+	return w
+}
+
+// Set sets the data on the diode. It never blocks.
+func (w *BatchWriter) Set(data GenericDataType) {
+	w.d.Set(data)
+}
@@ -0,0 +1,124 @@
+package diodes_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BatchWriter", func() {
+	var (
+		ctx     context.Context
+		cancel  context.CancelFunc
+		sink    func([]diodes.GenericDataType)
+		flushed func() [][]int
+	)
+
+	set := func(w *diodes.BatchWriter, values ...int) {
+		for _, v := range values {
+			v := v
+			w.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+
+		var (
+			mu      sync.Mutex
+			batches [][]int
+		)
+		sink = func(batch []diodes.GenericDataType) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			var b []int
+			for _, data := range batch {
+				b = append(b, *(*int)(data))
+			}
+			batches = append(batches, b)
+		}
+		flushed = func() [][]int {
+			mu.Lock()
+			defer mu.Unlock()
+			return batches
+		}
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("flushes once a batch is full", func() {
+		w := diodes.NewBatchWriter(diodes.NewOneToOne(16, nil), sink,
+			diodes.WithBatchSize(3),
+			diodes.WithFlushInterval(time.Hour),
+			diodes.WithBatchPollingInterval(time.Millisecond),
+		)
+		set(w, 0, 1, 2, 3, 4, 5, 6)
+		go w.Run(ctx)
+
+		Eventually(flushed).Should(Equal([][]int{{0, 1, 2}, {3, 4, 5}}))
+		Consistently(flushed).Should(HaveLen(2))
+	})
+
+	It("flushes a partial batch once the interval has passed", func() {
+		w := diodes.NewBatchWriter(diodes.NewOneToOne(16, nil), sink,
+			diodes.WithFlushInterval(10*time.Millisecond),
+			diodes.WithBatchPollingInterval(time.Millisecond),
+		)
+		go w.Run(ctx)
+		set(w, 0, 1)
+
+		Eventually(flushed).Should(Equal([][]int{{0, 1}}))
+	})
+
+	It("flushes the remaining data once the context is done", func() {
+		w := diodes.NewBatchWriter(diodes.NewOneToOne(16, nil), sink,
+			diodes.WithBatchSize(2),
+			diodes.WithFlushInterval(time.Hour),
+		)
+		set(w, 0, 1, 2, 3, 4)
+		cancel()
+		w.Run(ctx)
+
+		Expect(flushed()).To(Equal([][]int{{0, 1}, {2, 3}, {4}}))
+	})
+
+	It("batches the data set directly on the diode", func() {
+		d := diodes.NewManyToOne(16, nil)
+		w := diodes.NewBatchWriter(d, sink, diodes.WithBatchSize(2))
+		for i := 0; i < 3; i++ {
+			i := i
+			d.Set(diodes.GenericDataType(&i))
+		}
+		cancel()
+		w.Run(ctx)
+
+		Expect(flushed()).To(Equal([][]int{{0, 1}, {2}}))
+	})
+
+	It("flushes batches the sink may retain", func() {
+		var (
+			mu      sync.Mutex
+			batches [][]diodes.GenericDataType
+		)
+		w := diodes.NewBatchWriter(diodes.NewOneToOne(16, nil), func(batch []diodes.GenericDataType) {
+			mu.Lock()
+			defer mu.Unlock()
+			batches = append(batches, batch)
+		}, diodes.WithBatchSize(2))
+		set(w, 0, 1, 2, 3)
+		cancel()
+		w.Run(ctx)
+
+		Expect(batches).To(HaveLen(2))
+		Expect(*(*int)(batches[0][0])).To(Equal(0))
+		Expect(*(*int)(batches[1][0])).To(Equal(2))
+	})
+})
@@ -0,0 +1,164 @@
+package diodes
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Batcher drains a diode and emits its values to a callback in batches of
+// up to a number of items. A batch is emitted once it is full, or once the
+// batch window has passed since its first value arrived, so that no value
+// waits longer than the window for a slow trickle to fill its batch. This is
+// the building block of exporters that send their data in batches.
+//
+// Every batch is a new slice that the callback may retain, such as to send
+// it asynchronously. A BatchWriter is a Batcher that is set through.
+type Batcher struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	full    uint64
+	expired uint64
+
+	d       Diode
+	size    int
+	window  time.Duration
+	emit    func([]GenericDataType)
+	polling time.Duration
+}
+
+// BatcherOption can be used to setup the batcher.
+type BatcherOption func(*Batcher)
+
+// WithBatcherPollingInterval sets the interval at which the diode is queried
+// for new data while it is empty. The default is 10ms, or the window if it is
+// shorter.
+func WithBatcherPollingInterval(interval time.Duration) BatcherOption {
+	return BatcherOption(func(b *Batcher) {
+		b.polling = interval
+	})
+}
+
+// NewBatcher returns a new Batcher that drains the given diode into batches
+// of up to size values, or of the values that arrived within the window, and
+// emits them to the callback. It panics if size is less than 1.
+func NewBatcher(d Diode, size int, window time.Duration, emit func([]GenericDataType), opts ...BatcherOption) *Batcher {
+	if size < 1 {
+		panic("diodes: a batch must hold at least 1 value")
+	}
+
+	b := &Batcher{
+		d:       d,
+		size:    size,
+		window:  window,
+		emit:    emit,
+		polling: 10 * time.Millisecond,
+	}
+	if window > 0 && window < b.polling {
+		b.polling = window
+	}
+
+	for _, o := range opts {
+		o(b)
+	}
+
+	return b
+}
+
+// Run reads from the diode and emits the batches until the context is done.
+// The context is also checked after every full batch, so that Run returns
+// while the diode never runs empty. The values that are left in the diode are
+// then emitted as well, up to its lag at that time, or 1024 values if it does
+// not report its lag. Run must only be invoked once as it is the reader of
+// the diode.
+func (b *Batcher) Run(ctx context.Context) {
+	var (
+		batch    []GenericDataType
+		deadline time.Time
+	)
+
+	for {
+		data, ok := b.d.TryNext()
+		if ok {
+			if len(batch) == 0 {
+				batch = make([]GenericDataType, 0, b.size)
+				deadline = time.Now().Add(b.window)
+			}
+
+			batch = append(batch, data)
+			if len(batch) == b.size {
+				atomic.AddUint64(&b.full, 1)
+				b.emit(batch)
+				batch = nil
+
+				if ctx.Err() != nil {
+					b.drain(nil)
+					return
+				}
+			}
+			continue
+		}
+
+		wait := b.polling
+		if len(batch) > 0 {
+			until := time.Until(deadline)
+			if until <= 0 {
+				atomic.AddUint64(&b.expired, 1)
+				b.emit(batch)
+				batch = nil
+				continue
+			}
+			if until < wait {
+				wait = until
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			b.drain(batch)
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// drain emits the batch and whatever is left in the diode. It reads at most
+// the values that were in the diode when it was invoked, so that a writer
+// that keeps up with the reads cannot keep it from returning.
+func (b *Batcher) drain(batch []GenericDataType) {
+	for n := drainLimit(b.d); n > 0; n-- {
+		data, ok := b.d.TryNext()
+		if !ok {
+			break
+		}
+
+		batch = append(batch, data)
+		if len(batch) == b.size {
+			b.emit(batch)
+			batch = nil
+		}
+	}
+
+	if len(batch) > 0 {
+		b.emit(batch)
+	}
+}
+
+// BatcherStats are the counters of a Batcher.
+type BatcherStats struct {
+	// Full is the number of batches that were emitted as they were full.
+	Full uint64
+
+	// Expired is the number of batches that were emitted as their window
+	// passed before they were full.
+	Expired uint64
+}
+
+// Stats returns a snapshot of the batcher's counters. It is safe to call
+// from any go-routine.
+func (b *Batcher) Stats() BatcherStats {
+	return BatcherStats{
+		Full:    atomic.LoadUint64(&b.full),
+		Expired: atomic.LoadUint64(&b.expired),
+	}
+}
@@ -0,0 +1,137 @@
+package diodes_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batcher", func() {
+	var (
+		ctx     context.Context
+		cancel  context.CancelFunc
+		done    chan struct{}
+		d       *diodes.OneToOne
+		mu      sync.Mutex
+		batches [][]diodes.GenericDataType
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan struct{})
+		d = diodes.NewOneToOne(16, nil)
+		batches = nil
+	})
+
+	AfterEach(func() {
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+
+	run := func(b *diodes.Batcher) {
+		go func() {
+			defer close(done)
+			b.Run(ctx)
+		}()
+	}
+
+	emit := func(batch []diodes.GenericDataType) {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, batch)
+	}
+
+	emitted := func() [][]int {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var result [][]int
+		for _, batch := range batches {
+			var b []int
+			for _, data := range batch {
+				b = append(b, *(*int)(data))
+			}
+			result = append(result, b)
+		}
+		return result
+	}
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("emits a batch once it is full", func() {
+		b := diodes.NewBatcher(d, 3, time.Hour, emit, diodes.WithBatcherPollingInterval(time.Millisecond))
+		set(1, 2, 3, 4, 5, 6, 7)
+		run(b)
+
+		Eventually(emitted).Should(Equal([][]int{{1, 2, 3}, {4, 5, 6}}))
+		Consistently(emitted, 50*time.Millisecond).Should(HaveLen(2))
+		Expect(b.Stats()).To(Equal(diodes.BatcherStats{Full: 2}))
+	})
+
+	It("emits a partial batch once its window passed", func() {
+		b := diodes.NewBatcher(d, 100, 30*time.Millisecond, emit)
+		run(b)
+
+		start := time.Now()
+		set(1, 2)
+		Eventually(emitted).Should(Equal([][]int{{1, 2}}))
+		Expect(time.Since(start)).To(BeNumerically(">=", 30*time.Millisecond))
+		Expect(b.Stats()).To(Equal(diodes.BatcherStats{Expired: 1}))
+	})
+
+	It("starts the window with the first value of a batch", func() {
+		b := diodes.NewBatcher(d, 100, 50*time.Millisecond, emit)
+		run(b)
+
+		Consistently(emitted, 80*time.Millisecond).Should(BeEmpty())
+		set(1)
+		Consistently(emitted, 20*time.Millisecond).Should(BeEmpty())
+		Eventually(emitted).Should(Equal([][]int{{1}}))
+	})
+
+	It("emits batches the callback may retain", func() {
+		b := diodes.NewBatcher(d, 2, time.Hour, emit)
+		set(1, 2, 3, 4)
+		run(b)
+
+		Eventually(emitted).Should(Equal([][]int{{1, 2}, {3, 4}}))
+	})
+
+	It("emits what is left once the context is done", func() {
+		b := diodes.NewBatcher(d, 3, time.Hour, emit)
+		run(b)
+
+		set(1, 2)
+		cancel()
+		Eventually(done).Should(BeClosed())
+		Expect(emitted()).To(Equal([][]int{{1, 2}}))
+	})
+
+	It("returns once the context is done while the diode never runs empty", func() {
+		var n uint64
+		b := diodes.NewBatcher(&endlessDiode{lag: 5}, 3, time.Hour, func([]diodes.GenericDataType) {
+			atomic.AddUint64(&n, 1)
+		})
+		run(b)
+
+		Eventually(func() uint64 { return atomic.LoadUint64(&n) }).Should(BeNumerically(">", 0))
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("panics when a batch cannot hold a value", func() {
+		Expect(func() { diodes.NewBatcher(d, 0, time.Second, emit) }).To(Panic())
+		close(done)
+	})
+})
@@ -0,0 +1,266 @@
+// Package bench provides a reproducible producer/consumer benchmark harness
+// for comparing diodes with buffered channels and other ring buffers.
+//
+// The benchmarks in this package can be run with:
+//
+//	go test -bench=. -run=NoTest code.cloudfoundry.org/go-diodes/bench
+//
+// Each benchmark reports, alongside the usual ns/op, the fraction of items
+// that were dropped by the queue under test (drops/op). The same harness can
+// be used to benchmark custom queues by implementing diodes.Diode and
+// calling Run.
+package bench
+
+import (
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Seed is used to generate payloads so that runs are reproducible.
+const Seed = 42
+
+// Payload generates the data written by the producer.
+type Payload struct {
+	Name string
+	gen  func(r *rand.Rand) diodes.GenericDataType
+}
+
+// Bytes returns a payload of []byte of the given size.
+func Bytes(size int) Payload {
+	return Payload{
+		Name: "bytes-" + strconv.Itoa(size),
+		gen: func(r *rand.Rand) diodes.GenericDataType {
+			b := make([]byte, size)
+			r.Read(b)
+			return diodes.GenericDataType(&b)
+		},
+	}
+}
+
+// Ints returns a payload of *int.
+func Ints() Payload {
+	return Payload{
+		Name: "int",
+		gen: func(r *rand.Rand) diodes.GenericDataType {
+			i := r.Int()
+			return diodes.GenericDataType(&i)
+		},
+	}
+}
+
+// Structs returns a payload of a small struct with a few fields.
+func Structs() Payload {
+	type record struct {
+		ID        int64
+		Timestamp int64
+		Name      string
+		Tags      map[string]string
+	}
+
+	return Payload{
+		Name: "struct",
+		gen: func(r *rand.Rand) diodes.GenericDataType {
+			return diodes.GenericDataType(&record{
+				ID:        r.Int63(),
+				Timestamp: r.Int63(),
+				Name:      "some-record",
+				Tags:      map[string]string{"source": "bench"},
+			})
+		},
+	}
+}
+
+// Config describes a single benchmark run.
+type Config struct {
+	// Payload is the type of data written by the producers.
+	Payload Payload
+
+	// Producers is the number of writing go-routines. Defaults to 1.
+	Producers int
+
+	// Interval is the time between writes of a single producer. A zero
+	// interval writes as fast as possible.
+	Interval time.Duration
+}
+
+// Run benchmarks the given queue with the given configuration. Producers
+// write b.N items in total while a single consumer reads until the producers
+// are done and the queue is empty.
+func Run(b *testing.B, q diodes.Diode, c Config) {
+	if c.Producers < 1 {
+		c.Producers = 1
+	}
+
+	payloads := pregenerate(c.Payload, 64)
+
+	var (
+		wg   sync.WaitGroup
+		done int32
+	)
+	wg.Add(c.Producers)
+	perProducer := b.N / c.Producers
+
+	b.ResetTimer()
+
+	for p := 0; p < c.Producers; p++ {
+		go func() {
+			defer wg.Done()
+			next := time.Now()
+			for i := 0; i < perProducer; i++ {
+				if c.Interval > 0 {
+					next = next.Add(c.Interval)
+					for time.Now().Before(next) {
+						runtime.Gosched()
+					}
+				}
+				q.Set(payloads[i%len(payloads)])
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		atomic.StoreInt32(&done, 1)
+	}()
+
+	var read int
+	for {
+		if _, ok := q.TryNext(); ok {
+			read++
+			continue
+		}
+
+		if atomic.LoadInt32(&done) == 1 {
+			if _, ok := q.TryNext(); !ok {
+				break
+			}
+			read++
+			continue
+		}
+
+		runtime.Gosched()
+	}
+
+	b.StopTimer()
+
+	written := perProducer * c.Producers
+	if written > 0 {
+		b.ReportMetric(float64(written-read)/float64(written), "drops/op")
+	}
+}
+
+func pregenerate(p Payload, n int) []diodes.GenericDataType {
+	r := rand.New(rand.NewSource(Seed))
+	payloads := make([]diodes.GenericDataType, n)
+	for i := range payloads {
+		payloads[i] = p.gen(r)
+	}
+	return payloads
+}
+
+// Channel adapts a buffered channel to the diodes.Diode interface. Set blocks
+// when the channel is full, which is the usual way channels are used.
+type Channel chan diodes.GenericDataType
+
+// NewChannel returns a buffered channel of the given size.
+func NewChannel(size int) Channel {
+	return make(Channel, size)
+}
+
+// Set sends data on the channel.
+func (c Channel) Set(data diodes.GenericDataType) {
+	c <- data
+}
+
+// TryNext receives from the channel without blocking.
+func (c Channel) TryNext() (diodes.GenericDataType, bool) {
+	select {
+	case data := <-c:
+		return data, true
+	default:
+		return nil, false
+	}
+}
+
+// DroppingChannel adapts a buffered channel to the diodes.Diode interface.
+// Unlike Channel, Set drops the data instead of blocking when the channel is
+// full.
+type DroppingChannel chan diodes.GenericDataType
+
+// NewDroppingChannel returns a buffered channel of the given size.
+func NewDroppingChannel(size int) DroppingChannel {
+	return make(DroppingChannel, size)
+}
+
+// Set sends data on the channel if there is room for it.
+func (c DroppingChannel) Set(data diodes.GenericDataType) {
+	select {
+	case c <- data:
+	default:
+	}
+}
+
+// TryNext receives from the channel without blocking.
+func (c DroppingChannel) TryNext() (diodes.GenericDataType, bool) {
+	select {
+	case data := <-c:
+		return data, true
+	default:
+		return nil, false
+	}
+}
+
+// MutexRing is a ring buffer protected by a mutex that overwrites the oldest
+// data when full. It is the straightforward alternative to a diode.
+type MutexRing struct {
+	mu     sync.Mutex
+	buffer []unsafe.Pointer
+	read   int
+	write  int
+	len    int
+}
+
+// NewMutexRing returns a MutexRing of the given size.
+func NewMutexRing(size int) *MutexRing {
+	return &MutexRing{
+		buffer: make([]unsafe.Pointer, size),
+	}
+}
+
+// Set writes data to the ring, overwriting the oldest data when full.
+func (r *MutexRing) Set(data diodes.GenericDataType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buffer[r.write] = unsafe.Pointer(data)
+	r.write = (r.write + 1) % len(r.buffer)
+	if r.len == len(r.buffer) {
+		r.read = (r.read + 1) % len(r.buffer)
+		return
+	}
+	r.len++
+}
+
+// TryNext reads the oldest data from the ring.
+func (r *MutexRing) TryNext() (diodes.GenericDataType, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.len == 0 {
+		return nil, false
+	}
+
+	data := r.buffer[r.read]
+	r.buffer[r.read] = nil
+	r.read = (r.read + 1) % len(r.buffer)
+	r.len--
+	return diodes.GenericDataType(data), true
+}
@@ -0,0 +1,93 @@
+package bench_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/bench"
+)
+
+var (
+	sizes    = []int{64, 1024, 16384}
+	payloads = []bench.Payload{bench.Ints(), bench.Bytes(128), bench.Structs()}
+	rates    = map[string]time.Duration{
+		"unthrottled": 0,
+		"1us":         time.Microsecond,
+	}
+)
+
+type queue struct {
+	name  string
+	multi bool
+	new   func(size int) diodes.Diode
+}
+
+var queues = []queue{
+	{name: "OneToOne", new: func(size int) diodes.Diode {
+		return diodes.NewOneToOne(size, nil)
+	}},
+	{name: "OneToOneSeqlock", new: func(size int) diodes.Diode {
+		return diodes.NewOneToOne(size, nil, diodes.WithImplementation(diodes.Seqlock))
+	}},
+	{name: "OneToOneBasic", new: func(size int) diodes.Diode {
+		return diodes.NewOneToOne(size, nil, diodes.WithInstrumentation(diodes.InstrumentationBasic))
+	}},
+	{name: "OneToOneDetailed", new: func(size int) diodes.Diode {
+		return diodes.NewOneToOne(size, nil, diodes.WithInstrumentation(diodes.InstrumentationDetailed))
+	}},
+	{name: "Segmented", new: func(size int) diodes.Diode {
+		return diodes.NewSegmented(size/32, 32, nil)
+	}},
+	{name: "ManyToOne", multi: true, new: func(size int) diodes.Diode {
+		return diodes.NewManyToOne(size, nil)
+	}},
+	{name: "ManyToOnePadded", multi: true, new: func(size int) diodes.Diode {
+		return diodes.NewManyToOne(size, nil, diodes.WithPaddedSlots())
+	}},
+	{name: "ManyToOneSeqlock", multi: true, new: func(size int) diodes.Diode {
+		return diodes.NewManyToOne(size, nil, diodes.WithImplementation(diodes.Seqlock))
+	}},
+	{name: "Channel", multi: true, new: func(size int) diodes.Diode {
+		return bench.NewChannel(size)
+	}},
+	{name: "DroppingChannel", multi: true, new: func(size int) diodes.Diode {
+		return bench.NewDroppingChannel(size)
+	}},
+	{name: "MutexRing", multi: true, new: func(size int) diodes.Diode {
+		return bench.NewMutexRing(size)
+	}},
+}
+
+func BenchmarkSingleProducer(b *testing.B) {
+	runMatrix(b, 1)
+}
+
+func BenchmarkManyProducers(b *testing.B) {
+	runMatrix(b, 4)
+}
+
+func runMatrix(b *testing.B, producers int) {
+	for _, q := range queues {
+		if producers > 1 && !q.multi {
+			continue
+		}
+
+		for _, size := range sizes {
+			for _, p := range payloads {
+				for rate, interval := range rates {
+					q, size, c := q, size, bench.Config{
+						Payload:   p,
+						Producers: producers,
+						Interval:  interval,
+					}
+					name := q.name + "/size=" + strconv.Itoa(size) + "/payload=" + p.Name + "/rate=" + rate
+					b.Run(name, func(b *testing.B) {
+						bench.Run(b, q.new(size), c)
+					})
+				}
+			}
+		}
+	}
+}
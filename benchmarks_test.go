@@ -116,6 +116,33 @@ func BenchmarkManyToOneWaiter(b *testing.B) {
 	}
 }
 
+func BenchmarkOneToOnePacked(b *testing.B) {
+	d := diodes.NewPacked(diodes.NewOneToOne(b.N/16+1, diodes.AlertFunc(func(missed int) {
+		panic("Oops...")
+	})), 16)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	defer wg.Wait()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < b.N; i++ {
+			data := randData(i)
+			d.Set(diodes.GenericDataType(data))
+		}
+		d.Flush()
+	}()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; {
+		if _, ok := d.TryNext(); ok {
+			i++
+		}
+	}
+}
+
 func BenchmarkChannel(b *testing.B) {
 	c := make(chan []byte, b.N)
 
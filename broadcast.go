@@ -0,0 +1,288 @@
+package diodes
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Broadcast reads from a diode and sets the data on the diode of every
+// subscription. Each subscription is read at its own pace, so a slow
+// subscriber drops data instead of slowing down the source or the other
+// subscribers. The data is shared by all subscriptions and must not be
+// modified once it is set.
+type Broadcast struct {
+	dropped uint64
+
+	n    Nexter
+	size int
+	opts []DiodeConfigOption
+
+	mu            sync.Mutex
+	subscriptions map[*Subscription]struct{}
+	subscribed    int
+
+	// history holds the last values that were broadcast for WithReplay.
+	// next is the index of the oldest one once history is full.
+	history []GenericDataType
+	replay  int
+	next    int
+}
+
+// BroadcastOption can be used to setup the broadcast.
+type BroadcastOption func(*Broadcast)
+
+// WithSubscriptionSize sets the size of the diode of each subscription. The
+// default is 1024.
+func WithSubscriptionSize(size int) BroadcastOption {
+	return BroadcastOption(func(b *Broadcast) {
+		b.size = size
+	})
+}
+
+// WithReplay retains the last n values that were broadcast. A new
+// subscription receives them before the live data, so that a late
+// subscriber, such as a debug session or a reconnecting exporter, sees the
+// recent history. A subscription that is smaller than n drops the oldest of
+// them as usual. The values are retained until they are replaced, even when
+// there are no subscriptions.
+func WithReplay(n int) BroadcastOption {
+	return BroadcastOption(func(b *Broadcast) {
+		b.replay = n
+	})
+}
+
+// WithSubscriptionDiodeOptions sets the options the diodes of the
+// subscriptions are created with, such as WithInstrumentation for the
+// throughput rates of every subscriber.
+func WithSubscriptionDiodeOptions(opts ...DiodeConfigOption) BroadcastOption {
+	return BroadcastOption(func(b *Broadcast) {
+		b.opts = opts
+	})
+}
+
+// SubscriptionOption can be used to setup a subscription.
+type SubscriptionOption func(*Subscription)
+
+// WithSubscriptionName sets the name the subscription is reported by in
+// SubscriptionStats. The default is "subscription-" followed by the number
+// of the subscription.
+func WithSubscriptionName(name string) SubscriptionOption {
+	return SubscriptionOption(func(s *Subscription) {
+		s.name = name
+	})
+}
+
+// NewBroadcast returns a new Broadcast that reads from the given Poller or
+// Waiter.
+func NewBroadcast(n Nexter, opts ...BroadcastOption) *Broadcast {
+	b := &Broadcast{
+		n:             n,
+		size:          1024,
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+
+	for _, o := range opts {
+		o(b)
+	}
+
+	return b
+}
+
+// Run broadcasts the data until the Poller or Waiter returns nil. Run must
+// only be invoked once as it is the reader of the diode.
+func (b *Broadcast) Run() {
+	for {
+		data := b.n.Next()
+		if data == nil {
+			return
+		}
+
+		b.publish(data)
+	}
+}
+
+// publish sets the data on the diode of every subscription.
+func (b *Broadcast) publish(data GenericDataType) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscriptions {
+		s.w.Set(data)
+	}
+	b.retain(data)
+}
+
+// retain adds the value to the history. It must be invoked with the lock
+// held.
+func (b *Broadcast) retain(data GenericDataType) {
+	if b.replay <= 0 {
+		return
+	}
+
+	if len(b.history) < b.replay {
+		b.history = append(b.history, data)
+		return
+	}
+
+	b.history[b.next] = data
+	b.next = (b.next + 1) % b.replay
+}
+
+// Subscribe returns a new subscription that receives the data broadcast
+// from now on until it is closed, preceded by the retained values of
+// WithReplay. Its Next returns nil once the context is done. The alerter is
+// invoked on the subscriber's go-routine when data is dropped for it. A nil
+// can be used to ignore alerts.
+func (b *Broadcast) Subscribe(ctx context.Context, alerter Alerter, opts ...SubscriptionOption) *Subscription {
+	s := &Subscription{b: b, alerter: alerter}
+	for _, o := range opts {
+		o(s)
+	}
+	s.d = NewOneToOne(b.size, AlertFunc(s.alert), b.opts...)
+	s.w = NewWaiter(s.d, WithWaiterContext(ctx))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribed++
+	if s.name == "" {
+		s.name = "subscription-" + strconv.Itoa(b.subscribed)
+	}
+
+	// The history is replayed while the lock is held, so that no value is
+	// missed or received twice in between the history and the live data.
+	for i := range b.history {
+		s.w.Set(b.history[(b.next+i)%len(b.history)])
+	}
+	b.subscriptions[s] = struct{}{}
+
+	return s
+}
+
+// Subscriptions returns the number of open subscriptions.
+func (b *Broadcast) Subscriptions() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscriptions)
+}
+
+// SubscriptionStats returns the stats of every open subscription, ordered by
+// their lag with the subscriber that is furthest behind first, so that a
+// subscriber that is being lapped stands out from the aggregate drops. It
+// is safe to call from any go-routine.
+func (b *Broadcast) SubscriptionStats() []SubscriptionStats {
+	b.mu.Lock()
+	stats := make([]SubscriptionStats, 0, len(b.subscriptions))
+	for s := range b.subscriptions {
+		stats = append(stats, s.SubscriptionStats())
+	}
+	b.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Lag != stats[j].Lag {
+			return stats[i].Lag > stats[j].Lag
+		}
+		return stats[i].Name < stats[j].Name
+	})
+
+	return stats
+}
+
+// closeSubscriptions closes all subscriptions.
+func (b *Broadcast) closeSubscriptions() {
+	b.mu.Lock()
+	subscriptions := b.subscriptions
+	b.subscriptions = make(map[*Subscription]struct{})
+	b.mu.Unlock()
+
+	for s := range subscriptions {
+		s.w.Close()
+	}
+}
+
+// Drops returns the number of values dropped by all subscriptions, including
+// the closed ones.
+func (b *Broadcast) Drops() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Subscription is the cursor of a subscriber of a Broadcast. It is meant to
+// be read by a single go-routine.
+type Subscription struct {
+	b       *Broadcast
+	name    string
+	d       *OneToOne
+	w       *Waiter
+	alerter Alerter
+}
+
+// Next returns the next value that was broadcast. It blocks until there is
+// a value and returns nil once the context of the subscription is done or
+// the subscription is closed and the values it received are read.
+func (s *Subscription) Next() GenericDataType {
+	return s.w.Next()
+}
+
+// TryNext returns the next value that was broadcast, if any.
+func (s *Subscription) TryNext() (GenericDataType, bool) {
+	return s.d.TryNext()
+}
+
+// Close stops the broadcast to the subscription and closes its diode, which
+// wakes up a reader that is blocked in Next.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	delete(s.b.subscriptions, s)
+	s.b.mu.Unlock()
+
+	s.w.Close()
+}
+
+// Stats returns a snapshot of the statistics of the subscription's diode.
+// It is safe to call from any go-routine.
+func (s *Subscription) Stats() Stats {
+	return s.d.Stats()
+}
+
+// Name returns the name of the subscription.
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// SubscriptionStats returns a snapshot of the stats of the subscription
+// along with its name and throughput. It is safe to call from any
+// go-routine.
+func (s *Subscription) SubscriptionStats() SubscriptionStats {
+	return SubscriptionStats{
+		Name:  s.name,
+		Stats: s.d.Stats(),
+		Rates: s.d.Instrumentation().Rates,
+	}
+}
+
+// SubscriptionStats are the stats of a single subscriber of a Broadcast.
+type SubscriptionStats struct {
+	// Name is the name of the subscription.
+	Name string
+
+	// Stats are the stats of the subscription's diode. Its Lag is how far
+	// the subscriber is behind the broadcast and its Drops are the values
+	// the subscriber was lapped by.
+	Stats
+
+	// Rates are the throughput rates of the subscriber. They are only
+	// recorded when the subscriptions are created with
+	// InstrumentationDetailed by WithSubscriptionDiodeOptions.
+	Rates []Rate
+}
+
+func (s *Subscription) alert(missed int) {
+	atomic.AddUint64(&s.b.dropped, uint64(missed))
+	if s.alerter != nil {
+		s.alerter.Alert(missed)
+	}
+}
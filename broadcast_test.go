@@ -0,0 +1,226 @@
+package diodes_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Broadcast", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		w      *diodes.Waiter
+		b      *diodes.Broadcast
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(16, nil), diodes.WithWaiterContext(ctx))
+		b = diodes.NewBroadcast(w, diodes.WithSubscriptionSize(2))
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	set := func(v int) {
+		w.Set(diodes.GenericDataType(&v))
+	}
+
+	next := func(s *diodes.Subscription) int {
+		return *(*int)(s.Next())
+	}
+
+	It("sets the data on every subscription", func() {
+		s1 := b.Subscribe(ctx, nil)
+		s2 := b.Subscribe(ctx, nil)
+		Expect(b.Subscriptions()).To(Equal(2))
+		go b.Run()
+
+		set(1)
+		Expect(next(s1)).To(Equal(1))
+		Expect(next(s2)).To(Equal(1))
+	})
+
+	It("drops data for a slow subscription only", func() {
+		spy := newSpyAlerter()
+		slow := b.Subscribe(ctx, spy)
+		fast := b.Subscribe(ctx, nil)
+		go b.Run()
+
+		for i := 0; i < 5; i++ {
+			set(i)
+			Expect(next(fast)).To(Equal(i))
+		}
+
+		Expect(next(slow)).To(Equal(4))
+		Expect(spy.AlertInput.Missed).To(Receive(Equal(4)))
+		Expect(slow.Stats().Drops).To(Equal(uint64(4)))
+		Expect(fast.Stats().Drops).To(BeZero())
+		Expect(b.Drops()).To(Equal(uint64(4)))
+	})
+
+	It("stops the broadcast to closed subscriptions", func() {
+		s := b.Subscribe(ctx, nil)
+		s.Close()
+		Expect(b.Subscriptions()).To(BeZero())
+		go b.Run()
+
+		set(1)
+		Consistently(func() bool {
+			_, ok := s.TryNext()
+			return ok
+		}).Should(BeFalse())
+	})
+
+	It("wakes up a reader of a subscription once it is closed", func() {
+		s := b.Subscribe(ctx, nil)
+		go b.Run()
+
+		set(1)
+		Expect(next(s)).To(Equal(1))
+
+		done := make(chan diodes.GenericDataType)
+		go func() {
+			done <- s.Next()
+		}()
+		Consistently(done).ShouldNot(Receive())
+
+		s.Close()
+		var data diodes.GenericDataType
+		Eventually(done).Should(Receive(&data))
+		Expect(data == nil).To(BeTrue())
+		Expect(s.Next() == nil).To(BeTrue())
+	})
+
+	Describe("SubscriptionStats", func() {
+		It("reports every subscriber with the one furthest behind first", func() {
+			fast := b.Subscribe(ctx, nil, diodes.WithSubscriptionName("fast"))
+			b.Subscribe(ctx, nil, diodes.WithSubscriptionName("slow"))
+			b.Subscribe(ctx, nil)
+			go b.Run()
+
+			for i := 0; i < 5; i++ {
+				set(i)
+				Expect(next(fast)).To(Equal(i))
+			}
+
+			var stats []diodes.SubscriptionStats
+			Eventually(func() uint64 {
+				stats = b.SubscriptionStats()
+				return stats[0].Lag
+			}).Should(Equal(uint64(5)))
+
+			Expect(stats).To(HaveLen(3))
+			Expect(stats[0].Name).To(Equal("slow"))
+			Expect(stats[1].Name).To(Equal("subscription-3"))
+			Expect(stats[1].Lag).To(Equal(uint64(5)))
+			Expect(stats[2].Name).To(Equal("fast"))
+			Expect(stats[2].Lag).To(BeZero())
+			Expect(stats[2].Reads).To(Equal(uint64(5)))
+		})
+
+		It("reports the drops of a lapped subscriber", func() {
+			slow := b.Subscribe(ctx, nil, diodes.WithSubscriptionName("slow"))
+			go b.Run()
+
+			for i := 0; i < 5; i++ {
+				set(i)
+			}
+			Eventually(slow.Stats).Should(HaveField("Writes", uint64(5)))
+
+			Expect(next(slow)).To(Equal(4))
+			stats := slow.SubscriptionStats()
+			Expect(stats.Name).To(Equal("slow"))
+			Expect(stats.Drops).To(Equal(uint64(4)))
+			Expect(stats.Rates).To(BeEmpty())
+		})
+
+		It("reports the throughput of every subscriber with detailed instrumentation", func() {
+			b = diodes.NewBroadcast(w, diodes.WithSubscriptionDiodeOptions(
+				diodes.WithInstrumentation(diodes.InstrumentationDetailed),
+			))
+			s := b.Subscribe(ctx, nil)
+			go b.Run()
+
+			set(1)
+			Expect(next(s)).To(Equal(1))
+
+			stats := b.SubscriptionStats()
+			Expect(stats).To(HaveLen(1))
+			Expect(stats[0].Rates).To(HaveLen(3))
+			Expect(stats[0].Rates[0].Reads).To(BeNumerically(">", 0))
+		})
+
+		It("does not report closed subscriptions", func() {
+			s := b.Subscribe(ctx, nil)
+			s.Close()
+
+			Expect(b.SubscriptionStats()).To(BeEmpty())
+		})
+	})
+
+	Describe("WithReplay", func() {
+		BeforeEach(func() {
+			b = diodes.NewBroadcast(w, diodes.WithReplay(3))
+		})
+
+		It("replays the last values to a new subscription before the live data", func() {
+			live := b.Subscribe(ctx, nil)
+			go b.Run()
+
+			for i := 0; i < 5; i++ {
+				set(i)
+				Expect(next(live)).To(Equal(i))
+			}
+
+			s := b.Subscribe(ctx, nil)
+			Expect(next(s)).To(Equal(2))
+			Expect(next(s)).To(Equal(3))
+			Expect(next(s)).To(Equal(4))
+
+			set(5)
+			Expect(next(s)).To(Equal(5))
+		})
+
+		It("replays fewer values when fewer were broadcast", func() {
+			live := b.Subscribe(ctx, nil)
+			go b.Run()
+
+			set(1)
+			Expect(next(live)).To(Equal(1))
+
+			s := b.Subscribe(ctx, nil)
+			Expect(next(s)).To(Equal(1))
+			_, ok := s.TryNext()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("drops the oldest values that a subscription has no room for", func() {
+			b = diodes.NewBroadcast(w, diodes.WithReplay(3), diodes.WithSubscriptionSize(2))
+			live := b.Subscribe(ctx, nil)
+			go b.Run()
+
+			for i := 0; i < 3; i++ {
+				set(i)
+				Expect(next(live)).To(Equal(i))
+			}
+
+			s := b.Subscribe(ctx, nil)
+			Expect(next(s)).To(Equal(2))
+			Expect(s.Stats().Drops).To(Equal(uint64(2)))
+		})
+	})
+
+	It("returns nil once the context of a subscription is done", func() {
+		subCtx, subCancel := context.WithCancel(ctx)
+		s := b.Subscribe(subCtx, nil)
+		subCancel()
+
+		Expect(s.Next() == nil).To(BeTrue())
+	})
+})
@@ -0,0 +1,103 @@
+package diodes
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Bus is an in-process event bus of named topics. Publishers set values on
+// a topic and every subscriber of the topic receives them on its own diode,
+// at its own pace, like the subscribers of a Broadcast: a slow subscriber
+// drops data instead of slowing down the publishers or the other
+// subscribers. The values are shared by all subscriptions and must not be
+// modified once they are published.
+type Bus struct {
+	opts []BroadcastOption
+
+	mu     sync.Mutex
+	topics map[string]*Broadcast
+}
+
+// NewBus returns a new Bus. The options apply to the broadcast of every
+// topic, such as WithSubscriptionSize for the diodes of the subscriptions.
+func NewBus(opts ...BroadcastOption) *Bus {
+	return &Bus{
+		opts:   opts,
+		topics: make(map[string]*Broadcast),
+	}
+}
+
+// Publish sets the data on the diode of every subscription of the topic. It
+// never blocks on the subscribers and may be invoked by several
+// go-routines. The data of a topic without subscriptions is discarded,
+// unless it is retained for WithReplay.
+func (b *Bus) Publish(topic string, data GenericDataType) {
+	b.topic(topic).publish(data)
+}
+
+// Subscribe returns a new subscription to the topic. Its Next returns nil
+// once the context is done. The alerter is invoked on the subscriber's
+// go-routine when data is dropped for it. A nil can be used to ignore
+// alerts.
+func (b *Bus) Subscribe(ctx context.Context, topic string, alerter Alerter, opts ...SubscriptionOption) *Subscription {
+	return b.topic(topic).Subscribe(ctx, alerter, opts...)
+}
+
+// Topics returns the names of the topics that were published or subscribed
+// to, in order.
+func (b *Bus) Topics() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.topics))
+	for name := range b.topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Remove removes the topic along with the values it retains for WithReplay
+// and closes its subscriptions, whose Next returns nil once they read the
+// values they received. A topic is created again when it is published or
+// subscribed to. Remove reports whether the topic existed.
+func (b *Bus) Remove(topic string) bool {
+	b.mu.Lock()
+	t, ok := b.topics[topic]
+	delete(b.topics, topic)
+	b.mu.Unlock()
+
+	if ok {
+		t.closeSubscriptions()
+	}
+	return ok
+}
+
+// Drops returns the number of values dropped by all subscriptions of the
+// topic, including the closed ones.
+func (b *Bus) Drops(topic string) uint64 {
+	b.mu.Lock()
+	t, ok := b.topics[topic]
+	b.mu.Unlock()
+
+	if !ok {
+		return 0
+	}
+	return t.Drops()
+}
+
+// topic returns the broadcast of the topic, which is created on first use.
+func (b *Bus) topic(name string) *Broadcast {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.topics[name]
+	if !ok {
+		t = NewBroadcast(nil, b.opts...)
+		b.topics[name] = t
+	}
+
+	return t
+}
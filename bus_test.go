@@ -0,0 +1,98 @@
+package diodes_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bus", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		b      *diodes.Bus
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		b = diodes.NewBus(diodes.WithSubscriptionSize(2))
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	publish := func(topic string, v int) {
+		b.Publish(topic, diodes.GenericDataType(&v))
+	}
+
+	next := func(s *diodes.Subscription) int {
+		return *(*int)(s.Next())
+	}
+
+	It("delivers the values of a topic to its subscribers", func() {
+		s1 := b.Subscribe(ctx, "a", nil)
+		s2 := b.Subscribe(ctx, "a", nil)
+		other := b.Subscribe(ctx, "b", nil)
+
+		publish("a", 1)
+		Expect(next(s1)).To(Equal(1))
+		Expect(next(s2)).To(Equal(1))
+		_, ok := other.TryNext()
+		Expect(ok).To(BeFalse())
+		Expect(b.Topics()).To(Equal([]string{"a", "b"}))
+	})
+
+	It("drops data for a slow subscriber only", func() {
+		spy := newSpyAlerter()
+		slow := b.Subscribe(ctx, "a", spy)
+		fast := b.Subscribe(ctx, "a", nil)
+
+		for i := 0; i < 5; i++ {
+			publish("a", i)
+			Expect(next(fast)).To(Equal(i))
+		}
+
+		Expect(next(slow)).To(Equal(4))
+		Expect(spy.AlertInput.Missed).To(Receive(Equal(4)))
+		Expect(b.Drops("a")).To(Equal(uint64(4)))
+	})
+
+	It("discards the values of a topic without subscribers", func() {
+		publish("a", 1)
+		s := b.Subscribe(ctx, "a", nil)
+		publish("a", 2)
+
+		Expect(next(s)).To(Equal(2))
+	})
+
+	It("stops delivering to a closed subscription", func() {
+		s := b.Subscribe(ctx, "a", nil)
+		s.Close()
+		publish("a", 1)
+
+		_, ok := s.TryNext()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("removes a topic and closes its subscriptions", func() {
+		s := b.Subscribe(ctx, "a", nil)
+		b.Subscribe(ctx, "b", nil)
+		publish("a", 1)
+
+		Expect(b.Remove("a")).To(BeTrue())
+		Expect(b.Topics()).To(Equal([]string{"b"}))
+		Expect(b.Remove("a")).To(BeFalse())
+
+		Expect(next(s)).To(Equal(1))
+		Expect(s.Next() == nil).To(BeTrue())
+
+		publish("a", 2)
+		_, ok := s.TryNext()
+		Expect(ok).To(BeFalse())
+		Expect(b.Topics()).To(Equal([]string{"a", "b"}))
+	})
+})
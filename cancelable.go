@@ -0,0 +1,65 @@
+package diodes
+
+import (
+	"context"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Cancelable wraps a diode and carries the context a value is set with along
+// with the value, so that the reader skips the values whose context was
+// canceled while they sat in the diode, such as the requests of clients that
+// went away. The skipped values are counted apart from the drops of the
+// diode. Each value is wrapped in a small allocation.
+type Cancelable struct {
+	canceled uint64
+
+	d Diode
+}
+
+// cancelableValue is the value stored in the wrapped diode.
+type cancelableValue struct {
+	ctx  context.Context
+	data GenericDataType
+}
+
+// NewCancelable returns a Cancelable that stores its values in the given
+// diode. The diode must only be written and read through the Cancelable.
+func NewCancelable(d Diode) *Cancelable {
+	return &Cancelable{d: d}
+}
+
+// Set sets the data together with ctx. The value is skipped by the reader
+// once ctx is done.
+func (c *Cancelable) Set(ctx context.Context, data GenericDataType) {
+	c.d.Set(GenericDataType(&cancelableValue{
+		ctx:  ctx,
+		data: data,
+	}))
+}
+
+// TryNext will attempt to read the next value whose context is not done
+// yet. It returns the context the value was set with, so that the reader can
+// bound its work on the value by it. If there is no data available, it will
+// return (nil, nil, false).
+func (c *Cancelable) TryNext() (context.Context, GenericDataType, bool) {
+	for {
+		data, ok := c.d.TryNext()
+		if !ok {
+			return nil, nil, false
+		}
+
+		v := (*cancelableValue)(unsafe.Pointer(data))
+		if v.ctx.Err() == nil {
+			return v.ctx, v.data, true
+		}
+
+		atomic.AddUint64(&c.canceled, 1)
+	}
+}
+
+// Canceled returns the number of values that were skipped as their context
+// was done. It is safe to call from any go-routine.
+func (c *Cancelable) Canceled() uint64 {
+	return atomic.LoadUint64(&c.canceled)
+}
@@ -0,0 +1,66 @@
+package diodes_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cancelable", func() {
+	var (
+		d *diodes.OneToOne
+		c *diodes.Cancelable
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(4, nil)
+		c = diodes.NewCancelable(d)
+	})
+
+	set := func(ctx context.Context, i int) {
+		c.Set(ctx, diodes.GenericDataType(&i))
+	}
+
+	It("returns the values along with their context", func() {
+		type key struct{}
+		ctx := context.WithValue(context.Background(), key{}, "request")
+		set(ctx, 1)
+
+		got, data, ok := c.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(1))
+		Expect(got.Value(key{})).To(Equal("request"))
+	})
+
+	It("skips the values whose context was canceled and counts them", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		set(context.Background(), 1)
+		set(ctx, 2)
+		set(ctx, 3)
+		set(context.Background(), 4)
+		cancel()
+
+		var values []int
+		for {
+			_, data, ok := c.TryNext()
+			if !ok {
+				break
+			}
+			values = append(values, *(*int)(data))
+		}
+
+		Expect(values).To(Equal([]int{1, 4}))
+		Expect(c.Canceled()).To(Equal(uint64(2)))
+		Expect(d.Stats().Drops).To(BeZero())
+	})
+
+	It("returns false when the diode is empty", func() {
+		ctx, data, ok := c.TryNext()
+		Expect(ok).To(BeFalse())
+		Expect(ctx).To(BeNil())
+		Expect(data == nil).To(BeTrue())
+	})
+})
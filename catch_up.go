@@ -0,0 +1,57 @@
+package diodes
+
+import "strconv"
+
+// CatchUpPolicy selects where a reader resumes once the writer has lapped
+// it.
+type CatchUpPolicy int
+
+const (
+	// CatchUpOldest resumes with the oldest value the diode still holds, so
+	// that the reader only loses what was overwritten. It is the default and
+	// a good fit for streams such as logs, where every value counts.
+	CatchUpOldest CatchUpPolicy = iota
+
+	// CatchUpLatest resumes with the newest value, discarding the stale
+	// backlog in one step. It is a better fit for gauges and state updates,
+	// where a reader that stalled only wants the current value.
+	CatchUpLatest
+)
+
+// String returns the name of the policy.
+func (p CatchUpPolicy) String() string {
+	switch p {
+	case CatchUpOldest:
+		return "CatchUpOldest"
+	case CatchUpLatest:
+		return "CatchUpLatest"
+	default:
+		return "CatchUpPolicy(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// WithCatchUp sets where the reader resumes once the writer has lapped it.
+// The default is CatchUpOldest. With CatchUpLatest, the values that are
+// skipped to reach the newest one are alerted as dropped along with the
+// overwritten ones.
+func WithCatchUp(p CatchUpPolicy) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.catchUp = p
+	})
+}
+
+// newest follows the entries that were written after e to the newest one.
+// It follows at most one lap of entries, so a writer that keeps writing does
+// not hold up the reader.
+func (r *ring) newest(e entry) entry {
+	for i := uint64(1); i < r.size; i++ {
+		next, ok := r.load((e.seq + 1) % r.size)
+		if !ok || !seqAfter(next.seq, e.seq) {
+			return e
+		}
+
+		e = next
+	}
+
+	return e
+}
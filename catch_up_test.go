@@ -0,0 +1,80 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("WithCatchUp", func(impl diodes.Implementation) {
+	var spy *spyAlerter
+
+	BeforeEach(func() {
+		spy = newSpyAlerter()
+	})
+
+	set := func(d diodes.Diode, from, to int) {
+		for i := from; i < to; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func(d diodes.Diode) []int {
+		var values []int
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, *(*int)(data))
+		}
+	}
+
+	It("resumes with the oldest value by default", func() {
+		d := diodes.NewOneToOne(4, spy, diodes.WithImplementation(impl))
+		set(d, 0, 10)
+
+		Expect(readAll(d)).To(Equal([]int{8, 9}))
+		Expect(d.Stats().Drops).To(Equal(uint64(8)))
+	})
+
+	It("resumes a OneToOne with the newest value", func() {
+		d := diodes.NewOneToOne(4, spy, diodes.WithImplementation(impl), diodes.WithCatchUp(diodes.CatchUpLatest))
+		set(d, 0, 10)
+
+		Expect(readAll(d)).To(Equal([]int{9}))
+		Expect(spy.AlertInput.Missed).To(Receive(Equal(9)))
+		Expect(d.Stats().Drops).To(Equal(uint64(9)))
+
+		set(d, 10, 12)
+		Expect(readAll(d)).To(Equal([]int{10, 11}))
+	})
+
+	It("resumes a ManyToOne with the newest value", func() {
+		d := diodes.NewManyToOne(4, spy, diodes.WithImplementation(impl), diodes.WithCatchUp(diodes.CatchUpLatest))
+		set(d, 0, 10)
+
+		Expect(readAll(d)).To(Equal([]int{9}))
+		Expect(d.Stats().Drops).To(Equal(uint64(9)))
+
+		set(d, 10, 12)
+		Expect(readAll(d)).To(Equal([]int{10, 11}))
+	})
+
+	It("reads every value while the reader keeps up", func() {
+		d := diodes.NewOneToOne(4, spy, diodes.WithImplementation(impl), diodes.WithCatchUp(diodes.CatchUpLatest))
+		set(d, 0, 3)
+
+		Expect(readAll(d)).To(Equal([]int{0, 1, 2}))
+		Expect(d.Stats().Drops).To(BeZero())
+	})
+})
+
+var _ = Describe("CatchUpPolicy", func() {
+	It("has a name", func() {
+		Expect(diodes.CatchUpLatest.String()).To(Equal("CatchUpLatest"))
+		Expect(diodes.CatchUpPolicy(7).String()).To(Equal("CatchUpPolicy(7)"))
+	})
+})
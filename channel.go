@@ -0,0 +1,71 @@
+package diodes
+
+import "context"
+
+// Nexter is implemented by the access layers (Poller and Waiter), whose Next
+// blocks until data is available. Next returns nil once its context is done.
+type Nexter interface {
+	Next() GenericDataType
+}
+
+// ToChannel starts a go-routine that reads from n and sends the data on the
+// returned channel. This lets code that is built around select statements
+// consume a diode.
+//
+// Data is only dropped while it is in the diode. Once the go-routine has read
+// a value it is neither overwritten nor dropped: it waits for the channel
+// (which holds up to buffer values) to be received from. While the channel
+// is full, the go-routine stops reading and the diode drops data as usual.
+//
+// The channel is closed once Next returns nil or the context is done. Any
+// value that the go-routine read but could not send is then discarded.
+func ToChannel(ctx context.Context, n Nexter, buffer int) <-chan GenericDataType {
+	c := make(chan GenericDataType, buffer)
+
+	go func() {
+		defer close(c)
+
+		for {
+			data := n.Next()
+			if data == nil {
+				return
+			}
+
+			select {
+			case c <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return c
+}
+
+// FromChannel starts a go-routine that receives from c and sets the data on
+// the diode. Placed in front of a channel based consumer, the diode drops
+// data instead of blocking the senders when the consumer falls behind. The
+// go-routine is the only writer of the diode, so a OneToOne diode suffices.
+//
+// The returned channel is closed once c is closed or the context is done.
+func FromChannel(ctx context.Context, c <-chan GenericDataType, d Diode) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for {
+			select {
+			case data, ok := <-c:
+				if !ok {
+					return
+				}
+				d.Set(data)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return done
+}
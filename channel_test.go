@@ -0,0 +1,136 @@
+package diodes_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ToChannel", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		w      *diodes.Waiter
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(4, nil), diodes.WithWaiterContext(ctx))
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("sends the data of the diode on the channel", func() {
+		c := diodes.ToChannel(ctx, w, 0)
+
+		for i := 0; i < 3; i++ {
+			v := i
+			w.Set(diodes.GenericDataType(&v))
+		}
+
+		for i := 0; i < 3; i++ {
+			var data diodes.GenericDataType
+			Eventually(c).Should(Receive(&data))
+			Expect(*(*int)(data)).To(Equal(i))
+		}
+	})
+
+	It("leaves data in the diode to be dropped while the channel is full", func() {
+		spy := newSpyAlerter()
+		d := diodes.NewOneToOne(2, spy)
+		w = diodes.NewWaiter(d, diodes.WithWaiterContext(ctx))
+		c := diodes.ToChannel(ctx, w, 1)
+
+		set := func(v int) {
+			w.Set(diodes.GenericDataType(&v))
+		}
+
+		// The first value fills the channel, the second is held by the
+		// go-routine and the rest laps the diode.
+		set(0)
+		Eventually(func() int { return len(c) }).Should(Equal(1))
+		set(1)
+		Eventually(d.Lag).Should(BeZero())
+		for i := 2; i < 6; i++ {
+			set(i)
+		}
+
+		var read []int
+		for i := 0; i < 4; i++ {
+			var data diodes.GenericDataType
+			Eventually(c).Should(Receive(&data))
+			read = append(read, *(*int)(data))
+		}
+		Expect(read).To(Equal([]int{0, 1, 4, 5}))
+		Expect(spy.AlertInput.Missed).To(Receive(Equal(2)))
+	})
+
+	It("closes the channel once the context is done", func() {
+		c := diodes.ToChannel(ctx, w, 0)
+		cancel()
+
+		Eventually(c).Should(BeClosed())
+	})
+
+	It("closes the channel once the waiter's context is done", func() {
+		wctx, wcancel := context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(4, nil), diodes.WithWaiterContext(wctx))
+		c := diodes.ToChannel(ctx, w, 0)
+		wcancel()
+
+		Eventually(c).Should(BeClosed())
+	})
+})
+
+var _ = Describe("FromChannel", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		d      *diodes.OneToOne
+		c      chan diodes.GenericDataType
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		d = diodes.NewOneToOne(2, nil)
+		c = make(chan diodes.GenericDataType)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("sets the data received from the channel on the diode", func() {
+		done := diodes.FromChannel(ctx, c, d)
+
+		// The senders are never blocked by a slow reader.
+		for i := 0; i < 5; i++ {
+			v := i
+			c <- diodes.GenericDataType(&v)
+		}
+		close(c)
+		Eventually(done).Should(BeClosed())
+
+		var read []int
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				break
+			}
+			read = append(read, *(*int)(data))
+		}
+		Expect(read).To(Equal([]int{4}))
+	})
+
+	It("stops once the context is done", func() {
+		done := diodes.FromChannel(ctx, c, d)
+		cancel()
+
+		Eventually(done).Should(BeClosed())
+	})
+})
@@ -0,0 +1,66 @@
+package diodes
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// ErrNoCipher is returned when an encrypted payload is read without a
+// Cipher.
+var ErrNoCipher = errors.New("diodes: encrypted payload without a cipher")
+
+// Cipher encrypts the payloads that are written to disk by the persistent
+// diodes, the spill files and the snapshots of SaveTo. Payloads are
+// compressed before they are encrypted.
+type Cipher interface {
+	// Seal appends the encrypted src to dst and returns the extended
+	// slice.
+	Seal(dst, src []byte) ([]byte, error)
+
+	// Open appends the decrypted src to dst and returns the extended slice.
+	// It returns an error for payloads that were not sealed with the same
+	// key or were modified.
+	Open(dst, src []byte) ([]byte, error)
+}
+
+// AEADCipher is a Cipher that uses an AEAD such as AES-GCM. Every payload is
+// sealed with a random nonce that is prepended to it. It is safe for
+// concurrent use.
+type AEADCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAEADCipher returns an AEADCipher that uses the given AEAD, e.g. the one
+// returned by cipher.NewGCM.
+func NewAEADCipher(aead cipher.AEAD) *AEADCipher {
+	return &AEADCipher{aead: aead}
+}
+
+// Seal appends the nonce and the encrypted src to dst and returns the
+// extended slice.
+func (c *AEADCipher) Seal(dst, src []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	start := len(dst)
+	for i := 0; i < n; i++ {
+		dst = append(dst, 0)
+	}
+
+	nonce := dst[start:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return dst[:start], err
+	}
+
+	return c.aead.Seal(dst, nonce, src, nil), nil
+}
+
+// Open appends the decrypted src to dst and returns the extended slice.
+func (c *AEADCipher) Open(dst, src []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(src) < n {
+		return dst, errors.New("diodes: encrypted payload is too short")
+	}
+
+	return c.aead.Open(dst, src[:n], src[n:], nil)
+}
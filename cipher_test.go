@@ -0,0 +1,72 @@
+package diodes_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AEADCipher", func() {
+	var c *diodes.AEADCipher
+
+	BeforeEach(func() {
+		c = newCipher("0123456789abcdef")
+	})
+
+	It("round trips a payload", func() {
+		sealed, err := c.Seal(nil, []byte("some-data"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(sealed)).ToNot(ContainSubstring("some-data"))
+
+		opened, err := c.Open(nil, sealed)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(opened)).To(Equal("some-data"))
+	})
+
+	It("uses a new nonce for every payload", func() {
+		a, err := c.Seal(nil, []byte("some-data"))
+		Expect(err).ToNot(HaveOccurred())
+		b, err := c.Seal(nil, []byte("some-data"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(a).ToNot(Equal(b))
+	})
+
+	It("appends to the given slice", func() {
+		sealed, err := c.Seal([]byte("prefix"), []byte("some-data"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sealed[:6]).To(Equal([]byte("prefix")))
+
+		opened, err := c.Open([]byte("prefix"), sealed[6:])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(opened)).To(Equal("prefixsome-data"))
+	})
+
+	It("fails for payloads that were modified or sealed with another key", func() {
+		sealed, err := c.Seal(nil, []byte("some-data"))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = newCipher("fedcba9876543210").Open(nil, sealed)
+		Expect(err).To(HaveOccurred())
+
+		sealed[len(sealed)-1] ^= 1
+		_, err = c.Open(nil, sealed)
+		Expect(err).To(HaveOccurred())
+
+		_, err = c.Open(nil, []byte("short"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+func newCipher(key string) *diodes.AEADCipher {
+	block, err := aes.NewCipher([]byte(key))
+	Expect(err).ToNot(HaveOccurred())
+	aead, err := cipher.NewGCM(block)
+	Expect(err).ToNot(HaveOccurred())
+
+	return diodes.NewAEADCipher(aead)
+}
@@ -0,0 +1,34 @@
+// Command diodesoak runs a long-duration stress test against a diode
+// configuration. Writers set sequenced values while a reader checks that no
+// value is read twice or out of order and that the drops reported to the
+// alerter account for the values that were lost. It prints the drop and
+// latency statistics at an interval and exits with a non-zero status on the
+// first violation. It is meant to qualify the diodes on new hardware or Go
+// releases:
+//
+//	go run ./cmd/diodesoak -diode many-to-one -writers 8 -duration 1h
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	var c config
+	flag.StringVar(&c.diode, "diode", "many-to-one", "the diode to stress: one-to-one, many-to-one or segmented")
+	flag.StringVar(&c.implementation, "implementation", "pointer-swap", "the implementation of the ring: pointer-swap or seqlock")
+	flag.IntVar(&c.size, "size", 1024, "the size of the diode")
+	flag.IntVar(&c.writers, "writers", 4, "the number of writers, one for the one-to-one and segmented diodes")
+	flag.BoolVar(&c.padded, "padded", false, "pad the slots of the ring to a cache line")
+	flag.DurationVar(&c.duration, "duration", time.Minute, "how long to run")
+	flag.DurationVar(&c.report, "report", 10*time.Second, "the interval of the statistics")
+	flag.Parse()
+
+	if err := run(c, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "diodesoak:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// config is the diode configuration to stress and for how long.
+type config struct {
+	diode          string
+	implementation string
+	size           int
+	writers        int
+	padded         bool
+	duration       time.Duration
+	report         time.Duration
+}
+
+// value is what the writers set: the writer and its sequence number.
+type value struct {
+	writer int
+	seq    uint64
+}
+
+// soak is the state of a run. The counters are written by the reader and
+// read by the reports.
+type soak struct {
+	reads  uint64
+	missed uint64
+
+	d      diodes.Diode
+	instr  func() diodes.Instrumentation
+	writes []uint64
+	last   []uint64
+	seen   []bool
+}
+
+// run stresses the configured diode for the configured duration and writes
+// the statistics to out. It returns the first violation of an invariant.
+func run(c config, out io.Writer) error {
+	s := &soak{}
+	if err := s.setup(c); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := range s.writes {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			s.write(w, stop)
+		}(w)
+	}
+
+	written := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(written)
+	}()
+
+	deadline := time.After(c.duration)
+	report := time.NewTicker(c.report)
+	defer report.Stop()
+	start := time.Now()
+
+	for {
+		select {
+		case <-deadline:
+			close(stop)
+			<-written
+			if err := s.drain(); err != nil {
+				return err
+			}
+			s.print(out, time.Since(start))
+			return s.verify(c.writers > 1)
+		case <-report.C:
+			s.print(out, time.Since(start))
+		default:
+		}
+
+		if err := s.readBatch(); err != nil {
+			return err
+		}
+	}
+}
+
+// setup creates the diode of the configuration.
+func (s *soak) setup(c config) error {
+	opts := []diodes.DiodeConfigOption{diodes.WithLatencyHistogram()}
+	switch c.implementation {
+	case "pointer-swap":
+	case "seqlock":
+		opts = append(opts, diodes.WithImplementation(diodes.Seqlock))
+	default:
+		return fmt.Errorf("unknown implementation %q", c.implementation)
+	}
+	if c.padded {
+		opts = append(opts, diodes.WithPaddedSlots())
+	}
+
+	alerter := diodes.AlertFunc(func(missed int) {
+		atomic.AddUint64(&s.missed, uint64(missed))
+	})
+
+	writers := 1
+	switch c.diode {
+	case "one-to-one":
+		d := diodes.NewOneToOne(c.size, alerter, opts...)
+		s.d, s.instr = d, d.Instrumentation
+	case "many-to-one":
+		d := diodes.NewManyToOne(c.size, alerter, opts...)
+		s.d, s.instr = d, d.Instrumentation
+		writers = c.writers
+	case "segmented":
+		segments := c.size / 64
+		if segments < 2 {
+			segments = 2
+		}
+		s.d = diodes.NewSegmented(segments, 64, alerter)
+	default:
+		return fmt.Errorf("unknown diode %q", c.diode)
+	}
+	if writers < 1 {
+		return fmt.Errorf("at least one writer is required")
+	}
+
+	s.writes = make([]uint64, writers)
+	s.last = make([]uint64, writers)
+	s.seen = make([]bool, writers)
+	return nil
+}
+
+// write sets values until stop is closed.
+func (s *soak) write(w int, stop chan struct{}) {
+	for seq := uint64(0); ; seq++ {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		s.d.Set(diodes.GenericDataType(&value{writer: w, seq: seq}))
+		atomic.StoreUint64(&s.writes[w], seq+1)
+
+		// Let the reader catch up every now and then so that the run
+		// covers reads of a full as well as of an empty diode.
+		if seq%4096 == 0 {
+			runtime.Gosched()
+		}
+	}
+}
+
+// readBatch reads and checks up to a batch of values.
+func (s *soak) readBatch() error {
+	for i := 0; i < 1024; i++ {
+		data, ok := s.d.TryNext()
+		if !ok {
+			runtime.Gosched()
+			return nil
+		}
+
+		if err := s.check(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// drain reads what is left once the writers stopped.
+func (s *soak) drain() error {
+	for {
+		data, ok := s.d.TryNext()
+		if !ok {
+			return nil
+		}
+
+		if err := s.check(data); err != nil {
+			return err
+		}
+	}
+}
+
+// check verifies that the value was written and is read after the values
+// that were read before it.
+func (s *soak) check(data diodes.GenericDataType) error {
+	if data == nil {
+		return fmt.Errorf("read a nil value")
+	}
+
+	v := *(*value)(data)
+	if v.writer < 0 || v.writer >= len(s.last) || v.seq >= atomic.LoadUint64(&s.writes[v.writer]) && !s.pending(v) {
+		return fmt.Errorf("read %d of writer %d, which was not written", v.seq, v.writer)
+	}
+	if s.seen[v.writer] && v.seq <= s.last[v.writer] {
+		return fmt.Errorf("read %d of writer %d after %d", v.seq, v.writer, s.last[v.writer])
+	}
+
+	s.seen[v.writer] = true
+	s.last[v.writer] = v.seq
+	atomic.AddUint64(&s.reads, 1)
+	return nil
+}
+
+// pending reports whether the value is the one its writer is setting, which
+// can be read before the writer counted it.
+func (s *soak) pending(v value) bool {
+	return v.seq == atomic.LoadUint64(&s.writes[v.writer])
+}
+
+// verify checks that the drops account for the values that were lost. With
+// several writers, a write that collided with another writer is reported as
+// a drop too, so there may be more drops than lost values.
+func (s *soak) verify(collisions bool) error {
+	var written uint64
+	for w := range s.writes {
+		written += atomic.LoadUint64(&s.writes[w])
+	}
+
+	lost := written - atomic.LoadUint64(&s.reads)
+	missed := atomic.LoadUint64(&s.missed)
+	switch {
+	case !collisions && missed != lost:
+		return fmt.Errorf("%d values were lost, but %d drops were reported", lost, missed)
+	case missed < lost:
+		return fmt.Errorf("%d values were lost, but only %d drops were reported", lost, missed)
+	}
+
+	return nil
+}
+
+// print writes the statistics so far.
+func (s *soak) print(out io.Writer, elapsed time.Duration) {
+	var written uint64
+	for w := range s.writes {
+		written += atomic.LoadUint64(&s.writes[w])
+	}
+	reads := atomic.LoadUint64(&s.reads)
+	missed := atomic.LoadUint64(&s.missed)
+
+	var dropRate float64
+	if written > 0 {
+		dropRate = float64(missed) / float64(written) * 100
+	}
+
+	fmt.Fprintf(out, "%s writes=%d reads=%d drops=%d (%.2f%%)",
+		elapsed.Truncate(time.Second), written, reads, missed, dropRate)
+	if s.instr != nil {
+		h := s.instr().LatencyHistogram
+		fmt.Fprintf(out, " latency p50=%s p99=%s max=%s",
+			time.Duration(h.Quantile(0.5)), time.Duration(h.Quantile(0.99)), s.instr().Latency.Max)
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("run", func() {
+	soak := func(diode, implementation string) error {
+		var out bytes.Buffer
+		err := run(config{
+			diode:          diode,
+			implementation: implementation,
+			size:           64,
+			writers:        4,
+			duration:       100 * time.Millisecond,
+			report:         time.Second,
+		}, &out)
+
+		Expect(out.String()).To(ContainSubstring("writes="))
+		return err
+	}
+
+	for _, diode := range []string{"one-to-one", "many-to-one"} {
+		for _, implementation := range []string{"pointer-swap", "seqlock"} {
+			diode, implementation := diode, implementation
+
+			It("passes the "+diode+" diode with the "+implementation+" implementation", func() {
+				Expect(soak(diode, implementation)).To(Succeed())
+			})
+		}
+	}
+
+	It("passes the segmented diode", func() {
+		Expect(soak("segmented", "pointer-swap")).To(Succeed())
+	})
+
+	It("rejects an unknown diode", func() {
+		err := run(config{diode: "some-diode", implementation: "pointer-swap"}, &bytes.Buffer{})
+		Expect(err).To(MatchError(ContainSubstring("unknown diode")))
+	})
+})
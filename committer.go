@@ -0,0 +1,83 @@
+package diodes
+
+import "sync/atomic"
+
+// Committer wraps a diode with a two-phase read: Peek returns the next
+// value, and the value is only consumed once the reader commits it. Until
+// then, every Peek returns the same value again, so that a value is not lost
+// when its processing fails or the worker is restarted in the middle of it.
+// The value a reader peeked at is held by the Committer rather than the
+// diode, so the writers cannot overwrite it while it is processed.
+//
+// Like the diodes, a Committer is meant to be read by a single go-routine
+// at a time, such as the worker of a Supervisor and its restarts.
+type Committer struct {
+	committed   uint64
+	redelivered uint64
+
+	Diode
+	pending GenericDataType
+}
+
+// NewCommitter returns a new Committer that wraps the given diode.
+func NewCommitter(d Diode) *Committer {
+	return &Committer{Diode: d}
+}
+
+// Peek returns the value that was peeked at but not committed yet, or else
+// the next value of the wrapped diode. If there is no data available, it
+// will return (nil, false).
+func (c *Committer) Peek() (GenericDataType, bool) {
+	if c.pending != nil {
+		atomic.AddUint64(&c.redelivered, 1)
+		return c.pending, true
+	}
+
+	data, ok := c.Diode.TryNext()
+	if !ok {
+		return nil, false
+	}
+
+	c.pending = data
+	return data, true
+}
+
+// Commit consumes the value returned by Peek. It does nothing when there is
+// no value to commit.
+func (c *Committer) Commit() {
+	if c.pending == nil {
+		return
+	}
+
+	c.pending = nil
+	atomic.AddUint64(&c.committed, 1)
+}
+
+// TryNext returns the next value and commits it right away, which makes a
+// Committer a Diode that can be read like any other.
+func (c *Committer) TryNext() (GenericDataType, bool) {
+	data, ok := c.Peek()
+	if ok {
+		c.Commit()
+	}
+
+	return data, ok
+}
+
+// Pending reports whether there is a value that was peeked at but not
+// committed.
+func (c *Committer) Pending() bool {
+	return c.pending != nil
+}
+
+// Committed returns the number of values that were committed. It is safe to
+// call from any go-routine.
+func (c *Committer) Committed() uint64 {
+	return atomic.LoadUint64(&c.committed)
+}
+
+// Redelivered returns the number of times Peek returned a value again as it
+// was not committed. It is safe to call from any go-routine.
+func (c *Committer) Redelivered() uint64 {
+	return atomic.LoadUint64(&c.redelivered)
+}
@@ -0,0 +1,80 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Committer", func() {
+	var c *diodes.Committer
+
+	BeforeEach(func() {
+		c = diodes.NewCommitter(diodes.NewOneToOne(4, nil))
+	})
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			c.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	peek := func() int {
+		data, ok := c.Peek()
+		Expect(ok).To(BeTrue())
+		return *(*int)(data)
+	}
+
+	It("returns the same value until it is committed", func() {
+		set(1, 2)
+
+		Expect(peek()).To(Equal(1))
+		Expect(c.Pending()).To(BeTrue())
+		Expect(peek()).To(Equal(1))
+		Expect(c.Redelivered()).To(Equal(uint64(1)))
+
+		c.Commit()
+		Expect(c.Pending()).To(BeFalse())
+		Expect(peek()).To(Equal(2))
+		c.Commit()
+
+		_, ok := c.Peek()
+		Expect(ok).To(BeFalse())
+		Expect(c.Committed()).To(Equal(uint64(2)))
+	})
+
+	It("keeps the pending value while the writer laps the diode", func() {
+		set(1)
+		Expect(peek()).To(Equal(1))
+
+		set(2, 3, 4, 5, 6, 7)
+		Expect(peek()).To(Equal(1))
+		c.Commit()
+		Expect(peek()).To(Equal(6))
+	})
+
+	It("ignores a commit without a pending value", func() {
+		c.Commit()
+		Expect(c.Committed()).To(BeZero())
+
+		set(1)
+		Expect(peek()).To(Equal(1))
+	})
+
+	It("commits the values that are read with TryNext", func() {
+		set(1, 2)
+		Expect(peek()).To(Equal(1))
+
+		data, ok := c.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(1))
+
+		data, ok = c.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(2))
+		Expect(c.Pending()).To(BeFalse())
+		Expect(c.Committed()).To(Equal(uint64(2)))
+	})
+})
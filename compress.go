@@ -0,0 +1,80 @@
+package diodes
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrNoCompressor is returned when a compressed payload is read without a
+// Compressor.
+var ErrNoCompressor = errors.New("diodes: compressed payload without a compressor")
+
+// Compressor compresses the payloads that are written to disk by the
+// persistent diodes, the spill files and the snapshots of SaveTo. Adapters
+// for snappy or zstd satisfy it in a few lines.
+type Compressor interface {
+	// Compress appends the compressed src to dst and returns the extended
+	// slice.
+	Compress(dst, src []byte) ([]byte, error)
+
+	// Decompress appends the decompressed src to dst and returns the
+	// extended slice.
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// FlateCompressor is a Compressor that uses DEFLATE from compress/flate. It
+// is safe for concurrent use.
+type FlateCompressor struct {
+	level   int
+	writers sync.Pool
+}
+
+// NewFlateCompressor returns a FlateCompressor with the given compression
+// level, such as flate.BestSpeed.
+func NewFlateCompressor(level int) (*FlateCompressor, error) {
+	if _, err := flate.NewWriter(io.Discard, level); err != nil {
+		return nil, err
+	}
+
+	return &FlateCompressor{level: level}, nil
+}
+
+// Compress appends the compressed src to dst and returns the extended slice.
+func (c *FlateCompressor) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+
+	w, ok := c.writers.Get().(*flate.Writer)
+	if ok {
+		w.Reset(buf)
+	} else {
+		// The level was validated by NewFlateCompressor.
+		w, _ = flate.NewWriter(buf, c.level)
+	}
+	defer c.writers.Put(w)
+
+	if _, err := w.Write(src); err != nil {
+		return dst, err
+	}
+	if err := w.Close(); err != nil {
+		return dst, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decompress appends the decompressed src to dst and returns the extended
+// slice.
+func (c *FlateCompressor) Decompress(dst, src []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+
+	buf := bytes.NewBuffer(dst)
+	if _, err := io.Copy(buf, r); err != nil {
+		return dst, err
+	}
+
+	return buf.Bytes(), nil
+}
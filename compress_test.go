@@ -0,0 +1,53 @@
+package diodes_test
+
+import (
+	"bytes"
+	"compress/flate"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FlateCompressor", func() {
+	It("round trips a payload", func() {
+		c, err := diodes.NewFlateCompressor(flate.BestSpeed)
+		Expect(err).ToNot(HaveOccurred())
+
+		payload := bytes.Repeat([]byte("some-log-line "), 100)
+		compressed, err := c.Compress(nil, payload)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(compressed)).To(BeNumerically("<", len(payload)/5))
+
+		decompressed, err := c.Decompress(nil, compressed)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decompressed).To(Equal(payload))
+	})
+
+	It("appends to the given slice", func() {
+		c, err := diodes.NewFlateCompressor(flate.DefaultCompression)
+		Expect(err).ToNot(HaveOccurred())
+
+		compressed, err := c.Compress([]byte("prefix"), []byte("some-data"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(compressed[:6]).To(Equal([]byte("prefix")))
+
+		decompressed, err := c.Decompress([]byte("prefix"), compressed[6:])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(decompressed)).To(Equal("prefixsome-data"))
+	})
+
+	It("fails for data that is not compressed", func() {
+		c, err := diodes.NewFlateCompressor(flate.BestSpeed)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = c.Decompress(nil, []byte{0xff, 0xff, 0xff})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("refuses an invalid level", func() {
+		_, err := diodes.NewFlateCompressor(42)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,161 @@
+package diodes
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Implementation selects how a diode stores its data.
+type Implementation int
+
+const (
+	// PointerSwap stores every value in a newly allocated bucket that the
+	// writer atomically swaps into the ring and the reader atomically swaps
+	// out of it. It is the default and a good fit for queue-like workloads
+	// where the reader consumes most of what is written.
+	PointerSwap Implementation = iota
+
+	// Seqlock writes values in place into slots guarded by a sequence lock.
+	// Writes do not allocate and reads never write to the ring, which avoids
+	// bouncing cache lines between the reader and the writer. It is a better
+	// fit for read-mostly "latest value" workloads where the writer
+	// frequently laps the reader. Consumed values stay referenced by the ring
+	// until they are overwritten.
+	Seqlock
+)
+
+// String returns the name of the implementation.
+func (i Implementation) String() string {
+	switch i {
+	case PointerSwap:
+		return "PointerSwap"
+	case Seqlock:
+		return "Seqlock"
+	default:
+		return "Implementation(" + strconv.Itoa(int(i)) + ")"
+	}
+}
+
+// DiodeConfigOption can be used to setup a OneToOne or ManyToOne diode.
+type DiodeConfigOption func(*diodeConfig)
+
+type diodeConfig struct {
+	implementation  Implementation
+	paddedSlots     bool
+	instrumentation InstrumentationLevel
+	rateWindows     []time.Duration
+	latencies       bool
+	batchSizes      bool
+	traceRegions    bool
+	metricsHook     MetricsHook
+	hooks           *hooks
+	ctx             context.Context
+	idle            *idle
+	misuse          bool
+	leak            *leakHook
+	recorder        *Recorder
+	catchUp         CatchUpPolicy
+	strictOrder     bool
+	start           uint64
+	panics          func(*CallbackPanic)
+}
+
+// WithImplementation sets how the diode stores its data. The default is
+// PointerSwap.
+func WithImplementation(i Implementation) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.implementation = i
+	})
+}
+
+// WithPaddedSlots spaces the slots of the ring buffer at least a cache line
+// apart. For small diodes where adjacent slots are written and read by
+// different cores in quick succession this avoids false sharing at the cost
+// of using a cache line of memory per slot. When used with a caller provided
+// buffer, the size of the diode is reduced accordingly.
+func WithPaddedSlots() DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.paddedSlots = true
+	})
+}
+
+// WithInstrumentation sets the instrumentation level of the diode. The
+// default is InstrumentationOff.
+func WithInstrumentation(level InstrumentationLevel) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.instrumentation = level
+	})
+}
+
+// WithRateWindows sets the rolling windows over which the throughput of the
+// diode is averaged. The rates are only tracked with InstrumentationDetailed.
+// The default windows are 1s, 10s and 60s.
+func WithRateWindows(windows ...time.Duration) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.rateWindows = windows
+	})
+}
+
+// WithLatencyHistogram records the time between writing and reading data in
+// a histogram. As this requires timestamping every write, it implies
+// InstrumentationDetailed.
+func WithLatencyHistogram() DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.latencies = true
+	})
+}
+
+// WithBatchSizeHistogram records the number of items returned by each batch
+// read in a histogram. It only applies to diodes with batch reads.
+func WithBatchSizeHistogram() DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.batchSizes = true
+	})
+}
+
+// WithTraceRegions annotates Set, TryNext, batch reads and alerts with
+// runtime/trace regions so that execution traces show the activity of the
+// diode. The regions cost little while no trace is being recorded, but are
+// off by default.
+func WithTraceRegions() DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.traceRegions = true
+	})
+}
+
+// WithMetricsHook reports the drops, latencies and lag of the diode to the
+// given hook. As this requires timestamping every write, it implies
+// InstrumentationDetailed.
+func WithMetricsHook(h MetricsHook) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.metricsHook = h
+	})
+}
+
+// WithContext binds the diode to the context. Once the context is done, the
+// diode is closed as by Close, which runs the OnStop hook, and its Waiters
+// wake up to return nil once it is drained. This makes the diode end with the
+// lifecycle it belongs to, such as the context of an errgroup. It applies to
+// the OneToOne and ManyToOne diodes.
+func WithContext(ctx context.Context) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.ctx = ctx
+	})
+}
+
+func newDiodeConfig(opts []DiodeConfigOption) diodeConfig {
+	// Avoid allocating the config when there aren't any options so that a
+	// diode with the defaults is a single allocation.
+	if len(opts) == 0 {
+		return diodeConfig{}
+	}
+
+	c := new(diodeConfig)
+	for _, o := range opts {
+		o(c)
+	}
+	c.recoverCallbacks()
+
+	return *c
+}
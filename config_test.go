@@ -0,0 +1,132 @@
+package diodes_test
+
+import (
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Implementation", func() {
+	It("has a name", func() {
+		Expect(diodes.PointerSwap.String()).To(Equal("PointerSwap"))
+		Expect(diodes.Seqlock.String()).To(Equal("Seqlock"))
+		Expect(diodes.Implementation(7).String()).To(Equal("Implementation(7)"))
+	})
+})
+
+var _ = forEachImplementation("WithPaddedSlots()", func(impl diodes.Implementation) {
+	It("reads and writes like an unpadded diode", func() {
+		spy := newSpyAlerter()
+		d := diodes.NewOneToOne(4, spy, diodes.WithImplementation(impl), diodes.WithPaddedSlots())
+		for i := 0; i < 6; i++ {
+			j := i
+			d.Set(diodes.GenericDataType(&j))
+		}
+
+		var read []int
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				break
+			}
+			read = append(read, *(*int)(data))
+		}
+
+		Expect(read).To(Equal([]int{4, 5}))
+		Expect(spy.AlertInput.Missed).To(Receive(Equal(4)))
+	})
+
+	It("works with many writers", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl), diodes.WithPaddedSlots())
+		for i := 0; i < 3; i++ {
+			j := i
+			d.Set(diodes.GenericDataType(&j))
+		}
+
+		for i := 0; i < 3; i++ {
+			data, ok := d.TryNext()
+			Expect(ok).To(BeTrue())
+			Expect(*(*int)(data)).To(Equal(i))
+		}
+	})
+})
+
+var _ = Describe("WithPaddedSlots() and a caller provided buffer", func() {
+	It("spaces the slots a cache line apart", func() {
+		stride := 64 / int(unsafe.Sizeof(unsafe.Pointer(nil)))
+		buffer := make([]unsafe.Pointer, 2*stride)
+		d := diodes.NewOneToOneWithBuffer(buffer, nil, diodes.WithPaddedSlots())
+
+		data := []byte("some-data")
+		d.Set(diodes.GenericDataType(&data))
+		d.Set(diodes.GenericDataType(&data))
+		Expect(buffer[0] != nil).To(BeTrue())
+		Expect(buffer[1] == nil).To(BeTrue())
+		Expect(buffer[stride] != nil).To(BeTrue())
+
+		By("having a size of two")
+		d.Set(diodes.GenericDataType(&data))
+		Expect(buffer[1] == nil).To(BeTrue())
+	})
+
+	It("panics when the buffer is shorter than a padded slot", func() {
+		stride := 64 / int(unsafe.Sizeof(unsafe.Pointer(nil)))
+		buffer := make([]unsafe.Pointer, stride-1)
+
+		Expect(func() {
+			diodes.NewOneToOneWithBuffer(buffer, nil, diodes.WithPaddedSlots())
+		}).To(Panic())
+		Expect(func() {
+			diodes.NewManyToOneWithBuffer(buffer, nil, diodes.WithPaddedSlots())
+		}).To(Panic())
+
+		Expect(func() {
+			diodes.NewOneToOneWithBuffer(make([]unsafe.Pointer, stride), nil, diodes.WithPaddedSlots())
+		}).ToNot(Panic())
+	})
+})
+
+var _ = forEachImplementation("64-bit alignment", func(impl diodes.Implementation) {
+	// Unaligned 64-bit atomic operations panic on 32-bit platforms. Run the
+	// package's tests with GOARCH=386 to exercise this.
+	It("does not panic for any combination of options", func() {
+		data := []byte("some-data")
+		for size := 1; size <= 9; size++ {
+			for _, opts := range [][]diodes.DiodeConfigOption{
+				{diodes.WithImplementation(impl)},
+				{diodes.WithImplementation(impl), diodes.WithPaddedSlots()},
+			} {
+				o := diodes.NewOneToOne(size, nil, opts...)
+				m := diodes.NewManyToOne(size, nil, opts...)
+				Expect(func() {
+					for i := 0; i < 2*size; i++ {
+						o.Set(diodes.GenericDataType(&data))
+						m.Set(diodes.GenericDataType(&data))
+						o.TryNext()
+						m.TryNext()
+					}
+				}).ToNot(Panic())
+			}
+		}
+	})
+})
+
+var implementations = []diodes.Implementation{
+	diodes.PointerSwap,
+	diodes.Seqlock,
+}
+
+// forEachImplementation describes the given specs once per diode
+// implementation.
+func forEachImplementation(text string, body func(impl diodes.Implementation)) bool {
+	for _, impl := range implementations {
+		impl := impl
+		Describe(text+" ("+impl.String()+")", func() {
+			body(impl)
+		})
+	}
+	return true
+}
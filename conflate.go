@@ -0,0 +1,72 @@
+package diodes
+
+import "sync/atomic"
+
+// Conflate wraps a diode so that the reader always receives the most recent
+// value. The values that were set in between two reads are conflated: only
+// the last of them is read and the others are counted. It suits consumers of
+// state, such as a UI or a gauge, that only care about the current value.
+//
+// Like the diodes, a Conflate is meant to be read by a single go-routine.
+type Conflate struct {
+	conflated uint64
+
+	Diode
+}
+
+// NewConflate returns a new Conflate that wraps the given diode.
+func NewConflate(d Diode) *Conflate {
+	return &Conflate{Diode: d}
+}
+
+// TryNext returns the most recent value of the wrapped diode and discards
+// the values before it. It reads at most as many values as the diode held
+// when it was invoked, so that a writer that keeps pace with the reader
+// cannot keep it from returning; the values that were set since are left
+// for the next read. If there is no data available, it will return
+// (nil, false).
+func (c *Conflate) TryNext() (GenericDataType, bool) {
+	limit := drainLimit(c.Diode)
+	data, ok := c.Diode.TryNext()
+	if !ok {
+		return nil, false
+	}
+
+	for i := uint64(1); i < limit; i++ {
+		next, ok := c.Diode.TryNext()
+		if !ok {
+			break
+		}
+
+		atomic.AddUint64(&c.conflated, 1)
+		data = next
+	}
+
+	return data, true
+}
+
+// maxDrain bounds the values drainLimit allows for a diode that does not
+// report its lag.
+const maxDrain = 1024
+
+// drainLimit returns how many values a wrapper that drains the diode reads
+// at most in one go. It is the lag of the diode, if it reports it, and
+// maxDrain otherwise. It is at least 1.
+func drainLimit(d Diode) uint64 {
+	r, ok := d.(LagReporter)
+	if !ok {
+		return maxDrain
+	}
+
+	if lag := r.Lag(); lag > 1 {
+		return lag
+	}
+
+	return 1
+}
+
+// Conflated returns the number of values that were discarded for a more
+// recent one. It is safe to call from any go-routine.
+func (c *Conflate) Conflated() uint64 {
+	return atomic.LoadUint64(&c.conflated)
+}
@@ -0,0 +1,81 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conflate", func() {
+	var c *diodes.Conflate
+
+	BeforeEach(func() {
+		c = diodes.NewConflate(diodes.NewOneToOne(16, nil))
+	})
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			c.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("returns the most recent value", func() {
+		set(1, 2, 3)
+
+		data, ok := c.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(3))
+		Expect(c.Conflated()).To(Equal(uint64(2)))
+
+		_, ok = c.TryNext()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("does not conflate values that are read one by one", func() {
+		set(1)
+		data, _ := c.TryNext()
+		Expect(*(*int)(data)).To(Equal(1))
+
+		set(2)
+		data, _ = c.TryNext()
+		Expect(*(*int)(data)).To(Equal(2))
+		Expect(c.Conflated()).To(BeZero())
+	})
+
+	It("returns while a writer keeps pace with the reader", func() {
+		d := &endlessDiode{lag: 3}
+		c = diodes.NewConflate(d)
+
+		data, ok := c.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(3))
+		Expect(c.Conflated()).To(Equal(uint64(2)))
+
+		By("bounding the reads of a diode that does not report its lag")
+		c = diodes.NewConflate(struct{ diodes.Diode }{&endlessDiode{}})
+		data, ok = c.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(1024))
+	})
+})
+
+// endlessDiode always has another value, as if a writer set a value for
+// every read. The values count the reads.
+type endlessDiode struct {
+	lag   uint64
+	reads int
+}
+
+func (d *endlessDiode) Set(diodes.GenericDataType) {}
+
+func (d *endlessDiode) TryNext() (diodes.GenericDataType, bool) {
+	d.reads++
+	v := d.reads
+	return diodes.GenericDataType(&v), true
+}
+
+func (d *endlessDiode) Lag() uint64 {
+	return d.lag
+}
@@ -0,0 +1,148 @@
+package diodes
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Conn is a net.Conn whose writes are buffered by a diode and written to the
+// wrapped connection by a dedicated flusher go-routine. Write never blocks:
+// a slow or dead peer makes the diode drop payloads instead of stalling the
+// application, so the memory used by the buffer is bounded. Reads go to the
+// wrapped connection directly.
+type Conn struct {
+	net.Conn
+
+	d            *ManyToOne
+	w            *Waiter
+	writer       *Writer
+	writeTimeout time.Duration
+	size         int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+	closed int32
+
+	mu  sync.Mutex
+	err error
+}
+
+// ConnOption can be used to setup the connection.
+type ConnOption func(*Conn)
+
+// WithConnSize sets the number of payloads the connection buffers. The
+// default is 1024.
+func WithConnSize(size int) ConnOption {
+	return ConnOption(func(c *Conn) {
+		c.size = size
+	})
+}
+
+// WithConnWriteTimeout sets the write deadline of each write of the flusher.
+// A peer that does not read for that long fails the connection. By default
+// there is no deadline.
+func WithConnWriteTimeout(d time.Duration) ConnOption {
+	return ConnOption(func(c *Conn) {
+		c.writeTimeout = d
+	})
+}
+
+// NewConn wraps the given connection and starts the flusher. The connection
+// may be written by several go-routines.
+func NewConn(conn net.Conn, opts ...ConnOption) *Conn {
+	c := &Conn{
+		Conn: conn,
+		size: 1024,
+		done: make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(context.Background())
+	c.d = NewManyToOne(c.size, nil)
+	c.w = NewWaiter(c.d, WithWaiterContext(ctx))
+	c.writer = NewWriter(c.w)
+
+	go c.flush()
+
+	return c
+}
+
+// Write copies p into the buffer and returns immediately. It returns the
+// error of the flusher once a write to the wrapped connection failed, and
+// net.ErrClosed once the connection is closed.
+func (c *Conn) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&c.closed) != 0 {
+		return 0, net.ErrClosed
+	}
+
+	if err := c.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.writer.Write(p)
+}
+
+// Close stops accepting writes, waits for the flusher to write the buffered
+// payloads and closes the wrapped connection. With a dead peer, only
+// WithConnWriteTimeout bounds the wait.
+func (c *Conn) Close() error {
+	c.once.Do(func() {
+		atomic.StoreInt32(&c.closed, 1)
+		c.cancel()
+	})
+	<-c.done
+
+	return c.Conn.Close()
+}
+
+// Err returns the error of the first write to the wrapped connection that
+// failed, if any.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// Stats returns a snapshot of the statistics of the buffer. The payloads
+// that were not written because of a failed write are counted as reads. It
+// is safe to call from any go-routine.
+func (c *Conn) Stats() Stats {
+	return c.d.Stats()
+}
+
+// flush writes the buffered payloads until the connection is closed and the
+// buffer is drained. Once a write failed, the buffer is still drained but
+// nothing is written.
+func (c *Conn) flush() {
+	defer close(c.done)
+
+	for {
+		data := c.w.Next()
+		if data == nil {
+			return
+		}
+
+		if c.Err() != nil {
+			continue
+		}
+
+		if c.writeTimeout > 0 {
+			c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+		}
+
+		if _, err := c.Conn.Write(*(*[]byte)(data)); err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package diodes_test
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conn", func() {
+	var (
+		client net.Conn
+		peer   net.Conn
+	)
+
+	BeforeEach(func() {
+		client, peer = net.Pipe()
+	})
+
+	AfterEach(func() {
+		peer.Close()
+	})
+
+	It("writes the payloads to the wrapped connection", func() {
+		c := diodes.NewConn(client)
+		defer c.Close()
+
+		c.Write([]byte("some-"))
+		c.Write([]byte("data"))
+
+		buf := make([]byte, 9)
+		_, err := io.ReadFull(peer, buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(buf)).To(Equal("some-data"))
+	})
+
+	It("drops payloads instead of blocking on a slow peer", func() {
+		c := diodes.NewConn(client, diodes.WithConnSize(4))
+		defer c.Close()
+
+		for i := 0; i < 20; i++ {
+			n, err := c.Write([]byte("x"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(1))
+		}
+
+		buf := make([]byte, 1)
+		Eventually(func() uint64 {
+			peer.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+			peer.Read(buf)
+			return c.Stats().Drops
+		}).Should(BeNumerically(">", 0))
+		Expect(c.Stats().Writes).To(Equal(uint64(20)))
+
+		// Fail the pending writes so that Close does not wait for them.
+		peer.Close()
+	})
+
+	It("fails the writes once the peer did not read in time", func() {
+		c := diodes.NewConn(client, diodes.WithConnWriteTimeout(10*time.Millisecond))
+		defer c.Close()
+
+		_, err := c.Write([]byte("some-data"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(c.Err).Should(HaveOccurred())
+		_, err = c.Write([]byte("some-data"))
+		Expect(err).To(Equal(c.Err()))
+	})
+
+	It("writes the buffered payloads before it closes", func() {
+		c := diodes.NewConn(client)
+		c.Write([]byte("some-data"))
+
+		read := make(chan []byte)
+		go func() {
+			defer GinkgoRecover()
+			b, err := io.ReadAll(peer)
+			Expect(err).ToNot(HaveOccurred())
+			read <- b
+		}()
+
+		Expect(c.Close()).To(Succeed())
+		Eventually(read).Should(Receive(Equal([]byte("some-data"))))
+
+		_, err := c.Write([]byte("more-data"))
+		Expect(err).To(MatchError(net.ErrClosed))
+	})
+})
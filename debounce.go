@@ -0,0 +1,69 @@
+package diodes
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Debounce wraps a diode and only releases a value to the reader once no
+// other value followed it for a quiet period. Of a burst of values, such as
+// the events of a configuration change or of a file system, only the last
+// is read and the others are counted as suppressed.
+//
+// The quiet period is measured from when the reader sees a value, so the
+// reader should poll more often than the quiet period, such as with a
+// Poller. Like the diodes, a Debounce is meant to be read by a single
+// go-routine.
+type Debounce struct {
+	suppressed uint64
+
+	Diode
+	quiet   time.Duration
+	pending GenericDataType
+	seen    time.Time
+}
+
+// NewDebounce returns a new Debounce that wraps the given diode and
+// releases a value once the diode was quiet for the given period.
+func NewDebounce(d Diode, quiet time.Duration) *Debounce {
+	return &Debounce{
+		Diode: d,
+		quiet: quiet,
+	}
+}
+
+// TryNext returns the last value of a burst once the quiet period has
+// passed since it was seen. Otherwise it will return (nil, false). Like
+// Conflate, it reads at most as many values as the diode held when it was
+// invoked, so that a steady writer cannot keep it from returning.
+func (d *Debounce) TryNext() (GenericDataType, bool) {
+	now := time.Now()
+	limit := drainLimit(d.Diode)
+	for i := uint64(0); i < limit; i++ {
+		data, ok := d.Diode.TryNext()
+		if !ok {
+			break
+		}
+
+		if d.pending != nil {
+			atomic.AddUint64(&d.suppressed, 1)
+		}
+		d.pending = data
+		d.seen = now
+	}
+
+	if d.pending == nil || now.Sub(d.seen) < d.quiet {
+		return nil, false
+	}
+
+	data := d.pending
+	d.pending = nil
+	return data, true
+}
+
+// Suppressed returns the number of values that were followed by another
+// value within the quiet period and were not released. It is safe to call
+// from any go-routine.
+func (d *Debounce) Suppressed() uint64 {
+	return atomic.LoadUint64(&d.suppressed)
+}
@@ -0,0 +1,65 @@
+package diodes_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Debounce", func() {
+	var (
+		d  *diodes.OneToOne
+		db *diodes.Debounce
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(16, nil)
+		db = diodes.NewDebounce(d, 20*time.Millisecond)
+	})
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			db.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("releases the last value of a burst once it was quiet", func() {
+		set(1, 2)
+		_, ok := db.TryNext()
+		Expect(ok).To(BeFalse())
+		set(3)
+
+		var data diodes.GenericDataType
+		Eventually(func() bool {
+			data, ok = db.TryNext()
+			return ok
+		}).Should(BeTrue())
+		Expect(*(*int)(data)).To(Equal(3))
+		Expect(db.Suppressed()).To(Equal(uint64(2)))
+
+		_, ok = db.TryNext()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("releases a single value after the quiet period", func() {
+		set(1)
+		start := time.Now()
+
+		p := diodes.NewPoller(db, diodes.WithPollingInterval(time.Millisecond))
+		Expect(*(*int)(p.Next())).To(Equal(1))
+		Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+		Expect(db.Suppressed()).To(BeZero())
+	})
+
+	It("returns while a writer keeps pace with the reader", func() {
+		db = diodes.NewDebounce(&endlessDiode{lag: 3}, 20*time.Millisecond)
+
+		_, ok := db.TryNext()
+		Expect(ok).To(BeFalse())
+		Expect(db.Suppressed()).To(Equal(uint64(2)))
+	})
+})
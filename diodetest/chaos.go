@@ -0,0 +1,151 @@
+package diodetest
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Chaos wraps a diode and injects faults at specific sequence numbers, so
+// that consumers can be verified under loss without generating real
+// overload. The writes and the reads are numbered from 0 in the order they
+// happen: a write by every Set and a read by every TryNext that returns a
+// value. It is meant for tests only.
+type Chaos struct {
+	diodes.Diode
+	alerter diodes.Alerter
+
+	mu          sync.Mutex
+	writes      uint64
+	reads       uint64
+	setDelays   map[uint64]time.Duration
+	readDelays  map[uint64]time.Duration
+	overwrites  map[uint64]bool
+	failAlerts  map[uint64]bool
+	overwritten int
+}
+
+// NewChaos returns a new Chaos that wraps the diode returned by newDiode.
+// newDiode is invoked with the alerter the diode must report its drops to,
+// which forwards them to the given alerter unless the alert is failed. A
+// nil can be used to ignore alerts.
+func NewChaos(newDiode func(diodes.Alerter) diodes.Diode, alerter diodes.Alerter) *Chaos {
+	if alerter == nil {
+		alerter = diodes.AlertFunc(func(int) {})
+	}
+
+	c := &Chaos{
+		alerter:    alerter,
+		setDelays:  make(map[uint64]time.Duration),
+		readDelays: make(map[uint64]time.Duration),
+		overwrites: make(map[uint64]bool),
+		failAlerts: make(map[uint64]bool),
+	}
+	c.Diode = newDiode(diodes.AlertFunc(c.alert))
+
+	return c
+}
+
+// DelaySet delays the write with the given sequence number, such as to
+// widen a race between writers.
+func (c *Chaos) DelaySet(seq uint64, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setDelays[seq] = d
+}
+
+// DelayRead delays the read with the given sequence number, such as to let
+// the writers lap the reader.
+func (c *Chaos) DelayRead(seq uint64, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDelays[seq] = d
+}
+
+// Overwrite drops the write with the given sequence number as if the writer
+// had overwritten it. The drop is reported with the next read.
+func (c *Chaos) Overwrite(seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.overwrites[seq] = true
+}
+
+// FailAlert swallows the alerts that are raised by the read with the given
+// sequence number, as if the alert got lost.
+func (c *Chaos) FailAlert(seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failAlerts[seq] = true
+}
+
+// Set sets the data on the wrapped diode, unless the write is overwritten.
+func (c *Chaos) Set(data diodes.GenericDataType) {
+	c.mu.Lock()
+	seq := c.writes
+	c.writes++
+	delay := c.setDelays[seq]
+	overwrite := c.overwrites[seq]
+	if overwrite {
+		c.overwritten++
+	}
+	c.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if overwrite {
+		return
+	}
+
+	c.Diode.Set(data)
+}
+
+// TryNext returns the next value of the wrapped diode and reports the
+// overwritten writes since the previous read.
+func (c *Chaos) TryNext() (diodes.GenericDataType, bool) {
+	// A read is only delayed once, even if it finds the diode empty.
+	c.mu.Lock()
+	delay := c.readDelays[c.reads]
+	delete(c.readDelays, c.reads)
+	c.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	data, ok := c.Diode.TryNext()
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	missed := c.overwritten
+	c.overwritten = 0
+	c.mu.Unlock()
+
+	if missed > 0 {
+		c.alert(missed)
+	}
+
+	c.mu.Lock()
+	c.reads++
+	c.mu.Unlock()
+
+	return data, true
+}
+
+// alert forwards the alert, unless it is failed.
+func (c *Chaos) alert(missed int) {
+	c.mu.Lock()
+	failed := c.failAlerts[c.reads]
+	c.mu.Unlock()
+
+	if !failed {
+		c.alerter.Alert(missed)
+	}
+}
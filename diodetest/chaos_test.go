@@ -0,0 +1,86 @@
+package diodetest_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/diodetest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chaos", func() {
+	var (
+		c      *diodetest.Chaos
+		missed []int
+	)
+
+	BeforeEach(func() {
+		missed = nil
+		c = diodetest.NewChaos(func(a diodes.Alerter) diodes.Diode {
+			return diodes.NewOneToOne(4, a)
+		}, diodes.AlertFunc(func(n int) {
+			missed = append(missed, n)
+		}))
+	})
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			c.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func() []int {
+		var out []int
+		for {
+			data, ok := c.TryNext()
+			if !ok {
+				return out
+			}
+			out = append(out, *(*int)(data))
+		}
+	}
+
+	It("passes the values through", func() {
+		set(1, 2, 3)
+
+		Expect(readAll()).To(Equal([]int{1, 2, 3}))
+		Expect(missed).To(BeEmpty())
+	})
+
+	It("overwrites the given writes", func() {
+		c.Overwrite(1)
+		c.Overwrite(2)
+		set(1, 2, 3, 4)
+
+		Expect(readAll()).To(Equal([]int{1, 4}))
+		Expect(missed).To(Equal([]int{2}))
+	})
+
+	It("forwards the alerts of the wrapped diode", func() {
+		set(1, 2, 3, 4, 5, 6)
+
+		Expect(readAll()).To(Equal([]int{5, 6}))
+		Expect(missed).To(Equal([]int{4}))
+	})
+
+	It("fails the alerts of the given read", func() {
+		c.FailAlert(0)
+		set(1, 2, 3, 4, 5, 6)
+		readAll()
+
+		Expect(missed).To(BeEmpty())
+	})
+
+	It("delays the given writes and reads", func() {
+		c.DelaySet(1, 20*time.Millisecond)
+		c.DelayRead(0, 20*time.Millisecond)
+
+		start := time.Now()
+		set(1, 2)
+		readAll()
+		Expect(time.Since(start)).To(BeNumerically(">=", 40*time.Millisecond))
+	})
+})
@@ -0,0 +1,143 @@
+package diodetest
+
+import (
+	"testing"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Factory returns a new diode of the given size that reports its drops to
+// the alerter.
+type Factory func(size int, alerter diodes.Alerter) diodes.Diode
+
+// RunDiodeTests runs the conformance suite against the diodes returned by
+// the factory as subtests of t. Every implementation of diodes.Diode must
+// pass it: the values are read in order, a diode holds at least its size,
+// it keeps the newest value when it is lapped and it accounts for every
+// value it drops, through the alerter as well as its Stats if it is a
+// diodes.StatsReporter. The suite invokes the diode from the test's
+// go-routine only.
+func RunDiodeTests(t *testing.T, factory Factory) {
+	t.Run("empty", func(t *testing.T) {
+		d := factory(8, diodes.AlertFunc(func(int) {}))
+		if _, ok := d.TryNext(); ok {
+			t.Fatal("TryNext of a new diode returned a value")
+		}
+	})
+
+	t.Run("order", func(t *testing.T) {
+		r := newRecorder(factory, 8)
+		r.set(8)
+		r.expect(t, 0, 1, 2, 3, 4, 5, 6, 7)
+		r.expectMissed(t, 0)
+	})
+
+	t.Run("interleaved", func(t *testing.T) {
+		r := newRecorder(factory, 4)
+		for i := 0; i < 10; i++ {
+			r.set(3)
+			r.expect(t, 3*i, 3*i+1, 3*i+2)
+		}
+		r.expectMissed(t, 0)
+	})
+
+	t.Run("lap", func(t *testing.T) {
+		r := newRecorder(factory, 4)
+		r.set(21)
+		read := r.readAll()
+
+		if len(read) == 0 || read[len(read)-1] != 20 {
+			t.Fatalf("read %v after the diode was lapped, want the newest value 20 last", read)
+		}
+		if len(read) > 21-1 {
+			t.Fatalf("read %v after the diode was lapped, want values to be dropped", read)
+		}
+		for i := 1; i < len(read); i++ {
+			if read[i] <= read[i-1] {
+				t.Fatalf("read %v after the diode was lapped, want them in order", read)
+			}
+		}
+		r.expectMissed(t, 21-len(read))
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		r := newRecorder(factory, 4)
+		sr, ok := r.d.(diodes.StatsReporter)
+		if !ok {
+			t.Skip("the diode is not a StatsReporter")
+		}
+
+		r.set(10)
+		read := r.readAll()
+		s := sr.Stats()
+		if s.Reads != uint64(len(read)) || s.Drops != uint64(r.missed) || s.Lag != 0 {
+			t.Fatalf("stats are %+v after %d reads and %d drops", s, len(read), r.missed)
+		}
+	})
+
+	t.Run("invariants", func(t *testing.T) {
+		err := CheckInvariants(func(a diodes.Alerter) diodes.Diode {
+			return factory(16, a)
+		}, WithRuns(20))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// recorder sets sequenced values on a diode and records what is read and
+// dropped.
+type recorder struct {
+	d      diodes.Diode
+	seq    int
+	missed int
+}
+
+func newRecorder(factory Factory, size int) *recorder {
+	r := &recorder{}
+	r.d = factory(size, diodes.AlertFunc(func(missed int) {
+		r.missed += missed
+	}))
+	return r
+}
+
+func (r *recorder) set(n int) {
+	for i := 0; i < n; i++ {
+		v := r.seq
+		r.seq++
+		r.d.Set(diodes.GenericDataType(&v))
+	}
+}
+
+func (r *recorder) readAll() []int {
+	var read []int
+	for {
+		data, ok := r.d.TryNext()
+		if !ok {
+			return read
+		}
+		read = append(read, *(*int)(data))
+	}
+}
+
+func (r *recorder) expect(t *testing.T, values ...int) {
+	t.Helper()
+
+	read := r.readAll()
+	if len(read) != len(values) {
+		t.Fatalf("read %v, want %v", read, values)
+	}
+	for i := range values {
+		if read[i] != values[i] {
+			t.Fatalf("read %v, want %v", read, values)
+		}
+	}
+}
+
+func (r *recorder) expectMissed(t *testing.T, missed int) {
+	t.Helper()
+
+	if r.missed != missed {
+		t.Fatalf("the alerter was told about %d drops, want %d", r.missed, missed)
+	}
+}
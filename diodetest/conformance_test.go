@@ -0,0 +1,36 @@
+package diodetest_test
+
+import (
+	"testing"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/diodetest"
+)
+
+func TestConformanceOneToOne(t *testing.T) {
+	for _, impl := range []diodes.Implementation{diodes.PointerSwap, diodes.Seqlock} {
+		impl := impl
+		t.Run(impl.String(), func(t *testing.T) {
+			diodetest.RunDiodeTests(t, func(size int, a diodes.Alerter) diodes.Diode {
+				return diodes.NewOneToOne(size, a, diodes.WithImplementation(impl))
+			})
+		})
+	}
+}
+
+func TestConformanceManyToOne(t *testing.T) {
+	for _, impl := range []diodes.Implementation{diodes.PointerSwap, diodes.Seqlock} {
+		impl := impl
+		t.Run(impl.String(), func(t *testing.T) {
+			diodetest.RunDiodeTests(t, func(size int, a diodes.Alerter) diodes.Diode {
+				return diodes.NewManyToOne(size, a, diodes.WithImplementation(impl))
+			})
+		})
+	}
+}
+
+func TestConformanceFake(t *testing.T) {
+	diodetest.RunDiodeTests(t, func(size int, a diodes.Alerter) diodes.Diode {
+		return diodetest.NewFake(size, a)
+	})
+}
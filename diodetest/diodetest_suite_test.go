@@ -0,0 +1,13 @@
+package diodetest_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestDiodetest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Diodetest Suite")
+}
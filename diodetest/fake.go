@@ -0,0 +1,194 @@
+// Package diodetest provides a fake diode for the unit tests of code that
+// consumes diodes. The fake is deterministic: it holds the values in order,
+// and drops and empty reads only happen when the test scripts them, so the
+// tests do not need real concurrency to cover them.
+package diodetest
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Fake is an in-memory diode with scriptable behavior. Like a real diode, it
+// drops the oldest values once it holds more than its size and reports the
+// drops to the alerter on the next read. It implements diodes.Diode,
+// diodes.StatsReporter, diodes.LagReporter and diodes.Closer and is safe
+// to use from several go-routines.
+type Fake struct {
+	mu         sync.Mutex
+	size       int
+	alerter    diodes.Alerter
+	values     []diodes.GenericDataType
+	written    []diodes.GenericDataType
+	dropWrites int
+	emptyReads int
+	missed     int
+	stats      diodes.Stats
+	closed     bool
+}
+
+// NewFake returns a new Fake that holds up to size values. A size of 0 holds
+// any number of values. The alerter is invoked by TryNext with the number of
+// the values that were dropped since the previous read. A nil can be used to
+// ignore alerts.
+func NewFake(size int, alerter diodes.Alerter) *Fake {
+	if alerter == nil {
+		alerter = diodes.AlertFunc(func(int) {})
+	}
+
+	return &Fake{
+		size:    size,
+		alerter: alerter,
+		stats:   diodes.Stats{Capacity: size},
+	}
+}
+
+// Set records the data and holds it for the reader, unless the fake drops
+// it as scripted by DropWrites or it is closed.
+func (f *Fake) Set(data diodes.GenericDataType) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		f.stats.Rejected++
+		return
+	}
+
+	f.written = append(f.written, data)
+	f.stats.Writes++
+
+	if f.dropWrites > 0 {
+		f.dropWrites--
+		f.missed++
+		return
+	}
+
+	f.values = append(f.values, data)
+	if f.size > 0 && len(f.values) > f.size {
+		f.values[0] = nil
+		f.values = f.values[1:]
+		f.missed++
+	}
+}
+
+// TryNext returns the oldest value the fake holds, unless it reports empty
+// as scripted by EmptyReads. The drops since the previous read are reported
+// to the alerter before a value is returned.
+func (f *Fake) TryNext() (diodes.GenericDataType, bool) {
+	f.mu.Lock()
+
+	if f.emptyReads > 0 {
+		f.emptyReads--
+		f.mu.Unlock()
+		return nil, false
+	}
+
+	if len(f.values) == 0 {
+		f.mu.Unlock()
+		return nil, false
+	}
+
+	data := f.values[0]
+	f.values[0] = nil
+	f.values = f.values[1:]
+	f.stats.Reads++
+
+	missed := f.missed
+	f.missed = 0
+	f.stats.Drops += uint64(missed)
+	f.mu.Unlock()
+
+	if missed > 0 {
+		f.alerter.Alert(missed)
+	}
+
+	return data, true
+}
+
+// DropWrites makes the fake drop the next n values that are set, as if
+// the writer lapped the reader.
+func (f *Fake) DropWrites(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dropWrites += n
+}
+
+// Drop drops the n oldest values the fake holds, or all of them if it holds
+// fewer.
+func (f *Fake) Drop(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if n > len(f.values) {
+		n = len(f.values)
+	}
+
+	for i := 0; i < n; i++ {
+		f.values[i] = nil
+	}
+	f.values = f.values[n:]
+	f.missed += n
+}
+
+// EmptyReads makes the next n invocations of TryNext report that the fake is
+// empty, whether it holds values or not.
+func (f *Fake) EmptyReads(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.emptyReads += n
+}
+
+// Written returns every value that was set, in order, including the values
+// that were dropped.
+func (f *Fake) Written() []diodes.GenericDataType {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]diodes.GenericDataType(nil), f.written...)
+}
+
+// Len returns the number of values the fake holds.
+func (f *Fake) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.values)
+}
+
+// Stats returns the statistics of the fake. The lag includes the drops that
+// were not yet reported.
+func (f *Fake) Stats() diodes.Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := f.stats
+	s.Lag = uint64(len(f.values) + f.missed)
+	return s
+}
+
+// Lag returns the number of values the fake holds plus the drops that were
+// not yet reported.
+func (f *Fake) Lag() uint64 {
+	return f.Stats().Lag
+}
+
+// Close makes the fake reject the values that are set afterwards. It always
+// returns nil.
+func (f *Fake) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	return nil
+}
+
+// Closed reports whether the fake is closed.
+func (f *Fake) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.closed
+}
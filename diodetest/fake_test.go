@@ -0,0 +1,105 @@
+package diodetest_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/diodetest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fake", func() {
+	var (
+		f      *diodetest.Fake
+		missed []int
+	)
+
+	BeforeEach(func() {
+		missed = nil
+		f = diodetest.NewFake(4, diodes.AlertFunc(func(n int) {
+			missed = append(missed, n)
+		}))
+	})
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			f.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func() []int {
+		var out []int
+		for {
+			data, ok := f.TryNext()
+			if !ok {
+				return out
+			}
+			out = append(out, *(*int)(data))
+		}
+	}
+
+	It("returns the values in order", func() {
+		set(1, 2, 3)
+
+		Expect(f.Len()).To(Equal(3))
+		Expect(readAll()).To(Equal([]int{1, 2, 3}))
+		Expect(f.Stats()).To(Equal(diodes.Stats{Writes: 3, Reads: 3, Capacity: 4}))
+	})
+
+	It("drops the oldest values once it is full", func() {
+		set(1, 2, 3, 4, 5, 6)
+
+		Expect(f.Lag()).To(Equal(uint64(6)))
+		Expect(readAll()).To(Equal([]int{3, 4, 5, 6}))
+		Expect(missed).To(Equal([]int{2}))
+		Expect(f.Stats().Drops).To(Equal(uint64(2)))
+	})
+
+	It("drops the writes it is told to", func() {
+		f.DropWrites(2)
+		set(1, 2, 3)
+
+		Expect(readAll()).To(Equal([]int{3}))
+		Expect(missed).To(Equal([]int{2}))
+		Expect(f.Written()).To(HaveLen(3))
+	})
+
+	It("drops the values it holds", func() {
+		set(1, 2, 3)
+		f.Drop(2)
+
+		Expect(readAll()).To(Equal([]int{3}))
+		Expect(missed).To(Equal([]int{2}))
+	})
+
+	It("reports empty reads it is told to", func() {
+		set(1)
+		f.EmptyReads(2)
+
+		_, ok := f.TryNext()
+		Expect(ok).To(BeFalse())
+		_, ok = f.TryNext()
+		Expect(ok).To(BeFalse())
+		Expect(readAll()).To(Equal([]int{1}))
+	})
+
+	It("rejects the values once it is closed", func() {
+		set(1)
+		Expect(f.Close()).To(Succeed())
+		set(2)
+
+		Expect(f.Closed()).To(BeTrue())
+		Expect(readAll()).To(Equal([]int{1}))
+		Expect(f.Stats().Rejected).To(Equal(uint64(1)))
+	})
+
+	It("works with the access layer", func() {
+		set(1)
+		f.Close()
+		p := diodes.NewPoller(f)
+
+		Expect(*(*int)(p.Next())).To(Equal(1))
+		Expect(p.Next() == nil).To(BeTrue())
+	})
+})
@@ -0,0 +1,66 @@
+package diodetest
+
+import (
+	"testing"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// FuzzDiode is a native fuzz target for the diodes returned by the factory,
+// which exercises the index and lap arithmetic against adversarial
+// sequences of writes and reads. It is invoked from a fuzz test of the
+// implementation:
+//
+//	func FuzzMyDiode(f *testing.F) {
+//		diodetest.FuzzDiode(f, func(size int, a diodes.Alerter) diodes.Diode {
+//			return NewMyDiode(size, a)
+//		})
+//	}
+//
+// Every input is a size of up to 64 and a sequence of operations: a byte
+// with the high bit set writes, any other byte reads, as many times as its
+// low four bits plus one. The target verifies the invariants of
+// CheckInvariants for a single writer and, for a diodes.StatsReporter, that
+// the statistics account for every value once the diode is drained. The
+// seed corpus runs as a regular test with go test.
+func FuzzDiode(f *testing.F, factory Factory) {
+	f.Add(uint8(4), []byte{0x8f, 0x0f})
+	f.Add(uint8(4), []byte{0x83, 0x01, 0x85, 0x00, 0x8f, 0x8f, 0x03})
+	f.Add(uint8(1), []byte{0x80, 0x80, 0x00, 0x81, 0x00})
+	f.Add(uint8(7), []byte{0x86, 0x06, 0x87, 0x8f, 0x02, 0x8f, 0x8f})
+
+	f.Fuzz(func(t *testing.T, size uint8, ops []byte) {
+		tr := &tracker{read: make(map[value]bool), last: []int{-1}}
+		d := factory(1+int(size)%64, diodes.AlertFunc(tr.alert))
+
+		var seq int
+		written := func(v value) bool { return v.seq < seq }
+		for _, op := range ops {
+			for i := 0; i <= int(op&0x0f) && tr.err == nil; i++ {
+				if op&0x80 != 0 {
+					d.Set(diodes.GenericDataType(&value{seq: seq}))
+					seq++
+					continue
+				}
+
+				if data, ok := d.TryNext(); ok {
+					tr.observe(data, written)
+				}
+			}
+		}
+		check{}.drain(d, tr, written)
+
+		if err := tr.verify(seq, true); err != nil {
+			t.Fatalf("diodetest: operations %x on a diode of size %d: %s", ops, 1+int(size)%64, err)
+		}
+
+		sr, ok := d.(diodes.StatsReporter)
+		if !ok {
+			return
+		}
+		s := sr.Stats()
+		if s.Reads != uint64(len(tr.read)) || s.Drops != uint64(tr.missed) || s.Lag != 0 {
+			t.Fatalf("diodetest: operations %x: stats are %+v after %d reads and %d drops", ops, s, len(tr.read), tr.missed)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package diodetest
+
+import "code.cloudfoundry.org/go-diodes"
+
+// CheckLeaks fails the test when the diode still holds values that were not
+// read, naming their sequence range, to catch code that abandons data when
+// it shuts down. It is meant to be invoked once the code under test closed
+// the diode and stopped reading it. The diode must be a
+// diodes.StatsReporter; a Waiter or Poller is checked through the diode it
+// wraps.
+func CheckLeaks(t Reporter, d diodes.Diode) {
+	sr := statsReporter(d)
+	if sr == nil {
+		t.Errorf("diodetest: %T is not a StatsReporter", d)
+		return
+	}
+
+	if l, ok := sr.Stats().Unread(); ok {
+		t.Errorf("diodetest: %d values were not read (sequence %d to %d)", l.Len(), l.First, l.Last)
+	}
+}
@@ -0,0 +1,50 @@
+package diodetest_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/diodetest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CheckLeaks", func() {
+	var (
+		r *spyReporter
+		d *diodes.ManyToOne
+	)
+
+	BeforeEach(func() {
+		r = &spyReporter{}
+		d = diodes.NewManyToOne(8, nil)
+		for i := 0; i < 3; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	})
+
+	It("fails for the values that were not read", func() {
+		d.TryNext()
+		d.Close()
+
+		diodetest.CheckLeaks(r, diodes.NewWaiter(d))
+
+		Expect(r.errors()).To(ConsistOf("diodetest: 2 values were not read (sequence 1 to 2)"))
+	})
+
+	It("does not fail once the diode was drained", func() {
+		for i := 0; i < 3; i++ {
+			d.TryNext()
+		}
+
+		diodetest.CheckLeaks(r, d)
+
+		Expect(r.errors()).To(BeEmpty())
+	})
+
+	It("fails for a diode without stats", func() {
+		diodetest.CheckLeaks(r, &struct{ diodes.Diode }{})
+
+		Expect(r.errors()).To(ConsistOf(ContainSubstring("not a StatsReporter")))
+	})
+})
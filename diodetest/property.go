@@ -0,0 +1,260 @@
+package diodetest
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Order is the ordering policy of a diode that CheckInvariants verifies.
+type Order int
+
+const (
+	// FIFO requires that the values of every writer are read in the order
+	// they were written.
+	FIFO Order = iota
+
+	// AnyOrder allows the values to be read in any order, such as for a
+	// diode that reorders by priority.
+	AnyOrder
+)
+
+// CheckOption can be used to setup CheckInvariants.
+type CheckOption func(*check)
+
+type check struct {
+	seed    int64
+	runs    int
+	ops     int
+	writers int
+	order   Order
+}
+
+// WithSeed sets the seed of the random interleavings, such as to reproduce
+// a failure. The default is 1.
+func WithSeed(seed int64) CheckOption {
+	return CheckOption(func(c *check) {
+		c.seed = seed
+	})
+}
+
+// WithRuns sets how many diodes are checked, each with an interleaving of
+// its own. The default is 100.
+func WithRuns(n int) CheckOption {
+	return CheckOption(func(c *check) {
+		c.runs = n
+	})
+}
+
+// WithOperations sets how many writes and reads every run performs. The
+// default is 1000.
+func WithOperations(n int) CheckOption {
+	return CheckOption(func(c *check) {
+		c.ops = n
+	})
+}
+
+// WithWriters sets the number of writers. With a single writer, the default,
+// the writes and reads are interleaved on one go-routine, so a seed always
+// reproduces the same interleaving and the drops have to match the alerts
+// exactly. With several writers, they write on go-routines of their own
+// while the reader reads, and the alerts must account for at least the
+// values that were lost.
+func WithWriters(n int) CheckOption {
+	return CheckOption(func(c *check) {
+		c.writers = n
+	})
+}
+
+// WithOrder sets the ordering policy of the diode. The default is FIFO.
+func WithOrder(o Order) CheckOption {
+	return CheckOption(func(c *check) {
+		c.order = o
+	})
+}
+
+// CheckInvariants drives random interleavings of writes and reads against
+// the diodes returned by newDiode and verifies the invariants every diode
+// has to keep: no value is read twice, every value that is read was written,
+// the values are read in the order of the policy and the drops reported to
+// the alerter are consistent with the values that were lost. It returns an
+// error that describes the first violation, including the seed of the run.
+// newDiode is invoked for every run with the alerter the diode must report
+// its drops to. It can be used for any implementation of diodes.Diode.
+func CheckInvariants(newDiode func(diodes.Alerter) diodes.Diode, opts ...CheckOption) error {
+	c := check{seed: 1, runs: 100, ops: 1000, writers: 1}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	for i := 0; i < c.runs; i++ {
+		seed := c.seed + int64(i)
+		if err := c.run(newDiode, seed); err != nil {
+			return fmt.Errorf("diodetest: seed %d: %w", seed, err)
+		}
+	}
+
+	return nil
+}
+
+// value is what the harness writes: the writer and its sequence number.
+type value struct {
+	writer int
+	seq    int
+}
+
+// tracker records the reads of a run and finds the violations.
+type tracker struct {
+	order  Order
+	read   map[value]bool
+	last   []int
+	missed int
+	err    error
+}
+
+func (t *tracker) alert(missed int) {
+	t.missed += missed
+}
+
+func (t *tracker) observe(data diodes.GenericDataType, written func(value) bool) {
+	if t.err != nil {
+		return
+	}
+
+	if data == nil {
+		t.err = fmt.Errorf("read a nil value")
+		return
+	}
+
+	v := *(*value)(data)
+	switch {
+	case v.writer < 0 || v.writer >= len(t.last) || !written(v):
+		t.err = fmt.Errorf("read %d of writer %d, which was not written", v.seq, v.writer)
+	case t.read[v]:
+		t.err = fmt.Errorf("read %d of writer %d twice", v.seq, v.writer)
+	case t.order == FIFO && v.seq < t.last[v.writer]:
+		t.err = fmt.Errorf("read %d of writer %d after %d", v.seq, v.writer, t.last[v.writer])
+	}
+
+	t.read[v] = true
+	if v.seq > t.last[v.writer] {
+		t.last[v.writer] = v.seq
+	}
+}
+
+func (c check) run(newDiode func(diodes.Alerter) diodes.Diode, seed int64) error {
+	t := &tracker{
+		order: c.order,
+		read:  make(map[value]bool),
+		last:  make([]int, c.writers),
+	}
+	for i := range t.last {
+		t.last[i] = -1
+	}
+	d := newDiode(diodes.AlertFunc(t.alert))
+
+	var written int
+	if c.writers <= 1 {
+		written = c.interleave(d, t, rand.New(rand.NewSource(seed)))
+	} else {
+		written = c.concurrent(d, t)
+	}
+	return t.verify(written, c.writers <= 1)
+}
+
+// verify returns the first violation of the run. The drops must match the
+// lost values exactly when they are deterministic and account for at least
+// them otherwise.
+func (t *tracker) verify(written int, exact bool) error {
+	if t.err != nil {
+		return t.err
+	}
+
+	lost := written - len(t.read)
+	switch {
+	case exact && t.missed != lost:
+		return fmt.Errorf("%d values were lost, but %d drops were reported", lost, t.missed)
+	case t.missed < lost:
+		return fmt.Errorf("%d values were lost, but only %d drops were reported", lost, t.missed)
+	}
+
+	return nil
+}
+
+// interleave writes and reads in random bursts on the current go-routine,
+// so that the writer laps the reader every now and then. It returns the
+// number of values that were written.
+func (c check) interleave(d diodes.Diode, t *tracker, r *rand.Rand) int {
+	var seq int
+	written := func(v value) bool { return v.seq < seq }
+
+	for ops := 0; ops < c.ops && t.err == nil; {
+		burst := 1 + r.Intn(16)
+		write := r.Intn(2) == 0
+		for i := 0; i < burst && ops < c.ops; i++ {
+			ops++
+			if write {
+				d.Set(diodes.GenericDataType(&value{seq: seq}))
+				seq++
+				continue
+			}
+
+			if data, ok := d.TryNext(); ok {
+				t.observe(data, written)
+			}
+		}
+	}
+
+	c.drain(d, t, written)
+	return seq
+}
+
+// concurrent writes on a go-routine per writer while reading. It returns
+// the number of values that were written.
+func (c check) concurrent(d diodes.Diode, t *tracker) int {
+	perWriter := c.ops / c.writers
+	written := func(v value) bool { return v.seq < perWriter }
+
+	var wg sync.WaitGroup
+	for w := 0; w < c.writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for seq := 0; seq < perWriter; seq++ {
+				d.Set(diodes.GenericDataType(&value{writer: w, seq: seq}))
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			c.drain(d, t, written)
+			return perWriter * c.writers
+		default:
+		}
+
+		if data, ok := d.TryNext(); ok {
+			t.observe(data, written)
+		}
+	}
+}
+
+// drain reads what is left in the diode.
+func (c check) drain(d diodes.Diode, t *tracker, written func(value) bool) {
+	for t.err == nil {
+		data, ok := d.TryNext()
+		if !ok {
+			return
+		}
+		t.observe(data, written)
+	}
+}
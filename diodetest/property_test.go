@@ -0,0 +1,110 @@
+package diodetest_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/diodetest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CheckInvariants", func() {
+	for _, impl := range []diodes.Implementation{diodes.PointerSwap, diodes.Seqlock} {
+		impl := impl
+
+		It("passes a OneToOne ("+impl.String()+")", func() {
+			err := diodetest.CheckInvariants(func(a diodes.Alerter) diodes.Diode {
+				return diodes.NewOneToOne(8, a, diodes.WithImplementation(impl))
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("passes a ManyToOne with several writers ("+impl.String()+")", func() {
+			err := diodetest.CheckInvariants(func(a diodes.Alerter) diodes.Diode {
+				return diodes.NewManyToOne(64, a, diodes.WithImplementation(impl))
+			}, diodetest.WithWriters(4), diodetest.WithRuns(10))
+			Expect(err).ToNot(HaveOccurred())
+		})
+	}
+
+	It("passes a Segmented", func() {
+		err := diodetest.CheckInvariants(func(a diodes.Alerter) diodes.Diode {
+			return diodes.NewSegmented(4, 4, a)
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("passes the fake", func() {
+		err := diodetest.CheckInvariants(func(a diodes.Alerter) diodes.Diode {
+			return diodetest.NewFake(8, a)
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("reports a diode that repeats a value", func() {
+		err := diodetest.CheckInvariants(func(a diodes.Alerter) diodes.Diode {
+			return &repeating{Fake: diodetest.NewFake(8, a)}
+		}, diodetest.WithSeed(42))
+		Expect(err).To(MatchError(ContainSubstring("seed 42")))
+		Expect(err).To(MatchError(ContainSubstring("twice")))
+	})
+
+	It("reports a diode that does not report its drops", func() {
+		err := diodetest.CheckInvariants(func(diodes.Alerter) diodes.Diode {
+			return diodetest.NewFake(8, nil)
+		})
+		Expect(err).To(MatchError(ContainSubstring("drops were reported")))
+	})
+
+	It("reports a diode that reorders the values", func() {
+		newDiode := func(a diodes.Alerter) diodes.Diode {
+			return &reversing{Fake: diodetest.NewFake(0, a)}
+		}
+
+		Expect(diodetest.CheckInvariants(newDiode)).To(MatchError(ContainSubstring("after")))
+		Expect(diodetest.CheckInvariants(newDiode, diodetest.WithOrder(diodetest.AnyOrder))).To(Succeed())
+	})
+})
+
+// repeating returns every value twice.
+type repeating struct {
+	*diodetest.Fake
+	last diodes.GenericDataType
+}
+
+func (r *repeating) TryNext() (diodes.GenericDataType, bool) {
+	if r.last != nil {
+		data := r.last
+		r.last = nil
+		return data, true
+	}
+
+	data, ok := r.Fake.TryNext()
+	r.last = data
+	return data, ok
+}
+
+// reversing returns the values it holds newest first.
+type reversing struct {
+	*diodetest.Fake
+	pending []diodes.GenericDataType
+}
+
+func (r *reversing) TryNext() (diodes.GenericDataType, bool) {
+	if len(r.pending) == 0 {
+		for {
+			data, ok := r.Fake.TryNext()
+			if !ok {
+				break
+			}
+			r.pending = append(r.pending, data)
+		}
+	}
+	if len(r.pending) == 0 {
+		return nil, false
+	}
+
+	data := r.pending[len(r.pending)-1]
+	r.pending = r.pending[:len(r.pending)-1]
+	return data, true
+}
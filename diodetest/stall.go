@@ -0,0 +1,90 @@
+package diodetest
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Reporter is the part of testing.TB that DetectStalls uses to fail a test.
+// Ginkgo's GinkgoT() implements it as well.
+type Reporter interface {
+	Errorf(format string, args ...interface{})
+}
+
+// DetectStalls watches the diode and fails the test once it holds data but
+// its reader made no progress for the timeout, which catches deadlocks and
+// missed wake ups of the code that reads it, such as a Waiter or Poller
+// integration. The diode must be a diodes.StatsReporter; a Waiter or Poller
+// is watched through the diode it wraps. The returned function stops the
+// watching and must be invoked before the test ends. A stall fails the test
+// once.
+func DetectStalls(t Reporter, d diodes.Diode, timeout time.Duration) (stop func()) {
+	sr := statsReporter(d)
+	if sr == nil {
+		t.Errorf("diodetest: %T is not a StatsReporter", d)
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watch(t, sr, timeout, done)
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+func watch(t Reporter, sr diodes.StatsReporter, timeout time.Duration, done chan struct{}) {
+	interval := timeout / 10
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := sr.Stats()
+	progress := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		s := sr.Stats()
+		if s.Reads != last.Reads || s.Drops != last.Drops || s.Lag == 0 {
+			last = s
+			progress = time.Now()
+			continue
+		}
+
+		if stalled := time.Since(progress); stalled >= timeout {
+			t.Errorf("diodetest: the reader made no progress for %s while %d values are available", stalled.Truncate(time.Millisecond), s.Occupancy())
+			return
+		}
+	}
+}
+
+// statsReporter returns the StatsReporter of the diode or of the diode a
+// Waiter or Poller wraps.
+func statsReporter(d diodes.Diode) diodes.StatsReporter {
+	switch v := d.(type) {
+	case *diodes.Waiter:
+		d = v.Diode
+	case *diodes.Poller:
+		d = v.Diode
+	}
+
+	sr, _ := d.(diodes.StatsReporter)
+	return sr
+}
@@ -0,0 +1,83 @@
+package diodetest_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/diodetest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DetectStalls", func() {
+	var (
+		r *spyReporter
+		d *diodes.OneToOne
+	)
+
+	BeforeEach(func() {
+		r = &spyReporter{}
+		d = diodes.NewOneToOne(8, nil)
+	})
+
+	set := func(n int) {
+		for i := 0; i < n; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("fails once the reader makes no progress", func() {
+		stop := diodetest.DetectStalls(r, diodes.NewWaiter(d), 20*time.Millisecond)
+		defer stop()
+		set(2)
+
+		Eventually(r.errors).Should(ConsistOf(ContainSubstring("no progress")))
+	})
+
+	It("does not fail while the reader makes progress", func() {
+		stop := diodetest.DetectStalls(r, d, 20*time.Millisecond)
+		for i := 0; i < 10; i++ {
+			set(1)
+			time.Sleep(5 * time.Millisecond)
+			d.TryNext()
+		}
+		stop()
+
+		Expect(r.errors()).To(BeEmpty())
+	})
+
+	It("does not fail while the diode is empty", func() {
+		stop := diodetest.DetectStalls(r, d, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		stop()
+
+		Expect(r.errors()).To(BeEmpty())
+	})
+
+	It("fails for a diode without stats", func() {
+		diodetest.DetectStalls(r, &struct{ diodes.Diode }{}, time.Second)()
+
+		Expect(r.errors()).To(ConsistOf(ContainSubstring("not a StatsReporter")))
+	})
+})
+
+type spyReporter struct {
+	mu   sync.Mutex
+	errs []string
+}
+
+func (r *spyReporter) Errorf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *spyReporter) errors() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.errs...)
+}
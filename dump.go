@@ -0,0 +1,145 @@
+package diodes
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Dump returns a multi-line rendering of the diode's state for bug reports
+// and crash dumps. Next to String, it lists the recorded write index of each
+// slot, or "-" for an empty slot. It is safe to call from any go-routine
+// and does not consume data, but the slots may change while they are
+// rendered.
+func (d *OneToOne) Dump() string {
+	return d.ring.dump(d.String())
+}
+
+// String returns a summary of the diode's indices, occupancy and
+// configuration. It is safe to call from any go-routine.
+func (d *OneToOne) String() string {
+	return d.ring.describe("OneToOne", d.Stats(), atomic.LoadUint64(&d.writeIndex), atomic.LoadUint64(&d.readIndex), d.Closed())
+}
+
+// Dump returns a multi-line rendering of the diode's state for bug reports
+// and crash dumps. Next to String, it lists the recorded write index of each
+// slot, or "-" for an empty slot. It is safe to call from any go-routine
+// and does not consume data, but the slots may change while they are
+// rendered.
+func (d *ManyToOne) Dump() string {
+	return d.ring.dump(d.String())
+}
+
+// String returns a summary of the diode's indices, occupancy and
+// configuration. It is safe to call from any go-routine.
+func (d *ManyToOne) String() string {
+	return d.ring.describe("ManyToOne", d.Stats(), atomic.LoadUint64(&d.writeIndex)+1, atomic.LoadUint64(&d.readIndex), d.Closed())
+}
+
+// String returns a summary of the diode's indices, occupancy and
+// configuration. It is safe to call from any go-routine.
+func (d *Segmented) String() string {
+	s := d.Stats()
+
+	var b strings.Builder
+	b.WriteString("Segmented{segments: ")
+	b.WriteString(strconv.Itoa(len(d.segments)))
+	b.WriteString(", segmentSize: ")
+	b.WriteString(strconv.Itoa(d.segmentSize))
+	writeUint(&b, ", writeIndex: ", atomic.LoadUint64(&d.writeIndex))
+	writeUint(&b, ", readIndex: ", atomic.LoadUint64(&d.readIndex))
+	writeUint(&b, ", occupancy: ", s.Occupancy())
+	writeUint(&b, ", drops: ", s.Drops)
+	b.WriteString("}")
+
+	return b.String()
+}
+
+// describe renders the summary of a diode that uses the ring, with the
+// settings that change its behavior.
+func (r *ring) describe(name string, s Stats, writeIndex, readIndex uint64, closed bool) string {
+	implementation := PointerSwap
+	if r.slots != nil {
+		implementation = Seqlock
+	}
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteString("{size: ")
+	b.WriteString(strconv.FormatUint(r.size, 10))
+	b.WriteString(", implementation: ")
+	b.WriteString(implementation.String())
+	b.WriteString(", paddedSlots: ")
+	b.WriteString(strconv.FormatBool(r.stride > 1))
+	b.WriteString(", instrumentation: ")
+	b.WriteString(r.instr.snapshot().Level.String())
+	b.WriteString(", catchUp: ")
+	b.WriteString(r.catchUp().String())
+	b.WriteString(", strictOrder: ")
+	b.WriteString(strconv.FormatBool(r.ordered))
+	writeUint(&b, ", startIndex: ", r.start)
+	b.WriteString(", closed: ")
+	b.WriteString(strconv.FormatBool(closed))
+	writeUint(&b, ", writeIndex: ", writeIndex)
+	writeUint(&b, ", readIndex: ", readIndex)
+	writeUint(&b, ", occupancy: ", s.Occupancy())
+	writeUint(&b, ", drops: ", s.Drops)
+	b.WriteString("}")
+
+	return b.String()
+}
+
+// catchUp returns the catch-up policy of the ring.
+func (r *ring) catchUp() CatchUpPolicy {
+	if r.latest {
+		return CatchUpLatest
+	}
+
+	return CatchUpOldest
+}
+
+// dump renders the summary followed by the recorded write index of every
+// slot. The slots are only peeked at and are not consumed.
+func (r *ring) dump(summary string) string {
+	var b strings.Builder
+	b.WriteString(summary)
+	b.WriteString("\nslots: [")
+
+	for i := uint64(0); i < r.size; i++ {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+
+		seq, ok := r.peekSeq(i)
+		if !ok {
+			b.WriteByte('-')
+			continue
+		}
+		b.WriteString(strconv.FormatUint(seq, 10))
+	}
+
+	b.WriteString("]")
+
+	return b.String()
+}
+
+// peekSeq returns the write index recorded in the slot at the given index
+// without consuming it.
+func (r *ring) peekSeq(idx uint64) (uint64, bool) {
+	if r.slots != nil {
+		e, ok := r.loadSlot(idx)
+		return e.seq, ok
+	}
+
+	b := (*bucket)(atomic.LoadPointer(r.pointer(idx)))
+	if b == nil {
+		return 0, false
+	}
+
+	return b.seq, true
+}
+
+func writeUint(b *strings.Builder, label string, v uint64) {
+	b.WriteString(label)
+	b.WriteString(strconv.FormatUint(v, 10))
+}
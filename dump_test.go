@@ -0,0 +1,101 @@
+package diodes_test
+
+import (
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("Dump()", func(impl diodes.Implementation) {
+	var data []byte
+
+	BeforeEach(func() {
+		data = []byte("some-data")
+	})
+
+	It("renders the state of a OneToOne", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl))
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+
+		lines := strings.Split(d.Dump(), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(Equal(d.String()))
+		Expect(lines[0]).To(Equal("OneToOne{size: 4, implementation: " + impl.String() +
+			", paddedSlots: false, instrumentation: Off, catchUp: CatchUpOldest, strictOrder: false, startIndex: 0, closed: false, writeIndex: 6, readIndex: 5, occupancy: 1, drops: 4}"))
+
+		if impl == diodes.PointerSwap {
+			Expect(lines[1]).To(Equal("slots: [- 5 2 3]"))
+		} else {
+			Expect(lines[1]).To(Equal("slots: [4 5 2 3]"))
+		}
+	})
+
+	It("renders the state of a ManyToOne", func() {
+		d := diodes.NewManyToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithPaddedSlots(),
+			diodes.WithInstrumentation(diodes.InstrumentationBasic),
+		)
+		d.Set(diodes.GenericDataType(&data))
+
+		Expect(d.Dump()).To(Equal("ManyToOne{size: 4, implementation: " + impl.String() +
+			", paddedSlots: true, instrumentation: Basic, catchUp: CatchUpOldest, strictOrder: false, startIndex: 0, closed: false, writeIndex: 1, readIndex: 0, occupancy: 1, drops: 0}\n" +
+			"slots: [0 - - -]"))
+	})
+
+	It("renders the settings that change the behavior", func() {
+		d := diodes.NewManyToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithCatchUp(diodes.CatchUpLatest),
+			diodes.WithStrictOrder(),
+			diodes.WithStartIndex(10),
+		)
+		d.Close()
+
+		Expect(d.String()).To(ContainSubstring("catchUp: CatchUpLatest, strictOrder: true, startIndex: 10, closed: true"))
+	})
+
+	It("is safe to call while writing and reading", func() {
+		d := diodes.NewManyToOne(8, nil, diodes.WithImplementation(impl))
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				d.Set(diodes.GenericDataType(&data))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				d.TryNext()
+			}
+		}()
+
+		for i := 0; i < 100; i++ {
+			Expect(d.Dump()).To(HavePrefix("ManyToOne{"))
+		}
+		wg.Wait()
+	})
+})
+
+var _ = Describe("Segmented String()", func() {
+	It("renders the state", func() {
+		d := diodes.NewSegmented(3, 4, nil)
+		data := []byte("some-data")
+		for i := 0; i < 5; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+
+		Expect(d.String()).To(Equal("Segmented{segments: 3, segmentSize: 4, writeIndex: 5, readIndex: 4, occupancy: 1, drops: 0}"))
+	})
+})
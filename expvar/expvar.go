@@ -0,0 +1,32 @@
+// Package expvar publishes the stats of diodes as expvar variables.
+package expvar
+
+import (
+	"expvar"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Publish publishes the stats of the diode under the given name. The stats
+// are read whenever the variable is read, e.g. when /debug/vars is served.
+// Like expvar.Publish, it panics if the name is already in use.
+func Publish(name string, d diodes.StatsReporter) {
+	expvar.Publish(name, Func(d))
+}
+
+// Func returns an expvar.Func that reports the stats of the diode. It can be
+// used to add a diode to an existing expvar.Map.
+func Func(d diodes.StatsReporter) expvar.Func {
+	return expvar.Func(func() interface{} {
+		s := d.Stats()
+
+		return map[string]uint64{
+			"writes":    s.Writes,
+			"reads":     s.Reads,
+			"drops":     s.Drops,
+			"lag":       s.Lag,
+			"occupancy": s.Occupancy(),
+			"capacity":  uint64(s.Capacity),
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package expvar_test
+
+import (
+	"encoding/json"
+	"expvar"
+
+	"code.cloudfoundry.org/go-diodes"
+	diodesexpvar "code.cloudfoundry.org/go-diodes/expvar"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Expvar", func() {
+	var d *diodes.OneToOne
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(4, nil)
+
+		data := []byte("some-data")
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+	})
+
+	decode := func(v expvar.Var) map[string]uint64 {
+		var stats map[string]uint64
+		Expect(json.Unmarshal([]byte(v.String()), &stats)).To(Succeed())
+		return stats
+	}
+
+	It("publishes the stats of the diode", func() {
+		diodesexpvar.Publish("test-diode", d)
+
+		Expect(decode(expvar.Get("test-diode"))).To(Equal(map[string]uint64{
+			"writes":    6,
+			"reads":     1,
+			"drops":     4,
+			"lag":       1,
+			"occupancy": 1,
+			"capacity":  4,
+		}))
+	})
+
+	It("reads the stats whenever the variable is read", func() {
+		f := diodesexpvar.Func(d)
+		Expect(decode(f)["lag"]).To(Equal(uint64(1)))
+
+		d.TryNext()
+		Expect(decode(f)["lag"]).To(Equal(uint64(0)))
+	})
+
+	It("can be added to a map", func() {
+		m := new(expvar.Map).Init()
+		m.Set("ingress", diodesexpvar.Func(d))
+
+		Expect(decode(m.Get("ingress"))["capacity"]).To(Equal(uint64(4)))
+	})
+})
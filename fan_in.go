@@ -0,0 +1,232 @@
+package diodes
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// FanIn merges several source diodes into one stream, so that a single
+// reader can service the diodes of many writers. Each source keeps its own
+// capacity and alerter, and drops data on its own when the reader falls
+// behind. The policy decides which source is read when several have data.
+//
+// Like the diodes, a FanIn is meant to be read by a single go-routine.
+type FanIn struct {
+	sources  []Diode
+	policy   FanInPolicy
+	interval time.Duration
+	ctx      context.Context
+	last     int
+
+	// waits counts the reads of the other sources since each source was
+	// last read or found empty, for WithFanInAging. promotions counts the
+	// reads of each source that were promoted by its age.
+	aging      int
+	waits      []int
+	promotions []uint64
+}
+
+// FanInPolicy decides the order in which a FanIn tries its sources.
+type FanInPolicy interface {
+	// Start returns the index of the first of the n sources to try. The
+	// others are tried in order from it, wrapping around. Last is the index
+	// of the source that was read last, or -1.
+	Start(last, n int) int
+}
+
+// FanInPolicyFunc is an adapter to allow the use of ordinary functions as
+// a FanInPolicy.
+type FanInPolicyFunc func(last, n int) int
+
+// Start calls f(last, n).
+func (f FanInPolicyFunc) Start(last, n int) int {
+	return f(last, n)
+}
+
+// RoundRobin returns a FanInPolicy that tries the sources in turn, starting
+// with the one after the source that was read last. A busy source does not
+// starve the others.
+func RoundRobin() FanInPolicy {
+	return FanInPolicyFunc(func(last, n int) int {
+		return (last + 1) % n
+	})
+}
+
+// Priority returns a FanInPolicy that always tries the sources in the order
+// they were given to NewFanIn. A source is only read once the sources
+// before it are empty.
+func Priority() FanInPolicy {
+	return FanInPolicyFunc(func(int, int) int {
+		return 0
+	})
+}
+
+// FanInOption can be used to setup the fan-in.
+type FanInOption func(*FanIn)
+
+// WithFanInPolicy sets the policy that decides which source is read. The
+// default is RoundRobin.
+func WithFanInPolicy(p FanInPolicy) FanInOption {
+	return FanInOption(func(f *FanIn) {
+		f.policy = p
+	})
+}
+
+// WithFanInPollingInterval sets the interval at which Next queries the
+// sources for new data. The default is 10ms.
+func WithFanInPollingInterval(interval time.Duration) FanInOption {
+	return FanInOption(func(f *FanIn) {
+		f.interval = interval
+	})
+}
+
+// WithFanInContext sets the context to cancel Next. Default is
+// context.Background().
+func WithFanInContext(ctx context.Context) FanInOption {
+	return FanInOption(func(f *FanIn) {
+		f.ctx = ctx
+	})
+}
+
+// WithFanInAging promotes a source once the others were read after times
+// since it was last read, so that a source of a low priority is eventually
+// read under a constant stream of the others. The promoted source is read
+// once before the policy decides again, and a source that turns out to be
+// empty waits anew. Promotions counts the reads of every source that were
+// promoted. By default there is no aging.
+func WithFanInAging(after int) FanInOption {
+	return FanInOption(func(f *FanIn) {
+		f.aging = after
+	})
+}
+
+// NewFanIn returns a new FanIn that reads from the given sources.
+func NewFanIn(sources []Diode, opts ...FanInOption) *FanIn {
+	f := &FanIn{
+		sources:  sources,
+		policy:   RoundRobin(),
+		interval: 10 * time.Millisecond,
+		ctx:      context.Background(),
+		last:     -1,
+	}
+
+	for _, o := range opts {
+		o(f)
+	}
+
+	if f.aging > 0 {
+		f.waits = make([]int, len(sources))
+		f.promotions = make([]uint64, len(sources))
+	}
+
+	return f
+}
+
+// TryNext returns the next value of the source chosen by the policy. If
+// none of the sources has data available, it will return (nil, false).
+func (f *FanIn) TryNext() (GenericDataType, bool) {
+	data, _, ok := f.TryNextSource()
+	return data, ok
+}
+
+// TryNextSource is like TryNext, and also returns the index of the source
+// the value was read from.
+func (f *FanIn) TryNextSource() (GenericDataType, int, bool) {
+	n := len(f.sources)
+	if n == 0 {
+		return nil, 0, false
+	}
+
+	if f.waits != nil {
+		if data, idx, ok := f.tryNextAged(); ok {
+			return data, idx, true
+		}
+	}
+
+	start := f.policy.Start(f.last, n)
+	for i := 0; i < n; i++ {
+		idx := (start + i) % n
+		if data, ok := f.sources[idx].TryNext(); ok {
+			f.read(idx)
+			return data, idx, true
+		}
+	}
+
+	return nil, 0, false
+}
+
+// tryNextAged reads the first source that waited for WithFanInAging reads.
+// A source that turns out to be empty was not starved and waits anew.
+func (f *FanIn) tryNextAged() (GenericDataType, int, bool) {
+	for idx, wait := range f.waits {
+		if wait < f.aging {
+			continue
+		}
+
+		if data, ok := f.sources[idx].TryNext(); ok {
+			atomic.AddUint64(&f.promotions[idx], 1)
+			f.read(idx)
+			return data, idx, true
+		}
+		f.waits[idx] = 0
+	}
+
+	return nil, 0, false
+}
+
+// read records that the source was read.
+func (f *FanIn) read(idx int) {
+	f.last = idx
+	for i := range f.waits {
+		f.waits[i]++
+	}
+	if f.waits != nil {
+		f.waits[idx] = 0
+	}
+}
+
+// Next polls the sources until data is available or until the context is
+// done. If the context is done, then nil will be returned. It makes a FanIn
+// a Nexter, so that it can be broadcast.
+func (f *FanIn) Next() GenericDataType {
+	for {
+		data, ok := f.TryNext()
+		if !ok {
+			if f.ctx.Err() != nil {
+				return nil
+			}
+
+			time.Sleep(f.interval)
+			continue
+		}
+		return data
+	}
+}
+
+// Promotions returns the number of reads of every source, in the order they
+// were given to NewFanIn, that were promoted by WithFanInAging. It is safe
+// to call from any go-routine.
+func (f *FanIn) Promotions() []uint64 {
+	promotions := make([]uint64, len(f.sources))
+	for i := range f.promotions {
+		promotions[i] = atomic.LoadUint64(&f.promotions[i])
+	}
+
+	return promotions
+}
+
+// SourceStats returns a snapshot of the statistics of every source, in the
+// order they were given to NewFanIn, so that drops can be accounted to the
+// writer that caused them. The statistics of a source that is not a
+// StatsReporter are zero.
+func (f *FanIn) SourceStats() []Stats {
+	stats := make([]Stats, len(f.sources))
+	for i, s := range f.sources {
+		if r, ok := s.(StatsReporter); ok {
+			stats[i] = r.Stats()
+		}
+	}
+
+	return stats
+}
@@ -0,0 +1,131 @@
+package diodes_test
+
+import (
+	"context"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FanIn", func() {
+	var a, b *diodes.OneToOne
+
+	BeforeEach(func() {
+		a = diodes.NewOneToOne(4, nil)
+		b = diodes.NewOneToOne(4, nil)
+	})
+
+	set := func(d diodes.Diode, values ...int) {
+		for _, v := range values {
+			v := v
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func(f *diodes.FanIn) []int {
+		var values []int
+		for {
+			data, ok := f.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, *(*int)(data))
+		}
+	}
+
+	It("reads the sources in turn", func() {
+		f := diodes.NewFanIn([]diodes.Diode{a, b})
+		set(a, 1, 2, 3)
+		set(b, 10, 20)
+
+		Expect(readAll(f)).To(Equal([]int{1, 10, 2, 20, 3}))
+	})
+
+	It("reads the sources by priority", func() {
+		f := diodes.NewFanIn([]diodes.Diode{a, b}, diodes.WithFanInPolicy(diodes.Priority()))
+		set(b, 10, 20)
+		set(a, 1, 2)
+
+		Expect(readAll(f)).To(Equal([]int{1, 2, 10, 20}))
+	})
+
+	Describe("WithFanInAging", func() {
+		BeforeEach(func() {
+			a = diodes.NewOneToOne(8, nil)
+		})
+
+		It("promotes a source that waited for the others", func() {
+			f := diodes.NewFanIn([]diodes.Diode{a, b},
+				diodes.WithFanInPolicy(diodes.Priority()),
+				diodes.WithFanInAging(2),
+			)
+			set(a, 1, 2, 3, 4, 5, 6)
+			set(b, 10, 20)
+
+			Expect(readAll(f)).To(Equal([]int{1, 2, 10, 3, 4, 20, 5, 6}))
+			Expect(f.Promotions()).To(Equal([]uint64{0, 2}))
+		})
+
+		It("does not promote a source that was empty", func() {
+			f := diodes.NewFanIn([]diodes.Diode{a, b},
+				diodes.WithFanInPolicy(diodes.Priority()),
+				diodes.WithFanInAging(2),
+			)
+			set(a, 1, 2, 3, 4)
+			Expect(readAll(f)).To(Equal([]int{1, 2, 3, 4}))
+
+			set(a, 5)
+			set(b, 10)
+			Expect(readAll(f)).To(Equal([]int{5, 10}))
+			Expect(f.Promotions()).To(Equal([]uint64{0, 0}))
+		})
+
+		It("does not promote without aging", func() {
+			f := diodes.NewFanIn([]diodes.Diode{a, b}, diodes.WithFanInPolicy(diodes.Priority()))
+			set(a, 1, 2, 3)
+			set(b, 10)
+
+			Expect(readAll(f)).To(Equal([]int{1, 2, 3, 10}))
+			Expect(f.Promotions()).To(Equal([]uint64{0, 0}))
+		})
+	})
+
+	It("reports the source of a value", func() {
+		f := diodes.NewFanIn([]diodes.Diode{a, b})
+		set(b, 10)
+
+		data, idx, ok := f.TryNextSource()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(10))
+		Expect(idx).To(Equal(1))
+	})
+
+	It("accounts the drops to each source", func() {
+		f := diodes.NewFanIn([]diodes.Diode{a, b})
+		set(a, 1, 2, 3, 4, 5, 6)
+		set(b, 10)
+		readAll(f)
+
+		stats := f.SourceStats()
+		Expect(stats).To(HaveLen(2))
+		Expect(stats[0].Drops).To(Equal(uint64(4)))
+		Expect(stats[1].Drops).To(BeZero())
+		Expect(stats[1].Reads).To(Equal(uint64(1)))
+	})
+
+	It("returns nil from Next once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		f := diodes.NewFanIn([]diodes.Diode{a, b},
+			diodes.WithFanInContext(ctx),
+			diodes.WithFanInPollingInterval(time.Millisecond),
+		)
+		set(b, 10)
+		Expect(*(*int)(f.Next())).To(Equal(10))
+
+		cancel()
+		Expect(f.Next() == nil).To(BeTrue())
+	})
+})
@@ -0,0 +1,91 @@
+package diodes
+
+import "sync/atomic"
+
+// Filter wraps a diode and only passes the values that match a predicate.
+// By default the values are filtered by Set, so that the values that do not
+// match do not take up room in the diode. With WithFilterOnRead they are
+// filtered by TryNext instead, which keeps the predicate off the writer's
+// go-routine.
+type Filter struct {
+	filtered uint64
+
+	Diode
+	match  func(GenericDataType) bool
+	onRead bool
+	panics func(*CallbackPanic)
+}
+
+// FilterOption can be used to setup the filter.
+type FilterOption func(*Filter)
+
+// WithFilterOnRead filters the values when they are read instead of when
+// they are set.
+func WithFilterOnRead() FilterOption {
+	return FilterOption(func(f *Filter) {
+		f.onRead = true
+	})
+}
+
+// WithFilterPanicHandler recovers the panics of the predicate and hands them
+// to fn. A value the predicate panicked on does not match.
+func WithFilterPanicHandler(fn func(*CallbackPanic)) FilterOption {
+	return FilterOption(func(f *Filter) {
+		f.panics = fn
+	})
+}
+
+// NewFilter returns a new Filter that wraps the given diode and passes the
+// values for which match returns true.
+func NewFilter(d Diode, match func(GenericDataType) bool, opts ...FilterOption) *Filter {
+	f := &Filter{
+		Diode: d,
+		match: match,
+	}
+
+	for _, o := range opts {
+		o(f)
+	}
+
+	return f
+}
+
+// Set sets the data on the wrapped diode, unless it is filtered on write and
+// does not match.
+func (f *Filter) Set(data GenericDataType) {
+	if !f.onRead && !f.matches(data) {
+		atomic.AddUint64(&f.filtered, 1)
+		return
+	}
+
+	f.Diode.Set(data)
+}
+
+// TryNext returns the next value of the wrapped diode. When the values are
+// filtered on read, it skips the values that do not match.
+func (f *Filter) TryNext() (GenericDataType, bool) {
+	for {
+		data, ok := f.Diode.TryNext()
+		if !ok || !f.onRead || f.matches(data) {
+			return data, ok
+		}
+
+		atomic.AddUint64(&f.filtered, 1)
+	}
+}
+
+// matches applies the predicate, recovering its panics if there is a panic
+// handler.
+func (f *Filter) matches(data GenericDataType) (match bool) {
+	if f.panics != nil {
+		defer recoverCallback(f.panics, "Filter")
+	}
+
+	return f.match(data)
+}
+
+// Filtered returns the number of values that did not match. It is safe to
+// call from any go-routine.
+func (f *Filter) Filtered() uint64 {
+	return atomic.LoadUint64(&f.filtered)
+}
@@ -0,0 +1,75 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Filter", func() {
+	var d *diodes.OneToOne
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(4, nil)
+	})
+
+	odd := func(data diodes.GenericDataType) bool {
+		return *(*int)(data)%2 == 1
+	}
+
+	set := func(f *diodes.Filter, values ...int) {
+		for _, v := range values {
+			v := v
+			f.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func(f *diodes.Filter) []int {
+		var values []int
+		for {
+			data, ok := f.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, *(*int)(data))
+		}
+	}
+
+	It("does not set the values that do not match", func() {
+		f := diodes.NewFilter(d, odd)
+		set(f, 1, 2, 3, 4, 5, 6, 7)
+
+		Expect(d.Stats().Writes).To(Equal(uint64(4)))
+		Expect(f.Filtered()).To(Equal(uint64(3)))
+		Expect(readAll(f)).To(Equal([]int{1, 3, 5, 7}))
+	})
+
+	It("skips the values that do not match on read", func() {
+		f := diodes.NewFilter(d, odd, diodes.WithFilterOnRead())
+		set(f, 1, 2, 3, 4)
+
+		Expect(d.Stats().Writes).To(Equal(uint64(4)))
+		Expect(readAll(f)).To(Equal([]int{1, 3}))
+		Expect(f.Filtered()).To(Equal(uint64(2)))
+	})
+
+	It("treats a value the predicate panicked on as not matching", func() {
+		var panics []*diodes.CallbackPanic
+		explode := func(data diodes.GenericDataType) bool {
+			if *(*int)(data) == 2 {
+				panic("boom")
+			}
+			return true
+		}
+		f := diodes.NewFilter(d, explode, diodes.WithFilterPanicHandler(func(p *diodes.CallbackPanic) {
+			panics = append(panics, p)
+		}))
+		set(f, 1, 2, 3)
+
+		Expect(readAll(f)).To(Equal([]int{1, 3}))
+		Expect(f.Filtered()).To(Equal(uint64(1)))
+		Expect(panics).To(HaveLen(1))
+		Expect(panics[0].Callback).To(Equal("Filter"))
+	})
+})
@@ -0,0 +1,13 @@
+package fluent_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFluent(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fluent Suite")
+}
@@ -0,0 +1,261 @@
+// Package fluent ships the data of a diode to fluentd or fluent-bit using the
+// Fluent forward protocol.
+package fluent
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Record is the body of a Fluent event. The values may be strings, byte
+// slices, integers, floats, bools, nil, times, slices and maps of those.
+type Record map[string]interface{}
+
+// Entry is a single Fluent event.
+type Entry struct {
+	Time   time.Time
+	Record Record
+}
+
+// Forwarder buffers entries in a diode and sends them to a Fluent server in
+// forward mode, one message per batch. A slow or unavailable server makes
+// the diode drop entries instead of blocking the writers, and a batch that
+// fails to be sent is counted and discarded.
+type Forwarder struct {
+	sent   uint64
+	failed uint64
+
+	network string
+	addr    string
+	tag     string
+	entry   func(diodes.GenericDataType) Entry
+	acks    bool
+	timeout time.Duration
+	size    int
+	flush   time.Duration
+	w       *diodes.BatchWriter
+	dial    func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// conn, r and buf are only used by the go-routine of Run.
+	conn net.Conn
+	r    *bufio.Reader
+	buf  []byte
+}
+
+// ForwarderStats are the counters of a Forwarder. The drops of the diode are
+// found in the diode's Stats.
+type ForwarderStats struct {
+	// Sent is the number of entries sent (and acknowledged with WithAcks).
+	Sent uint64
+
+	// Failed is the number of entries of the batches that failed to be sent.
+	Failed uint64
+}
+
+// ForwarderOption can be used to setup the forwarder.
+type ForwarderOption func(*Forwarder)
+
+// WithEntry sets the function that converts the data of the diode to an
+// entry. By default the values of the diode must be *[]byte, such as the
+// ones set by a diodes.Writer, and are sent as the "message" of a record at
+// the time they are read.
+func WithEntry(f func(diodes.GenericDataType) Entry) ForwarderOption {
+	return ForwarderOption(func(fw *Forwarder) {
+		fw.entry = f
+	})
+}
+
+// WithAcks requests an acknowledgement for every batch. A batch is only
+// counted as sent once the server acknowledged it.
+func WithAcks() ForwarderOption {
+	return ForwarderOption(func(f *Forwarder) {
+		f.acks = true
+	})
+}
+
+// WithTimeout sets the time to connect, to send a batch and to wait for its
+// acknowledgement. The default is 5s.
+func WithTimeout(d time.Duration) ForwarderOption {
+	return ForwarderOption(func(f *Forwarder) {
+		f.timeout = d
+	})
+}
+
+// WithBatchSize sets the number of entries at which a batch is sent. The
+// default is 100.
+func WithBatchSize(size int) ForwarderOption {
+	return ForwarderOption(func(f *Forwarder) {
+		f.size = size
+	})
+}
+
+// WithFlushInterval sets the longest time a partial batch waits before it is
+// sent. The default is 1s.
+func WithFlushInterval(interval time.Duration) ForwarderOption {
+	return ForwarderOption(func(f *Forwarder) {
+		f.flush = interval
+	})
+}
+
+// NewForwarder returns a new Forwarder that stores the data in the given
+// diode and sends it with the tag to the server. The network and address
+// are those of net.Dial, e.g. "tcp" and "localhost:24224".
+func NewForwarder(network, addr, tag string, d diodes.Diode, opts ...ForwarderOption) *Forwarder {
+	f := &Forwarder{
+		network: network,
+		addr:    addr,
+		tag:     tag,
+		entry:   messageEntry,
+		timeout: 5 * time.Second,
+		size:    100,
+		flush:   time.Second,
+		dial:    new(net.Dialer).DialContext,
+	}
+
+	for _, o := range opts {
+		o(f)
+	}
+
+	f.w = diodes.NewBatchWriter(d, f.send,
+		diodes.WithBatchSize(f.size),
+		diodes.WithFlushInterval(f.flush),
+	)
+
+	return f
+}
+
+// Set sets the data on the diode. It never blocks.
+func (f *Forwarder) Set(data diodes.GenericDataType) {
+	f.w.Set(data)
+}
+
+// Run sends the batches until the context is done. The data that is left in
+// the diode is then sent as well. Run must only be invoked once as it is the
+// reader of the diode.
+func (f *Forwarder) Run(ctx context.Context) {
+	defer f.close()
+
+	f.w.Run(ctx)
+}
+
+// Stats returns the counters of the forwarder. It is safe to call from any
+// go-routine.
+func (f *Forwarder) Stats() ForwarderStats {
+	return ForwarderStats{
+		Sent:   atomic.LoadUint64(&f.sent),
+		Failed: atomic.LoadUint64(&f.failed),
+	}
+}
+
+func messageEntry(data diodes.GenericDataType) Entry {
+	return Entry{
+		Time:   time.Now(),
+		Record: Record{"message": *(*[]byte)(data)},
+	}
+}
+
+var errAck = errors.New("fluent: unexpected ack")
+
+// send is the sink of the batch writer. A failed batch closes the
+// connection, the next batch connects again.
+func (f *Forwarder) send(batch []diodes.GenericDataType) {
+	if err := f.write(batch); err != nil {
+		f.close()
+		atomic.AddUint64(&f.failed, uint64(len(batch)))
+		return
+	}
+
+	atomic.AddUint64(&f.sent, uint64(len(batch)))
+}
+
+func (f *Forwarder) write(batch []diodes.GenericDataType) error {
+	if f.conn == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+		defer cancel()
+
+		conn, err := f.dial(ctx, f.network, f.addr)
+		if err != nil {
+			return err
+		}
+		f.conn = conn
+		f.r = bufio.NewReader(conn)
+	}
+
+	if err := f.conn.SetDeadline(time.Now().Add(f.timeout)); err != nil {
+		return err
+	}
+
+	msg, chunk := f.encode(batch)
+	if _, err := f.conn.Write(msg); err != nil {
+		return err
+	}
+
+	if !f.acks {
+		return nil
+	}
+
+	ack, err := readAck(f.r)
+	if err != nil {
+		return err
+	}
+	if ack != chunk {
+		return errAck
+	}
+
+	return nil
+}
+
+// encode renders a batch as a message in forward mode:
+// [tag, [[time, record], ...], {"size": n, "chunk": id}].
+func (f *Forwarder) encode(batch []diodes.GenericDataType) ([]byte, string) {
+	b := f.buf[:0]
+	b = appendArrayHeader(b, 3)
+	b = appendString(b, f.tag)
+
+	b = appendArrayHeader(b, len(batch))
+	for _, data := range batch {
+		e := f.entry(data)
+		b = appendArrayHeader(b, 2)
+		b = appendEventTime(b, e.Time)
+		b = appendMap(b, e.Record)
+	}
+
+	var chunk string
+	if f.acks {
+		chunk = newChunkID()
+		b = appendMapHeader(b, 2)
+		b = appendString(b, "chunk")
+		b = appendString(b, chunk)
+	} else {
+		b = appendMapHeader(b, 1)
+	}
+	b = appendString(b, "size")
+	b = appendInt(b, int64(len(batch)))
+	f.buf = b
+
+	return b, chunk
+}
+
+func (f *Forwarder) close() {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+		f.r = nil
+	}
+}
+
+// newChunkID returns a random chunk ID as recommended by the protocol.
+func newChunkID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+
+	return base64.StdEncoding.EncodeToString(id[:])
+}
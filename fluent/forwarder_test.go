@@ -0,0 +1,176 @@
+package fluent_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/fluent"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forwarder", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		l      net.Listener
+		conns  chan net.Conn
+		ts     time.Time
+	)
+
+	entry := func(data diodes.GenericDataType) fluent.Entry {
+		return fluent.Entry{
+			Time:   ts,
+			Record: fluent.Record{"message": *(*[]byte)(data)},
+		}
+	}
+
+	set := func(f *fluent.Forwarder, msg string) {
+		b := []byte(msg)
+		f.Set(diodes.GenericDataType(&b))
+	}
+
+	// message is the forward mode encoding of a batch with a single "hi"
+	// message and no chunk.
+	message := func() []byte {
+		b := []byte{0x93, 0xa3, 'a', 'p', 'p', 0x91, 0x92, 0xd7, 0x00}
+		b = append(b, 0x00, 0x00, 0x00, 0x64, 0x00, 0x00, 0x00, 0x07)
+		b = append(b, 0x81, 0xa7, 'm', 'e', 's', 's', 'a', 'g', 'e', 0xa2, 'h', 'i')
+		return append(b, 0x81, 0xa4, 's', 'i', 'z', 'e', 0x01)
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		ts = time.Unix(100, 7)
+
+		var err error
+		l, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		conns = make(chan net.Conn, 4)
+		go func(l net.Listener) {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				conns <- conn
+			}
+		}(l)
+	})
+
+	AfterEach(func() {
+		cancel()
+		l.Close()
+	})
+
+	It("sends the batches in forward mode", func() {
+		f := fluent.NewForwarder("tcp", l.Addr().String(), "app", diodes.NewOneToOne(16, nil),
+			fluent.WithEntry(entry),
+			fluent.WithBatchSize(1),
+		)
+		go f.Run(ctx)
+		set(f, "hi")
+
+		var conn net.Conn
+		Eventually(conns).Should(Receive(&conn))
+		defer conn.Close()
+
+		expected := message()
+		msg := make([]byte, len(expected))
+		_, err := io.ReadFull(conn, msg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(msg).To(Equal(expected))
+		Eventually(f.Stats).Should(Equal(fluent.ForwarderStats{Sent: 1}))
+	})
+
+	It("waits for the acknowledgement of a batch", func() {
+		f := fluent.NewForwarder("tcp", l.Addr().String(), "app", diodes.NewOneToOne(16, nil),
+			fluent.WithEntry(entry),
+			fluent.WithBatchSize(1),
+			fluent.WithAcks(),
+		)
+		go f.Run(ctx)
+		set(f, "hi")
+
+		var conn net.Conn
+		Eventually(conns).Should(Receive(&conn))
+		defer conn.Close()
+
+		chunk := readChunk(bufio.NewReader(conn))
+		Consistently(f.Stats).Should(Equal(fluent.ForwarderStats{}))
+
+		ack := append([]byte{0x81, 0xa3, 'a', 'c', 'k', 0xb8}, chunk...)
+		_, err := conn.Write(ack)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(f.Stats).Should(Equal(fluent.ForwarderStats{Sent: 1}))
+	})
+
+	It("counts the entries of batches that are not acknowledged", func() {
+		f := fluent.NewForwarder("tcp", l.Addr().String(), "app", diodes.NewOneToOne(16, nil),
+			fluent.WithBatchSize(1),
+			fluent.WithAcks(),
+			fluent.WithTimeout(10*time.Millisecond),
+		)
+		go f.Run(ctx)
+		set(f, "hi")
+
+		Eventually(f.Stats).Should(Equal(fluent.ForwarderStats{Failed: 1}))
+	})
+
+	It("connects again after a failed batch", func() {
+		addr := l.Addr().String()
+		l.Close()
+
+		f := fluent.NewForwarder("tcp", addr, "app", diodes.NewOneToOne(16, nil),
+			fluent.WithEntry(entry),
+			fluent.WithBatchSize(1),
+		)
+		go f.Run(ctx)
+		set(f, "hi")
+		Eventually(f.Stats).Should(Equal(fluent.ForwarderStats{Failed: 1}))
+
+		var err error
+		l, err = net.Listen("tcp", addr)
+		Expect(err).ToNot(HaveOccurred())
+		go func(l net.Listener) {
+			conn, err := l.Accept()
+			if err == nil {
+				conns <- conn
+			}
+		}(l)
+		set(f, "hi")
+
+		var conn net.Conn
+		Eventually(conns).Should(Receive(&conn))
+		defer conn.Close()
+
+		expected := message()
+		msg := make([]byte, len(expected))
+		_, err = io.ReadFull(conn, msg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(msg).To(Equal(expected))
+	})
+})
+
+// readChunk reads a message and returns its chunk ID, which is the 24
+// character string that follows the "chunk" key of the options.
+func readChunk(r *bufio.Reader) []byte {
+	var msg []byte
+	key := []byte{0xa5, 'c', 'h', 'u', 'n', 'k', 0xb8}
+	for {
+		b, err := r.ReadByte()
+		Expect(err).ToNot(HaveOccurred())
+		msg = append(msg, b)
+
+		if i := bytes.Index(msg, key); i >= 0 && len(msg) >= i+len(key)+24 {
+			return msg[i+len(key) : i+len(key)+24]
+		}
+	}
+}
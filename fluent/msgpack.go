@@ -0,0 +1,235 @@
+package fluent
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// The msgpack encoding covers the types used by Fluent records. Values of
+// other types are encoded as their fmt.Sprint string.
+
+func appendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return appendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xde), uint16(n))
+	default:
+		return appendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+
+	return append(b, s...)
+}
+
+func appendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i < 128:
+		return append(b, byte(i))
+	case i < 0 && i >= -32:
+		return append(b, byte(i))
+	default:
+		return appendUint64(append(b, 0xd3), uint64(i))
+	}
+}
+
+func appendUint(b []byte, u uint64) []byte {
+	if u < 128 {
+		return append(b, byte(u))
+	}
+
+	return appendUint64(append(b, 0xcf), u)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+// appendEventTime appends the EventTime extension of the forward protocol,
+// which keeps the nanoseconds of a time.
+func appendEventTime(b []byte, t time.Time) []byte {
+	b = append(b, 0xd7, 0x00)
+	b = appendUint32(b, uint32(t.Unix()))
+	return appendUint32(b, uint32(t.Nanosecond()))
+}
+
+func appendValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if v {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case int:
+		return appendInt(b, int64(v))
+	case int64:
+		return appendInt(b, v)
+	case uint64:
+		return appendUint(b, v)
+	case float64:
+		return appendUint64(append(b, 0xcb), math.Float64bits(v))
+	case string:
+		return appendString(b, v)
+	case []byte:
+		return appendString(b, string(v))
+	case time.Time:
+		return appendEventTime(b, v)
+	case Record:
+		return appendMap(b, v)
+	case map[string]interface{}:
+		return appendMap(b, v)
+	case []interface{}:
+		b = appendArrayHeader(b, len(v))
+		for _, e := range v {
+			b = appendValue(b, e)
+		}
+		return b
+	default:
+		return appendString(b, fmt.Sprint(v))
+	}
+}
+
+// appendMap appends a map with sorted keys so that the encoding of a record
+// is deterministic.
+func appendMap(b []byte, m map[string]interface{}) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b = appendMapHeader(b, len(m))
+	for _, k := range keys {
+		b = appendString(b, k)
+		b = appendValue(b, m[k])
+	}
+
+	return b
+}
+
+var errUnexpectedType = errors.New("fluent: unexpected msgpack type")
+
+// readAck reads the response of the server to a chunk, which is a map of
+// strings such as {"ack": "<chunk>"}, and returns the value of "ack".
+func readAck(r *bufio.Reader) (string, error) {
+	n, err := readMapHeader(r)
+	if err != nil {
+		return "", err
+	}
+
+	var ack string
+	for i := 0; i < n; i++ {
+		k, err := readString(r)
+		if err != nil {
+			return "", err
+		}
+		v, err := readString(r)
+		if err != nil {
+			return "", err
+		}
+
+		if k == "ack" {
+			ack = v
+		}
+	}
+
+	return ack, nil
+}
+
+func readMapHeader(r *bufio.Reader) (int, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case c&0xf0 == 0x80:
+		return int(c & 0x0f), nil
+	case c == 0xde:
+		return readLength(r, 2)
+	case c == 0xdf:
+		return readLength(r, 4)
+	default:
+		return 0, errUnexpectedType
+	}
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	var n int
+	switch {
+	case c&0xe0 == 0xa0:
+		n = int(c & 0x1f)
+	case c == 0xd9:
+		n, err = readLength(r, 1)
+	case c == 0xda:
+		n, err = readLength(r, 2)
+	case c == 0xdb:
+		n, err = readLength(r, 4)
+	default:
+		return "", errUnexpectedType
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s := make([]byte, n)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+
+	return string(s), nil
+}
+
+func readLength(r *bufio.Reader, size int) (int, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[4-size:]); err != nil {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint32(b[:])), nil
+}
@@ -0,0 +1,62 @@
+package diodes
+
+import "sync/atomic"
+
+// FrameReader reads fixed size frames from a diode, e.g. for audio or
+// control-loop callbacks that must hand over exactly N items per invocation.
+// Reading a frame does not allocate and does a bounded amount of work: at
+// most one TryNext per item of the frame.
+type FrameReader struct {
+	underruns uint64
+
+	d       Diode
+	padding GenericDataType
+}
+
+// FrameReaderOption can be used to setup the frame reader.
+type FrameReaderOption func(*FrameReader)
+
+// WithPadding sets the value that fills the rest of a frame when the diode
+// runs out of data. The default is nil.
+func WithPadding(data GenericDataType) FrameReaderOption {
+	return FrameReaderOption(func(r *FrameReader) {
+		r.padding = data
+	})
+}
+
+// NewFrameReader returns a new FrameReader that reads from the given diode.
+func NewFrameReader(d Diode, opts ...FrameReaderOption) *FrameReader {
+	r := &FrameReader{d: d}
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// ReadFrame fills the frame with the next len(frame) items of the diode and
+// returns how many items it read. When the diode runs out of data first, it
+// is an underrun: the rest of the frame is filled with the padding and the
+// underrun is counted.
+func (r *FrameReader) ReadFrame(frame []GenericDataType) int {
+	for i := range frame {
+		data, ok := r.d.TryNext()
+		if !ok {
+			for j := i; j < len(frame); j++ {
+				frame[j] = r.padding
+			}
+			atomic.AddUint64(&r.underruns, 1)
+			return i
+		}
+
+		frame[i] = data
+	}
+
+	return len(frame)
+}
+
+// Underruns returns the number of frames that could not be filled from the
+// diode. It is safe to call from any go-routine.
+func (r *FrameReader) Underruns() uint64 {
+	return atomic.LoadUint64(&r.underruns)
+}
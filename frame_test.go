@@ -0,0 +1,78 @@
+package diodes_test
+
+import (
+	"testing"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FrameReader", func() {
+	var (
+		d       *diodes.OneToOne
+		samples []int
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(16, nil)
+		samples = []int{1, 2, 3, 4, 5}
+		for i := range samples {
+			d.Set(diodes.GenericDataType(&samples[i]))
+		}
+	})
+
+	values := func(frame []diodes.GenericDataType) []int {
+		var v []int
+		for _, data := range frame {
+			if data == nil {
+				v = append(v, 0)
+				continue
+			}
+			v = append(v, *(*int)(data))
+		}
+		return v
+	}
+
+	It("fills the frame from the diode", func() {
+		r := diodes.NewFrameReader(d)
+		frame := make([]diodes.GenericDataType, 3)
+
+		Expect(r.ReadFrame(frame)).To(Equal(3))
+		Expect(values(frame)).To(Equal([]int{1, 2, 3}))
+		Expect(r.Underruns()).To(BeZero())
+	})
+
+	It("pads the frame and counts an underrun", func() {
+		r := diodes.NewFrameReader(d)
+		frame := make([]diodes.GenericDataType, 3)
+		r.ReadFrame(frame)
+
+		Expect(r.ReadFrame(frame)).To(Equal(2))
+		Expect(values(frame)).To(Equal([]int{4, 5, 0}))
+		Expect(r.Underruns()).To(Equal(uint64(1)))
+	})
+
+	It("pads with the given value", func() {
+		silence := -1
+		r := diodes.NewFrameReader(d, diodes.WithPadding(diodes.GenericDataType(&silence)))
+		frame := make([]diodes.GenericDataType, 8)
+
+		Expect(r.ReadFrame(frame)).To(Equal(5))
+		Expect(values(frame)).To(Equal([]int{1, 2, 3, 4, 5, -1, -1, -1}))
+	})
+
+	It("does not allocate", func() {
+		// The Seqlock implementation does not allocate on writes either.
+		d = diodes.NewOneToOne(16, nil, diodes.WithImplementation(diodes.Seqlock))
+		r := diodes.NewFrameReader(d)
+		frame := make([]diodes.GenericDataType, 4)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			d.Set(diodes.GenericDataType(&samples[0]))
+			r.ReadFrame(frame)
+		})
+		Expect(allocs).To(BeZero())
+	})
+})
@@ -0,0 +1,101 @@
+package framing
+
+import (
+	"io"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// NewCodec returns a Codec of the given functions, such as to encode the
+// values of a diode as protobuf or msgpack messages with the library of the
+// application.
+func NewCodec(marshal func(diodes.GenericDataType) ([]byte, error), unmarshal func([]byte) (diodes.GenericDataType, error)) diodes.Codec {
+	return codec{marshal: marshal, unmarshal: unmarshal}
+}
+
+type codec struct {
+	marshal   func(diodes.GenericDataType) ([]byte, error)
+	unmarshal func([]byte) (diodes.GenericDataType, error)
+}
+
+func (c codec) Marshal(data diodes.GenericDataType) ([]byte, error) {
+	return c.marshal(data)
+}
+
+func (c codec) Unmarshal(b []byte) (diodes.GenericDataType, error) {
+	return c.unmarshal(b)
+}
+
+// Encoder writes values as frames to a stream, marshaled by a Codec. The
+// frames are numbered from zero. It is not safe for concurrent use.
+type Encoder struct {
+	w   io.Writer
+	c   diodes.Codec
+	seq uint64
+	buf []byte
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer, c diodes.Codec) *Encoder {
+	return &Encoder{w: w, c: c}
+}
+
+// Encode marshals the data and writes its frame. A value that fails to be
+// marshaled is skipped like a dropped one, so the reader observes the gap.
+func (e *Encoder) Encode(data diodes.GenericDataType) error {
+	seq := e.seq
+	e.seq++
+
+	b, err := e.c.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	e.buf = Append(e.buf[:0], seq, b)
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+// Skip numbers the next n frames as lost, such as for the values a diode
+// dropped before they were encoded.
+func (e *Encoder) Skip(n int) {
+	e.seq += uint64(n)
+}
+
+// Decoder reads the frames of an Encoder from a stream and unmarshals them.
+// It is not safe for concurrent use.
+type Decoder struct {
+	r       io.Reader
+	c       diodes.Codec
+	maxSize int
+	next    uint64
+}
+
+// NewDecoder returns a new Decoder that reads from r. Frames larger than
+// maxSize fail with ErrTooLarge.
+func NewDecoder(r io.Reader, c diodes.Codec, maxSize int) *Decoder {
+	return &Decoder{r: r, c: c, maxSize: maxSize}
+}
+
+// Decode reads and unmarshals the next value. It returns the number of
+// values that were lost before it along with it, as told by the gap in the
+// numbers of the frames. It returns io.EOF at the end of the stream.
+func (d *Decoder) Decode() (diodes.GenericDataType, int, error) {
+	seq, b, err := Read(d.r, d.maxSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var missed int
+	if seq > d.next {
+		missed = int(seq - d.next)
+	}
+	d.next = seq + 1
+
+	data, err := d.c.Unmarshal(b)
+	if err != nil {
+		return nil, missed, err
+	}
+
+	return data, missed, nil
+}
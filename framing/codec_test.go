@@ -0,0 +1,85 @@
+package framing_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Codec", func() {
+	var (
+		codec diodes.Codec
+		buf   *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		// The ints are encoded as text in place of a protobuf or msgpack
+		// library.
+		codec = framing.NewCodec(
+			func(data diodes.GenericDataType) ([]byte, error) {
+				i := *(*int)(data)
+				if i < 0 {
+					return nil, errors.New("negative")
+				}
+				return []byte(strconv.Itoa(i)), nil
+			},
+			func(b []byte) (diodes.GenericDataType, error) {
+				i, err := strconv.Atoi(string(b))
+				return diodes.GenericDataType(&i), err
+			},
+		)
+		buf = &bytes.Buffer{}
+	})
+
+	encode := func(e *framing.Encoder, i int) error {
+		return e.Encode(diodes.GenericDataType(&i))
+	}
+
+	It("decodes what the encoder encoded", func() {
+		e := framing.NewEncoder(buf, codec)
+		Expect(encode(e, 1)).To(Succeed())
+		Expect(encode(e, 22)).To(Succeed())
+
+		d := framing.NewDecoder(buf, codec, 64)
+		data, missed, err := d.Decode()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*(*int)(data)).To(Equal(1))
+		Expect(missed).To(BeZero())
+
+		data, _, err = d.Decode()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*(*int)(data)).To(Equal(22))
+
+		_, _, err = d.Decode()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("reports the values that were skipped or failed to be marshaled", func() {
+		e := framing.NewEncoder(buf, codec)
+		Expect(encode(e, 1)).To(Succeed())
+		e.Skip(2)
+		Expect(encode(e, -1)).ToNot(Succeed())
+		Expect(encode(e, 2)).To(Succeed())
+
+		d := framing.NewDecoder(buf, codec, 64)
+		d.Decode()
+		data, missed, err := d.Decode()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*(*int)(data)).To(Equal(2))
+		Expect(missed).To(Equal(3))
+	})
+
+	It("returns the error of the codec", func() {
+		framing.NewEncoder(buf, diodes.BytesCodec{}).Encode(diodes.GenericDataType(&[]byte{'x'}))
+
+		_, _, err := framing.NewDecoder(buf, codec, 64).Decode()
+		Expect(err).To(HaveOccurred())
+	})
+})
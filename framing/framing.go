@@ -0,0 +1,96 @@
+// Package framing provides the length-prefixed frames the byte transports of
+// the diodes share, such as the uds, mirror and udp packages and the spill
+// files, so that the payloads are encoded alike and corruption is detected by
+// a checksum.
+package framing
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// A frame is a header that is followed by the payload:
+//
+//	header: length uint32 | crc uint32 | seq uint64
+//
+// The crc is the CRC-32 (IEEE) of the payload. The seq numbers the payloads of
+// a stream, including the ones that were dropped, so that a reader tells
+// from a gap how many were lost on the way.
+const HeaderSize = 16
+
+var (
+	// ErrTooLarge is returned by Read for a frame that is larger than the
+	// maximum size.
+	ErrTooLarge = errors.New("framing: frame exceeds the maximum size")
+
+	// ErrChecksum is returned by Read and Parse for a frame whose payload
+	// does not match its checksum.
+	ErrChecksum = errors.New("framing: checksum mismatch")
+)
+
+// Append appends the frame of the payload to b and returns the extended
+// buffer.
+func Append(b []byte, seq uint64, payload []byte) []byte {
+	var header [HeaderSize]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint64(header[8:], seq)
+
+	b = append(b, header[:]...)
+	return append(b, payload...)
+}
+
+// Read reads the next frame of a stream. It returns io.EOF when the stream
+// ends between two frames and io.ErrUnexpectedEOF when it ends within one.
+// A payload larger than maxSize is not read and ErrTooLarge is returned. A
+// frame that fails its checksum is returned along with ErrChecksum, so that
+// the caller can tell which one it was; the stream cannot be trusted past it
+// as its length may be corrupt too.
+func Read(r io.Reader, maxSize int) (uint64, []byte, error) {
+	var header [HeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[:])
+	if int64(length) > int64(maxSize) {
+		return 0, nil, ErrTooLarge
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+
+	return check(header[:], payload)
+}
+
+// Parse parses a buffer that holds a single frame, such as a datagram. The
+// payload refers to b. It returns io.ErrUnexpectedEOF for a buffer that is
+// too short for the frame and ErrChecksum for a corrupt one.
+func Parse(b []byte) (uint64, []byte, error) {
+	if len(b) < HeaderSize {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+
+	length := binary.LittleEndian.Uint32(b)
+	if int64(length) > int64(len(b)-HeaderSize) {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+
+	return check(b[:HeaderSize], b[HeaderSize:HeaderSize+int(length)])
+}
+
+func check(header, payload []byte) (uint64, []byte, error) {
+	seq := binary.LittleEndian.Uint64(header[8:])
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+		return seq, payload, ErrChecksum
+	}
+
+	return seq, payload, nil
+}
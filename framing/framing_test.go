@@ -0,0 +1,94 @@
+package framing_test
+
+import (
+	"bytes"
+	"io"
+
+	"code.cloudfoundry.org/go-diodes/framing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Framing", func() {
+	Describe("Read()", func() {
+		It("reads the frames in order", func() {
+			b := framing.Append(nil, 7, []byte("a"))
+			b = framing.Append(b, 8, []byte("bc"))
+			r := bytes.NewReader(b)
+
+			seq, payload, err := framing.Read(r, 16)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(seq).To(Equal(uint64(7)))
+			Expect(payload).To(Equal([]byte("a")))
+
+			seq, payload, err = framing.Read(r, 16)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(seq).To(Equal(uint64(8)))
+			Expect(payload).To(Equal([]byte("bc")))
+
+			_, _, err = framing.Read(r, 16)
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("reads an empty payload", func() {
+			_, payload, err := framing.Read(bytes.NewReader(framing.Append(nil, 0, nil)), 16)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payload).To(BeEmpty())
+		})
+
+		It("fails for a stream that ends within a frame", func() {
+			b := framing.Append(nil, 0, []byte("abc"))
+
+			_, _, err := framing.Read(bytes.NewReader(b[:len(b)-1]), 16)
+			Expect(err).To(Equal(io.ErrUnexpectedEOF))
+
+			_, _, err = framing.Read(bytes.NewReader(b[:framing.HeaderSize-1]), 16)
+			Expect(err).To(Equal(io.ErrUnexpectedEOF))
+		})
+
+		It("fails for a frame that is larger than the maximum size", func() {
+			b := framing.Append(nil, 0, []byte("abc"))
+
+			_, _, err := framing.Read(bytes.NewReader(b), 2)
+			Expect(err).To(MatchError(framing.ErrTooLarge))
+		})
+
+		It("detects a corrupt payload", func() {
+			b := framing.Append(nil, 3, []byte("abc"))
+			b[framing.HeaderSize] ^= 0xff
+
+			seq, payload, err := framing.Read(bytes.NewReader(b), 16)
+			Expect(err).To(MatchError(framing.ErrChecksum))
+			Expect(seq).To(Equal(uint64(3)))
+			Expect(payload).To(HaveLen(3))
+		})
+	})
+
+	Describe("Parse()", func() {
+		It("parses a single frame", func() {
+			seq, payload, err := framing.Parse(framing.Append(nil, 5, []byte("abc")))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(seq).To(Equal(uint64(5)))
+			Expect(payload).To(Equal([]byte("abc")))
+		})
+
+		It("fails for a truncated frame", func() {
+			b := framing.Append(nil, 5, []byte("abc"))
+
+			_, _, err := framing.Parse(b[:len(b)-1])
+			Expect(err).To(Equal(io.ErrUnexpectedEOF))
+
+			_, _, err = framing.Parse(b[:3])
+			Expect(err).To(Equal(io.ErrUnexpectedEOF))
+		})
+
+		It("detects a corrupt payload", func() {
+			b := framing.Append(nil, 5, []byte("abc"))
+			b[len(b)-1] ^= 0xff
+
+			_, _, err := framing.Parse(b)
+			Expect(err).To(MatchError(framing.ErrChecksum))
+		})
+	})
+})
@@ -0,0 +1,85 @@
+package diodes_test
+
+import (
+	"bytes"
+	"testing"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/diodetest"
+)
+
+func FuzzOneToOne(f *testing.F) {
+	diodetest.FuzzDiode(f, func(size int, a diodes.Alerter) diodes.Diode {
+		return diodes.NewOneToOne(size, a)
+	})
+}
+
+func FuzzOneToOneSeqlock(f *testing.F) {
+	diodetest.FuzzDiode(f, func(size int, a diodes.Alerter) diodes.Diode {
+		return diodes.NewOneToOne(size, a, diodes.WithImplementation(diodes.Seqlock))
+	})
+}
+
+func FuzzManyToOne(f *testing.F) {
+	diodetest.FuzzDiode(f, func(size int, a diodes.Alerter) diodes.Diode {
+		return diodes.NewManyToOne(size, a)
+	})
+}
+
+func FuzzManyToOneSeqlock(f *testing.F) {
+	diodetest.FuzzDiode(f, func(size int, a diodes.Alerter) diodes.Diode {
+		return diodes.NewManyToOne(size, a, diodes.WithImplementation(diodes.Seqlock))
+	})
+}
+
+func FuzzStatsUnread(f *testing.F) {
+	f.Add(uint64(3), uint64(2), uint64(3), uint16(4))
+	f.Add(uint64(0), uint64(0), uint64(9), uint16(4))
+	f.Add(uint64(7), uint64(0), uint64(0), uint16(1))
+
+	f.Fuzz(func(t *testing.T, reads, drops, lag uint64, capacity uint16) {
+		s := diodes.Stats{Reads: reads, Drops: drops, Lag: lag, Capacity: int(capacity)}
+		l, ok := s.Unread()
+		if ok != (s.Occupancy() > 0) {
+			t.Fatalf("Unread of %+v reports %v", s, ok)
+		}
+		if !ok {
+			return
+		}
+
+		if l.Len() != s.Occupancy() || l.Last+1 != reads+drops+lag {
+			t.Fatalf("Unread of %+v is %+v", s, l)
+		}
+	})
+}
+
+func FuzzLoadFrom(f *testing.F) {
+	for _, n := range []int{0, 1, 3} {
+		d := diodes.NewOneToOne(4, nil)
+		for i := 0; i < n; i++ {
+			b := []byte{byte(i), byte(i + 1)}
+			d.Set(diodes.GenericDataType(&b))
+		}
+
+		var buf bytes.Buffer
+		if err := diodes.SaveTo(&buf, d, diodes.BytesCodec{}); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, snapshot []byte) {
+		d := diodes.NewOneToOne(4, nil)
+		if err := diodes.LoadFrom(bytes.NewReader(snapshot), d, diodes.BytesCodec{}); err != nil {
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := diodes.SaveTo(&buf, d, diodes.BytesCodec{}); err != nil {
+			t.Fatalf("saving a loaded snapshot failed: %s", err)
+		}
+		if err := diodes.LoadFrom(&buf, diodes.NewOneToOne(4, nil), diodes.BytesCodec{}); err != nil {
+			t.Fatalf("loading a saved snapshot failed: %s", err)
+		}
+	})
+}
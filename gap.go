@@ -0,0 +1,26 @@
+package diodes
+
+// Gap describes the values that were overwritten before the reader reached
+// them, such as for a consumer to annotate its output with a precise loss
+// marker. The values are numbered by their write index, counting from zero
+// or from WithStartIndex.
+type Gap struct {
+	// Missed is the number of values that were skipped. It is zero when
+	// nothing was skipped.
+	Missed uint64
+
+	// First and Last are the sequence numbers of the first and the last
+	// value that were skipped. They are zero when nothing was skipped.
+	First uint64
+	Last  uint64
+}
+
+// newGap returns the gap between the read index and the next value the
+// reader reads, or the zero Gap if there is none.
+func newGap(readIndex, next uint64) Gap {
+	if !seqAfter(next, readIndex) {
+		return Gap{}
+	}
+
+	return Gap{Missed: next - readIndex, First: readIndex, Last: next - 1}
+}
@@ -0,0 +1,108 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("TryNextGap", func(impl diodes.Implementation) {
+	set := func(d diodes.Diode, from, to int) {
+		for i := from; i < to; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("reports no gap while the reader keeps up", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl))
+		set(d, 0, 2)
+
+		data, gap, ok := d.TryNextGap()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(0))
+		Expect(gap).To(Equal(diodes.Gap{}))
+	})
+
+	It("reports the values a OneToOne overwrote before the read", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl))
+		set(d, 0, 10)
+
+		data, gap, ok := d.TryNextGap()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(8))
+		Expect(gap).To(Equal(diodes.Gap{Missed: 8, First: 0, Last: 7}))
+
+		_, gap, _ = d.TryNextGap()
+		Expect(gap).To(Equal(diodes.Gap{}))
+
+		set(d, 10, 15)
+		data, gap, _ = d.TryNextGap()
+		Expect(*(*int)(data)).To(Equal(14))
+		Expect(gap).To(Equal(diodes.Gap{Missed: 4, First: 10, Last: 13}))
+	})
+
+	It("reports the values a ManyToOne overwrote before the read", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl))
+		set(d, 0, 10)
+
+		_, gap, ok := d.TryNextGap()
+		Expect(ok).To(BeTrue())
+		Expect(gap).To(Equal(diodes.Gap{Missed: 8, First: 0, Last: 7}))
+	})
+
+	It("includes the values CatchUpLatest skipped", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl), diodes.WithCatchUp(diodes.CatchUpLatest))
+		set(d, 0, 10)
+
+		data, gap, _ := d.TryNextGap()
+		Expect(*(*int)(data)).To(Equal(9))
+		Expect(gap).To(Equal(diodes.Gap{Missed: 9, First: 0, Last: 8}))
+	})
+
+	It("returns false when the diode is empty", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl))
+
+		_, gap, ok := d.TryNextGap()
+		Expect(ok).To(BeFalse())
+		Expect(gap).To(Equal(diodes.Gap{}))
+	})
+})
+
+var _ = Describe("Segmented TryNextBatchGap", func() {
+	It("reports the items that were overwritten before the batch", func() {
+		d := diodes.NewSegmented(2, 2, nil)
+		for i := 0; i < 7; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+
+		batch, gap, ok := d.TryNextBatchGap(nil)
+		Expect(ok).To(BeTrue())
+		Expect(batch).To(HaveLen(2))
+		Expect(*(*int)(batch[0])).To(Equal(4))
+		Expect(gap).To(Equal(diodes.Gap{Missed: 4, First: 0, Last: 3}))
+
+		batch, gap, ok = d.TryNextBatchGap(nil)
+		Expect(ok).To(BeTrue())
+		Expect(batch).To(HaveLen(1))
+		Expect(gap).To(Equal(diodes.Gap{}))
+	})
+
+	It("reports the gap only once when the segment was partly read", func() {
+		d := diodes.NewSegmented(2, 2, nil)
+		for i := 0; i < 6; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+
+		_, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+
+		batch, gap, ok := d.TryNextBatchGap(nil)
+		Expect(ok).To(BeTrue())
+		Expect(batch).To(HaveLen(1))
+		Expect(gap).To(Equal(diodes.Gap{}))
+	})
+})
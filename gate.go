@@ -0,0 +1,54 @@
+package diodes
+
+import "sync/atomic"
+
+// Gate wraps a diode and can pause the writes to it, such as during a
+// config reload or while a downstream dependency asks to stop sending. While
+// the gate is paused, the values that are set are dropped and counted. The
+// reader keeps reading what is left in the diode.
+type Gate struct {
+	gated uint64
+
+	Diode
+	paused uint32
+}
+
+// NewGate returns a new Gate that wraps the given diode. The gate starts
+// open.
+func NewGate(d Diode) *Gate {
+	return &Gate{Diode: d}
+}
+
+// Set sets the data on the wrapped diode, unless the gate is paused.
+func (g *Gate) Set(data GenericDataType) {
+	if atomic.LoadUint32(&g.paused) != 0 {
+		atomic.AddUint64(&g.gated, 1)
+		return
+	}
+
+	g.Diode.Set(data)
+}
+
+// Pause stops the writes to the wrapped diode. A Set that runs concurrently
+// with Pause may still be passed. It is safe to call from any go-routine.
+func (g *Gate) Pause() {
+	atomic.StoreUint32(&g.paused, 1)
+}
+
+// Resume passes the writes to the wrapped diode again. It is safe to call
+// from any go-routine.
+func (g *Gate) Resume() {
+	atomic.StoreUint32(&g.paused, 0)
+}
+
+// Paused reports whether the gate is paused. It is safe to call from any
+// go-routine.
+func (g *Gate) Paused() bool {
+	return atomic.LoadUint32(&g.paused) != 0
+}
+
+// Gated returns the number of values that were set while the gate was
+// paused. It is safe to call from any go-routine.
+func (g *Gate) Gated() uint64 {
+	return atomic.LoadUint64(&g.gated)
+}
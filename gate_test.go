@@ -0,0 +1,65 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gate", func() {
+	var (
+		d *diodes.OneToOne
+		g *diodes.Gate
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(8, nil)
+		g = diodes.NewGate(d)
+	})
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			g.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func() []int {
+		var out []int
+		for {
+			data, ok := g.TryNext()
+			if !ok {
+				return out
+			}
+			out = append(out, *(*int)(data))
+		}
+	}
+
+	It("passes the values while it is open", func() {
+		set(1, 2)
+
+		Expect(g.Paused()).To(BeFalse())
+		Expect(readAll()).To(Equal([]int{1, 2}))
+	})
+
+	It("drops and counts the values while it is paused", func() {
+		set(1)
+		g.Pause()
+		set(2, 3)
+
+		Expect(g.Paused()).To(BeTrue())
+		Expect(g.Gated()).To(Equal(uint64(2)))
+		Expect(readAll()).To(Equal([]int{1}))
+	})
+
+	It("passes the values again once it is resumed", func() {
+		g.Pause()
+		set(1)
+		g.Resume()
+		set(2)
+
+		Expect(readAll()).To(Equal([]int{2}))
+		Expect(g.Gated()).To(Equal(uint64(1)))
+	})
+})
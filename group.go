@@ -0,0 +1,198 @@
+package diodes
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrBudgetExceeded is returned when a diode would take the capacity of a
+// Group over its budget.
+var ErrBudgetExceeded = errors.New("diodes: the budget of the group is exceeded")
+
+// ErrGroupClosed is returned when a diode is added to a closed Group.
+var ErrGroupClosed = errors.New("diodes: the group is closed")
+
+// Group creates and tracks a set of related diodes by name, such as one
+// diode per tailed source of an agent. The diodes share the size, the
+// alerter and a budget for their total capacity, their stats are
+// aggregated, and they are closed together.
+type Group struct {
+	size    int
+	budget  int
+	alerter func(name string, missed int)
+	opts    []DiodeConfigOption
+
+	mu       sync.Mutex
+	diodes   map[string]*ManyToOne
+	capacity int
+	closed   bool
+}
+
+// GroupOption can be used to setup the group.
+type GroupOption func(*Group)
+
+// WithGroupSize sets the size of the diodes of the group. The default is
+// 1024.
+func WithGroupSize(size int) GroupOption {
+	return GroupOption(func(g *Group) {
+		g.size = size
+	})
+}
+
+// WithGroupBudget sets the total capacity of the diodes of the group. A
+// diode that would exceed it is not added. By default there is no budget.
+func WithGroupBudget(budget int) GroupOption {
+	return GroupOption(func(g *Group) {
+		g.budget = budget
+	})
+}
+
+// WithGroupAlerter sets the function that is invoked with the name of a
+// diode when it drops data. It is invoked on the diode's reader go-routine.
+func WithGroupAlerter(fn func(name string, missed int)) GroupOption {
+	return GroupOption(func(g *Group) {
+		g.alerter = fn
+	})
+}
+
+// WithGroupDiodeOptions sets the options the diodes of the group are created
+// with.
+func WithGroupDiodeOptions(opts ...DiodeConfigOption) GroupOption {
+	return GroupOption(func(g *Group) {
+		g.opts = opts
+	})
+}
+
+// NewGroup returns a new Group without diodes.
+func NewGroup(opts ...GroupOption) *Group {
+	g := &Group{
+		size:   1024,
+		diodes: make(map[string]*ManyToOne),
+	}
+
+	for _, o := range opts {
+		o(g)
+	}
+
+	return g
+}
+
+// Add returns the diode with the given name, which is created if the group
+// does not have it yet. It returns ErrBudgetExceeded if the new diode would
+// exceed the budget, and ErrGroupClosed once the group is closed.
+func (g *Group) Add(name string) (*ManyToOne, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil, ErrGroupClosed
+	}
+	if d, ok := g.diodes[name]; ok {
+		return d, nil
+	}
+	if g.budget > 0 && g.capacity+g.size > g.budget {
+		return nil, ErrBudgetExceeded
+	}
+
+	var alerter Alerter
+	if g.alerter != nil {
+		alerter = AlertFunc(func(missed int) {
+			g.alerter(name, missed)
+		})
+	}
+
+	d := NewManyToOne(g.size, alerter, g.opts...)
+	g.diodes[name] = d
+	g.capacity += g.size
+
+	return d, nil
+}
+
+// Get returns the diode with the given name.
+func (g *Group) Get(name string) (*ManyToOne, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	d, ok := g.diodes[name]
+	return d, ok
+}
+
+// Remove closes the diode with the given name and removes it from the
+// group, which frees its capacity of the budget.
+func (g *Group) Remove(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	d, ok := g.diodes[name]
+	if !ok {
+		return
+	}
+
+	d.Close()
+	delete(g.diodes, name)
+	g.capacity -= g.size
+}
+
+// Names returns the names of the diodes of the group, in order.
+func (g *Group) Names() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	names := make([]string, 0, len(g.diodes))
+	for name := range g.diodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Stats returns the sum of the stats of the diodes of the group. It is safe
+// to call from any go-routine.
+func (g *Group) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var total Stats
+	for _, d := range g.diodes {
+		s := d.Stats()
+		total.Writes += s.Writes
+		total.Reads += s.Reads
+		total.Drops += s.Drops
+		total.Rejected += s.Rejected
+		total.Lag += s.Lag
+		total.Capacity += s.Capacity
+	}
+
+	return total
+}
+
+// DiodeStats returns the stats of every diode of the group by name. It is
+// safe to call from any go-routine.
+func (g *Group) DiodeStats() map[string]Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := make(map[string]Stats, len(g.diodes))
+	for name, d := range g.diodes {
+		stats[name] = d.Stats()
+	}
+
+	return stats
+}
+
+// Close closes every diode of the group. The diodes stay in the group so
+// that their readers can drain them, but no diodes can be added anymore.
+// It always returns nil.
+func (g *Group) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+	for _, d := range g.diodes {
+		d.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Group", func() {
+	set := func(d diodes.Diode, n int) {
+		for i := 0; i < n; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("creates a diode per name", func() {
+		g := diodes.NewGroup(diodes.WithGroupSize(4))
+
+		a, err := g.Add("a")
+		Expect(err).ToNot(HaveOccurred())
+		again, err := g.Add("a")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(again).To(BeIdenticalTo(a))
+		_, err = g.Add("b")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(g.Names()).To(Equal([]string{"a", "b"}))
+		d, ok := g.Get("b")
+		Expect(ok).To(BeTrue())
+		Expect(d.Stats().Capacity).To(Equal(4))
+	})
+
+	It("aggregates the stats of the diodes", func() {
+		g := diodes.NewGroup(diodes.WithGroupSize(4))
+		a, _ := g.Add("a")
+		b, _ := g.Add("b")
+		set(a, 2)
+		set(b, 3)
+		b.TryNext()
+
+		Expect(g.Stats()).To(Equal(diodes.Stats{Writes: 5, Reads: 1, Lag: 4, Capacity: 8}))
+		Expect(g.DiodeStats()).To(HaveKeyWithValue("a", diodes.Stats{Writes: 2, Lag: 2, Capacity: 4}))
+	})
+
+	It("does not exceed the budget", func() {
+		g := diodes.NewGroup(diodes.WithGroupSize(4), diodes.WithGroupBudget(10))
+		_, err := g.Add("a")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = g.Add("b")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = g.Add("c")
+		Expect(err).To(MatchError(diodes.ErrBudgetExceeded))
+
+		g.Remove("a")
+		_, err = g.Add("c")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("alerts with the name of the diode", func() {
+		var names []string
+		g := diodes.NewGroup(diodes.WithGroupSize(4), diodes.WithGroupAlerter(func(name string, missed int) {
+			names = append(names, name)
+		}))
+		b, _ := g.Add("b")
+		set(b, 6)
+		b.TryNext()
+
+		Expect(names).To(Equal([]string{"b"}))
+	})
+
+	It("closes the diodes together", func() {
+		g := diodes.NewGroup()
+		a, _ := g.Add("a")
+		b, _ := g.Add("b")
+
+		Expect(g.Close()).To(Succeed())
+		Expect(a.Closed()).To(BeTrue())
+		Expect(b.Closed()).To(BeTrue())
+
+		_, err := g.Add("c")
+		Expect(err).To(MatchError(diodes.ErrGroupClosed))
+	})
+})
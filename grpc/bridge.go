@@ -0,0 +1,157 @@
+// Package grpc pumps the data of a diode into a gRPC stream. It does not
+// depend on gRPC: the client and server streams of grpc-go satisfy Stream.
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Stream is the sending side of a gRPC stream, such as grpc.ClientStream or
+// grpc.ServerStream.
+type Stream interface {
+	SendMsg(m interface{}) error
+}
+
+// Bridge reads from a diode and sends the data on a gRPC stream. When a send
+// fails, the bridge opens a new stream and sends the message again. While it
+// reconnects, the diode drops data as usual, so the writers of the diode are
+// never blocked by the stream.
+type Bridge struct {
+	sent       uint64
+	sendErrors uint64
+	reconnects uint64
+
+	n       diodes.Nexter
+	open    func(context.Context) (Stream, error)
+	message func(diodes.GenericDataType) interface{}
+	backoff time.Duration
+
+	// stream and opened are only used by Run.
+	stream Stream
+	opened bool
+}
+
+// BridgeStats are the counters of a Bridge. The drops of the diode are found
+// in the diode's Stats.
+type BridgeStats struct {
+	// Sent is the number of messages sent.
+	Sent uint64
+
+	// SendErrors is the number of failed sends.
+	SendErrors uint64
+
+	// Reconnects is the number of attempts to open a stream after the first
+	// one.
+	Reconnects uint64
+}
+
+// BridgeOption can be used to setup the bridge.
+type BridgeOption func(*Bridge)
+
+// WithReconnectBackoff sets the time to wait between attempts to open a
+// stream. The default is 1s.
+func WithReconnectBackoff(d time.Duration) BridgeOption {
+	return BridgeOption(func(b *Bridge) {
+		b.backoff = d
+	})
+}
+
+// NewBridge returns a new Bridge that reads from the given Poller or Waiter.
+// The open function opens a stream, e.g. by invoking the streaming method of
+// a generated client. The message function converts the data of the diode to
+// the message that is sent, typically by casting it to the pointer type of
+// the message.
+func NewBridge(
+	n diodes.Nexter,
+	open func(context.Context) (Stream, error),
+	message func(diodes.GenericDataType) interface{},
+	opts ...BridgeOption,
+) *Bridge {
+	b := &Bridge{
+		n:       n,
+		open:    open,
+		message: message,
+		backoff: time.Second,
+	}
+
+	for _, o := range opts {
+		o(b)
+	}
+
+	return b
+}
+
+// Run sends data until the Poller or Waiter returns nil, or until the context
+// is done while a failed send is retried. A client stream is then closed with
+// CloseSend. Run waits for data in the Poller or Waiter, so for the context to
+// also stop an idle Run, the Poller or Waiter must be created with the same
+// context, such as with diodes.WithWaiterContext. Run must only be invoked
+// once.
+func (b *Bridge) Run(ctx context.Context) {
+	defer b.closeSend()
+
+	for {
+		data := b.n.Next()
+		if data == nil {
+			return
+		}
+
+		msg := b.message(data)
+		for !b.send(ctx, msg) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(b.backoff):
+			}
+		}
+	}
+}
+
+// Stats returns the counters of the bridge. It is safe to call from any
+// go-routine.
+func (b *Bridge) Stats() BridgeStats {
+	return BridgeStats{
+		Sent:       atomic.LoadUint64(&b.sent),
+		SendErrors: atomic.LoadUint64(&b.sendErrors),
+		Reconnects: atomic.LoadUint64(&b.reconnects),
+	}
+}
+
+// send sends a message and reports whether it succeeded. It opens a stream
+// if there is none.
+func (b *Bridge) send(ctx context.Context, msg interface{}) bool {
+	if b.stream == nil {
+		if b.opened {
+			atomic.AddUint64(&b.reconnects, 1)
+		}
+		b.opened = true
+
+		s, err := b.open(ctx)
+		if err != nil {
+			return false
+		}
+		b.stream = s
+	}
+
+	if err := b.stream.SendMsg(msg); err != nil {
+		atomic.AddUint64(&b.sendErrors, 1)
+		b.closeSend()
+		return false
+	}
+
+	atomic.AddUint64(&b.sent, 1)
+	return true
+}
+
+// closeSend closes a client stream. Server streams are closed by returning
+// from the handler.
+func (b *Bridge) closeSend() {
+	if c, ok := b.stream.(interface{ CloseSend() error }); ok {
+		_ = c.CloseSend()
+	}
+	b.stream = nil
+}
@@ -0,0 +1,158 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/grpc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bridge", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		w      *diodes.Waiter
+		opened chan *spyStream
+		open   func(context.Context) (grpc.Stream, error)
+	)
+
+	message := func(data diodes.GenericDataType) interface{} {
+		return *(*int)(data)
+	}
+
+	set := func(v int) {
+		w.Set(diodes.GenericDataType(&v))
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(16, nil), diodes.WithWaiterContext(ctx))
+		opened = make(chan *spyStream, 16)
+		open = func(context.Context) (grpc.Stream, error) {
+			s := new(spyStream)
+			opened <- s
+			return s, nil
+		}
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("sends the data on the stream", func() {
+		b := grpc.NewBridge(w, open, message)
+		go b.Run(ctx)
+		set(1)
+		set(2)
+
+		var s *spyStream
+		Eventually(opened).Should(Receive(&s))
+		Eventually(s.messages).Should(Equal([]interface{}{1, 2}))
+		Eventually(b.Stats).Should(Equal(grpc.BridgeStats{Sent: 2}))
+	})
+
+	It("opens a new stream and resends the message when a send fails", func() {
+		b := grpc.NewBridge(w, open, message, grpc.WithReconnectBackoff(time.Millisecond))
+		go b.Run(ctx)
+
+		set(1)
+		var first *spyStream
+		Eventually(opened).Should(Receive(&first))
+		Eventually(first.messages).Should(HaveLen(1))
+
+		first.fail()
+		set(2)
+
+		var second *spyStream
+		Eventually(opened).Should(Receive(&second))
+		Eventually(second.messages).Should(Equal([]interface{}{2}))
+		Expect(first.closed()).To(BeTrue())
+		Eventually(b.Stats).Should(Equal(grpc.BridgeStats{Sent: 2, SendErrors: 1, Reconnects: 1}))
+	})
+
+	It("retries to open a stream", func() {
+		var attempts int
+		failing := func(ctx context.Context) (grpc.Stream, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, errors.New("unavailable")
+			}
+			return open(ctx)
+		}
+		b := grpc.NewBridge(w, failing, message, grpc.WithReconnectBackoff(time.Millisecond))
+		go b.Run(ctx)
+		set(1)
+
+		var s *spyStream
+		Eventually(opened).Should(Receive(&s))
+		Eventually(s.messages).Should(Equal([]interface{}{1}))
+		Eventually(func() uint64 { return b.Stats().Reconnects }).Should(Equal(uint64(2)))
+	})
+
+	It("closes the stream once the context is done", func() {
+		b := grpc.NewBridge(w, open, message)
+		done := make(chan struct{})
+		go func() {
+			b.Run(ctx)
+			close(done)
+		}()
+		set(1)
+
+		var s *spyStream
+		Eventually(opened).Should(Receive(&s))
+		cancel()
+
+		Eventually(done).Should(BeClosed())
+		Expect(s.closed()).To(BeTrue())
+	})
+})
+
+// spyStream is a client stream that records the messages sent on it.
+type spyStream struct {
+	mu       sync.Mutex
+	msgs     []interface{}
+	failing  bool
+	isClosed bool
+}
+
+func (s *spyStream) SendMsg(m interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failing {
+		return errors.New("stream broken")
+	}
+	s.msgs = append(s.msgs, m)
+	return nil
+}
+
+func (s *spyStream) CloseSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isClosed = true
+	return nil
+}
+
+func (s *spyStream) messages() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.msgs
+}
+
+func (s *spyStream) fail() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = true
+}
+
+func (s *spyStream) closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isClosed
+}
@@ -0,0 +1,128 @@
+package diodes
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Health judges whether a diode is healthy based on its drop rate and lag.
+// It can back a health check or trip a circuit breaker that sheds optional
+// traffic while the reader cannot keep up.
+type Health struct {
+	d           StatsReporter
+	maxDropRate float64
+	maxLag      uint64
+	onChange    func(healthy bool)
+
+	mu      sync.Mutex
+	healthy bool
+	prev    Stats
+
+	// notify serializes the health change callbacks. It is acquired while
+	// mu is held, so the callbacks are invoked in the order of the changes.
+	notify sync.Mutex
+}
+
+// HealthOption can be used to setup the health.
+type HealthOption func(*Health)
+
+// WithMaxDropRate sets the highest fraction of writes (between 0 and 1) that
+// may be dropped between two checks. The default of 0 disables the check.
+func WithMaxDropRate(rate float64) HealthOption {
+	return HealthOption(func(h *Health) {
+		h.maxDropRate = rate
+	})
+}
+
+// WithMaxLag sets the highest lag of the reader. The default of 0 disables
+// the check.
+func WithMaxLag(lag uint64) HealthOption {
+	return HealthOption(func(h *Health) {
+		h.maxLag = lag
+	})
+}
+
+// WithHealthChange sets a callback that is invoked whenever a check changes
+// the health. It is invoked on the go-routine of the check, and the changes
+// of concurrent checks are delivered one at a time in the order they
+// happened. It must not check the health itself.
+func WithHealthChange(f func(healthy bool)) HealthOption {
+	return HealthOption(func(h *Health) {
+		h.onChange = f
+	})
+}
+
+// NewHealth returns a new Health for the given diode. The diode starts out
+// healthy.
+func NewHealth(d StatsReporter, opts ...HealthOption) *Health {
+	h := &Health{
+		d:       d,
+		healthy: true,
+		prev:    d.Stats(),
+	}
+
+	for _, o := range opts {
+		o(h)
+	}
+
+	return h
+}
+
+// Healthy checks the diode and reports whether it is healthy. The drop rate
+// is measured over the writes since the previous check. It is safe to call
+// from any go-routine.
+func (h *Health) Healthy() bool {
+	h.mu.Lock()
+
+	s := h.d.Stats()
+	healthy := h.check(s)
+	h.prev = s
+
+	notify := healthy != h.healthy && h.onChange != nil
+	h.healthy = healthy
+	if notify {
+		h.notify.Lock()
+	}
+	h.mu.Unlock()
+
+	if notify {
+		defer h.notify.Unlock()
+		h.onChange(healthy)
+	}
+
+	return healthy
+}
+
+// Run checks the diode at the given interval until the context is done. It
+// is meant to drive the health change callback when nothing else checks
+// the health.
+func (h *Health) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			h.Healthy()
+		}
+	}
+}
+
+func (h *Health) check(s Stats) bool {
+	if h.maxLag > 0 && s.Lag > h.maxLag {
+		return false
+	}
+
+	if h.maxDropRate > 0 {
+		writes := s.Writes - h.prev.Writes
+		drops := s.Drops - h.prev.Drops
+		if writes > 0 && float64(drops)/float64(writes) > h.maxDropRate {
+			return false
+		}
+	}
+
+	return true
+}
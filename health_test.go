@@ -0,0 +1,139 @@
+package diodes_test
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	var (
+		d       *diodes.OneToOne
+		data    []byte
+		changes []bool
+	)
+
+	set := func(n int) {
+		for i := 0; i < n; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+	}
+
+	drain := func() {
+		for {
+			if _, ok := d.TryNext(); !ok {
+				return
+			}
+		}
+	}
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(4, nil)
+		data = []byte("some-data")
+		changes = nil
+	})
+
+	onChange := diodes.WithHealthChange(func(healthy bool) {
+		changes = append(changes, healthy)
+	})
+
+	It("is healthy without thresholds", func() {
+		h := diodes.NewHealth(d)
+		set(10)
+		drain()
+
+		Expect(h.Healthy()).To(BeTrue())
+	})
+
+	It("is unhealthy while the lag exceeds the threshold", func() {
+		h := diodes.NewHealth(d, diodes.WithMaxLag(2), onChange)
+		set(3)
+		Expect(h.Healthy()).To(BeFalse())
+
+		drain()
+		Expect(h.Healthy()).To(BeTrue())
+		Expect(changes).To(Equal([]bool{false, true}))
+	})
+
+	It("is unhealthy when the drop rate since the previous check exceeds the threshold", func() {
+		h := diodes.NewHealth(d, diodes.WithMaxDropRate(0.5), onChange)
+
+		// 10 writes of which 6 are dropped
+		set(10)
+		drain()
+		Expect(h.Healthy()).To(BeFalse())
+
+		set(4)
+		drain()
+		Expect(h.Healthy()).To(BeTrue())
+
+		// Checking again without writes keeps the state.
+		Expect(h.Healthy()).To(BeTrue())
+		Expect(changes).To(Equal([]bool{false, true}))
+	})
+
+	It("checks periodically", func() {
+		changed := make(chan bool, 1)
+		h := diodes.NewHealth(d, diodes.WithMaxLag(2), diodes.WithHealthChange(func(healthy bool) {
+			changed <- healthy
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go h.Run(ctx, time.Millisecond)
+
+		set(3)
+		Eventually(changed).Should(Receive(BeFalse()))
+	})
+
+	It("delivers the changes of concurrent checks in order", func() {
+		var mu sync.Mutex
+		h := diodes.NewHealth(&togglingLag{}, diodes.WithMaxLag(1), diodes.WithHealthChange(func(healthy bool) {
+			runtime.Gosched()
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, healthy)
+		}))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					h.Healthy()
+				}
+			}()
+		}
+		wg.Wait()
+
+		Expect(changes).To(HaveLen(800))
+		for i, healthy := range changes {
+			Expect(healthy).To(Equal(i%2 == 1), "change %d", i)
+		}
+	})
+})
+
+// togglingLag reports a lag that toggles between 0 and 2 with every call,
+// starting with 0 for the initial stats of a Health.
+type togglingLag struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (t *togglingLag) Stats() diodes.Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.calls++
+	if t.calls%2 == 0 {
+		return diodes.Stats{Lag: 2}
+	}
+	return diodes.Stats{}
+}
@@ -0,0 +1,121 @@
+package diodes
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// The histogram buckets are log-linear like those of an HDR histogram: each
+// power of two is split into histogramSubBuckets linear buckets, which keeps
+// the relative error of a bucket below 1/histogramSubBuckets.
+const (
+	histogramSubBits    = 3
+	histogramSubBuckets = 1 << histogramSubBits
+	histogramBuckets    = (64 - histogramSubBits + 1) * histogramSubBuckets
+)
+
+// Histogram is a snapshot of the distribution of recorded values.
+type Histogram struct {
+	// Buckets are the buckets that recorded at least one value, in order of
+	// their upper bounds.
+	Buckets []HistogramBucket
+}
+
+// HistogramBucket counts the recorded values in the range (previous upper
+// bound, UpperBound].
+type HistogramBucket struct {
+	UpperBound uint64
+	Count      uint64
+}
+
+// Count returns the number of recorded values.
+func (h Histogram) Count() uint64 {
+	var n uint64
+	for _, b := range h.Buckets {
+		n += b.Count
+	}
+
+	return n
+}
+
+// Quantile returns the upper bound of the bucket that holds the given
+// quantile (e.g. 0.99). It returns zero if no values were recorded.
+func (h Histogram) Quantile(q float64) uint64 {
+	n := h.Count()
+	if n == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(n))
+	if rank >= n {
+		rank = n - 1
+	}
+
+	var seen uint64
+	for _, b := range h.Buckets {
+		seen += b.Count
+		if seen > rank {
+			return b.UpperBound
+		}
+	}
+
+	return h.Buckets[len(h.Buckets)-1].UpperBound
+}
+
+// histogram records values into log-linear buckets.
+type histogram struct {
+	counts [histogramBuckets]uint64
+}
+
+func (h *histogram) observe(v uint64) {
+	if h == nil {
+		return
+	}
+
+	atomic.AddUint64(&h.counts[histogramIndex(v)], 1)
+}
+
+func (h *histogram) snapshot() Histogram {
+	if h == nil {
+		return Histogram{}
+	}
+
+	var s Histogram
+	for i := range h.counts {
+		if n := atomic.LoadUint64(&h.counts[i]); n > 0 {
+			s.Buckets = append(s.Buckets, HistogramBucket{
+				UpperBound: histogramUpperBound(i),
+				Count:      n,
+			})
+		}
+	}
+
+	return s
+}
+
+// histogramIndex returns the bucket of a value. Values below
+// histogramSubBuckets have a bucket each, larger values share a bucket with
+// the values that have the same leading histogramSubBits+1 bits.
+func histogramIndex(v uint64) int {
+	if v < histogramSubBuckets {
+		return int(v)
+	}
+
+	shift := bits.Len64(v) - histogramSubBits - 1
+	sub := int(v>>uint(shift)) & (histogramSubBuckets - 1)
+
+	return (shift+1)*histogramSubBuckets + sub
+}
+
+// histogramUpperBound returns the largest value in the given bucket.
+func histogramUpperBound(i int) uint64 {
+	if i < histogramSubBuckets {
+		return uint64(i)
+	}
+
+	shift := uint(i/histogramSubBuckets - 1)
+	sub := uint64(i % histogramSubBuckets)
+	lower := (histogramSubBuckets + sub) << shift
+
+	return lower + (1 << shift) - 1
+}
@@ -0,0 +1,86 @@
+package diodes_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("Latency histogram", func(impl diodes.Implementation) {
+	var data []byte
+
+	BeforeEach(func() {
+		data = []byte("some-data")
+	})
+
+	It("is not recorded without the option", func() {
+		d := diodes.NewOneToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithInstrumentation(diodes.InstrumentationDetailed),
+		)
+		d.Set(diodes.GenericDataType(&data))
+		d.TryNext()
+
+		Expect(d.Instrumentation().LatencyHistogram.Buckets).To(BeEmpty())
+	})
+
+	It("implies the detailed level", func() {
+		d := diodes.NewManyToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithLatencyHistogram(),
+		)
+
+		Expect(d.Instrumentation().Level).To(Equal(diodes.InstrumentationDetailed))
+	})
+
+	It("records the latency of every read", func() {
+		d := diodes.NewOneToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithLatencyHistogram(),
+		)
+		for i := 0; i < 3; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		time.Sleep(10 * time.Millisecond)
+		for i := 0; i < 3; i++ {
+			d.TryNext()
+		}
+
+		i := d.Instrumentation()
+		h := i.LatencyHistogram
+		Expect(h.Count()).To(Equal(uint64(3)))
+		Expect(time.Duration(h.Quantile(0))).To(BeNumerically(">=", 10*time.Millisecond))
+
+		// The buckets are within 12.5% of the recorded values.
+		Expect(float64(h.Quantile(1))).To(BeNumerically("~", float64(i.Latency.Max), float64(i.Latency.Max)/8))
+	})
+})
+
+var _ = Describe("Histogram", func() {
+	h := diodes.Histogram{
+		Buckets: []diodes.HistogramBucket{
+			{UpperBound: 1, Count: 50},
+			{UpperBound: 10, Count: 40},
+			{UpperBound: 100, Count: 10},
+		},
+	}
+
+	It("counts the values", func() {
+		Expect(h.Count()).To(Equal(uint64(100)))
+	})
+
+	It("returns the upper bound of the bucket holding the quantile", func() {
+		Expect(h.Quantile(0)).To(Equal(uint64(1)))
+		Expect(h.Quantile(0.5)).To(Equal(uint64(10)))
+		Expect(h.Quantile(0.89)).To(Equal(uint64(10)))
+		Expect(h.Quantile(0.9)).To(Equal(uint64(100)))
+		Expect(h.Quantile(1)).To(Equal(uint64(100)))
+	})
+
+	It("returns zero for an empty histogram", func() {
+		Expect(diodes.Histogram{}.Quantile(0.5)).To(BeZero())
+	})
+})
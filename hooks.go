@@ -0,0 +1,74 @@
+package diodes
+
+import "sync/atomic"
+
+// Hooks are invoked at the points of a diode's lifecycle, so that embedding
+// frameworks can tie diodes into their own component systems. Any of the
+// functions may be nil. Like the alerter, they are invoked on the hot path of
+// the diode and must be cheap.
+type Hooks struct {
+	// OnStart is invoked once, by the writer that sets the first value.
+	OnStart func()
+
+	// OnStop is invoked once, by the first Close of the diode.
+	OnStop func()
+
+	// OnDrop is invoked by the reader with the number of values it noticed
+	// were dropped, right after the alerter.
+	OnDrop func(missed int)
+}
+
+// WithHooks registers the lifecycle hooks of the diode. The Waiter and the
+// Poller close the diode they wrap, so its hooks also cover them.
+func WithHooks(h Hooks) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.hooks = &hooks{Hooks: h}
+	})
+}
+
+// hooks is the state of the registered Hooks. A nil *hooks has no hooks
+// registered.
+type hooks struct {
+	Hooks
+
+	started uint32
+	stopped uint32
+}
+
+func (h *hooks) start() {
+	if h == nil || h.OnStart == nil || atomic.LoadUint32(&h.started) != 0 {
+		return
+	}
+
+	if atomic.CompareAndSwapUint32(&h.started, 0, 1) {
+		h.OnStart()
+	}
+}
+
+func (h *hooks) stop() {
+	if h == nil || h.OnStop == nil {
+		return
+	}
+
+	if atomic.CompareAndSwapUint32(&h.stopped, 0, 1) {
+		h.OnStop()
+	}
+}
+
+func (h *hooks) drop(missed int) {
+	if h == nil || h.OnDrop == nil {
+		return
+	}
+
+	h.OnDrop(missed)
+}
+
+// reset rearms the hooks for a diode that is reopened.
+func (h *hooks) reset() {
+	if h == nil {
+		return
+	}
+
+	atomic.StoreUint32(&h.started, 0)
+	atomic.StoreUint32(&h.stopped, 0)
+}
@@ -0,0 +1,90 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("Hooks", func(impl diodes.Implementation) {
+	var (
+		starts, stops int
+		drops         []int
+		hooks         diodes.Hooks
+	)
+
+	BeforeEach(func() {
+		starts, stops, drops = 0, 0, nil
+		hooks = diodes.Hooks{
+			OnStart: func() { starts++ },
+			OnStop:  func() { stops++ },
+			OnDrop:  func(missed int) { drops = append(drops, missed) },
+		}
+	})
+
+	set := func(d diodes.Diode, n int) {
+		for i := 0; i < n; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("invokes the hooks of a OneToOne", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl), diodes.WithHooks(hooks))
+		Expect(starts).To(BeZero())
+
+		set(d, 6)
+		d.TryNext()
+		Expect(d.Close()).To(Succeed())
+		Expect(d.Close()).To(Succeed())
+
+		Expect(starts).To(Equal(1))
+		Expect(stops).To(Equal(1))
+		Expect(drops).To(Equal([]int{4}))
+	})
+
+	It("invokes the hooks of a ManyToOne", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl), diodes.WithHooks(hooks))
+
+		set(d, 6)
+		d.TryNext()
+		Expect(diodes.NewWaiter(d).Close()).To(Succeed())
+
+		Expect(starts).To(Equal(1))
+		Expect(stops).To(Equal(1))
+		Expect(drops).To(Equal([]int{4}))
+	})
+
+	It("ignores the hooks that are nil", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl), diodes.WithHooks(diodes.Hooks{}))
+
+		Expect(func() {
+			set(d, 6)
+			d.TryNext()
+			d.Close()
+		}).ToNot(Panic())
+	})
+})
+
+var _ = Describe("Hooks of a Segmented", func() {
+	It("invokes the start and drop hooks", func() {
+		var (
+			starts int
+			drops  []int
+		)
+		d := diodes.NewSegmented(2, 2, nil, diodes.WithHooks(diodes.Hooks{
+			OnStart: func() { starts++ },
+			OnDrop:  func(missed int) { drops = append(drops, missed) },
+		}))
+
+		for i := 0; i < 6; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+		d.TryNext()
+
+		Expect(starts).To(Equal(1))
+		Expect(drops).To(Equal([]int{4}))
+	})
+})
@@ -0,0 +1,81 @@
+package diodes
+
+import (
+	"sync"
+	"time"
+)
+
+// WithIdleCallback invokes fn once the diode was empty without writes for
+// the given duration, so that the consumer can release resources, stop its
+// pollers or scale down its workers for a quiet stream. The diode is checked
+// at that interval, so fn is invoked between one and two durations into the
+// quiet and then not again until the diode was written to. It is invoked on
+// a go-routine of its own. The checks keep the diode alive until it is
+// closed, which stops them. It applies to the OneToOne and ManyToOne diodes.
+func WithIdleCallback(after time.Duration, fn func()) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.idle = &idle{after: after, fn: fn}
+	})
+}
+
+// idle checks whether a diode is idle. A nil *idle does not check.
+type idle struct {
+	after time.Duration
+	fn    func()
+
+	mu       sync.Mutex
+	stats    func() Stats
+	timer    *time.Timer
+	writes   uint64
+	notified bool
+	stopped  bool
+}
+
+func (i *idle) start(stats func() Stats) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.stats = stats
+	i.writes = stats().Writes
+	i.notified = false
+	i.stopped = false
+	if i.timer == nil {
+		i.timer = time.AfterFunc(i.after, i.check)
+		return
+	}
+	i.timer.Reset(i.after)
+}
+
+func (i *idle) stop() {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.stopped = true
+	if i.timer != nil {
+		i.timer.Stop()
+	}
+}
+
+func (i *idle) check() {
+	i.mu.Lock()
+	if i.stopped {
+		i.mu.Unlock()
+		return
+	}
+
+	s := i.stats()
+	quiet := s.Writes == i.writes && s.Lag == 0
+	notify := quiet && !i.notified
+	i.notified = quiet
+	i.writes = s.Writes
+	i.timer.Reset(i.after)
+	i.mu.Unlock()
+
+	if notify {
+		i.fn()
+	}
+}
@@ -0,0 +1,65 @@
+package diodes_test
+
+import (
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("WithIdleCallback()", func(impl diodes.Implementation) {
+	var (
+		idles int32
+		d     *diodes.ManyToOne
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&idles, 0)
+		d = diodes.NewManyToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithIdleCallback(10*time.Millisecond, func() { atomic.AddInt32(&idles, 1) }),
+		)
+	})
+
+	AfterEach(func() {
+		d.Close()
+	})
+
+	count := func() int32 {
+		return atomic.LoadInt32(&idles)
+	}
+
+	It("notifies once the diode is quiet", func() {
+		Eventually(count).Should(Equal(int32(1)))
+		Consistently(count, 50*time.Millisecond).Should(Equal(int32(1)))
+	})
+
+	It("does not notify while the diode holds data", func() {
+		v := 1
+		d.Set(diodes.GenericDataType(&v))
+
+		Consistently(count, 50*time.Millisecond).Should(BeZero())
+
+		d.TryNext()
+		Eventually(count).Should(Equal(int32(1)))
+	})
+
+	It("notifies again after the diode was written to", func() {
+		Eventually(count).Should(Equal(int32(1)))
+
+		v := 1
+		d.Set(diodes.GenericDataType(&v))
+		d.TryNext()
+
+		Eventually(count).Should(Equal(int32(2)))
+	})
+
+	It("stops checking once the diode is closed", func() {
+		d.Close()
+
+		Consistently(count, 50*time.Millisecond).Should(BeZero())
+	})
+})
@@ -0,0 +1,266 @@
+package diodes
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// InstrumentationLevel gates the counters and timings a diode records.
+type InstrumentationLevel int
+
+const (
+	// InstrumentationOff does not record anything. It is the default and
+	// adds no measurable overhead to Set and TryNext.
+	InstrumentationOff InstrumentationLevel = iota
+
+	// InstrumentationBasic counts empty reads, alerts and (for ManyToOne)
+	// write collisions, and tracks the high watermark of the occupancy.
+	InstrumentationBasic
+
+	// InstrumentationDetailed records everything InstrumentationBasic does
+	// and additionally timestamps every write to track how long data sits
+	// in the diode before it is read and the rolling throughput rates.
+	InstrumentationDetailed
+)
+
+// String returns the name of the level.
+func (l InstrumentationLevel) String() string {
+	switch l {
+	case InstrumentationOff:
+		return "Off"
+	case InstrumentationBasic:
+		return "Basic"
+	case InstrumentationDetailed:
+		return "Detailed"
+	default:
+		return "InstrumentationLevel(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// Instrumentation is a snapshot of the values recorded by a diode's
+// instrumentation.
+type Instrumentation struct {
+	Level InstrumentationLevel
+
+	// EmptyReads is the number of TryNext calls that did not return data.
+	EmptyReads uint64
+
+	// Alerts is the number of times the alerter was invoked.
+	Alerts uint64
+
+	// Collisions is the number of times a writer had to retry because of
+	// another writer. It is only recorded by ManyToOne diodes.
+	Collisions uint64
+
+	// HighWatermark is the highest occupancy observed after a write.
+	HighWatermark Watermark
+
+	// Latency summarizes the time between writing and reading data. It is
+	// only recorded with InstrumentationDetailed.
+	Latency LatencySummary
+
+	// LatencyHistogram is the distribution of the latencies in nanoseconds.
+	// It is only recorded with WithLatencyHistogram.
+	LatencyHistogram Histogram
+
+	// Rates are the throughput rates for each of the windows set with
+	// WithRateWindows. They are only recorded with InstrumentationDetailed.
+	Rates []Rate
+}
+
+// Watermark is an occupancy of a diode and the time it was first observed.
+type Watermark struct {
+	Occupancy uint64
+	Time      time.Time
+}
+
+// LatencySummary summarizes the time data spent in a diode.
+type LatencySummary struct {
+	Count uint64
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Mean returns the average latency.
+func (s LatencySummary) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.Total / time.Duration(s.Count)
+}
+
+// instrumentation records the values of an instrumentation level other than
+// InstrumentationOff. A nil *instrumentation records nothing so that the
+// diodes can invoke it unconditionally.
+type instrumentation struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	emptyReads   uint64
+	alerts       uint64
+	latencyCount uint64
+	latencyTotal uint64
+	latencyMax   uint64
+	peak         uint64
+	peakTime     int64
+
+	level     InstrumentationLevel
+	rates     *rates
+	latencies *histogram
+	hook      MetricsHook
+}
+
+func newInstrumentation(c diodeConfig) *instrumentation {
+	level := c.instrumentation
+	if (c.latencies || c.metricsHook != nil) && level < InstrumentationDetailed {
+		level = InstrumentationDetailed
+	}
+
+	if level <= InstrumentationOff {
+		return nil
+	}
+
+	i := &instrumentation{level: level, hook: c.metricsHook}
+	if i.detailed() {
+		i.rates = newRates(c.rateWindows)
+	}
+	if c.latencies {
+		i.latencies = new(histogram)
+	}
+
+	return i
+}
+
+func (i *instrumentation) detailed() bool {
+	return i != nil && i.level >= InstrumentationDetailed
+}
+
+// now returns the current time in nanoseconds if writes are timestamped and
+// zero otherwise.
+func (i *instrumentation) now() int64 {
+	if !i.detailed() {
+		return 0
+	}
+
+	return time.Now().UnixNano()
+}
+
+func (i *instrumentation) emptyRead() {
+	if i == nil {
+		return
+	}
+
+	atomic.AddUint64(&i.emptyReads, 1)
+}
+
+func (i *instrumentation) alert(dropped uint64) {
+	if i == nil {
+		return
+	}
+
+	atomic.AddUint64(&i.alerts, 1)
+	if i.hook != nil {
+		i.hook.IncDrops(dropped)
+	}
+}
+
+// observeOccupancy records the occupancy after the write with the given
+// write index and reports the lag to the metrics hook. The read index is
+// only loaded when the occupancy is recorded. The time is only taken for a
+// new peak, which happens at most capacity times.
+func (i *instrumentation) observeOccupancy(writeIndex uint64, readIndex *uint64, capacity uint64) {
+	if i == nil {
+		return
+	}
+
+	occupancy := lag(writeIndex+1, atomic.LoadUint64(readIndex))
+	if i.hook != nil {
+		i.hook.SetLag(occupancy)
+	}
+	if occupancy > capacity {
+		occupancy = capacity
+	}
+
+	for {
+		peak := atomic.LoadUint64(&i.peak)
+		if occupancy <= peak {
+			return
+		}
+
+		if atomic.CompareAndSwapUint64(&i.peak, peak, occupancy) {
+			atomic.StoreInt64(&i.peakTime, time.Now().UnixNano())
+			return
+		}
+	}
+}
+
+// tick takes a sample for the rates at the time of a write, which is zero if
+// the write was not timestamped.
+func (i *instrumentation) tick(ts int64) {
+	if i == nil || ts == 0 {
+		return
+	}
+
+	i.rates.tick(ts)
+}
+
+// observeLatency records the latency of data written at the given time. A
+// zero time means the write was not timestamped.
+func (i *instrumentation) observeLatency(ts int64) {
+	if i == nil || ts == 0 {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	i.rates.tick(now)
+
+	latency := now - ts
+	if latency < 0 {
+		latency = 0
+	}
+
+	i.latencies.observe(uint64(latency))
+	if i.hook != nil {
+		i.hook.ObserveLatency(time.Duration(latency))
+	}
+	atomic.AddUint64(&i.latencyCount, 1)
+	atomic.AddUint64(&i.latencyTotal, uint64(latency))
+
+	for {
+		max := atomic.LoadUint64(&i.latencyMax)
+		if uint64(latency) <= max || atomic.CompareAndSwapUint64(&i.latencyMax, max, uint64(latency)) {
+			return
+		}
+	}
+}
+
+func (i *instrumentation) snapshot() Instrumentation {
+	if i == nil {
+		return Instrumentation{Level: InstrumentationOff}
+	}
+
+	w := Watermark{Occupancy: atomic.LoadUint64(&i.peak)}
+	if t := atomic.LoadInt64(&i.peakTime); t != 0 {
+		w.Time = time.Unix(0, t)
+	}
+
+	var rates []Rate
+	if i.rates != nil {
+		rates = i.rates.snapshot()
+	}
+
+	return Instrumentation{
+		Level:         i.level,
+		EmptyReads:    atomic.LoadUint64(&i.emptyReads),
+		Alerts:        atomic.LoadUint64(&i.alerts),
+		HighWatermark: w,
+		Latency: LatencySummary{
+			Count: atomic.LoadUint64(&i.latencyCount),
+			Total: time.Duration(atomic.LoadUint64(&i.latencyTotal)),
+			Max:   time.Duration(atomic.LoadUint64(&i.latencyMax)),
+		},
+		LatencyHistogram: i.latencies.snapshot(),
+		Rates:            rates,
+	}
+}
@@ -0,0 +1,110 @@
+package diodes_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("Instrumentation", func(impl diodes.Implementation) {
+	var data []byte
+
+	BeforeEach(func() {
+		data = []byte("some-data")
+	})
+
+	It("records nothing when off", func() {
+		d := diodes.NewOneToOne(2, nil, diodes.WithImplementation(impl))
+		d.TryNext()
+
+		Expect(d.Instrumentation()).To(Equal(diodes.Instrumentation{
+			Level: diodes.InstrumentationOff,
+		}))
+	})
+
+	It("counts empty reads and alerts at the basic level", func() {
+		d := diodes.NewOneToOne(2, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithInstrumentation(diodes.InstrumentationBasic),
+		)
+		d.TryNext()
+		for i := 0; i < 3; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+		d.TryNext()
+
+		i := d.Instrumentation()
+		Expect(i.Level).To(Equal(diodes.InstrumentationBasic))
+		Expect(i.EmptyReads).To(Equal(uint64(2)))
+		Expect(i.Alerts).To(Equal(uint64(1)))
+		Expect(i.Latency.Count).To(BeZero())
+	})
+
+	It("tracks latency at the detailed level", func() {
+		d := diodes.NewManyToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithInstrumentation(diodes.InstrumentationDetailed),
+		)
+		d.Set(diodes.GenericDataType(&data))
+		time.Sleep(10 * time.Millisecond)
+		result, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*[]byte)(result)).To(Equal(data))
+
+		latency := d.Instrumentation().Latency
+		Expect(latency.Count).To(Equal(uint64(1)))
+		Expect(latency.Max).To(BeNumerically(">=", 10*time.Millisecond))
+		Expect(latency.Mean()).To(Equal(latency.Total))
+	})
+
+	It("tracks the high watermark of a OneToOne", func() {
+		d := diodes.NewOneToOne(8, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithInstrumentation(diodes.InstrumentationBasic),
+		)
+		before := time.Now()
+		for i := 0; i < 3; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		for i := 0; i < 3; i++ {
+			d.TryNext()
+		}
+		d.Set(diodes.GenericDataType(&data))
+
+		w := d.Instrumentation().HighWatermark
+		Expect(w.Occupancy).To(Equal(uint64(3)))
+		Expect(w.Time).To(BeTemporally(">=", before))
+		Expect(w.Time).To(BeTemporally("<=", time.Now()))
+	})
+
+	It("caps the high watermark of a ManyToOne at the capacity", func() {
+		d := diodes.NewManyToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithInstrumentation(diodes.InstrumentationBasic),
+		)
+		for i := 0; i < 10; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+
+		Expect(d.Instrumentation().HighWatermark.Occupancy).To(Equal(uint64(4)))
+	})
+})
+
+var _ = Describe("InstrumentationLevel", func() {
+	It("has a name", func() {
+		Expect(diodes.InstrumentationOff.String()).To(Equal("Off"))
+		Expect(diodes.InstrumentationBasic.String()).To(Equal("Basic"))
+		Expect(diodes.InstrumentationDetailed.String()).To(Equal("Detailed"))
+		Expect(diodes.InstrumentationLevel(5).String()).To(Equal("InstrumentationLevel(5)"))
+	})
+})
+
+var _ = Describe("LatencySummary", func() {
+	It("has a zero mean without observations", func() {
+		Expect(diodes.LatencySummary{}.Mean()).To(BeZero())
+	})
+})
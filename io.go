@@ -0,0 +1,63 @@
+package diodes
+
+import "io"
+
+// Writer is an io.Writer that sets a copy of each payload on a diode. Write
+// never blocks, so it suits loggers and network writers that must not stall
+// the hot path. When the reader falls behind, payloads are dropped as
+// reported by the diode's alerter. The values of the diode are *[]byte.
+type Writer struct {
+	d Diode
+}
+
+// NewWriter returns a new Writer that writes to the given diode. A Writer
+// that is used from several go-routines requires a ManyToOne diode.
+func NewWriter(d Diode) *Writer {
+	return &Writer{d: d}
+}
+
+// Write copies p into the diode. It always returns len(p) and a nil error.
+func (w *Writer) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	w.d.Set(GenericDataType(&b))
+
+	return len(p), nil
+}
+
+// Reader is an io.Reader that streams the payloads of a diode whose values
+// are *[]byte, such as the ones set by a Writer. It blocks until data is
+// available, so standard library consumers (bufio.Scanner, io.Copy) can drain
+// a diode directly. Payloads are not delimited: a payload may be returned
+// over several reads and a read returns data of at most one payload.
+type Reader struct {
+	n   Nexter
+	buf []byte
+}
+
+// NewReader returns a new Reader that reads from the given Poller or Waiter.
+func NewReader(n Nexter) *Reader {
+	return &Reader{n: n}
+}
+
+// Read reads the next data of the diode into p. Once the context of the
+// Poller or Waiter is done, it returns io.EOF.
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for len(r.buf) == 0 {
+		data := r.n.Next()
+		if data == nil {
+			return 0, io.EOF
+		}
+
+		r.buf = *(*[]byte)(data)
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+}
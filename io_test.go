@@ -0,0 +1,98 @@
+package diodes_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Writer", func() {
+	It("sets a copy of each payload on the diode", func() {
+		d := diodes.NewOneToOne(4, nil)
+		w := diodes.NewWriter(d)
+
+		p := []byte("some-data")
+		n, err := w.Write(p)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(len(p)))
+		copy(p, "xxxx")
+
+		data, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*[]byte)(data)).To(Equal([]byte("some-data")))
+	})
+
+	It("never blocks", func() {
+		spy := newSpyAlerter()
+		d := diodes.NewOneToOne(2, spy)
+		w := diodes.NewWriter(d)
+
+		for i := 0; i < 5; i++ {
+			_, err := w.Write([]byte("some-data"))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		d.TryNext()
+		Expect(spy.AlertInput.Missed).To(Receive(Equal(4)))
+	})
+})
+
+var _ = Describe("Reader", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		w      *diodes.Waiter
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(8, nil), diodes.WithWaiterContext(ctx))
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("streams the payloads", func() {
+		dw := diodes.NewWriter(w)
+		_, _ = dw.Write([]byte("first\nsec"))
+		_, _ = dw.Write([]byte("ond\n"))
+
+		s := bufio.NewScanner(diodes.NewReader(w))
+		Expect(s.Scan()).To(BeTrue())
+		Expect(s.Text()).To(Equal("first"))
+		Expect(s.Scan()).To(BeTrue())
+		Expect(s.Text()).To(Equal("second"))
+	})
+
+	It("returns a payload over several reads", func() {
+		_, _ = diodes.NewWriter(w).Write([]byte("some-data"))
+		r := diodes.NewReader(w)
+
+		p := make([]byte, 4)
+		n, err := r.Read(p)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(p[:n])).To(Equal("some"))
+
+		n, err = r.Read(p)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(p[:n])).To(Equal("-dat"))
+	})
+
+	It("returns io.EOF once the context is done", func() {
+		_, _ = diodes.NewWriter(w).Write([]byte("some-data"))
+		done := make(chan []byte)
+		go func() {
+			b, _ := io.ReadAll(diodes.NewReader(w))
+			done <- b
+		}()
+
+		cancel()
+		Eventually(done).Should(Receive(Equal([]byte("some-data"))))
+	})
+})
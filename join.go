@@ -0,0 +1,156 @@
+package diodes
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Pair is a value of the left diode of a Join and the matching value of the
+// right diode.
+type Pair struct {
+	Left  GenericDataType
+	Right GenericDataType
+}
+
+// Join pairs the values of two diodes, such as requests and responses or
+// metrics and their metadata. By default the values are paired in the order
+// they arrive: the first value of the left diode with the first value of the
+// right diode and so on. With WithJoinKey, values are paired by key instead.
+// A value that is not paired within the window is discarded and counted as
+// expired.
+//
+// The values are timed when the Join reads them, so it should be read more
+// often than the window. Like the diodes, a Join is meant to be read by a
+// single go-routine.
+type Join struct {
+	expired uint64
+
+	left, right       Diode
+	leftKey, rightKey func(GenericDataType) string
+	window            time.Duration
+	leftPending       map[string][]joinEntry
+	rightPending      map[string][]joinEntry
+	ready             []Pair
+}
+
+type joinEntry struct {
+	data GenericDataType
+	seen time.Time
+}
+
+// JoinOption can be used to setup the join.
+type JoinOption func(*Join)
+
+// WithJoinKey pairs the values whose keys are equal. The functions return
+// the key of a value of the left and of the right diode. Values with the same
+// key are paired in the order they arrive.
+func WithJoinKey(left, right func(GenericDataType) string) JoinOption {
+	return JoinOption(func(j *Join) {
+		j.leftKey = left
+		j.rightKey = right
+	})
+}
+
+// WithJoinWindow sets how long a value waits for its match. The default is
+// one second.
+func WithJoinWindow(window time.Duration) JoinOption {
+	return JoinOption(func(j *Join) {
+		j.window = window
+	})
+}
+
+// NewJoin returns a new Join that pairs the values of the given diodes.
+func NewJoin(left, right Diode, opts ...JoinOption) *Join {
+	byArrival := func(GenericDataType) string { return "" }
+	j := &Join{
+		left:         left,
+		right:        right,
+		leftKey:      byArrival,
+		rightKey:     byArrival,
+		window:       time.Second,
+		leftPending:  make(map[string][]joinEntry),
+		rightPending: make(map[string][]joinEntry),
+	}
+
+	for _, o := range opts {
+		o(j)
+	}
+
+	return j
+}
+
+// TryNext returns the next pair. If no value could be paired, it will return
+// (Pair{}, false).
+func (j *Join) TryNext() (Pair, bool) {
+	now := time.Now()
+	j.expire(now)
+	j.read(now, j.left, j.leftKey, j.leftPending, j.rightPending, false)
+	j.read(now, j.right, j.rightKey, j.rightPending, j.leftPending, true)
+
+	if len(j.ready) == 0 {
+		return Pair{}, false
+	}
+
+	p := j.ready[0]
+	j.ready[0] = Pair{}
+	j.ready = j.ready[1:]
+	return p, true
+}
+
+// read pairs the values of the diode with the values that are pending on
+// the other side, or leaves them pending on their own.
+func (j *Join) read(now time.Time, d Diode, key func(GenericDataType) string, own, other map[string][]joinEntry, right bool) {
+	for {
+		data, ok := d.TryNext()
+		if !ok {
+			return
+		}
+
+		k := key(data)
+		match := other[k]
+		if len(match) == 0 {
+			own[k] = append(own[k], joinEntry{data: data, seen: now})
+			continue
+		}
+
+		p := Pair{Left: match[0].data, Right: data}
+		if !right {
+			p = Pair{Left: data, Right: match[0].data}
+		}
+		j.ready = append(j.ready, p)
+
+		if len(match) == 1 {
+			delete(other, k)
+		} else {
+			other[k] = match[1:]
+		}
+	}
+}
+
+// expire discards the pending values that are older than the window.
+func (j *Join) expire(now time.Time) {
+	for _, pending := range []map[string][]joinEntry{j.leftPending, j.rightPending} {
+		for k, entries := range pending {
+			n := 0
+			for n < len(entries) && now.Sub(entries[n].seen) >= j.window {
+				n++
+			}
+			if n == 0 {
+				continue
+			}
+
+			atomic.AddUint64(&j.expired, uint64(n))
+			if n == len(entries) {
+				delete(pending, k)
+			} else {
+				pending[k] = entries[n:]
+			}
+		}
+	}
+}
+
+// Expired returns the number of values that were discarded as they were not
+// paired within the window. It is safe to call from any go-routine.
+func (j *Join) Expired() uint64 {
+	return atomic.LoadUint64(&j.expired)
+}
@@ -0,0 +1,71 @@
+package diodes_test
+
+import (
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Join", func() {
+	var left, right *diodes.OneToOne
+
+	BeforeEach(func() {
+		left = diodes.NewOneToOne(16, nil)
+		right = diodes.NewOneToOne(16, nil)
+	})
+
+	set := func(d diodes.Diode, values ...int) {
+		for _, v := range values {
+			v := v
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func(j *diodes.Join) [][2]int {
+		var pairs [][2]int
+		for {
+			p, ok := j.TryNext()
+			if !ok {
+				return pairs
+			}
+			pairs = append(pairs, [2]int{*(*int)(p.Left), *(*int)(p.Right)})
+		}
+	}
+
+	It("pairs the values in the order they arrive", func() {
+		j := diodes.NewJoin(left, right)
+		set(left, 1, 2, 3)
+		set(right, 10, 20)
+
+		Expect(readAll(j)).To(Equal([][2]int{{1, 10}, {2, 20}}))
+
+		set(right, 30)
+		Expect(readAll(j)).To(Equal([][2]int{{3, 30}}))
+	})
+
+	It("pairs the values by key", func() {
+		key := func(data diodes.GenericDataType) string {
+			return strconv.Itoa(*(*int)(data) % 10)
+		}
+		j := diodes.NewJoin(left, right, diodes.WithJoinKey(key, key))
+		set(left, 1, 2)
+		set(right, 22, 11, 33)
+
+		Expect(readAll(j)).To(Equal([][2]int{{2, 22}, {1, 11}}))
+	})
+
+	It("discards the values that are not paired within the window", func() {
+		j := diodes.NewJoin(left, right, diodes.WithJoinWindow(10*time.Millisecond))
+		set(left, 1, 2)
+		readAll(j)
+
+		time.Sleep(20 * time.Millisecond)
+		set(right, 10)
+		Expect(readAll(j)).To(BeEmpty())
+		Expect(j.Expired()).To(Equal(uint64(2)))
+	})
+})
@@ -0,0 +1,157 @@
+// Package kafka drains a diode into a Kafka producer. It does not depend on
+// a Kafka client: a small adapter turns the writer of segmentio/kafka-go or
+// the client of franz-go into a Producer.
+package kafka
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Message is a record that is produced to Kafka.
+type Message struct {
+	Key   []byte
+	Value []byte
+}
+
+// Producer produces a batch of messages. It must not retain the batch, it is
+// reused once Produce returns. A kafka-go adapter would convert the messages
+// and invoke (*kafka.Writer).WriteMessages.
+type Producer interface {
+	Produce(ctx context.Context, msgs []Message) error
+}
+
+// ProducerFunc is an adapter to allow the use of ordinary functions as
+// Producers.
+type ProducerFunc func(ctx context.Context, msgs []Message) error
+
+// Produce calls f(ctx, msgs).
+func (f ProducerFunc) Produce(ctx context.Context, msgs []Message) error {
+	return f(ctx, msgs)
+}
+
+// Buffer buffers messages in a diode and produces them in batches on the
+// go-routine of Run. A slow broker makes the diode drop messages instead of
+// blocking the writers, and a batch that fails to be produced is counted and
+// discarded.
+type Buffer struct {
+	produced uint64
+	failed   uint64
+
+	w        *diodes.BatchWriter
+	p        Producer
+	message  func(diodes.GenericDataType) Message
+	size     int
+	interval time.Duration
+	timeout  time.Duration
+	msgs     []Message
+}
+
+// BufferStats are the counters of a Buffer. The drops of the diode are found
+// in the diode's Stats.
+type BufferStats struct {
+	// Produced is the number of messages that were produced.
+	Produced uint64
+
+	// Failed is the number of messages of the batches that failed to be
+	// produced.
+	Failed uint64
+}
+
+// BufferOption can be used to setup the buffer.
+type BufferOption func(*Buffer)
+
+// WithBatchSize sets the number of messages at which a batch is produced.
+// The default is 100.
+func WithBatchSize(size int) BufferOption {
+	return BufferOption(func(b *Buffer) {
+		b.size = size
+	})
+}
+
+// WithFlushInterval sets the longest time a partial batch waits before it is
+// produced. The default is 1s.
+func WithFlushInterval(interval time.Duration) BufferOption {
+	return BufferOption(func(b *Buffer) {
+		b.interval = interval
+	})
+}
+
+// WithProduceTimeout sets the time a batch may take to be produced. The
+// default is 10s.
+func WithProduceTimeout(timeout time.Duration) BufferOption {
+	return BufferOption(func(b *Buffer) {
+		b.timeout = timeout
+	})
+}
+
+// NewBuffer returns a new Buffer that stores the data in the given diode and
+// produces it with the producer. The message function converts the data of
+// the diode to a message.
+func NewBuffer(
+	d diodes.Diode,
+	p Producer,
+	message func(diodes.GenericDataType) Message,
+	opts ...BufferOption,
+) *Buffer {
+	b := &Buffer{
+		p:        p,
+		message:  message,
+		size:     100,
+		interval: time.Second,
+		timeout:  10 * time.Second,
+	}
+
+	for _, o := range opts {
+		o(b)
+	}
+
+	b.w = diodes.NewBatchWriter(d, b.produce,
+		diodes.WithBatchSize(b.size),
+		diodes.WithFlushInterval(b.interval),
+	)
+
+	return b
+}
+
+// Set sets the data on the diode. It never blocks.
+func (b *Buffer) Set(data diodes.GenericDataType) {
+	b.w.Set(data)
+}
+
+// Run produces the batches until the context is done. The data that is left
+// in the diode is then produced as well. Run must only be invoked once as it
+// is the reader of the diode.
+func (b *Buffer) Run(ctx context.Context) {
+	b.w.Run(ctx)
+}
+
+// Stats returns the counters of the buffer. It is safe to call from any
+// go-routine.
+func (b *Buffer) Stats() BufferStats {
+	return BufferStats{
+		Produced: atomic.LoadUint64(&b.produced),
+		Failed:   atomic.LoadUint64(&b.failed),
+	}
+}
+
+// produce is the sink of the batch writer. It does not use the context of
+// Run so that the remaining messages are still produced once it is done.
+func (b *Buffer) produce(batch []diodes.GenericDataType) {
+	b.msgs = b.msgs[:0]
+	for _, data := range batch {
+		b.msgs = append(b.msgs, b.message(data))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+
+	if err := b.p.Produce(ctx, b.msgs); err != nil {
+		atomic.AddUint64(&b.failed, uint64(len(b.msgs)))
+		return
+	}
+	atomic.AddUint64(&b.produced, uint64(len(b.msgs)))
+}
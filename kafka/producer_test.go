@@ -0,0 +1,126 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/kafka"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Buffer", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		spy    *spyProducer
+		done   chan struct{}
+	)
+
+	message := func(data diodes.GenericDataType) kafka.Message {
+		return kafka.Message{Value: *(*[]byte)(data)}
+	}
+
+	set := func(b *kafka.Buffer, v string) {
+		p := []byte(v)
+		b.Set(diodes.GenericDataType(&p))
+	}
+
+	run := func(b *kafka.Buffer) {
+		done = make(chan struct{})
+		go func() {
+			b.Run(ctx)
+			close(done)
+		}()
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		spy = &spyProducer{}
+	})
+
+	AfterEach(func() {
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("produces the messages in batches", func() {
+		b := kafka.NewBuffer(diodes.NewOneToOne(16, nil), spy, message, kafka.WithBatchSize(2))
+		set(b, "a")
+		set(b, "b")
+		set(b, "c")
+		run(b)
+
+		Eventually(spy.values).Should(Equal([]string{"a", "b"}))
+		cancel()
+		Eventually(spy.values).Should(Equal([]string{"a", "b", "c"}))
+		Eventually(b.Stats).Should(Equal(kafka.BufferStats{Produced: 3}))
+	})
+
+	It("produces partial batches after the flush interval", func() {
+		b := kafka.NewBuffer(diodes.NewOneToOne(16, nil), spy, message,
+			kafka.WithFlushInterval(10*time.Millisecond),
+		)
+		run(b)
+		set(b, "a")
+
+		Eventually(spy.values).Should(Equal([]string{"a"}))
+	})
+
+	It("counts the messages of batches that fail to be produced", func() {
+		spy.err = errors.New("broker unavailable")
+		b := kafka.NewBuffer(diodes.NewOneToOne(16, nil), spy, message, kafka.WithBatchSize(2))
+		run(b)
+		set(b, "a")
+		set(b, "b")
+
+		Eventually(b.Stats).Should(Equal(kafka.BufferStats{Failed: 2}))
+	})
+
+	It("bounds the time to produce a batch", func() {
+		spy.block = true
+		b := kafka.NewBuffer(diodes.NewOneToOne(16, nil), spy, message,
+			kafka.WithBatchSize(1),
+			kafka.WithProduceTimeout(10*time.Millisecond),
+		)
+		run(b)
+		set(b, "a")
+
+		Eventually(b.Stats).Should(Equal(kafka.BufferStats{Failed: 1}))
+	})
+})
+
+type spyProducer struct {
+	mu    sync.Mutex
+	msgs  []string
+	err   error
+	block bool
+}
+
+func (p *spyProducer) Produce(ctx context.Context, msgs []kafka.Message) error {
+	if p.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+	for _, m := range msgs {
+		p.msgs = append(p.msgs, string(m.Value))
+	}
+	return nil
+}
+
+func (p *spyProducer) values() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.msgs
+}
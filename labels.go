@@ -0,0 +1,21 @@
+package diodes
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+)
+
+// ConsumeWithLabels runs the consumer loop fn with a pprof label "diode" set
+// to the given name, as well as any additional key value pairs of labels. CPU
+// profiles then attribute the time spent in fn, and in any go-routines it
+// starts, to the diode. The context passed to fn carries the labels. The
+// labels must be pairs of a key and a value; it panics if a key is missing
+// its value.
+func ConsumeWithLabels(ctx context.Context, name string, fn func(context.Context), labels ...string) {
+	if len(labels)%2 != 0 {
+		panic("diodes: ConsumeWithLabels requires key value pairs of labels, got an odd number of " + strconv.Itoa(len(labels)))
+	}
+
+	pprof.Do(ctx, pprof.Labels(append([]string{"diode", name}, labels...)...), fn)
+}
@@ -0,0 +1,40 @@
+package diodes_test
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConsumeWithLabels", func() {
+	It("runs the consumer with the name of the diode as a label", func() {
+		var called bool
+		diodes.ConsumeWithLabels(context.Background(), "ingress", func(ctx context.Context) {
+			called = true
+
+			name, ok := pprof.Label(ctx, "diode")
+			Expect(ok).To(BeTrue())
+			Expect(name).To(Equal("ingress"))
+		})
+
+		Expect(called).To(BeTrue())
+	})
+
+	It("adds the additional labels", func() {
+		diodes.ConsumeWithLabels(context.Background(), "ingress", func(ctx context.Context) {
+			tenant, ok := pprof.Label(ctx, "tenant")
+			Expect(ok).To(BeTrue())
+			Expect(tenant).To(Equal("a"))
+		}, "tenant", "a")
+	})
+
+	It("panics when a label is missing its value", func() {
+		Expect(func() {
+			diodes.ConsumeWithLabels(context.Background(), "ingress", func(context.Context) {}, "tenant")
+		}).To(PanicWith("diodes: ConsumeWithLabels requires key value pairs of labels, got an odd number of 1"))
+	})
+})
@@ -0,0 +1,94 @@
+package diodes
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// Leak is the range of sequence numbers of the values a diode still held,
+// unread, when it was closed or garbage collected. The values before First
+// that were not read were overwritten and are counted as drops instead.
+type Leak struct {
+	First uint64
+	Last  uint64
+}
+
+// Len returns the number of values in the range.
+func (l Leak) Len() uint64 {
+	return l.Last - l.First + 1
+}
+
+// Unread returns the range of the values the diode holds that were not read
+// yet, numbered from zero as the Stats are, also for a diode created
+// WithStartIndex. It returns false when the diode holds none.
+func (s Stats) Unread() (Leak, bool) {
+	n := s.Occupancy()
+	if n == 0 {
+		return Leak{}, false
+	}
+
+	writeIndex := s.Reads + s.Drops + s.Lag
+	return Leak{First: writeIndex - n, Last: writeIndex - 1}, true
+}
+
+// WithLeakHook invokes fn with the values that are still unread when the
+// diode is closed, or when it is garbage collected without having been
+// closed, to catch pipelines that silently abandon data at shutdown. A diode
+// whose reader should finish first is drained with Drain before it is
+// closed. The hook is invoked once, on the go-routine that closes the diode
+// or on the finalizer's go-routine, until the diode is reopened. A diode
+// that is kept alive, such as by WithIdleCallback or WithContext before the
+// context is done, is not garbage collected. It applies to the OneToOne and
+// ManyToOne diodes.
+func WithLeakHook(fn func(Leak)) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.leak = &leakHook{fn: fn}
+	})
+}
+
+// leakHook is the state of the registered leak hook. A nil *leakHook has no
+// hook registered.
+type leakHook struct {
+	fn       func(Leak)
+	reported uint32
+}
+
+// check invokes the hook when the diode holds unread values and it was not
+// invoked before. The range is moved to the start index of the diode.
+func (h *leakHook) check(s Stats, start uint64) {
+	if h == nil {
+		return
+	}
+
+	l, ok := s.Unread()
+	if !ok {
+		return
+	}
+	l.First += start
+	l.Last += start
+
+	if atomic.CompareAndSwapUint32(&h.reported, 0, 1) {
+		h.fn(l)
+	}
+}
+
+// reset rearms the hook for a diode that is reopened.
+func (h *leakHook) reset() {
+	if h == nil {
+		return
+	}
+
+	atomic.StoreUint32(&h.reported, 0)
+}
+
+func watchOneToOneLeaks(d *OneToOne) {
+	runtime.SetFinalizer(d, func(d *OneToOne) {
+		d.leak.check(d.Stats(), d.start)
+	})
+}
+
+func watchManyToOneLeaks(d *ManyToOne) {
+	runtime.SetFinalizer(d, func(d *ManyToOne) {
+		d.leak.check(d.Stats(), d.start)
+	})
+}
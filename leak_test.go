@@ -0,0 +1,112 @@
+package diodes_test
+
+import (
+	"runtime"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("WithLeakHook", func(impl diodes.Implementation) {
+	var leaks chan diodes.Leak
+
+	BeforeEach(func() {
+		leaks = make(chan diodes.Leak, 10)
+	})
+
+	hook := func(l diodes.Leak) {
+		leaks <- l
+	}
+
+	set := func(d diodes.Diode, n int) {
+		for i := 0; i < n; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("reports the values a OneToOne holds when it is closed", func() {
+		d := diodes.NewOneToOne(8, nil, diodes.WithImplementation(impl), diodes.WithLeakHook(hook))
+		set(d, 5)
+		d.TryNext()
+		d.TryNext()
+
+		d.Close()
+		d.Close()
+
+		Expect(leaks).To(Receive(Equal(diodes.Leak{First: 2, Last: 4})))
+		Expect(leaks).ToNot(Receive())
+	})
+
+	It("reports the values a ManyToOne holds when it is closed", func() {
+		d := diodes.NewManyToOne(8, nil, diodes.WithImplementation(impl), diodes.WithLeakHook(hook))
+		set(d, 3)
+
+		d.Close()
+
+		Expect(leaks).To(Receive(Equal(diodes.Leak{First: 0, Last: 2})))
+	})
+
+	It("only reports the values that were not overwritten", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl), diodes.WithLeakHook(hook))
+		set(d, 6)
+
+		d.Close()
+
+		var l diodes.Leak
+		Expect(leaks).To(Receive(&l))
+		Expect(l).To(Equal(diodes.Leak{First: 2, Last: 5}))
+		Expect(l.Len()).To(Equal(uint64(4)))
+	})
+
+	It("does not report a diode that was drained", func() {
+		d := diodes.NewOneToOne(8, nil, diodes.WithImplementation(impl), diodes.WithLeakHook(hook))
+		set(d, 2)
+		d.TryNext()
+		d.TryNext()
+
+		d.Close()
+
+		Expect(leaks).ToNot(Receive())
+	})
+
+	It("reports again once the diode was reopened", func() {
+		d := diodes.NewManyToOne(8, nil, diodes.WithImplementation(impl), diodes.WithLeakHook(hook))
+		set(d, 1)
+		d.Close()
+		Expect(leaks).To(Receive())
+
+		Expect(d.Reopen()).To(Succeed())
+		set(d, 2)
+		d.Close()
+
+		Expect(leaks).To(Receive(Equal(diodes.Leak{First: 0, Last: 1})))
+	})
+
+	It("reports the values of a diode that is garbage collected", func() {
+		func() {
+			d := diodes.NewOneToOne(8, nil, diodes.WithImplementation(impl), diodes.WithLeakHook(hook))
+			set(d, 3)
+		}()
+
+		Eventually(func() chan diodes.Leak {
+			runtime.GC()
+			return leaks
+		}).Should(Receive(Equal(diodes.Leak{First: 0, Last: 2})))
+	})
+})
+
+var _ = Describe("Stats.Unread()", func() {
+	It("returns the range after the values that were read or dropped", func() {
+		l, ok := diodes.Stats{Reads: 3, Drops: 2, Lag: 3, Capacity: 4}.Unread()
+		Expect(ok).To(BeTrue())
+		Expect(l).To(Equal(diodes.Leak{First: 5, Last: 7}))
+	})
+
+	It("returns false when the diode holds nothing", func() {
+		_, ok := diodes.Stats{Reads: 3, Capacity: 4}.Unread()
+		Expect(ok).To(BeFalse())
+	})
+})
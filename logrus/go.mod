@@ -0,0 +1,23 @@
+module code.cloudfoundry.org/go-diodes/logrus
+
+go 1.18
+
+require (
+	code.cloudfoundry.org/go-diodes v0.0.0
+	github.com/onsi/ginkgo v1.16.5
+	github.com/onsi/gomega v1.23.0
+	github.com/sirupsen/logrus v1.9.3
+)
+
+require (
+	github.com/fsnotify/fsnotify v1.4.9 // indirect
+	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/nxadm/tail v1.4.8 // indirect
+	golang.org/x/net v0.1.0 // indirect
+	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/text v0.4.0 // indirect
+	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace code.cloudfoundry.org/go-diodes => ../
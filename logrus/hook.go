@@ -0,0 +1,189 @@
+// Package logrus provides a logrus hook that writes entries without blocking
+// the logging go-routine.
+package logrus
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+	"github.com/sirupsen/logrus"
+)
+
+// Hook is a logrus.Hook that formats entries and sets them on a diode. A
+// background go-routine writes them to the output. When the background
+// go-routine falls behind, entries are dropped and the number of dropped
+// entries is logged periodically.
+//
+// As logrus always writes entries to the logger's output, the output of the
+// logger should be set to io.Discard and the real output given to the hook:
+//
+//	logger.SetOutput(io.Discard)
+//	logger.AddHook(hook)
+type Hook struct {
+	dropped uint64
+
+	out          io.Writer
+	formatter    logrus.Formatter
+	levels       []logrus.Level
+	dropInterval time.Duration
+
+	waiter *diodes.Waiter
+	cancel context.CancelFunc
+	once   sync.Once
+	done   chan struct{}
+}
+
+// HookOption can be used to setup the hook.
+type HookOption func(*hookConfig)
+
+type hookConfig struct {
+	size         int
+	dropInterval time.Duration
+	formatter    logrus.Formatter
+	levels       []logrus.Level
+}
+
+// WithSize sets the number of entries the diode holds. The default is 1024.
+func WithSize(size int) HookOption {
+	return HookOption(func(c *hookConfig) {
+		c.size = size
+	})
+}
+
+// WithDropInterval sets the interval at which the number of dropped entries
+// is logged. Nothing is logged for an interval without drops. The default is
+// 10s.
+func WithDropInterval(interval time.Duration) HookOption {
+	return HookOption(func(c *hookConfig) {
+		c.dropInterval = interval
+	})
+}
+
+// WithFormatter sets the formatter of the entries. The default is a
+// logrus.TextFormatter.
+func WithFormatter(f logrus.Formatter) HookOption {
+	return HookOption(func(c *hookConfig) {
+		c.formatter = f
+	})
+}
+
+// WithLevels sets the levels of the entries the hook writes. The default is
+// all levels.
+func WithLevels(levels ...logrus.Level) HookOption {
+	return HookOption(func(c *hookConfig) {
+		c.levels = levels
+	})
+}
+
+// NewHook returns a new Hook that writes the entries to out on a background
+// go-routine. Close must be called to stop the go-routine.
+func NewHook(out io.Writer, opts ...HookOption) *Hook {
+	c := hookConfig{
+		size:         1024,
+		dropInterval: 10 * time.Second,
+		formatter:    new(logrus.TextFormatter),
+		levels:       logrus.AllLevels,
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Hook{
+		out:          out,
+		formatter:    c.formatter,
+		levels:       c.levels,
+		dropInterval: c.dropInterval,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+	h.waiter = diodes.NewWaiter(
+		diodes.NewManyToOne(c.size, diodes.AlertFunc(func(missed int) {
+			atomic.AddUint64(&h.dropped, uint64(missed))
+		})),
+		diodes.WithWaiterContext(ctx),
+	)
+
+	go h.run(ctx)
+
+	return h
+}
+
+// Levels implements logrus.Hook.
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire formats the entry and sets it on the diode. It never blocks on the
+// output.
+func (h *Hook) Fire(e *logrus.Entry) error {
+	b, err := h.formatter.Format(e)
+	if err != nil {
+		return err
+	}
+
+	// Formatters may reuse the entry's buffer.
+	p := make([]byte, len(b))
+	copy(p, b)
+	h.waiter.Set(diodes.GenericDataType(&p))
+
+	return nil
+}
+
+// Close writes the entries that are still in the diode and any drops that
+// were not logged yet, and stops the background go-routine. Entries fired
+// after Close are dropped. It is safe to call Close more than once.
+func (h *Hook) Close() {
+	h.once.Do(h.cancel)
+	<-h.done
+}
+
+func (h *Hook) run(ctx context.Context) {
+	defer close(h.done)
+
+	t := time.NewTicker(h.dropInterval)
+	defer t.Stop()
+
+	// Next keeps returning the entries that are in the diode until it is
+	// empty, even after the context is done.
+	for {
+		data := h.waiter.Next()
+		if data == nil {
+			break
+		}
+
+		_, _ = h.out.Write(*(*[]byte)(unsafe.Pointer(data)))
+
+		select {
+		case <-t.C:
+			h.logDrops()
+		default:
+		}
+	}
+
+	h.logDrops()
+}
+
+// logDrops logs the number of entries dropped since it was last invoked.
+// Drops are only noticed by the reader, so they are logged after the next
+// entry that is read.
+func (h *Hook) logDrops() {
+	dropped := atomic.SwapUint64(&h.dropped, 0)
+	if dropped == 0 {
+		return
+	}
+
+	e := logrus.NewEntry(logrus.StandardLogger()).WithField("dropped", dropped)
+	e.Time = time.Now()
+	e.Level = logrus.WarnLevel
+	e.Message = "dropped log entries"
+
+	if b, err := h.formatter.Format(e); err == nil {
+		_, _ = h.out.Write(b)
+	}
+}
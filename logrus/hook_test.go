@@ -0,0 +1,110 @@
+package logrus_test
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	diodeslogrus "code.cloudfoundry.org/go-diodes/logrus"
+	"github.com/sirupsen/logrus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hook", func() {
+	var (
+		out    *syncBuffer
+		hook   *diodeslogrus.Hook
+		logger *logrus.Logger
+	)
+
+	formatter := diodeslogrus.WithFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	BeforeEach(func() {
+		out = new(syncBuffer)
+		logger = logrus.New()
+		logger.SetOutput(io.Discard)
+	})
+
+	AfterEach(func() {
+		hook.Close()
+	})
+
+	It("writes the entries on a background go-routine", func() {
+		hook = diodeslogrus.NewHook(out, formatter)
+		logger.AddHook(hook)
+
+		logger.WithField("diode", "ingress").Info("some message")
+
+		Eventually(out.String).Should(Equal("level=info msg=\"some message\" diode=ingress\n"))
+	})
+
+	It("writes the remaining entries on Close", func() {
+		hook = diodeslogrus.NewHook(out, formatter, diodeslogrus.WithLevels(logrus.WarnLevel))
+		logger.AddHook(hook)
+		logger.Info("not written")
+		logger.Warn("first")
+		logger.Warn("second")
+		hook.Close()
+
+		Expect(out.String()).To(Equal("level=warning msg=first\nlevel=warning msg=second\n"))
+	})
+
+	It("logs the number of dropped entries", func() {
+		out.block = make(chan struct{})
+		hook = diodeslogrus.NewHook(out, formatter,
+			diodeslogrus.WithSize(2),
+			diodeslogrus.WithDropInterval(time.Millisecond),
+		)
+		logger.AddHook(hook)
+
+		logger.Info("first")
+		Eventually(out.blocked).Should(BeTrue())
+		for i := 0; i < 6; i++ {
+			logger.WithField("i", i).Info("some message")
+		}
+		close(out.block)
+		hook.Close()
+
+		Expect(out.String()).To(ContainSubstring("level=warning msg=\"dropped log entries\" dropped=4\n"))
+	})
+})
+
+// syncBuffer is an io.Writer. If block is set, the first write blocks until
+// it is closed.
+type syncBuffer struct {
+	block chan struct{}
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	isBlocked bool
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	first := !b.isBlocked
+	b.isBlocked = true
+	b.mu.Unlock()
+
+	if first && b.block != nil {
+		<-b.block
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) blocked() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.isBlocked
+}
@@ -1,7 +1,9 @@
 package diodes
 
 import (
+	"context"
 	"log"
+	"reflect"
 	"sync/atomic"
 	"unsafe"
 )
@@ -9,10 +11,16 @@ import (
 // ManyToOne diode is optimal for many writers (go-routines B-n) and a single
 // reader (go-routine A). It is not thread safe for multiple readers.
 type ManyToOne struct {
+	// The 64-bit fields (including the embedded ones) must stay first so
+	// that they are aligned on 32-bit platforms.
 	writeIndex uint64
-	buffer     []unsafe.Pointer
-	readIndex  uint64
-	alerter    Alerter
+	published  uint64
+	collisions uint64
+	rejected   uint64
+	reader
+	ring
+
+	closed uint32
 }
 
 // NewManyToOne creates a new diode (ring buffer). The ManyToOne diode
@@ -20,111 +28,260 @@ type ManyToOne struct {
 // (on go-routine A). The alerter is invoked on the read's go-routine. It is
 // called when it notices that the writer go-routine has passed it and wrote
 // over data. A nil can be used to ignore alerts.
-func NewManyToOne(size int, alerter Alerter) *ManyToOne {
-	if alerter == nil {
-		alerter = AlertFunc(func(int) {})
+//
+// The diode and its ring buffer are allocated in a single block.
+func NewManyToOne(size int, alerter Alerter, opts ...DiodeConfigOption) *ManyToOne {
+	p, r := newRing(manyToOneType, size, newDiodeConfig(opts))
+
+	d := (*ManyToOne)(p)
+	d.init(r, alerter)
+
+	return d
+}
+
+// NewManyToOneWithBuffer creates a new ManyToOne diode that uses the given
+// buffer as its ring buffer instead of allocating one. The size of the diode
+// is len(buffer), or len(buffer)/stride with WithPaddedSlots, where each slot
+// takes up stride pointers of the buffer. This is meant for embedders that
+// manage memory themselves. Any existing contents of the buffer are
+// discarded and the buffer must not be accessed by anything other than the
+// diode while it is in use. It panics if an implementation other than
+// PointerSwap is requested, or if the buffer does not hold a single slot.
+func NewManyToOneWithBuffer(buffer []unsafe.Pointer, alerter Alerter, opts ...DiodeConfigOption) *ManyToOne {
+	d := new(ManyToOne)
+	d.init(newBufferRing(buffer, newDiodeConfig(opts)), alerter)
+
+	return d
+}
+
+var manyToOneType = reflect.TypeOf(ManyToOne{})
+
+func (d *ManyToOne) init(r ring, alerter Alerter) {
+	d.ring = r
+	d.reader.init(recoverAlerter(alerter, d.panics))
+
+	// Start write index at the value before the start index
+	// to allow the first write to use AddUint64
+	// and still have a beginning index of the start index
+	d.writeIndex = d.start - 1
+	d.published = d.start
+	d.readIndex = d.start
+
+	if d.instr.detailed() {
+		d.instr.rates.init(d.Stats)
 	}
 
-	d := &ManyToOne{
-		buffer:  make([]unsafe.Pointer, size),
-		alerter: alerter,
+	if d.idle != nil {
+		d.idle.start(d.Stats)
 	}
 
-	// Start write index at the value before 0
-	// to allow the first write to use AddUint64
-	// and still have a beginning index of 0
-	d.writeIndex = ^d.writeIndex
-	return d
+	if d.leak != nil {
+		watchManyToOneLeaks(d)
+	}
+
+	if d.ctx != nil {
+		go func() {
+			<-d.ctx.Done()
+			d.Close()
+		}()
+	}
 }
 
-// Set sets the data in the next slot of the ring buffer.
+// Set sets the data in the next slot of the ring buffer. Once the diode is
+// closed, the data is dropped and counted in Stats.Rejected.
 func (d *ManyToOne) Set(data GenericDataType) {
+	if d.regions {
+		defer startRegion("diode.Set").End()
+	}
+
+	if atomic.LoadUint32(&d.closed) != 0 {
+		atomic.AddUint64(&d.rejected, 1)
+		return
+	}
+
+	if d.ordered {
+		d.setOrdered(data)
+		return
+	}
+
+	if d.slots != nil {
+		d.setSeqlock(data)
+		return
+	}
+
+	ts := d.instr.now()
 	for {
 		writeIndex := atomic.AddUint64(&d.writeIndex, 1)
-		idx := writeIndex % uint64(len(d.buffer))
-		old := atomic.LoadPointer(&d.buffer[idx])
+		yield()
+		idx := writeIndex % d.size
+		slot := d.pointer(idx)
+		old := atomic.LoadPointer(slot)
+		yield()
 
-		if old != nil &&
-			(*bucket)(old) != nil &&
-			(*bucket)(old).seq > writeIndex-uint64(len(d.buffer)) {
-			log.Println("Diode set collision: consider using a larger diode")
+		// A slot that was written by a later index belongs to a writer
+		// that lapped this one.
+		if old != nil && seqAfter((*bucket)(old).seq, writeIndex) {
+			d.collision()
 			continue
 		}
 
-		newBucket := &bucket{
-			data: data,
-			seq:  writeIndex,
+		if !atomic.CompareAndSwapPointer(slot, old, d.newBucket(writeIndex, data, ts)) {
+			d.collision()
+			continue
+		}
+
+		d.instr.observeOccupancy(writeIndex, &d.readIndex, d.size)
+		d.instr.tick(ts)
+		d.hooks.start()
+		d.rec.record(EventSet, writeIndex, 0)
+		return
+	}
+}
+
+// setSeqlock is Set for the Seqlock implementation. Writers lock the slot
+// before writing so that two writers never write into the same slot at the
+// same time.
+func (d *ManyToOne) setSeqlock(data GenericDataType) {
+	ts := d.instr.now()
+	for {
+		writeIndex := atomic.AddUint64(&d.writeIndex, 1)
+		yield()
+		idx := writeIndex % d.size
+		s := d.seqSlot(idx)
+
+		version, ok := s.tryLock()
+		if !ok {
+			d.collision()
+			continue
 		}
 
-		if !atomic.CompareAndSwapPointer(&d.buffer[idx], old, unsafe.Pointer(newBucket)) {
-			log.Println("Diode set collision: consider using a larger diode")
+		if version != 0 && seqAfter(atomic.LoadUint64(&s.seq), writeIndex) {
+			s.unlock(version)
+			d.collision()
 			continue
 		}
 
+		d.writeSlot(idx, version, writeIndex, data, ts)
+		d.instr.observeOccupancy(writeIndex, &d.readIndex, d.size)
+		d.instr.tick(ts)
+		d.hooks.start()
+		d.rec.record(EventSet, writeIndex, 0)
 		return
 	}
 }
 
+func (d *ManyToOne) collision() {
+	atomic.AddUint64(&d.collisions, 1)
+	log.Println("Diode set collision: consider using a larger diode")
+}
+
 // TryNext will attempt to read from the next slot of the ring buffer.
 // If there is not data available, it will return (nil, false).
 func (d *ManyToOne) TryNext() (data GenericDataType, ok bool) {
-	// Read a value from the ring buffer based on the readIndex.
-	idx := d.readIndex % uint64(len(d.buffer))
-	result := (*bucket)(atomic.SwapPointer(&d.buffer[idx], nil))
-
-	// When the result is nil that means the writer has not had the
-	// opportunity to write a value into the diode. This value must be ignored
-	// and the read head must not increment.
-	if result == nil {
-		return nil, false
-	}
-
-	// When the seq value is less than the current read index that means a
-	// value was read from idx that was previously written but has since has
-	// been dropped. This value must be ignored and the read head must not
-	// increment.
-	//
-	// The simulation for this scenario assumes the fast forward occurred as
-	// detailed below.
-	//
-	// 5. The reader reads again getting seq 5. It then reads again expecting
-	//    seq 6 but gets seq 2. This is a read of a stale value that was
-	//    effectively "dropped" so the read fails and the read head stays put.
-	//    `| 4 | 5 | 2 | 3 |` r: 7, w: 6
-	//
-	if result.seq < d.readIndex {
-		return nil, false
-	}
-
-	// When the seq value is greater than the current read index that means a
-	// value was read from idx that overwrote the value that was expected to
-	// be at this idx. This happens when the writer has lapped the reader. The
-	// reader needs to catch up to the writer so it moves its write head to
-	// the new seq, effectively dropping the messages that were not read in
-	// between the two values.
-	//
-	// Here is a simulation of this scenario:
-	//
-	// 1. Both the read and write heads start at 0.
-	//    `| nil | nil | nil | nil |` r: 0, w: 0
-	// 2. The writer fills the buffer.
-	//    `| 0 | 1 | 2 | 3 |` r: 0, w: 4
-	// 3. The writer laps the read head.
-	//    `| 4 | 5 | 2 | 3 |` r: 0, w: 6
-	// 4. The reader reads the first value, expecting a seq of 0 but reads 4,
-	//    this forces the reader to fast forward to 5.
-	//    `| 4 | 5 | 2 | 3 |` r: 5, w: 6
-	//
-	if result.seq > d.readIndex {
-		dropped := result.seq - d.readIndex
-		d.readIndex = result.seq
-		d.alerter.Alert(int(dropped))
-	}
-
-	// Only increment read index if a regular read occurred (where seq was
-	// equal to readIndex) or a value was read that caused a fast forward
-	// (where seq was greater than readIndex).
-	//
-	d.readIndex++
-	return result.data, true
+	if d.regions {
+		defer startRegion("diode.TryNext").End()
+	}
+
+	return d.reader.tryNext(&d.ring)
+}
+
+// TryNextGap is TryNext that also returns the values that were overwritten
+// before the one it read, so that the reader knows exactly which part of the
+// stream it lost. The gap is also alerted as usual.
+func (d *ManyToOne) TryNextGap() (data GenericDataType, gap Gap, ok bool) {
+	if d.regions {
+		defer startRegion("diode.TryNext").End()
+	}
+
+	return d.reader.tryNextGap(&d.ring)
+}
+
+// Instrumentation returns a snapshot of the values recorded by the diode's
+// instrumentation. It is safe to call from any go-routine.
+func (d *ManyToOne) Instrumentation() Instrumentation {
+	i := d.instr.snapshot()
+	if i.Level != InstrumentationOff {
+		i.Collisions = atomic.LoadUint64(&d.collisions)
+	}
+
+	return i
+}
+
+// Lag returns how many values the reader is behind the writers. When it
+// exceeds the size of the diode, the writers have lapped the reader and data
+// will be dropped. It is safe to call from any go-routine.
+func (d *ManyToOne) Lag() uint64 {
+	return d.reader.lag(atomic.LoadUint64(&d.writeIndex) + 1)
+}
+
+// Stats returns a snapshot of the diode's statistics. It is safe to call from
+// any go-routine. A write that collided with another writer claims and skips
+// a slot, which the reader later reports as dropped.
+func (d *ManyToOne) Stats() Stats {
+	writeIndex := atomic.LoadUint64(&d.writeIndex) + 1
+	s := d.reader.stats(&d.ring, writeIndex-d.start-atomic.LoadUint64(&d.collisions), writeIndex)
+	s.Rejected = atomic.LoadUint64(&d.rejected)
+	return s
+}
+
+// Drain blocks the writer until the reader read or dropped everything that
+// was set so far by any writer, such as to flush the logs before responding
+// to a request. It returns the context's error if the context is done first,
+// which is also the case when the last write collided with another writer as
+// its slot is only reported as dropped once the next value is read.
+func (d *ManyToOne) Drain(ctx context.Context) error {
+	return d.reader.drain(ctx, atomic.LoadUint64(&d.writeIndex)+1)
+}
+
+// Close closes the diode to signal the end of the stream. The data that is
+// set afterwards is dropped, while the reader can still read what is left.
+// A Set that runs concurrently with Close may or may not be dropped. It is
+// safe to call from any go-routine and always returns nil.
+func (d *ManyToOne) Close() error {
+	atomic.StoreUint32(&d.closed, 1)
+	d.idle.stop()
+	d.hooks.stop()
+	if d.leak != nil {
+		d.leak.check(d.Stats(), d.start)
+	}
+	return nil
+}
+
+// Closed reports whether the diode is closed. It is safe to call from any
+// go-routine.
+func (d *ManyToOne) Closed() bool {
+	return atomic.LoadUint32(&d.closed) != 0
+}
+
+// Reopen empties a closed diode and opens it again, so that it can be
+// reused, such as from a free list of diodes for connections, without
+// allocating a new one. The statistics and the hooks start over, while the
+// instrumentation keeps counting. It returns ErrNotClosed if the diode is
+// open and ErrBoundToContext if it was created WithContext. Reopen must not
+// be used concurrently with the reader or the writers of the diode.
+func (d *ManyToOne) Reopen() error {
+	if d.ctx != nil {
+		return ErrBoundToContext
+	}
+	if !d.Closed() {
+		return ErrNotClosed
+	}
+
+	d.ring.reset()
+	d.reader.reset(d.start)
+	d.hooks.reset()
+	d.leak.reset()
+	atomic.StoreUint64(&d.writeIndex, d.start-1)
+	atomic.StoreUint64(&d.published, d.start)
+	atomic.StoreUint64(&d.collisions, 0)
+	atomic.StoreUint64(&d.rejected, 0)
+	atomic.StoreUint32(&d.closed, 0)
+	if d.idle != nil {
+		d.idle.start(d.Stats)
+	}
+	return nil
+}
+
+func (d *ManyToOne) boundContext() context.Context {
+	return d.ctx
 }
@@ -0,0 +1,22 @@
+package diodes
+
+import "time"
+
+// MetricsHook receives the measurements of a diode as they happen so that
+// they can be recorded with any metrics library. The prometheus, otel and
+// statsd packages provide implementations. The methods are invoked on the
+// hot path of the diode by the reader and the writers, so they must be safe
+// for concurrent use and cheap.
+type MetricsHook interface {
+	// IncDrops is invoked by the reader with the number of values it
+	// noticed were dropped.
+	IncDrops(n uint64)
+
+	// ObserveLatency is invoked by the reader with the time a value spent
+	// in the diode.
+	ObserveLatency(d time.Duration)
+
+	// SetLag is invoked after each write with the number of values the
+	// reader is behind the writers.
+	SetLag(lag uint64)
+}
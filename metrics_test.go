@@ -0,0 +1,88 @@
+package diodes_test
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MetricsHook", func() {
+	var (
+		hook *spyMetricsHook
+		data []byte
+	)
+
+	BeforeEach(func() {
+		hook = &spyMetricsHook{}
+		data = []byte("some-data")
+	})
+
+	It("reports the lag after each write", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithMetricsHook(hook))
+		d.Set(diodes.GenericDataType(&data))
+		d.Set(diodes.GenericDataType(&data))
+		d.TryNext()
+		d.Set(diodes.GenericDataType(&data))
+
+		Expect(hook.lags).To(Equal([]uint64{1, 2, 2}))
+	})
+
+	It("reports the latencies of the reads", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithMetricsHook(hook))
+		d.Set(diodes.GenericDataType(&data))
+		time.Sleep(time.Millisecond)
+		d.TryNext()
+
+		Expect(hook.latencies).To(HaveLen(1))
+		Expect(hook.latencies[0]).To(BeNumerically(">=", time.Millisecond))
+	})
+
+	It("reports the drops", func() {
+		d := diodes.NewOneToOne(2, nil, diodes.WithMetricsHook(hook))
+		for i := 0; i < 5; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+
+		Expect(hook.drops).To(Equal(uint64(4)))
+	})
+
+	It("reports the lag of the Seqlock implementation", func() {
+		d := diodes.NewManyToOne(4, nil,
+			diodes.WithImplementation(diodes.Seqlock),
+			diodes.WithMetricsHook(hook),
+		)
+		d.Set(diodes.GenericDataType(&data))
+
+		Expect(hook.lags).To(Equal([]uint64{1}))
+	})
+})
+
+type spyMetricsHook struct {
+	mu        sync.Mutex
+	drops     uint64
+	latencies []time.Duration
+	lags      []uint64
+}
+
+func (h *spyMetricsHook) IncDrops(n uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drops += n
+}
+
+func (h *spyMetricsHook) ObserveLatency(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.latencies = append(h.latencies, d)
+}
+
+func (h *spyMetricsHook) SetLag(lag uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lags = append(h.lags, lag)
+}
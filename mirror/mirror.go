@@ -0,0 +1,232 @@
+// Package mirror taps the stream of a diode and mirrors it to a remote
+// endpoint over TCP, such as for a central collector to watch the in-memory
+// buffer of a service while debugging. The copies are sent as the frames of
+// the framing package, numbered so that the collector tells from a gap how
+// many copies were dropped.
+package mirror
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+)
+
+// Mirror is a Nexter that passes the values of the one it wraps through to
+// its reader and mirrors a copy of every value to a TCP endpoint. The
+// mirroring is best effort: the copies are buffered by a diode of their own
+// and sent by a go-routine of the Mirror, so a slow or unreachable endpoint
+// drops copies instead of slowing down the reader.
+type Mirror struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	sent     uint64
+	dropped  uint64
+	connects uint64
+
+	n            diodes.Nexter
+	addr         string
+	size         int
+	reconnect    time.Duration
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+
+	d      *diodes.OneToOne
+	w      *diodes.Waiter
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// conn, next, seq and buf are only used by the sending go-routine.
+	conn net.Conn
+	next time.Time
+	seq  uint64
+	buf  []byte
+}
+
+// Option can be used to setup the mirror.
+type Option func(*Mirror)
+
+// WithSize sets the number of copies the mirror buffers. The default is
+// 1024.
+func WithSize(size int) Option {
+	return Option(func(m *Mirror) {
+		m.size = size
+	})
+}
+
+// WithReconnectInterval sets how long the mirror waits before it dials
+// again once dialing or writing failed. The default is one second.
+func WithReconnectInterval(interval time.Duration) Option {
+	return Option(func(m *Mirror) {
+		m.reconnect = interval
+	})
+}
+
+// WithDialTimeout sets the timeout of dialing the endpoint. The default is
+// one second.
+func WithDialTimeout(timeout time.Duration) Option {
+	return Option(func(m *Mirror) {
+		m.dialTimeout = timeout
+	})
+}
+
+// WithWriteTimeout sets the write deadline of each frame. An endpoint that
+// does not read for that long fails the connection, and the mirror
+// reconnects. By default there is no deadline.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return Option(func(m *Mirror) {
+		m.writeTimeout = timeout
+	})
+}
+
+// New returns a new Mirror that reads from the given Poller or Waiter and
+// mirrors to the TCP endpoint at addr. The values must be *[]byte, such as
+// the ones set by a diodes.Writer. They are shared with the sending
+// go-routine and must not be modified by the reader. It starts the sending
+// go-routine, which runs until Close.
+func New(n diodes.Nexter, addr string, opts ...Option) *Mirror {
+	m := &Mirror{
+		n:           n,
+		addr:        addr,
+		size:        1024,
+		reconnect:   time.Second,
+		dialTimeout: time.Second,
+		done:        make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	m.d = diodes.NewOneToOne(m.size, diodes.AlertFunc(m.drop))
+	m.w = diodes.NewWaiter(m.d, diodes.WithWaiterContext(ctx))
+
+	go m.run()
+
+	return m
+}
+
+// Next returns the next value of the wrapped Nexter and mirrors it. It
+// returns nil once the wrapped Nexter does.
+func (m *Mirror) Next() diodes.GenericDataType {
+	data := m.n.Next()
+	if data != nil {
+		m.w.Set(data)
+	}
+
+	return data
+}
+
+// Close stops the mirroring. The copies that were not sent yet are
+// discarded. Close does not close the wrapped Nexter.
+func (m *Mirror) Close() error {
+	m.cancel()
+	<-m.done
+	return nil
+}
+
+// drop counts the copies the buffer dropped, and moves the sequence past
+// them so that the endpoint observes the gap.
+func (m *Mirror) drop(missed int) {
+	atomic.AddUint64(&m.dropped, uint64(missed))
+	m.seq += uint64(missed)
+}
+
+func (m *Mirror) run() {
+	defer close(m.done)
+	defer m.disconnect()
+
+	for {
+		data := m.w.Next()
+		if data == nil {
+			return
+		}
+
+		m.send(*(*[]byte)(data))
+	}
+}
+
+// send writes the frame of the payload, or drops it when there is no
+// connection.
+func (m *Mirror) send(payload []byte) {
+	seq := m.seq
+	m.seq++
+
+	if !m.connect() {
+		atomic.AddUint64(&m.dropped, 1)
+		return
+	}
+
+	if m.writeTimeout > 0 {
+		m.conn.SetWriteDeadline(time.Now().Add(m.writeTimeout))
+	}
+
+	m.buf = framing.Append(m.buf[:0], seq, payload)
+
+	if _, err := m.conn.Write(m.buf); err != nil {
+		m.disconnect()
+		m.next = time.Now().Add(m.reconnect)
+		atomic.AddUint64(&m.dropped, 1)
+		return
+	}
+
+	atomic.AddUint64(&m.sent, 1)
+}
+
+// connect dials the endpoint unless it is connected already or it is not
+// time to dial again yet. It reports whether there is a connection.
+func (m *Mirror) connect() bool {
+	if m.conn != nil {
+		return true
+	}
+
+	if time.Now().Before(m.next) {
+		return false
+	}
+
+	conn, err := net.DialTimeout("tcp", m.addr, m.dialTimeout)
+	if err != nil {
+		m.next = time.Now().Add(m.reconnect)
+		return false
+	}
+
+	atomic.AddUint64(&m.connects, 1)
+	m.conn = conn
+	return true
+}
+
+func (m *Mirror) disconnect() {
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn = nil
+	}
+}
+
+// Stats are the counters of a Mirror.
+type Stats struct {
+	// Sent is the number of copies that were written to the endpoint.
+	Sent uint64
+
+	// Dropped is the number of copies that were dropped by the buffer or
+	// as there was no connection or the write failed.
+	Dropped uint64
+
+	// Connects is the number of connections that were established,
+	// including the first one.
+	Connects uint64
+}
+
+// Stats returns a snapshot of the mirror's counters. It is safe to call from
+// any go-routine.
+func (m *Mirror) Stats() Stats {
+	return Stats{
+		Sent:     atomic.LoadUint64(&m.sent),
+		Dropped:  atomic.LoadUint64(&m.dropped),
+		Connects: atomic.LoadUint64(&m.connects),
+	}
+}
@@ -0,0 +1,186 @@
+package mirror_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+	"code.cloudfoundry.org/go-diodes/mirror"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type frame struct {
+	seq     uint64
+	payload string
+}
+
+// collector accepts the connections of a mirror and records the frames.
+type collector struct {
+	l net.Listener
+
+	mu     sync.Mutex
+	frames []frame
+	conns  []net.Conn
+}
+
+func newCollector(addr string) *collector {
+	l, err := net.Listen("tcp", addr)
+	Expect(err).ToNot(HaveOccurred())
+
+	c := &collector{l: l}
+	go c.accept()
+	return c
+}
+
+func (c *collector) accept() {
+	for {
+		conn, err := c.l.Accept()
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		c.conns = append(c.conns, conn)
+		c.mu.Unlock()
+
+		go c.read(conn)
+	}
+}
+
+func (c *collector) read(conn net.Conn) {
+	for {
+		seq, payload, err := framing.Read(conn, 1024)
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		c.frames = append(c.frames, frame{seq: seq, payload: string(payload)})
+		c.mu.Unlock()
+	}
+}
+
+func (c *collector) received() []frame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]frame(nil), c.frames...)
+}
+
+func (c *collector) close() {
+	c.l.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+}
+
+var _ = Describe("Mirror", func() {
+	var (
+		cancel context.CancelFunc
+		w      *diodes.Waiter
+		writer *diodes.Writer
+	)
+
+	BeforeEach(func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(64, nil), diodes.WithWaiterContext(ctx))
+		writer = diodes.NewWriter(w)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("passes the values through and mirrors them", func() {
+		c := newCollector("127.0.0.1:0")
+		defer c.close()
+
+		m := mirror.New(w, c.l.Addr().String())
+		defer m.Close()
+
+		writer.Write([]byte("a"))
+		writer.Write([]byte("b"))
+		Expect(string(*(*[]byte)(m.Next()))).To(Equal("a"))
+		Expect(string(*(*[]byte)(m.Next()))).To(Equal("b"))
+
+		Eventually(c.received).Should(Equal([]frame{{0, "a"}, {1, "b"}}))
+		Expect(m.Stats()).To(Equal(mirror.Stats{Sent: 2, Connects: 1}))
+	})
+
+	It("passes the values through while the endpoint is unreachable", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		addr := l.Addr().String()
+		l.Close()
+
+		m := mirror.New(w, addr, mirror.WithReconnectInterval(10*time.Millisecond))
+		defer m.Close()
+
+		writer.Write([]byte("a"))
+		Expect(string(*(*[]byte)(m.Next()))).To(Equal("a"))
+		Eventually(func() uint64 { return m.Stats().Dropped }).Should(Equal(uint64(1)))
+
+		c := newCollector(addr)
+		defer c.close()
+		time.Sleep(20 * time.Millisecond)
+
+		writer.Write([]byte("b"))
+		m.Next()
+		Eventually(c.received).Should(Equal([]frame{{1, "b"}}))
+	})
+
+	It("reconnects once the endpoint restarted", func() {
+		c := newCollector("127.0.0.1:0")
+		addr := c.l.Addr().String()
+
+		m := mirror.New(w, addr, mirror.WithReconnectInterval(10*time.Millisecond))
+		defer m.Close()
+
+		writer.Write([]byte("a"))
+		m.Next()
+		Eventually(c.received).Should(HaveLen(1))
+		c.close()
+
+		c = newCollector(addr)
+		defer c.close()
+		Eventually(func() []frame {
+			writer.Write([]byte("b"))
+			m.Next()
+			return c.received()
+		}).ShouldNot(BeEmpty())
+		Expect(m.Stats().Connects).To(Equal(uint64(2)))
+	})
+
+	It("drops copies instead of holding up the reader", func() {
+		block, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer block.Close()
+
+		m := mirror.New(w, block.Addr().String(), mirror.WithSize(2), mirror.WithWriteTimeout(10*time.Millisecond))
+		defer m.Close()
+
+		payload := make([]byte, 1<<20)
+		for i := 0; i < 20; i++ {
+			writer.Write(payload)
+			Expect(m.Next() == nil).To(BeFalse())
+		}
+
+		Eventually(func() uint64 { return m.Stats().Dropped }).ShouldNot(BeZero())
+	})
+
+	It("returns nil once the wrapped Nexter does", func() {
+		m := mirror.New(w, "127.0.0.1:1")
+		defer m.Close()
+
+		cancel()
+		Expect(m.Next() == nil).To(BeTrue())
+	})
+})
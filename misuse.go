@@ -0,0 +1,77 @@
+package diodes
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// WithMisuseDetection makes the diode check that it is used by the
+// go-routines its contract allows: a single writer for a OneToOne and a
+// single reader for both the OneToOne and the ManyToOne. The first
+// go-routine to write or read owns that role, and the diode panics when
+// another go-routine takes it, instead of silently corrupting data. Handing
+// a role over to another go-routine is reported as misuse too. Finding the
+// go-routine is slow, so this is meant for tests and debugging only.
+func WithMisuseDetection() DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.misuse = true
+	})
+}
+
+// owners records the go-routines that own the roles of a diode. A nil
+// *owners does not check.
+type owners struct {
+	writer uint64
+	reader uint64
+}
+
+func newOwners(enabled bool) *owners {
+	if !enabled {
+		return nil
+	}
+
+	return new(owners)
+}
+
+func (o *owners) checkWriter() {
+	if o != nil {
+		o.check(&o.writer, "writer", "Set")
+	}
+}
+
+func (o *owners) checkReader() {
+	if o != nil {
+		o.check(&o.reader, "reader", "TryNext")
+	}
+}
+
+// check claims the role for the current go-routine or panics if another
+// go-routine owns it. The ids start at 1, so 0 is never owned.
+func (o *owners) check(owner *uint64, role, method string) {
+	id := goroutineID()
+	if atomic.CompareAndSwapUint64(owner, 0, id) {
+		return
+	}
+
+	if current := atomic.LoadUint64(owner); current != id {
+		panic(fmt.Sprintf("diodes: %s invoked by go-routine %d, but go-routine %d is the %s of the diode, which must only have a single %s",
+			method, id, current, role, role))
+	}
+}
+
+// goroutineID returns the id of the current go-routine, which the runtime
+// only reveals in stack traces.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
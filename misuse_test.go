@@ -0,0 +1,64 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithMisuseDetection()", func() {
+	// on runs fn on another go-routine and returns what it panicked with.
+	on := func(fn func()) interface{} {
+		recovered := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				recovered <- recover()
+			}()
+			fn()
+		}()
+		return <-recovered
+	}
+
+	data := 1
+
+	It("allows a single writer and a single reader", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithMisuseDetection())
+
+		Expect(on(func() {
+			d.Set(diodes.GenericDataType(&data))
+			d.Set(diodes.GenericDataType(&data))
+		})).To(BeNil())
+		Expect(func() {
+			d.TryNext()
+			d.TryNext()
+		}).ToNot(Panic())
+	})
+
+	It("panics on a second writer of a OneToOne", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithMisuseDetection())
+		d.Set(diodes.GenericDataType(&data))
+
+		Expect(on(func() {
+			d.Set(diodes.GenericDataType(&data))
+		})).To(ContainSubstring("must only have a single writer"))
+	})
+
+	It("panics on a second reader", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithMisuseDetection())
+		d.TryNext()
+
+		Expect(on(func() {
+			d.TryNext()
+		})).To(ContainSubstring("must only have a single reader"))
+	})
+
+	It("allows many writers of a ManyToOne", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithMisuseDetection())
+		d.Set(diodes.GenericDataType(&data))
+
+		Expect(on(func() {
+			d.Set(diodes.GenericDataType(&data))
+		})).To(BeNil())
+	})
+})
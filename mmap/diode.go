@@ -0,0 +1,461 @@
+// Package mmap provides a diode whose ring buffer lives in a memory-mapped
+// file, so that the entries that were not read yet survive a restart of the
+// process.
+package mmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+	"os"
+	"sync/atomic"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// The file starts with a header that is followed by the slots:
+//
+//	header: magic [8]byte | version uint32 | slotSize uint32 | slots uint64 | readIndex uint64
+//	slot:   lock uint64 | seq uint64 | length uint32 | crc uint32 | payload [slotSize]byte
+//
+// Like the Seqlock implementation of the in-memory diodes, the lock of a slot
+// is odd while the slot is written and seq is the write index plus one, or
+// zero for an empty slot. The highest bit of the length is set for encrypted
+// payloads, and the crc is the checksum of the payload as it is written.
+const (
+	headerSize     = 64
+	slotHeaderSize = 24
+	formatVersion  = 1
+
+	offReadIndex = 24
+
+	encryptedFlag = 1 << 31
+	lengthMask    = encryptedFlag - 1
+)
+
+var magic = [8]byte{'g', 'o', 'd', 'i', 'o', 'd', 'e', 0}
+
+var (
+	// ErrTooLarge is returned by Set for payloads larger than the slot size.
+	ErrTooLarge = errors.New("mmap: payload exceeds the slot size")
+
+	// ErrIncompatible is returned by Open for a file that is not a diode or
+	// has a different number or size of slots.
+	ErrIncompatible = errors.New("mmap: incompatible file")
+
+	// ErrUnsupported is returned by Open on platforms without mmap.
+	ErrUnsupported = errors.New("mmap: unsupported platform")
+)
+
+// Diode is a diode whose ring buffer is a memory-mapped file. It is meant to
+// be used by a single reader and a single writer. The payloads are copied
+// into the file, so they are bytes instead of pointers.
+type Diode struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	writeIndex uint64
+	dropped    uint64
+
+	f         *os.File
+	data      []byte
+	slots     uint64
+	slotSize  int
+	stride    int
+	alerter   diodes.Alerter
+	cipher    diodes.Cipher
+	sealed    []byte
+	syncEvery uint64
+	recovery  Recovery
+}
+
+// Recovery describes what Open found in an existing file.
+type Recovery struct {
+	// Unread is the number of entries that were written but not read
+	// before the file was closed.
+	Unread uint64
+
+	// Corrupt is the number of slots whose write was interrupted or whose
+	// checksum did not match. Their entries are discarded.
+	Corrupt uint64
+
+	// FirstSeq and LastSeq are the sequence numbers of the first and the
+	// last unread entry, counting from zero. They are zero when there are
+	// no unread entries.
+	FirstSeq uint64
+	LastSeq  uint64
+}
+
+// Option can be used to setup the diode.
+type Option func(*Diode)
+
+// WithSyncEvery flushes the file to stable storage after every n writes. The
+// entries written in between survive a crash of the process, but not
+// necessarily a crash of the machine. By default the file is only flushed by
+// Sync and Close.
+func WithSyncEvery(n int) Option {
+	return Option(func(d *Diode) {
+		d.syncEvery = uint64(n)
+	})
+}
+
+// WithCipher encrypts the payloads in the file. The size limit of Set
+// applies to the encrypted payload, which is larger than the payload.
+// Encrypted payloads are only read by a Diode with a Cipher; without one
+// they are dropped.
+func WithCipher(c diodes.Cipher) Option {
+	return Option(func(d *Diode) {
+		d.cipher = c
+	})
+}
+
+// Open opens or creates the file at the given path as a diode with the given
+// number of slots, each holding a payload of up to slotSize bytes. An
+// existing file must have the same number and size of slots. Its entries
+// are validated by their sequence numbers and checksums, and the unread
+// entries are read again. The alerter is invoked on the read's go-routine.
+// A nil can be used to ignore alerts.
+func Open(path string, slots, slotSize int, alerter diodes.Alerter, opts ...Option) (*Diode, error) {
+	if alerter == nil {
+		alerter = diodes.AlertFunc(func(int) {})
+	}
+
+	d := &Diode{
+		slots:    uint64(slots),
+		slotSize: slotSize,
+		stride:   slotHeaderSize + (slotSize+7)&^7,
+		alerter:  alerter,
+	}
+	for _, o := range opts {
+		o(d)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.open(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *Diode) open(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := int64(headerSize + int(d.slots)*d.stride)
+	fresh := info.Size() == 0
+	if fresh {
+		if err := f.Truncate(size); err != nil {
+			return err
+		}
+	} else if info.Size() != size {
+		return ErrIncompatible
+	}
+
+	data, err := mmap(f, int(size))
+	if err != nil {
+		return err
+	}
+	d.f = f
+	d.data = data
+
+	if fresh {
+		copy(data, magic[:])
+		binary.LittleEndian.PutUint32(data[8:], formatVersion)
+		binary.LittleEndian.PutUint32(data[12:], uint32(d.slotSize))
+		binary.LittleEndian.PutUint64(data[16:], d.slots)
+		return nil
+	}
+
+	if err := d.validateHeader(); err != nil {
+		munmap(data)
+		return err
+	}
+	d.recover()
+
+	return nil
+}
+
+func (d *Diode) validateHeader() error {
+	if *(*[8]byte)(d.data) != magic ||
+		binary.LittleEndian.Uint32(d.data[8:]) != formatVersion ||
+		binary.LittleEndian.Uint32(d.data[12:]) != uint32(d.slotSize) ||
+		binary.LittleEndian.Uint64(d.data[16:]) != d.slots {
+		return ErrIncompatible
+	}
+
+	return nil
+}
+
+// recover restores the write index from the sequence numbers of the slots.
+// Slots that were being written or fail their checksum are cleared.
+func (d *Diode) recover() {
+	var writeIndex uint64
+	for i := uint64(0); i < d.slots; i++ {
+		lock := atomic.LoadUint64(d.word(d.slot(i)))
+		seq := atomic.LoadUint64(d.word(d.slot(i) + 8))
+		if lock&1 == 1 || (seq != 0 && !d.valid(i)) {
+			d.clear(i)
+			d.recovery.Corrupt++
+			continue
+		}
+
+		if seq > writeIndex {
+			writeIndex = seq
+		}
+	}
+
+	readIndex := atomic.LoadUint64(d.word(offReadIndex))
+	if readIndex > writeIndex {
+		readIndex = writeIndex
+		atomic.StoreUint64(d.word(offReadIndex), readIndex)
+	}
+
+	d.writeIndex = writeIndex
+	d.recovery.Unread = writeIndex - readIndex
+	if d.recovery.Unread > d.slots {
+		d.recovery.Unread = d.slots
+	}
+	if d.recovery.Unread > 0 {
+		d.recovery.FirstSeq = writeIndex - d.recovery.Unread
+		d.recovery.LastSeq = writeIndex - 1
+	}
+}
+
+// Recovery returns what Open found in an existing file.
+func (d *Diode) Recovery() Recovery {
+	return d.recovery
+}
+
+// Recover passes the unread entries that Open found in the file to fn, in
+// order, and returns what Open found. It is meant to be invoked by the reader
+// before it reads the entries that are set after Open, so that the consumer
+// can handle them apart, such as by reprocessing them. It returns early when
+// the reader has already read them. Entries that were overwritten in the
+// meantime are dropped as usual. Recover stops at the first error of fn and
+// returns it; the entry passed to fn is read all the same.
+func (d *Diode) Recover(fn func(payload []byte) error) (Recovery, error) {
+	end := d.recovery.FirstSeq + d.recovery.Unread
+	for {
+		payload, ok := d.next(end)
+		if !ok {
+			return d.recovery, nil
+		}
+
+		if err := fn(payload); err != nil {
+			return d.recovery, err
+		}
+	}
+}
+
+// Set copies the payload into the next slot of the ring buffer. It returns
+// ErrTooLarge for payloads that exceed the slot size.
+func (d *Diode) Set(payload []byte) error {
+	var flags uint32
+	if d.cipher != nil {
+		var err error
+		d.sealed, err = d.cipher.Seal(d.sealed[:0], payload)
+		if err != nil {
+			return err
+		}
+		payload = d.sealed
+		flags = encryptedFlag
+	}
+
+	if len(payload) > d.slotSize {
+		return ErrTooLarge
+	}
+
+	seq := d.writeIndex
+	off := d.slot(seq % d.slots)
+	lock := d.word(off)
+	version := atomic.LoadUint64(lock)
+
+	atomic.StoreUint64(lock, version+1)
+	binary.LittleEndian.PutUint32(d.data[off+16:], uint32(len(payload))|flags)
+	binary.LittleEndian.PutUint32(d.data[off+20:], crc32.ChecksumIEEE(payload))
+	copy(d.data[off+slotHeaderSize:], payload)
+	atomic.StoreUint64(d.word(off+8), seq+1)
+	atomic.StoreUint64(lock, version+2)
+
+	atomic.StoreUint64(&d.writeIndex, seq+1)
+	if d.syncEvery > 0 && (seq+1)%d.syncEvery == 0 {
+		return d.Sync()
+	}
+
+	return nil
+}
+
+// TryNext will attempt to read a copy of the payload of the next slot of the
+// ring buffer. If there is no data available, it will return (nil, false).
+func (d *Diode) TryNext() ([]byte, bool) {
+	return d.next(math.MaxUint64)
+}
+
+// next reads the next entry whose sequence number is below end.
+func (d *Diode) next(end uint64) ([]byte, bool) {
+	for {
+		readIndex := atomic.LoadUint64(d.word(offReadIndex))
+		if readIndex >= end {
+			return nil, false
+		}
+
+		off := d.slot(readIndex % d.slots)
+		lock := d.word(off)
+
+		// The writer stores the write index after the slot, so it is
+		// loaded before the slot: if the writer was past the slot
+		// already, the slot is complete.
+		writeIndex := atomic.LoadUint64(&d.writeIndex)
+
+		version := atomic.LoadUint64(lock)
+		if version&1 == 1 {
+			return nil, false
+		}
+
+		seq := atomic.LoadUint64(d.word(off + 8))
+		if seq == 0 || seq-1 < readIndex {
+			// When the writer was past the slot and it did not change
+			// since, it was written but cleared by the recovery. Its
+			// entry is dropped. Otherwise it is being written.
+			if readIndex < writeIndex && atomic.LoadUint64(lock) == version {
+				d.drop(readIndex, 1)
+				continue
+			}
+
+			return nil, false
+		}
+
+		length := binary.LittleEndian.Uint32(d.data[off+16:])
+		n := int(length & lengthMask)
+		if n > d.slotSize {
+			n = d.slotSize
+		}
+		payload := make([]byte, n)
+		copy(payload, d.data[off+slotHeaderSize:])
+
+		if atomic.LoadUint64(lock) != version {
+			return nil, false
+		}
+
+		// Like the in-memory diodes, the reader fast forwards when the
+		// writer has lapped it.
+		if seq-1 > readIndex {
+			if seq-1 >= end {
+				d.drop(readIndex, end-readIndex)
+				return nil, false
+			}
+
+			d.drop(readIndex, seq-1-readIndex)
+			readIndex = seq - 1
+		}
+
+		atomic.StoreUint64(d.word(offReadIndex), readIndex+1)
+		if length&encryptedFlag == 0 {
+			return payload, true
+		}
+
+		payload, err := d.decrypt(payload)
+		if err != nil {
+			// A payload that cannot be decrypted is dropped. The read
+			// index already moved past it.
+			d.drop(readIndex, 1)
+			continue
+		}
+
+		return payload, true
+	}
+}
+
+func (d *Diode) decrypt(payload []byte) ([]byte, error) {
+	if d.cipher == nil {
+		return nil, diodes.ErrNoCipher
+	}
+
+	return d.cipher.Open(nil, payload)
+}
+
+// drop moves the reader past the n entries that follow the read index and
+// alerts.
+func (d *Diode) drop(readIndex, n uint64) {
+	atomic.AddUint64(&d.dropped, n)
+	atomic.StoreUint64(d.word(offReadIndex), readIndex+n)
+	d.alerter.Alert(int(n))
+}
+
+// Stats returns a snapshot of the diode's statistics. The writes and reads
+// include those that were recovered from the file. It is safe to call from
+// any go-routine.
+func (d *Diode) Stats() diodes.Stats {
+	writeIndex := atomic.LoadUint64(&d.writeIndex)
+	readIndex := atomic.LoadUint64(d.word(offReadIndex))
+	dropped := atomic.LoadUint64(&d.dropped)
+
+	var lag uint64
+	if writeIndex > readIndex {
+		lag = writeIndex - readIndex
+	}
+
+	return diodes.Stats{
+		Writes:   writeIndex,
+		Reads:    readIndex - dropped,
+		Drops:    dropped,
+		Lag:      lag,
+		Capacity: int(d.slots),
+	}
+}
+
+// Sync flushes the file to stable storage.
+func (d *Diode) Sync() error {
+	return d.f.Sync()
+}
+
+// Close flushes the file to stable storage and closes it.
+func (d *Diode) Close() error {
+	err := d.Sync()
+	if uerr := munmap(d.data); err == nil {
+		err = uerr
+	}
+	if cerr := d.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (d *Diode) slot(idx uint64) int {
+	return headerSize + int(idx)*d.stride
+}
+
+// word returns the 64-bit word at the given offset, which must be a multiple
+// of 8.
+func (d *Diode) word(off int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&d.data[off]))
+}
+
+// valid reports whether the checksum of a slot matches its payload.
+func (d *Diode) valid(idx uint64) bool {
+	off := d.slot(idx)
+	n := int(binary.LittleEndian.Uint32(d.data[off+16:]) & lengthMask)
+	if n > d.slotSize {
+		return false
+	}
+
+	payload := d.data[off+slotHeaderSize : off+slotHeaderSize+n]
+	return crc32.ChecksumIEEE(payload) == binary.LittleEndian.Uint32(d.data[off+20:])
+}
+
+func (d *Diode) clear(idx uint64) {
+	off := d.slot(idx)
+	for i := off; i < off+slotHeaderSize; i++ {
+		d.data[i] = 0
+	}
+}
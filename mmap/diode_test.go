@@ -0,0 +1,217 @@
+package mmap_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/mmap"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diode", func() {
+	var (
+		dir  string
+		path string
+		spy  *spyAlerter
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "mmap")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "diode")
+		spy = &spyAlerter{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	open := func(opts ...mmap.Option) *mmap.Diode {
+		d, err := mmap.Open(path, 4, 16, spy, opts...)
+		Expect(err).ToNot(HaveOccurred())
+		return d
+	}
+
+	readAll := func(d *mmap.Diode) []string {
+		var payloads []string
+		for {
+			p, ok := d.TryNext()
+			if !ok {
+				return payloads
+			}
+			payloads = append(payloads, string(p))
+		}
+	}
+
+	It("reads the payloads in order", func() {
+		d := open()
+		defer d.Close()
+
+		Expect(d.Set([]byte("a"))).To(Succeed())
+		Expect(d.Set([]byte("b"))).To(Succeed())
+
+		Expect(readAll(d)).To(Equal([]string{"a", "b"}))
+	})
+
+	It("rejects payloads larger than the slot size", func() {
+		d := open()
+		defer d.Close()
+
+		Expect(d.Set(make([]byte, 17))).To(MatchError(mmap.ErrTooLarge))
+	})
+
+	It("drops the oldest entries when the writer laps the reader", func() {
+		d := open()
+		defer d.Close()
+
+		for _, p := range []string{"a", "b", "c", "d", "e", "f"} {
+			Expect(d.Set([]byte(p))).To(Succeed())
+		}
+
+		Expect(readAll(d)).To(Equal([]string{"e", "f"}))
+		Expect(spy.missed).To(Equal(4))
+		Expect(d.Stats().Drops).To(Equal(uint64(4)))
+	})
+
+	It("does not drop the entries the writer sets while the reader polls", func() {
+		const n = 10000
+		d, err := mmap.Open(path, n, 16, spy)
+		Expect(err).ToNot(HaveOccurred())
+		defer d.Close()
+
+		go func() {
+			defer GinkgoRecover()
+			for i := 0; i < n; i++ {
+				Expect(d.Set([]byte(strconv.Itoa(i)))).To(Succeed())
+			}
+		}()
+
+		for i := 0; i < n; {
+			p, ok := d.TryNext()
+			if !ok {
+				continue
+			}
+			Expect(string(p)).To(Equal(strconv.Itoa(i)))
+			i++
+		}
+		Expect(d.Stats().Drops).To(BeZero())
+		Expect(spy.missed).To(BeZero())
+	})
+
+	It("reads the unread entries again after reopening the file", func() {
+		d := open(mmap.WithSyncEvery(1))
+		for _, p := range []string{"a", "b", "c"} {
+			Expect(d.Set([]byte(p))).To(Succeed())
+		}
+		d.TryNext()
+		Expect(d.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		Expect(d.Recovery()).To(Equal(mmap.Recovery{Unread: 2, FirstSeq: 1, LastSeq: 2}))
+		Expect(d.Stats().Lag).To(Equal(uint64(2)))
+
+		Expect(d.Set([]byte("d"))).To(Succeed())
+		Expect(readAll(d)).To(Equal([]string{"b", "c", "d"}))
+	})
+
+	It("passes the unread entries to Recover before the new ones", func() {
+		d := open()
+		for _, p := range []string{"a", "b", "c"} {
+			Expect(d.Set([]byte(p))).To(Succeed())
+		}
+		d.TryNext()
+		Expect(d.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		Expect(d.Set([]byte("d"))).To(Succeed())
+
+		var recovered []string
+		recovery, err := d.Recover(func(p []byte) error {
+			recovered = append(recovered, string(p))
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(recovery).To(Equal(mmap.Recovery{Unread: 2, FirstSeq: 1, LastSeq: 2}))
+		Expect(recovered).To(Equal([]string{"b", "c"}))
+		Expect(readAll(d)).To(Equal([]string{"d"}))
+	})
+
+	It("discards entries that fail their checksum", func() {
+		d := open()
+		for _, p := range []string{"a", "b", "c"} {
+			Expect(d.Set([]byte(p))).To(Succeed())
+		}
+		Expect(d.Close()).To(Succeed())
+
+		// Corrupt the payload of the second slot.
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.WriteAt([]byte("x"), 64+40+24)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		Expect(d.Recovery()).To(Equal(mmap.Recovery{Unread: 3, Corrupt: 1, LastSeq: 2}))
+		Expect(readAll(d)).To(Equal([]string{"a", "c"}))
+		Expect(spy.missed).To(Equal(1))
+	})
+
+	It("encrypts the payloads", func() {
+		c := newCipher("0123456789abcdef")
+		d, err := mmap.Open(path, 4, 64, spy, mmap.WithCipher(c))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(d.Set([]byte("some-secret"))).To(Succeed())
+		Expect(d.Set(make([]byte, 64))).To(MatchError(mmap.ErrTooLarge))
+		Expect(d.Close()).To(Succeed())
+
+		b, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).ToNot(ContainSubstring("some-secret"))
+
+		d, err = mmap.Open(path, 4, 64, spy)
+		Expect(err).ToNot(HaveOccurred())
+		_, ok := d.TryNext()
+		Expect(ok).To(BeFalse())
+		Expect(spy.missed).To(Equal(1))
+		Expect(d.Close()).To(Succeed())
+	})
+
+	It("rejects files with a different layout", func() {
+		d := open()
+		Expect(d.Close()).To(Succeed())
+
+		_, err := mmap.Open(path, 8, 16, nil)
+		Expect(err).To(MatchError(mmap.ErrIncompatible))
+
+		Expect(os.WriteFile(path, make([]byte, 64+4*40), 0o600)).To(Succeed())
+		_, err = mmap.Open(path, 4, 16, nil)
+		Expect(err).To(MatchError(mmap.ErrIncompatible))
+	})
+})
+
+func newCipher(key string) *diodes.AEADCipher {
+	block, err := aes.NewCipher([]byte(key))
+	Expect(err).ToNot(HaveOccurred())
+	aead, err := cipher.NewGCM(block)
+	Expect(err).ToNot(HaveOccurred())
+
+	return diodes.NewAEADCipher(aead)
+}
+
+type spyAlerter struct {
+	missed int
+}
+
+func (s *spyAlerter) Alert(missed int) {
+	s.missed += missed
+}
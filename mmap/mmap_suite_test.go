@@ -0,0 +1,13 @@
+package mmap_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMmap(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Mmap Suite")
+}
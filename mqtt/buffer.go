@@ -0,0 +1,181 @@
+// Package mqtt buffers readings in a diode and publishes them to an MQTT
+// broker whenever the device is connected. It does not depend on an MQTT
+// client: a small adapter turns a client such as paho.mqtt.golang into a
+// Client.
+package mqtt
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Client publishes messages to a broker. A paho adapter would invoke
+// Publish of the client with the QoS of its choice and wait for the token.
+type Client interface {
+	IsConnected() bool
+	Publish(ctx context.Context, topic string, payload []byte) error
+}
+
+// reading is a payload and the time it was set.
+type reading struct {
+	ts      int64
+	payload []byte
+}
+
+// Buffer buffers readings while the client is offline. When it is offline
+// for too long, the diode overwrites the oldest readings, so the memory used
+// by the buffer is bounded no matter how long the device is offline.
+type Buffer struct {
+	published uint64
+	expired   uint64
+	failed    uint64
+
+	d        *diodes.ManyToOne
+	c        Client
+	topic    string
+	size     int
+	maxAge   time.Duration
+	interval time.Duration
+}
+
+// BufferStats are the statistics of a Buffer.
+type BufferStats struct {
+	// Stats are the statistics of the diode. Its drops are the readings that
+	// were overwritten while the client was offline.
+	diodes.Stats
+
+	// Published is the number of readings published.
+	Published uint64
+
+	// Expired is the number of readings that were discarded because they
+	// were older than the maximum age.
+	Expired uint64
+
+	// Failed is the number of failed attempts to publish a reading.
+	Failed uint64
+}
+
+// BufferOption can be used to setup the buffer.
+type BufferOption func(*Buffer)
+
+// WithSize sets the number of readings the buffer holds. The default is
+// 1024.
+func WithSize(size int) BufferOption {
+	return BufferOption(func(b *Buffer) {
+		b.size = size
+	})
+}
+
+// WithMaxAge sets the age after which a reading is discarded instead of
+// published. By default readings never expire.
+func WithMaxAge(d time.Duration) BufferOption {
+	return BufferOption(func(b *Buffer) {
+		b.maxAge = d
+	})
+}
+
+// WithPollingInterval sets the interval at which the buffer checks for new
+// readings and for the client to be connected. The default is 1s.
+func WithPollingInterval(d time.Duration) BufferOption {
+	return BufferOption(func(b *Buffer) {
+		b.interval = d
+	})
+}
+
+// NewBuffer returns a new Buffer that publishes the readings on the given
+// topic. The buffer may be set by several go-routines.
+func NewBuffer(c Client, topic string, opts ...BufferOption) *Buffer {
+	b := &Buffer{
+		c:        c,
+		topic:    topic,
+		size:     1024,
+		interval: time.Second,
+	}
+
+	for _, o := range opts {
+		o(b)
+	}
+
+	b.d = diodes.NewManyToOne(b.size, nil)
+
+	return b
+}
+
+// Set copies the reading into the buffer. It never blocks.
+func (b *Buffer) Set(payload []byte) {
+	r := &reading{
+		ts:      time.Now().UnixNano(),
+		payload: append([]byte(nil), payload...),
+	}
+	b.d.Set(diodes.GenericDataType(r))
+}
+
+// Run publishes the readings until the context is done. Run must only be
+// invoked once as it is the reader of the diode.
+func (b *Buffer) Run(ctx context.Context) {
+	var pending *reading
+	for {
+		if pending == nil {
+			if data, ok := b.d.TryNext(); ok {
+				pending = (*reading)(unsafe.Pointer(data))
+			}
+		}
+
+		if pending != nil && b.expire(pending) {
+			pending = nil
+			continue
+		}
+
+		if pending != nil && b.publish(ctx, pending) {
+			pending = nil
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.interval):
+		}
+	}
+}
+
+// Stats returns the statistics of the buffer. It is safe to call from any
+// go-routine.
+func (b *Buffer) Stats() BufferStats {
+	return BufferStats{
+		Stats:     b.d.Stats(),
+		Published: atomic.LoadUint64(&b.published),
+		Expired:   atomic.LoadUint64(&b.expired),
+		Failed:    atomic.LoadUint64(&b.failed),
+	}
+}
+
+// expire reports whether the reading is older than the maximum age.
+func (b *Buffer) expire(r *reading) bool {
+	if b.maxAge <= 0 || time.Since(time.Unix(0, r.ts)) <= b.maxAge {
+		return false
+	}
+
+	atomic.AddUint64(&b.expired, 1)
+	return true
+}
+
+// publish publishes a reading and reports whether it succeeded. Nothing is
+// published while the client is offline.
+func (b *Buffer) publish(ctx context.Context, r *reading) bool {
+	if !b.c.IsConnected() {
+		return false
+	}
+
+	if err := b.c.Publish(ctx, b.topic, r.payload); err != nil {
+		atomic.AddUint64(&b.failed, 1)
+		return false
+	}
+
+	atomic.AddUint64(&b.published, 1)
+	return true
+}
@@ -0,0 +1,141 @@
+package mqtt_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes/mqtt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Buffer", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		client *spyClient
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		client = &spyClient{connected: true}
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("publishes the readings on the topic", func() {
+		b := mqtt.NewBuffer(client, "sensors/temp", mqtt.WithPollingInterval(time.Millisecond))
+		go b.Run(ctx)
+
+		b.Set([]byte("21.5"))
+		b.Set([]byte("21.7"))
+
+		Eventually(client.messages).Should(Equal([]string{"sensors/temp 21.5", "sensors/temp 21.7"}))
+		Expect(b.Stats().Published).To(Equal(uint64(2)))
+	})
+
+	It("buffers the readings while the client is offline", func() {
+		client.setConnected(false)
+		b := mqtt.NewBuffer(client, "sensors/temp", mqtt.WithPollingInterval(time.Millisecond))
+		go b.Run(ctx)
+
+		b.Set([]byte("21.5"))
+		Consistently(client.messages).Should(BeEmpty())
+
+		client.setConnected(true)
+		Eventually(client.messages).Should(Equal([]string{"sensors/temp 21.5"}))
+	})
+
+	It("drops the oldest readings when offline for too long", func() {
+		client.setConnected(false)
+		b := mqtt.NewBuffer(client, "sensors/temp",
+			mqtt.WithSize(2),
+			mqtt.WithPollingInterval(time.Millisecond),
+		)
+		for _, r := range []string{"1", "2", "3", "4"} {
+			b.Set([]byte(r))
+		}
+
+		go b.Run(ctx)
+		client.setConnected(true)
+
+		Eventually(client.messages).Should(Equal([]string{"sensors/temp 3", "sensors/temp 4"}))
+		Expect(b.Stats().Drops).To(Equal(uint64(2)))
+	})
+
+	It("discards readings older than the maximum age", func() {
+		client.setConnected(false)
+		b := mqtt.NewBuffer(client, "sensors/temp",
+			mqtt.WithMaxAge(10*time.Millisecond),
+			mqtt.WithPollingInterval(time.Millisecond),
+		)
+		go b.Run(ctx)
+
+		b.Set([]byte("21.5"))
+		time.Sleep(20 * time.Millisecond)
+		client.setConnected(true)
+		b.Set([]byte("21.7"))
+
+		Eventually(client.messages).Should(Equal([]string{"sensors/temp 21.7"}))
+		Expect(b.Stats().Expired).To(Equal(uint64(1)))
+	})
+
+	It("publishes a reading again after a failure", func() {
+		client.setErr(errors.New("not acknowledged"))
+		b := mqtt.NewBuffer(client, "sensors/temp", mqtt.WithPollingInterval(time.Millisecond))
+		go b.Run(ctx)
+
+		b.Set([]byte("21.5"))
+		Eventually(func() uint64 { return b.Stats().Failed }).Should(BeNumerically(">", 0))
+
+		client.setErr(nil)
+		Eventually(client.messages).Should(Equal([]string{"sensors/temp 21.5"}))
+	})
+})
+
+type spyClient struct {
+	mu        sync.Mutex
+	msgs      []string
+	err       error
+	connected bool
+}
+
+func (c *spyClient) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *spyClient) Publish(ctx context.Context, topic string, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	c.msgs = append(c.msgs, topic+" "+string(payload))
+	return nil
+}
+
+func (c *spyClient) messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.msgs
+}
+
+func (c *spyClient) setConnected(connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = connected
+}
+
+func (c *spyClient) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
@@ -0,0 +1,131 @@
+// Package nats publishes the data of a diode to NATS. It does not depend on
+// the NATS client: *nats.Conn satisfies Conn.
+package nats
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Conn publishes messages, such as *nats.Conn.
+type Conn interface {
+	Publish(subject string, data []byte) error
+}
+
+// Publisher reads from a diode and publishes the data to NATS. While the
+// connection is reconnecting, the publisher waits instead of filling the
+// reconnect buffer of the client and the diode drops data, so the writers
+// of the diode are never blocked.
+type Publisher struct {
+	published uint64
+	failed    uint64
+
+	n       diodes.Nexter
+	conn    Conn
+	subject func(diodes.GenericDataType) (string, []byte)
+	backoff time.Duration
+}
+
+// PublisherStats are the counters of a Publisher. The drops of the diode are
+// found in the diode's Stats.
+type PublisherStats struct {
+	// Published is the number of messages published.
+	Published uint64
+
+	// Failed is the number of failed attempts to publish a message.
+	Failed uint64
+}
+
+// PublisherOption can be used to setup the publisher.
+type PublisherOption func(*Publisher)
+
+// WithReconnectBackoff sets the time to wait before a message is published
+// again after a failure or while the connection is reconnecting. The default
+// is 100ms.
+func WithReconnectBackoff(d time.Duration) PublisherOption {
+	return PublisherOption(func(p *Publisher) {
+		p.backoff = d
+	})
+}
+
+// NewPublisher returns a new Publisher that reads from the given Poller or
+// Waiter. The subject function maps the data of the diode to the subject and
+// the payload of a message.
+func NewPublisher(
+	n diodes.Nexter,
+	conn Conn,
+	subject func(diodes.GenericDataType) (string, []byte),
+	opts ...PublisherOption,
+) *Publisher {
+	p := &Publisher{
+		n:       n,
+		conn:    conn,
+		subject: subject,
+		backoff: 100 * time.Millisecond,
+	}
+
+	for _, o := range opts {
+		o(p)
+	}
+
+	return p
+}
+
+// Subject returns a subject function that publishes every message on the
+// given subject. The values of the diode must be *[]byte, such as the ones
+// set by a diodes.Writer.
+func Subject(subject string) func(diodes.GenericDataType) (string, []byte) {
+	return func(data diodes.GenericDataType) (string, []byte) {
+		return subject, *(*[]byte)(data)
+	}
+}
+
+// Run publishes messages until the Poller or Waiter returns nil or the
+// context is done. A message that fails to be published is published again
+// once the connection is back.
+func (p *Publisher) Run(ctx context.Context) {
+	for {
+		data := p.n.Next()
+		if data == nil {
+			return
+		}
+
+		subject, msg := p.subject(data)
+		for !p.publish(subject, msg) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.backoff):
+			}
+		}
+	}
+}
+
+// Stats returns the counters of the publisher. It is safe to call from any
+// go-routine.
+func (p *Publisher) Stats() PublisherStats {
+	return PublisherStats{
+		Published: atomic.LoadUint64(&p.published),
+		Failed:    atomic.LoadUint64(&p.failed),
+	}
+}
+
+// publish publishes a message and reports whether it succeeded. When the
+// connection reports its state, such as *nats.Conn does, nothing is
+// published while it is not connected.
+func (p *Publisher) publish(subject string, msg []byte) bool {
+	if c, ok := p.conn.(interface{ IsConnected() bool }); ok && !c.IsConnected() {
+		return false
+	}
+
+	if err := p.conn.Publish(subject, msg); err != nil {
+		atomic.AddUint64(&p.failed, 1)
+		return false
+	}
+
+	atomic.AddUint64(&p.published, 1)
+	return true
+}
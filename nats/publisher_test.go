@@ -0,0 +1,135 @@
+package nats_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/nats"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Publisher", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		writer *diodes.Writer
+		w      *diodes.Waiter
+		conn   *spyConn
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(16, nil), diodes.WithWaiterContext(ctx))
+		writer = diodes.NewWriter(w)
+		conn = newSpyConn()
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("publishes the data on the mapped subjects", func() {
+		subject := func(data diodes.GenericDataType) (string, []byte) {
+			msg := *(*[]byte)(data)
+			return "events." + string(msg[:1]), msg
+		}
+		p := nats.NewPublisher(w, conn, subject)
+		go p.Run(ctx)
+
+		writer.Write([]byte("a1"))
+		writer.Write([]byte("b1"))
+
+		Eventually(conn.messages).Should(Equal([]string{"events.a a1", "events.b b1"}))
+		Eventually(p.Stats).Should(Equal(nats.PublisherStats{Published: 2}))
+	})
+
+	It("publishes a message again after a failure", func() {
+		conn.setErr(errors.New("nats: connection closed"))
+		p := nats.NewPublisher(w, conn, nats.Subject("events"), nats.WithReconnectBackoff(time.Millisecond))
+		go p.Run(ctx)
+
+		writer.Write([]byte("a"))
+		Eventually(func() uint64 { return p.Stats().Failed }).Should(BeNumerically(">", 0))
+
+		conn.setErr(nil)
+		Eventually(conn.messages).Should(Equal([]string{"events a"}))
+	})
+
+	It("waits while the connection is reconnecting", func() {
+		conn.setConnected(false)
+		p := nats.NewPublisher(w, conn, nats.Subject("events"), nats.WithReconnectBackoff(time.Millisecond))
+		go p.Run(ctx)
+
+		writer.Write([]byte("a"))
+		Consistently(conn.messages).Should(BeEmpty())
+		Expect(p.Stats().Failed).To(BeZero())
+
+		conn.setConnected(true)
+		Eventually(conn.messages).Should(Equal([]string{"events a"}))
+	})
+
+	It("stops once the context is done", func() {
+		conn.setConnected(false)
+		p := nats.NewPublisher(w, conn, nats.Subject("events"))
+		done := make(chan struct{})
+		go func() {
+			p.Run(ctx)
+			close(done)
+		}()
+
+		writer.Write([]byte("a"))
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+})
+
+type spyConn struct {
+	mu        sync.Mutex
+	msgs      []string
+	err       error
+	connected bool
+}
+
+func newSpyConn() *spyConn {
+	return &spyConn{connected: true}
+}
+
+func (c *spyConn) Publish(subject string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	c.msgs = append(c.msgs, subject+" "+string(data))
+	return nil
+}
+
+func (c *spyConn) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *spyConn) messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.msgs
+}
+
+func (c *spyConn) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func (c *spyConn) setConnected(connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = connected
+}
@@ -0,0 +1,13 @@
+package offset_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestOffset(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Offset Suite")
+}
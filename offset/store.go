@@ -0,0 +1,226 @@
+// Package offset provides a store of the offsets of consumers, the sequence
+// number of the first value each of them did not consume yet, so that a
+// collector that restarts resumes where it left off instead of replaying or
+// skipping values. Several collectors, each with an identity of its own, can
+// share a store.
+package offset
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"sort"
+	"sync"
+)
+
+// The file holds a header that is followed by an entry for every consumer
+// and the checksum of both:
+//
+//	header: magic [8]byte | entries uint32
+//	entry:  seq uint64 | length uint16 | name [length]byte
+//	footer: crc uint32
+//
+// The file is written next to its path and renamed, so a crash leaves either
+// the old or the new offsets.
+const (
+	headerSize     = 12
+	entryHeader    = 10
+	footerSize     = 4
+	maxConsumerLen = 1<<16 - 1
+)
+
+var magic = [8]byte{'g', 'o', 'd', 'o', 'f', 'f', 0, 1}
+
+var (
+	// ErrCorrupt is returned by Open for a file that is not a store or failed
+	// its checksum.
+	ErrCorrupt = errors.New("offset: corrupt file")
+
+	// ErrInvalidConsumer is returned by Commit for a consumer that is empty
+	// or longer than 65535 bytes.
+	ErrInvalidConsumer = errors.New("offset: invalid consumer")
+)
+
+// Store holds the offsets of consumers in a file. It is safe for concurrent
+// use in a process. A store must only be opened by one process at a time.
+type Store struct {
+	path string
+	sync bool
+
+	mu      sync.Mutex
+	offsets map[string]uint64
+	dirty   bool
+	buf     []byte
+}
+
+// Option can be used to setup the store.
+type Option func(*Store)
+
+// WithSync flushes the file to stable storage on every Commit, so that the
+// offsets survive a crash of the machine and not only of the process.
+func WithSync() Option {
+	return Option(func(s *Store) {
+		s.sync = true
+	})
+}
+
+// Open opens the store at the given path, or an empty store if there is no
+// file yet. The file is created by the first Commit.
+func Open(path string, opts ...Option) (*Store, error) {
+	s := &Store{path: path, offsets: make(map[string]uint64)}
+	for _, o := range opts {
+		o(s)
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.decode(b); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Store) decode(b []byte) error {
+	if len(b) < headerSize+footerSize || *(*[8]byte)(b) != magic {
+		return ErrCorrupt
+	}
+
+	body := b[:len(b)-footerSize]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(b[len(body):]) {
+		return ErrCorrupt
+	}
+
+	n := binary.LittleEndian.Uint32(body[8:])
+	body = body[headerSize:]
+	for i := uint32(0); i < n; i++ {
+		if len(body) < entryHeader {
+			return ErrCorrupt
+		}
+
+		seq := binary.LittleEndian.Uint64(body)
+		length := int(binary.LittleEndian.Uint16(body[8:]))
+		if len(body) < entryHeader+length {
+			return ErrCorrupt
+		}
+
+		s.offsets[string(body[entryHeader:entryHeader+length])] = seq
+		body = body[entryHeader+length:]
+	}
+
+	return nil
+}
+
+// Offset returns the offset the consumer last committed, the sequence number
+// of the first value it did not consume yet. It returns false for a consumer
+// that never committed.
+func (s *Store) Offset(consumer string) (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq, ok := s.offsets[consumer]
+	return seq, ok
+}
+
+// Consumers returns the consumers that committed, in order.
+func (s *Store) Consumers() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.consumers()
+}
+
+func (s *Store) consumers() []string {
+	names := make([]string, 0, len(s.offsets))
+	for name := range s.offsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Commit records the offset of the consumer, the sequence number of the
+// first value it did not consume yet, and writes the file. When the file
+// fails to be written, the offset is written by the next Commit.
+func (s *Store) Commit(consumer string, seq uint64) error {
+	if consumer == "" || len(consumer) > maxConsumerLen {
+		return ErrInvalidConsumer
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.offsets[consumer]; ok && old == seq && !s.dirty {
+		return nil
+	}
+
+	s.offsets[consumer] = seq
+	return s.write()
+}
+
+// Remove removes the offset of the consumer, such as for a consumer that
+// was retired, and writes the file.
+func (s *Store) Remove(consumer string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.offsets[consumer]; !ok && !s.dirty {
+		return nil
+	}
+
+	delete(s.offsets, consumer)
+	return s.write()
+}
+
+// write replaces the file with the offsets. The file is written next to it
+// and renamed.
+func (s *Store) write() error {
+	s.dirty = true
+
+	var word [8]byte
+	b := append(s.buf[:0], magic[:]...)
+	binary.LittleEndian.PutUint32(word[:], uint32(len(s.offsets)))
+	b = append(b, word[:4]...)
+	for _, name := range s.consumers() {
+		binary.LittleEndian.PutUint64(word[:], s.offsets[name])
+		b = append(b, word[:]...)
+		binary.LittleEndian.PutUint16(word[:], uint16(len(name)))
+		b = append(b, word[:2]...)
+		b = append(b, name...)
+	}
+	binary.LittleEndian.PutUint32(word[:], crc32.ChecksumIEEE(b))
+	b = append(b, word[:4]...)
+	s.buf = b
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b)
+	if err == nil && s.sync {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package offset_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/go-diodes/offset"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		dir  string
+		path string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "offset")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "offsets")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	open := func(opts ...offset.Option) *offset.Store {
+		s, err := offset.Open(path, opts...)
+		Expect(err).ToNot(HaveOccurred())
+		return s
+	}
+
+	It("starts out empty", func() {
+		s := open()
+
+		_, ok := s.Offset("collector")
+		Expect(ok).To(BeFalse())
+		Expect(s.Consumers()).To(BeEmpty())
+		Expect(path).ToNot(BeAnExistingFile())
+	})
+
+	It("keeps the offsets of every consumer across restarts", func() {
+		s := open(offset.WithSync())
+		Expect(s.Commit("a", 5)).To(Succeed())
+		Expect(s.Commit("b", 7)).To(Succeed())
+		Expect(s.Commit("a", 6)).To(Succeed())
+
+		s = open()
+		seq, ok := s.Offset("a")
+		Expect(ok).To(BeTrue())
+		Expect(seq).To(Equal(uint64(6)))
+		seq, _ = s.Offset("b")
+		Expect(seq).To(Equal(uint64(7)))
+		Expect(s.Consumers()).To(Equal([]string{"a", "b"}))
+	})
+
+	It("removes the offset of a consumer", func() {
+		s := open()
+		Expect(s.Commit("a", 5)).To(Succeed())
+		Expect(s.Commit("b", 7)).To(Succeed())
+		Expect(s.Remove("a")).To(Succeed())
+
+		s = open()
+		_, ok := s.Offset("a")
+		Expect(ok).To(BeFalse())
+		Expect(s.Consumers()).To(Equal([]string{"b"}))
+	})
+
+	It("keeps the file when a commit fails and writes it with the next one", func() {
+		s := open()
+		Expect(s.Commit("a", 5)).To(Succeed())
+
+		Expect(os.Mkdir(path+".tmp", 0o700)).To(Succeed())
+		Expect(s.Commit("a", 6)).ToNot(Succeed())
+		seq, _ := open().Offset("a")
+		Expect(seq).To(Equal(uint64(5)))
+
+		Expect(os.Remove(path + ".tmp")).To(Succeed())
+		Expect(s.Commit("a", 6)).To(Succeed())
+		seq, _ = open().Offset("a")
+		Expect(seq).To(Equal(uint64(6)))
+	})
+
+	It("refuses invalid consumers", func() {
+		s := open()
+
+		Expect(s.Commit("", 1)).To(MatchError(offset.ErrInvalidConsumer))
+		Expect(s.Commit(strings.Repeat("a", 1<<16), 1)).To(MatchError(offset.ErrInvalidConsumer))
+	})
+
+	It("fails for a corrupt file", func() {
+		Expect(open().Commit("a", 5)).To(Succeed())
+
+		b, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		b[len(b)-5] ^= 0xff
+		Expect(os.WriteFile(path, b, 0o600)).To(Succeed())
+
+		_, err = offset.Open(path)
+		Expect(err).To(MatchError(offset.ErrCorrupt))
+
+		Expect(os.WriteFile(path, []byte("offsets"), 0o600)).To(Succeed())
+		_, err = offset.Open(path)
+		Expect(err).To(MatchError(offset.ErrCorrupt))
+	})
+})
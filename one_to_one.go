@@ -1,6 +1,8 @@
 package diodes
 
 import (
+	"context"
+	"reflect"
 	"sync/atomic"
 	"unsafe"
 )
@@ -23,107 +25,201 @@ func (f AlertFunc) Alert(missed int) {
 	f(missed)
 }
 
-type bucket struct {
-	data GenericDataType
-	seq  uint64 // seq is the recorded write index at the time of writing
-}
-
 // OneToOne diode is meant to be used by a single reader and a single writer.
 // It is not thread safe if used otherwise.
 type OneToOne struct {
-	buffer     []unsafe.Pointer
+	// The 64-bit fields (including the embedded ones) must stay first so
+	// that they are aligned on 32-bit platforms.
 	writeIndex uint64
-	readIndex  uint64
-	alerter    Alerter
+	rejected   uint64
+	reader
+	ring
+
+	closed uint32
 }
 
 // NewOneToOne creates a new diode is meant to be used by a single reader and
 // a single writer. The alerter is invoked on the read's go-routine. It is
 // called when it notices that the writer go-routine has passed it and wrote
 // over data. A nil can be used to ignore alerts.
-func NewOneToOne(size int, alerter Alerter) *OneToOne {
-	if alerter == nil {
-		alerter = AlertFunc(func(int) {})
+//
+// The diode and its ring buffer are allocated in a single block.
+func NewOneToOne(size int, alerter Alerter, opts ...DiodeConfigOption) *OneToOne {
+	p, r := newRing(oneToOneType, size, newDiodeConfig(opts))
+
+	d := (*OneToOne)(p)
+	d.init(r, alerter)
+
+	return d
+}
+
+// NewOneToOneWithBuffer creates a new OneToOne diode that uses the given
+// buffer as its ring buffer instead of allocating one. The size of the diode
+// is len(buffer), or len(buffer)/stride with WithPaddedSlots, where each slot
+// takes up stride pointers of the buffer. This is meant for embedders that
+// manage memory themselves. Any existing contents of the buffer are
+// discarded and the buffer must not be accessed by anything other than the
+// diode while it is in use. It panics if an implementation other than
+// PointerSwap is requested, or if the buffer does not hold a single slot.
+func NewOneToOneWithBuffer(buffer []unsafe.Pointer, alerter Alerter, opts ...DiodeConfigOption) *OneToOne {
+	d := new(OneToOne)
+	d.init(newBufferRing(buffer, newDiodeConfig(opts)), alerter)
+
+	return d
+}
+
+func (d *OneToOne) init(r ring, alerter Alerter) {
+	d.ring = r
+	d.reader.init(recoverAlerter(alerter, d.panics))
+	d.writeIndex = d.start
+	d.readIndex = d.start
+
+	if d.instr.detailed() {
+		d.instr.rates.init(d.Stats)
 	}
 
-	return &OneToOne{
-		buffer:  make([]unsafe.Pointer, size),
-		alerter: alerter,
+	if d.idle != nil {
+		d.idle.start(d.Stats)
+	}
+
+	if d.leak != nil {
+		watchOneToOneLeaks(d)
+	}
+
+	if d.ctx != nil {
+		go func() {
+			<-d.ctx.Done()
+			d.Close()
+		}()
 	}
 }
 
-// Set sets the data in the next slot of the ring buffer.
+var oneToOneType = reflect.TypeOf(OneToOne{})
+
+// Set sets the data in the next slot of the ring buffer. Once the diode is
+// closed, the data is dropped and counted in Stats.Rejected.
 func (d *OneToOne) Set(data GenericDataType) {
-	idx := d.writeIndex % uint64(len(d.buffer))
+	if d.regions {
+		defer startRegion("diode.Set").End()
+	}
+
+	// The writeIndex is only written by the writer, so it can be loaded
+	// without synchronization. It is stored atomically so that it can be
+	// observed from other go-routines.
+	d.owners.checkWriter()
 
-	newBucket := &bucket{
-		data: data,
-		seq:  d.writeIndex,
+	if atomic.LoadUint32(&d.closed) != 0 {
+		atomic.AddUint64(&d.rejected, 1)
+		return
 	}
-	d.writeIndex++
 
-	atomic.StorePointer(&d.buffer[idx], unsafe.Pointer(newBucket))
+	seq := d.writeIndex
+	ts := d.instr.now()
+	d.ring.store(seq%d.size, seq, data, ts)
+	yield()
+	atomic.StoreUint64(&d.writeIndex, seq+1)
+	d.instr.observeOccupancy(seq, &d.readIndex, d.size)
+	d.instr.tick(ts)
+	d.hooks.start()
+	d.rec.record(EventSet, seq, 0)
 }
 
 // TryNext will attempt to read from the next slot of the ring buffer.
 // If there is no data available, it will return (nil, false).
 func (d *OneToOne) TryNext() (data GenericDataType, ok bool) {
-	// Read a value from the ring buffer based on the readIndex.
-	idx := d.readIndex % uint64(len(d.buffer))
-	result := (*bucket)(atomic.SwapPointer(&d.buffer[idx], nil))
-
-	// When the result is nil that means the writer has not had the
-	// opportunity to write a value into the diode. This value must be ignored
-	// and the read head must not increment.
-	if result == nil {
-		return nil, false
+	if d.regions {
+		defer startRegion("diode.TryNext").End()
+	}
+
+	return d.reader.tryNext(&d.ring)
+}
+
+// TryNextGap is TryNext that also returns the values that were overwritten
+// before the one it read, so that the reader knows exactly which part of the
+// stream it lost. The gap is also alerted as usual.
+func (d *OneToOne) TryNextGap() (data GenericDataType, gap Gap, ok bool) {
+	if d.regions {
+		defer startRegion("diode.TryNext").End()
 	}
 
-	// When the seq value is less than the current read index that means a
-	// value was read from idx that was previously written but has since has
-	// been dropped. This value must be ignored and the read head must not
-	// increment.
-	//
-	// The simulation for this scenario assumes the fast forward occurred as
-	// detailed below.
-	//
-	// 5. The reader reads again getting seq 5. It then reads again expecting
-	//    seq 6 but gets seq 2. This is a read of a stale value that was
-	//    effectively "dropped" so the read fails and the read head stays put.
-	//    `| 4 | 5 | 2 | 3 |` r: 7, w: 6
-	//
-	if result.seq < d.readIndex {
-		return nil, false
+	return d.reader.tryNextGap(&d.ring)
+}
+
+// Instrumentation returns a snapshot of the values recorded by the diode's
+// instrumentation. It is safe to call from any go-routine.
+func (d *OneToOne) Instrumentation() Instrumentation {
+	return d.instr.snapshot()
+}
+
+// Lag returns how many values the reader is behind the writer. When it
+// exceeds the size of the diode, the writer has lapped the reader and data
+// will be dropped. It is safe to call from any go-routine.
+func (d *OneToOne) Lag() uint64 {
+	return d.reader.lag(atomic.LoadUint64(&d.writeIndex))
+}
+
+// Stats returns a snapshot of the diode's statistics. It is safe to call from
+// any go-routine.
+func (d *OneToOne) Stats() Stats {
+	writeIndex := atomic.LoadUint64(&d.writeIndex)
+	s := d.reader.stats(&d.ring, writeIndex-d.start, writeIndex)
+	s.Rejected = atomic.LoadUint64(&d.rejected)
+	return s
+}
+
+// Drain blocks the writer until the reader read or dropped everything that
+// was set so far, such as to flush the logs before responding to a request.
+// It returns the context's error if the context is done first.
+func (d *OneToOne) Drain(ctx context.Context) error {
+	return d.reader.drain(ctx, atomic.LoadUint64(&d.writeIndex))
+}
+
+// Close closes the diode to signal the end of the stream. The data that is
+// set afterwards is dropped, while the reader can still read what is left.
+// It is safe to call from any go-routine and always returns nil.
+func (d *OneToOne) Close() error {
+	atomic.StoreUint32(&d.closed, 1)
+	d.idle.stop()
+	d.hooks.stop()
+	if d.leak != nil {
+		d.leak.check(d.Stats(), d.start)
 	}
+	return nil
+}
+
+// Closed reports whether the diode is closed. It is safe to call from any
+// go-routine.
+func (d *OneToOne) Closed() bool {
+	return atomic.LoadUint32(&d.closed) != 0
+}
 
-	// When the seq value is greater than the current read index that means a
-	// value was read from idx that overwrote the value that was expected to
-	// be at this idx. This happens when the writer has lapped the reader. The
-	// reader needs to catch up to the writer so it moves its write head to
-	// the new seq, effectively dropping the messages that were not read in
-	// between the two values.
-	//
-	// Here is a simulation of this scenario:
-	//
-	// 1. Both the read and write heads start at 0.
-	//    `| nil | nil | nil | nil |` r: 0, w: 0
-	// 2. The writer fills the buffer.
-	//    `| 0 | 1 | 2 | 3 |` r: 0, w: 4
-	// 3. The writer laps the read head.
-	//    `| 4 | 5 | 2 | 3 |` r: 0, w: 6
-	// 4. The reader reads the first value, expecting a seq of 0 but reads 4,
-	//    this forces the reader to fast forward to 5.
-	//    `| 4 | 5 | 2 | 3 |` r: 5, w: 6
-	//
-	if result.seq > d.readIndex {
-		dropped := result.seq - d.readIndex
-		d.readIndex = result.seq
-		d.alerter.Alert(int(dropped))
+// Reopen empties a closed diode and opens it again, so that it can be
+// reused, such as from a free list of diodes for connections, without
+// allocating a new one. The statistics and the hooks start over, while the
+// instrumentation keeps counting. It returns ErrNotClosed if the diode is
+// open and ErrBoundToContext if it was created WithContext. Reopen must not
+// be used concurrently with the reader or the writers of the diode.
+func (d *OneToOne) Reopen() error {
+	if d.ctx != nil {
+		return ErrBoundToContext
 	}
+	if !d.Closed() {
+		return ErrNotClosed
+	}
+
+	d.ring.reset()
+	d.reader.reset(d.start)
+	d.hooks.reset()
+	d.leak.reset()
+	atomic.StoreUint64(&d.writeIndex, d.start)
+	atomic.StoreUint64(&d.rejected, 0)
+	atomic.StoreUint32(&d.closed, 0)
+	if d.idle != nil {
+		d.idle.start(d.Stats)
+	}
+	return nil
+}
 
-	// Only increment read index if a regular read occurred (where seq was
-	// equal to readIndex) or a value was read that caused a fast forward
-	// (where seq was greater than readIndex).
-	d.readIndex++
-	return result.data, true
+func (d *OneToOne) boundContext() context.Context {
+	return d.ctx
 }
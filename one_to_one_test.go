@@ -1,13 +1,19 @@
 package diodes_test
 
 import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
 	"code.cloudfoundry.org/go-diodes"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
-var _ = Describe("OneToOne", func() {
+var _ = forEachImplementation("OneToOne", func(impl diodes.Implementation) {
 	var (
 		d    *diodes.OneToOne
 		data []byte
@@ -18,7 +24,7 @@ var _ = Describe("OneToOne", func() {
 	BeforeEach(func() {
 		spy = newSpyAlerter()
 
-		d = diodes.NewOneToOne(5, spy)
+		d = diodes.NewOneToOne(5, spy, diodes.WithImplementation(impl))
 
 		data = []byte("some-data")
 		d.Set(diodes.GenericDataType(&data))
@@ -111,7 +117,7 @@ var _ = Describe("OneToOne", func() {
 
 			Context("writer laps reader with nil alerter", func() {
 				It("drops the alert", func() {
-					d = diodes.NewOneToOne(5, nil)
+					d = diodes.NewOneToOne(5, nil, diodes.WithImplementation(impl))
 					for i := 0; i < 10; i++ {
 						d.Set(diodes.GenericDataType(&secondData))
 					}
@@ -124,13 +130,140 @@ var _ = Describe("OneToOne", func() {
 		})
 	})
 
+	Describe("Close()", func() {
+		BeforeEach(func() {
+			Expect(d.Close()).To(Succeed())
+		})
+
+		It("reports that it is closed", func() {
+			Expect(d.Closed()).To(BeTrue())
+		})
+
+		It("keeps the data that was set before Close", func() {
+			result, ok := d.TryNext()
+			Expect(ok).To(BeTrue())
+			Expect(*(*[]byte)(result)).To(Equal([]byte("some-data")))
+		})
+
+		It("rejects the data that is set after Close", func() {
+			d.Set(diodes.GenericDataType(&data))
+			d.Set(diodes.GenericDataType(&data))
+
+			d.TryNext()
+			_, ok := d.TryNext()
+			Expect(ok).To(BeFalse())
+			Expect(d.Stats().Rejected).To(Equal(uint64(2)))
+			Expect(d.Stats().Writes).To(Equal(uint64(1)))
+		})
+	})
+
+	Describe("Drain()", func() {
+		It("waits until the reader read what was set", func() {
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				d.TryNext()
+			}()
+
+			Expect(d.Drain(context.Background())).To(Succeed())
+			Expect(d.Lag()).To(BeZero())
+		})
+
+		It("returns once the context is done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			Expect(d.Drain(ctx)).To(MatchError(context.DeadlineExceeded))
+		})
+	})
+
+	Describe("WithContext()", func() {
+		It("closes the diode once the context is done", func() {
+			var stops int32
+			ctx, cancel := context.WithCancel(context.Background())
+			d = diodes.NewOneToOne(5, nil,
+				diodes.WithImplementation(impl),
+				diodes.WithContext(ctx),
+				diodes.WithHooks(diodes.Hooks{OnStop: func() { atomic.AddInt32(&stops, 1) }}),
+			)
+			Expect(d.Closed()).To(BeFalse())
+
+			cancel()
+			Eventually(d.Closed).Should(BeTrue())
+			Expect(atomic.LoadInt32(&stops)).To(Equal(int32(1)))
+		})
+	})
+
+	Describe("Reopen()", func() {
+		It("does not reopen a diode that is open", func() {
+			Expect(d.Reopen()).To(MatchError(diodes.ErrNotClosed))
+
+			result, ok := d.TryNext()
+			Expect(ok).To(BeTrue())
+			Expect(*(*[]byte)(result)).To(Equal([]byte("some-data")))
+		})
+
+		It("empties a closed diode and opens it again", func() {
+			for i := 0; i < 7; i++ {
+				d.Set(diodes.GenericDataType(&data))
+			}
+			d.Close()
+			d.Set(diodes.GenericDataType(&data))
+
+			Expect(d.Reopen()).To(Succeed())
+			Expect(d.Closed()).To(BeFalse())
+			Expect(d.Stats()).To(Equal(diodes.Stats{Capacity: 5}))
+			_, ok := d.TryNext()
+			Expect(ok).To(BeFalse())
+
+			for i := 0; i < 3; i++ {
+				v := []byte{byte(i)}
+				d.Set(diodes.GenericDataType(&v))
+			}
+			for i := 0; i < 3; i++ {
+				result, ok := d.TryNext()
+				Expect(ok).To(BeTrue())
+				Expect(*(*[]byte)(result)).To(Equal([]byte{byte(i)}))
+			}
+			Expect(spy.AlertInput.Missed).ToNot(Receive())
+
+			Expect(d.Reopen()).To(MatchError(diodes.ErrNotClosed))
+			d.Close()
+			Expect(d.Reopen()).To(Succeed())
+		})
+
+		It("rearms the hooks", func() {
+			var starts, stops int
+			d = diodes.NewOneToOne(5, nil, diodes.WithImplementation(impl), diodes.WithHooks(diodes.Hooks{
+				OnStart: func() { starts++ },
+				OnStop:  func() { stops++ },
+			}))
+
+			for i := 0; i < 2; i++ {
+				d.Set(diodes.GenericDataType(&data))
+				d.Close()
+				Expect(d.Reopen()).To(Succeed())
+			}
+
+			Expect(starts).To(Equal(2))
+			Expect(stops).To(Equal(2))
+		})
+
+		It("does not reopen a diode that is bound to a context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			d = diodes.NewOneToOne(5, nil, diodes.WithImplementation(impl), diodes.WithContext(ctx))
+			cancel()
+			Eventually(d.Closed).Should(BeTrue())
+
+			Expect(d.Reopen()).To(MatchError(diodes.ErrBoundToContext))
+		})
+	})
 })
 
-var _ = Describe("reader ahead of writer", func() {
+var _ = forEachImplementation("reader ahead of writer", func(impl diodes.Implementation) {
 	It("must not occur after alerting", func() {
 		length := 4
 		spy := newSpyAlerter()
-		d := diodes.NewOneToOne(length, spy)
+		d := diodes.NewOneToOne(length, spy, diodes.WithImplementation(impl))
 		data := []byte("some-data")
 		genData := diodes.GenericDataType(&data)
 
@@ -157,6 +290,66 @@ var _ = Describe("reader ahead of writer", func() {
 	})
 })
 
+var _ = Describe("NewOneToOne()", func() {
+	It("allocates the diode and its buffer together", func() {
+		diodes.NewOneToOne(1024, nil)
+		allocs := testing.AllocsPerRun(10, func() {
+			diodes.NewOneToOne(1024, nil)
+		})
+		Expect(allocs).To(Equal(1.0))
+	})
+})
+
+var _ = Describe("NewOneToOneWithBuffer()", func() {
+	It("uses the given buffer as its ring", func() {
+		buffer := make([]unsafe.Pointer, 4)
+		d := diodes.NewOneToOneWithBuffer(buffer, nil)
+		data := []byte("some-data")
+		d.Set(diodes.GenericDataType(&data))
+
+		Expect(buffer[0] != nil).To(BeTrue())
+
+		result, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*[]byte)(result)).To(Equal(data))
+	})
+
+	It("panics when the Seqlock implementation is requested", func() {
+		Expect(func() {
+			diodes.NewOneToOneWithBuffer(make([]unsafe.Pointer, 4), nil, diodes.WithImplementation(diodes.Seqlock))
+		}).To(Panic())
+	})
+
+	It("panics when the buffer is empty", func() {
+		Expect(func() {
+			diodes.NewOneToOneWithBuffer(nil, nil)
+		}).To(PanicWith("diodes: a caller provided buffer must not be empty"))
+	})
+
+	It("discards existing contents of the buffer", func() {
+		data := []byte("some-data")
+		buffer := []unsafe.Pointer{unsafe.Pointer(&data), unsafe.Pointer(&data)}
+		d := diodes.NewOneToOneWithBuffer(buffer, nil)
+
+		_, ok := d.TryNext()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("drops data once the buffer is lapped", func() {
+		spy := newSpyAlerter()
+		d := diodes.NewOneToOneWithBuffer(make([]unsafe.Pointer, 2), spy)
+		for i := 0; i < 5; i++ {
+			j := i
+			d.Set(diodes.GenericDataType(&j))
+		}
+
+		result, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(result)).To(Equal(4))
+		Expect(spy.AlertInput.Missed).To(Receive(Equal(4)))
+	})
+})
+
 type spyAlerter struct {
 	AlertCalled chan bool
 	AlertInput  struct {
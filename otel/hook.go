@@ -0,0 +1,88 @@
+package otel
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// MetricsHook is a diodes.MetricsHook that records the measurements of a
+// diode with OpenTelemetry instruments. Unlike RegisterMetrics, it also
+// records the distribution of the latencies. It creates drops and lag
+// instruments just like RegisterMetrics, so the two are not meant to be used
+// with the same prefix.
+type MetricsHook struct {
+	lag uint64
+
+	drops   metric.Int64Counter
+	latency metric.Float64Histogram
+	attrs   metric.MeasurementOption
+}
+
+// NewMetricsHook creates the instruments of a new MetricsHook with the meter.
+// The options are those of RegisterMetrics.
+func NewMetricsHook(meter metric.Meter, opts ...MetricsOption) (*MetricsHook, error) {
+	c := metricsConfig{
+		prefix: "diode",
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	h := &MetricsHook{
+		attrs: metric.WithAttributeSet(attribute.NewSet(c.attributes...)),
+	}
+
+	var err error
+	h.drops, err = meter.Int64Counter(c.prefix+".drops",
+		metric.WithDescription("Total number of values dropped by the diode."),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	h.latency, err = meter.Float64Histogram(c.prefix+".latency",
+		metric.WithDescription("Time values spent in the diode before they were read."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Int64ObservableGauge(c.prefix+".lag",
+		metric.WithDescription("Number of values the reader is behind the writer."),
+		metric.WithUnit("{value}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(atomic.LoadUint64(&h.lag)), h.attrs)
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// IncDrops implements diodes.MetricsHook.
+func (h *MetricsHook) IncDrops(n uint64) {
+	h.drops.Add(context.Background(), int64(n), h.attrs)
+}
+
+// ObserveLatency implements diodes.MetricsHook.
+func (h *MetricsHook) ObserveLatency(d time.Duration) {
+	h.latency.Record(context.Background(), d.Seconds(), h.attrs)
+}
+
+// SetLag implements diodes.MetricsHook. The lag is observed whenever the
+// meter's reader collects.
+func (h *MetricsHook) SetLag(lag uint64) {
+	atomic.StoreUint64(&h.lag, lag)
+}
+
+var _ diodes.MetricsHook = (*MetricsHook)(nil)
@@ -0,0 +1,52 @@
+package otel_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+	diodesotel "code.cloudfoundry.org/go-diodes/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MetricsHook", func() {
+	It("records the measurements of the diode", func() {
+		reader := sdkmetric.NewManualReader()
+		provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		h, err := diodesotel.NewMetricsHook(provider.Meter("test"))
+		Expect(err).ToNot(HaveOccurred())
+
+		d := diodes.NewOneToOne(4, nil, diodes.WithMetricsHook(h))
+		data := []byte("some-data")
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+
+		values := make(map[string]interface{})
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				switch data := m.Data.(type) {
+				case metricdata.Sum[int64]:
+					values[m.Name] = data.DataPoints[0].Value
+				case metricdata.Gauge[int64]:
+					values[m.Name] = data.DataPoints[0].Value
+				case metricdata.Histogram[float64]:
+					values[m.Name] = data.DataPoints[0].Count
+				}
+			}
+		}
+
+		Expect(values).To(Equal(map[string]interface{}{
+			"diode.drops":   int64(4),
+			"diode.lag":     int64(6),
+			"diode.latency": uint64(1),
+		}))
+	})
+})
@@ -0,0 +1,110 @@
+// Package otel provides OpenTelemetry instrumentation for diodes.
+package otel
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// MetricsOption can be used to setup the instruments.
+type MetricsOption func(*metricsConfig)
+
+type metricsConfig struct {
+	prefix     string
+	attributes []attribute.KeyValue
+}
+
+// WithPrefix sets the prefix of the instrument names. The default is
+// "diode".
+func WithPrefix(prefix string) MetricsOption {
+	return MetricsOption(func(c *metricsConfig) {
+		c.prefix = prefix
+	})
+}
+
+// WithAttributes sets attributes that are recorded with every measurement.
+// They are used to tell several diodes apart.
+func WithAttributes(attributes ...attribute.KeyValue) MetricsOption {
+	return MetricsOption(func(c *metricsConfig) {
+		c.attributes = append(c.attributes, attributes...)
+	})
+}
+
+// RegisterMetrics creates asynchronous instruments for the Stats of the given
+// diode with the meter. The instruments are observed whenever the meter's
+// reader collects. The returned registration can be used to stop observing
+// the diode.
+func RegisterMetrics(meter metric.Meter, d diodes.StatsReporter, opts ...MetricsOption) (metric.Registration, error) {
+	c := metricsConfig{
+		prefix: "diode",
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	writes, err := meter.Int64ObservableCounter(c.prefix+".writes",
+		metric.WithDescription("Total number of values written to the diode."),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reads, err := meter.Int64ObservableCounter(c.prefix+".reads",
+		metric.WithDescription("Total number of values read from the diode."),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	drops, err := meter.Int64ObservableCounter(c.prefix+".drops",
+		metric.WithDescription("Total number of values dropped by the diode."),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	lag, err := meter.Int64ObservableGauge(c.prefix+".lag",
+		metric.WithDescription("Number of values the reader is behind the writer."),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	occupancy, err := meter.Int64ObservableGauge(c.prefix+".occupancy",
+		metric.WithDescription("Number of values held by the diode."),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity, err := meter.Int64ObservableGauge(c.prefix+".capacity",
+		metric.WithDescription("Number of values the diode can hold."),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := metric.WithAttributeSet(attribute.NewSet(c.attributes...))
+
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := d.Stats()
+
+		o.ObserveInt64(writes, int64(s.Writes), attrs)
+		o.ObserveInt64(reads, int64(s.Reads), attrs)
+		o.ObserveInt64(drops, int64(s.Drops), attrs)
+		o.ObserveInt64(lag, int64(s.Lag), attrs)
+		o.ObserveInt64(occupancy, int64(s.Occupancy()), attrs)
+		o.ObserveInt64(capacity, int64(s.Capacity), attrs)
+
+		return nil
+	}, writes, reads, drops, lag, occupancy, capacity)
+}
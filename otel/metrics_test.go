@@ -0,0 +1,89 @@
+package otel_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+	diodesotel "code.cloudfoundry.org/go-diodes/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RegisterMetrics", func() {
+	var (
+		d        *diodes.OneToOne
+		reader   sdkmetric.Reader
+		provider *sdkmetric.MeterProvider
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(4, nil)
+		reader = sdkmetric.NewManualReader()
+		provider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+		data := []byte("some-data")
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+	})
+
+	collect := func() map[string]metricdata.DataPoint[int64] {
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+
+		points := make(map[string]metricdata.DataPoint[int64])
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				switch data := m.Data.(type) {
+				case metricdata.Sum[int64]:
+					points[m.Name] = data.DataPoints[0]
+				case metricdata.Gauge[int64]:
+					points[m.Name] = data.DataPoints[0]
+				}
+			}
+		}
+		return points
+	}
+
+	It("observes the stats of the diode", func() {
+		_, err := diodesotel.RegisterMetrics(provider.Meter("test"), d)
+		Expect(err).ToNot(HaveOccurred())
+
+		points := collect()
+		Expect(points["diode.writes"].Value).To(Equal(int64(6)))
+		Expect(points["diode.reads"].Value).To(Equal(int64(1)))
+		Expect(points["diode.drops"].Value).To(Equal(int64(4)))
+		Expect(points["diode.lag"].Value).To(Equal(int64(1)))
+		Expect(points["diode.occupancy"].Value).To(Equal(int64(1)))
+		Expect(points["diode.capacity"].Value).To(Equal(int64(4)))
+	})
+
+	It("records the attributes with every measurement", func() {
+		_, err := diodesotel.RegisterMetrics(provider.Meter("test"), d,
+			diodesotel.WithPrefix("app.buffer"),
+			diodesotel.WithAttributes(attribute.String("source", "a")),
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		points := collect()
+		Expect(points).To(HaveKey("app.buffer.writes"))
+		for _, p := range points {
+			v, ok := p.Attributes.Value("source")
+			Expect(ok).To(BeTrue())
+			Expect(v.AsString()).To(Equal("a"))
+		}
+	})
+
+	It("stops observing once unregistered", func() {
+		reg, err := diodesotel.RegisterMetrics(provider.Meter("test"), d)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reg.Unregister()).To(Succeed())
+
+		Expect(collect()).To(BeEmpty())
+	})
+})
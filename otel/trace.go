@@ -0,0 +1,78 @@
+package otel
+
+import (
+	"context"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Traced wraps a diode and carries the span context that is active when a
+// value is set along with the value. This keeps the traces of work items
+// connected across the diode. Each value is wrapped in a small allocation.
+type Traced struct {
+	d diodes.Diode
+}
+
+// tracedValue is the value stored in the wrapped diode.
+type tracedValue struct {
+	sc   trace.SpanContext
+	data diodes.GenericDataType
+}
+
+// NewTraced returns a Traced that stores its values in the given diode. The
+// diode must only be written and read through the Traced.
+func NewTraced(d diodes.Diode) *Traced {
+	return &Traced{d: d}
+}
+
+// Set captures the span context of ctx and sets it together with the data.
+func (t *Traced) Set(ctx context.Context, data diodes.GenericDataType) {
+	t.d.Set(diodes.GenericDataType(&tracedValue{
+		sc:   trace.SpanContextFromContext(ctx),
+		data: data,
+	}))
+}
+
+// TryNext will attempt to read the next value. The span context captured by
+// Set is restored into ctx as the remote parent so that spans started from
+// the returned context continue the writer's trace. If the writer had no
+// span, ctx is returned as it is. If there is no data available, it will
+// return (ctx, nil, false).
+func (t *Traced) TryNext(ctx context.Context) (context.Context, diodes.GenericDataType, bool) {
+	v, ok := t.tryNext()
+	if !ok {
+		return ctx, nil, false
+	}
+
+	if v.sc.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, v.sc)
+	}
+
+	return ctx, v.data, true
+}
+
+// TryNextLink will attempt to read the next value. Rather than continuing
+// the writer's trace, it returns a link to the span context captured by Set.
+// The link can be added to a new span with trace.WithLinks, which suits
+// readers that handle values in batches. If the writer had no span, the
+// link's span context is invalid. If there is no data available, it will
+// return (trace.Link{}, nil, false).
+func (t *Traced) TryNextLink() (trace.Link, diodes.GenericDataType, bool) {
+	v, ok := t.tryNext()
+	if !ok {
+		return trace.Link{}, nil, false
+	}
+
+	return trace.Link{SpanContext: v.sc}, v.data, true
+}
+
+func (t *Traced) tryNext() (*tracedValue, bool) {
+	data, ok := t.d.TryNext()
+	if !ok {
+		return nil, false
+	}
+
+	return (*tracedValue)(unsafe.Pointer(data)), true
+}
@@ -0,0 +1,74 @@
+package otel_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+	diodesotel "code.cloudfoundry.org/go-diodes/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Traced", func() {
+	var (
+		t        *diodesotel.Traced
+		recorder *tracetest.SpanRecorder
+		tracer   trace.Tracer
+		data     []byte
+	)
+
+	BeforeEach(func() {
+		t = diodesotel.NewTraced(diodes.NewOneToOne(4, nil))
+		recorder = tracetest.NewSpanRecorder()
+		tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		data = []byte("some-data")
+	})
+
+	It("continues the writer's trace", func() {
+		ctx, span := tracer.Start(context.Background(), "write")
+		t.Set(ctx, diodes.GenericDataType(&data))
+		span.End()
+
+		ctx, value, ok := t.TryNext(context.Background())
+		Expect(ok).To(BeTrue())
+		Expect(*(*[]byte)(value)).To(Equal(data))
+
+		_, read := tracer.Start(ctx, "read")
+		read.End()
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[1].Parent().SpanID()).To(Equal(spans[0].SpanContext().SpanID()))
+		Expect(spans[1].SpanContext().TraceID()).To(Equal(spans[0].SpanContext().TraceID()))
+	})
+
+	It("links to the writer's span", func() {
+		ctx, span := tracer.Start(context.Background(), "write")
+		t.Set(ctx, diodes.GenericDataType(&data))
+		span.End()
+
+		link, _, ok := t.TryNextLink()
+		Expect(ok).To(BeTrue())
+		Expect(link.SpanContext.SpanID()).To(Equal(span.SpanContext().SpanID()))
+	})
+
+	It("returns the context as it is when the writer had no span", func() {
+		t.Set(context.Background(), diodes.GenericDataType(&data))
+
+		ctx, _, ok := t.TryNext(context.Background())
+		Expect(ok).To(BeTrue())
+		Expect(trace.SpanContextFromContext(ctx).IsValid()).To(BeFalse())
+	})
+
+	It("returns false when there is no data", func() {
+		_, _, ok := t.TryNext(context.Background())
+		Expect(ok).To(BeFalse())
+
+		_, _, ok = t.TryNextLink()
+		Expect(ok).To(BeFalse())
+	})
+})
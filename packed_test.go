@@ -0,0 +1,110 @@
+package diodes_test
+
+import (
+	"testing"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("Packed", func(impl diodes.Implementation) {
+	var (
+		d *diodes.OneToOne
+		p *diodes.Packed
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(2, nil, diodes.WithImplementation(impl))
+		p = diodes.NewPacked(d, 3)
+	})
+
+	set := func(from, to int) {
+		for i := from; i < to; i++ {
+			v := i
+			p.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func() []int {
+		var values []int
+		for {
+			data, ok := p.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, *(*int)(data))
+		}
+	}
+
+	It("sets a bucket on the wrapped diode once it is full", func() {
+		set(0, 2)
+		Expect(d.Stats().Writes).To(BeZero())
+		Expect(readAll()).To(BeEmpty())
+
+		set(2, 4)
+		Expect(d.Stats().Writes).To(Equal(uint64(1)))
+		Expect(readAll()).To(Equal([]int{0, 1, 2}))
+	})
+
+	It("sets the pending values when flushed", func() {
+		set(0, 4)
+		p.Flush()
+		p.Flush()
+
+		Expect(d.Stats().Writes).To(Equal(uint64(2)))
+		Expect(readAll()).To(Equal([]int{0, 1, 2, 3}))
+	})
+
+	It("unpacks a bucket across reads", func() {
+		set(0, 6)
+
+		for i := 0; i < 6; i++ {
+			data, ok := p.TryNext()
+			Expect(ok).To(BeTrue())
+			Expect(*(*int)(data)).To(Equal(i))
+
+			set(6+i, 7+i)
+		}
+	})
+
+	It("counts the values of the dropped buckets", func() {
+		set(0, 12)
+		p.Flush()
+
+		Expect(readAll()).To(Equal([]int{6, 7, 8, 9, 10, 11}))
+		Expect(p.Dropped()).To(Equal(uint64(6)))
+	})
+
+	It("counts the values of a dropped partial bucket", func() {
+		set(0, 1)
+		p.Flush()
+		set(1, 7)
+		p.Flush()
+		set(7, 8)
+		p.Flush()
+
+		Expect(readAll()).To(Equal([]int{4, 5, 6, 7}))
+		Expect(p.Dropped()).To(Equal(uint64(4)))
+	})
+
+	It("panics when a bucket cannot hold a value", func() {
+		Expect(func() { diodes.NewPacked(d, 0) }).To(Panic())
+	})
+
+	It("allocates a bucket and its values for k values", func() {
+		data := diodes.GenericDataType(&struct{}{})
+		allocs := testing.AllocsPerRun(10, func() {
+			for i := 0; i < 3; i++ {
+				p.Set(data)
+			}
+		})
+
+		expected := 2.0
+		if impl == diodes.PointerSwap {
+			expected++
+		}
+		Expect(allocs).To(Equal(expected))
+	})
+})
@@ -0,0 +1,139 @@
+package diodes
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// CallbackPanic is a panic of a user-supplied callback that was recovered
+// and handed to the panic handler.
+type CallbackPanic struct {
+	// Callback names the callback that panicked, such as "Alerter",
+	// "OnDrop", "MetricsHook.SetLag", "LeakHook", "IdleCallback",
+	// "Transform" or "Filter".
+	Callback string
+
+	// Value is the value the callback panicked with.
+	Value interface{}
+
+	// Stack is the stack trace of the go-routine at the time of the panic.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (p *CallbackPanic) Error() string {
+	return fmt.Sprintf("diodes: %s panicked: %v", p.Callback, p.Value)
+}
+
+// WithPanicHandler recovers the panics of the callbacks of the diode and
+// hands them to fn instead of letting them crash the reader or writer that
+// invoked the callback. It covers the alerter, the Hooks, the MetricsHook,
+// the leak hook and the idle callback. A callback that panicked is skipped
+// as if it returned, and is invoked again the next time. fn is invoked on
+// the go-routine of the callback and must not panic itself. By default the
+// panics are not recovered. It applies to the OneToOne, ManyToOne and
+// Segmented diodes.
+func WithPanicHandler(fn func(*CallbackPanic)) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.panics = fn
+	})
+}
+
+// recoverCallback recovers a panic of the named callback and hands it to
+// the handler. It must be deferred directly.
+func recoverCallback(handler func(*CallbackPanic), callback string) {
+	if v := recover(); v != nil {
+		handler(&CallbackPanic{Callback: callback, Value: v, Stack: debug.Stack()})
+	}
+}
+
+// recoverCallbacks wraps the callbacks of the config so that their panics
+// are handed to the panic handler. It is invoked once all options are
+// applied, so that the order of the options does not matter.
+func (c *diodeConfig) recoverCallbacks() {
+	if c.panics == nil {
+		return
+	}
+
+	if c.metricsHook != nil {
+		c.metricsHook = recoveringMetricsHook{hook: c.metricsHook, handler: c.panics}
+	}
+
+	if c.hooks != nil {
+		c.hooks.OnStart = recoverFunc(c.hooks.OnStart, c.panics, "OnStart")
+		c.hooks.OnStop = recoverFunc(c.hooks.OnStop, c.panics, "OnStop")
+		if fn := c.hooks.OnDrop; fn != nil {
+			handler := c.panics
+			c.hooks.OnDrop = func(missed int) {
+				defer recoverCallback(handler, "OnDrop")
+				fn(missed)
+			}
+		}
+	}
+
+	if c.leak != nil {
+		fn, handler := c.leak.fn, c.panics
+		c.leak.fn = func(l Leak) {
+			defer recoverCallback(handler, "LeakHook")
+			fn(l)
+		}
+	}
+
+	if c.idle != nil {
+		c.idle.fn = recoverFunc(c.idle.fn, c.panics, "IdleCallback")
+	}
+}
+
+// recoverFunc wraps fn so that its panics are handed to the handler. A nil
+// fn stays nil.
+func recoverFunc(fn func(), handler func(*CallbackPanic), callback string) func() {
+	if fn == nil {
+		return nil
+	}
+
+	return func() {
+		defer recoverCallback(handler, callback)
+		fn()
+	}
+}
+
+// recoverAlerter wraps the alerter so that its panics are handed to the
+// handler. Without a handler or an alerter, the alerter is returned as is.
+func recoverAlerter(alerter Alerter, handler func(*CallbackPanic)) Alerter {
+	if alerter == nil || handler == nil {
+		return alerter
+	}
+
+	return recoveringAlerter{alerter: alerter, handler: handler}
+}
+
+type recoveringAlerter struct {
+	alerter Alerter
+	handler func(*CallbackPanic)
+}
+
+func (a recoveringAlerter) Alert(missed int) {
+	defer recoverCallback(a.handler, "Alerter")
+	a.alerter.Alert(missed)
+}
+
+type recoveringMetricsHook struct {
+	hook    MetricsHook
+	handler func(*CallbackPanic)
+}
+
+func (h recoveringMetricsHook) IncDrops(n uint64) {
+	defer recoverCallback(h.handler, "MetricsHook.IncDrops")
+	h.hook.IncDrops(n)
+}
+
+func (h recoveringMetricsHook) ObserveLatency(d time.Duration) {
+	defer recoverCallback(h.handler, "MetricsHook.ObserveLatency")
+	h.hook.ObserveLatency(d)
+}
+
+func (h recoveringMetricsHook) SetLag(n uint64) {
+	defer recoverCallback(h.handler, "MetricsHook.SetLag")
+	h.hook.SetLag(n)
+}
@@ -0,0 +1,158 @@
+package diodes_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("WithPanicHandler", func(impl diodes.Implementation) {
+	var panics []*diodes.CallbackPanic
+
+	BeforeEach(func() {
+		panics = nil
+	})
+
+	handler := diodes.WithPanicHandler(func(p *diodes.CallbackPanic) {
+		panics = append(panics, p)
+	})
+
+	callbacks := func() []string {
+		var names []string
+		for _, p := range panics {
+			names = append(names, p.Callback)
+		}
+		return names
+	}
+
+	set := func(d diodes.Diode, n int) {
+		for i := 0; i < n; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	explode := diodes.AlertFunc(func(int) { panic("boom") })
+
+	It("recovers a panic of the alerter and keeps reading", func() {
+		for _, d := range []diodes.Diode{
+			diodes.NewOneToOne(4, explode, diodes.WithImplementation(impl), handler),
+			diodes.NewManyToOne(4, explode, diodes.WithImplementation(impl), handler),
+		} {
+			panics = nil
+			set(d, 6)
+
+			data, ok := d.TryNext()
+			Expect(ok).To(BeTrue())
+			Expect(*(*int)(data)).To(BeNumerically(">=", 2))
+			Expect(callbacks()).To(Equal([]string{"Alerter"}))
+
+			p := panics[0]
+			Expect(p.Value).To(Equal("boom"))
+			Expect(p.Error()).To(Equal("diodes: Alerter panicked: boom"))
+			Expect(string(p.Stack)).To(ContainSubstring("panic"))
+		}
+	})
+
+	It("recovers the panics of the hooks", func() {
+		d := diodes.NewOneToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithHooks(diodes.Hooks{
+				OnStart: func() { panic("start") },
+				OnStop:  func() { panic("stop") },
+				OnDrop:  func(int) { panic("drop") },
+			}),
+			handler,
+		)
+		set(d, 6)
+		d.TryNext()
+		Expect(d.Close()).To(Succeed())
+
+		Expect(callbacks()).To(Equal([]string{"OnStart", "OnDrop", "OnStop"}))
+	})
+
+	It("recovers the panics of the metrics hook", func() {
+		d := diodes.NewOneToOne(2, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithMetricsHook(panickingMetricsHook{}),
+			handler,
+		)
+		set(d, 3)
+		_, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+
+		Expect(callbacks()).To(ContainElement("MetricsHook.SetLag"))
+		Expect(callbacks()).To(ContainElement("MetricsHook.IncDrops"))
+		Expect(callbacks()).To(ContainElement("MetricsHook.ObserveLatency"))
+	})
+
+	It("recovers a panic of the leak hook", func() {
+		d := diodes.NewOneToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithLeakHook(func(diodes.Leak) { panic("leak") }),
+			handler,
+		)
+		set(d, 1)
+		Expect(d.Close()).To(Succeed())
+
+		Expect(callbacks()).To(Equal([]string{"LeakHook"}))
+	})
+
+	It("does not depend on the order of the options", func() {
+		d := diodes.NewOneToOne(4, nil,
+			diodes.WithImplementation(impl),
+			handler,
+			diodes.WithHooks(diodes.Hooks{OnStart: func() { panic("start") }}),
+		)
+		set(d, 1)
+
+		Expect(callbacks()).To(Equal([]string{"OnStart"}))
+	})
+
+	It("does not recover the panics without a handler", func() {
+		d := diodes.NewOneToOne(4, explode, diodes.WithImplementation(impl))
+		set(d, 6)
+
+		Expect(func() { d.TryNext() }).To(PanicWith("boom"))
+	})
+})
+
+var _ = Describe("WithPanicHandler", func() {
+	It("recovers a panic of the idle callback", func() {
+		panics := make(chan *diodes.CallbackPanic, 1)
+		d := diodes.NewOneToOne(4, nil,
+			diodes.WithIdleCallback(10*time.Millisecond, func() { panic("idle") }),
+			diodes.WithPanicHandler(func(p *diodes.CallbackPanic) { panics <- p }),
+		)
+		defer d.Close()
+
+		var p *diodes.CallbackPanic
+		Eventually(panics).Should(Receive(&p))
+		Expect(p.Callback).To(Equal("IdleCallback"))
+	})
+
+	It("recovers a panic of the alerter of a Segmented", func() {
+		var panics []*diodes.CallbackPanic
+		d := diodes.NewSegmented(2, 2, diodes.AlertFunc(func(int) { panic("boom") }),
+			diodes.WithPanicHandler(func(p *diodes.CallbackPanic) { panics = append(panics, p) }),
+		)
+		for i := 0; i < 8; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+
+		_, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(panics).To(HaveLen(1))
+		Expect(panics[0].Callback).To(Equal("Alerter"))
+	})
+})
+
+type panickingMetricsHook struct{}
+
+func (panickingMetricsHook) IncDrops(uint64)              { panic("drops") }
+func (panickingMetricsHook) ObserveLatency(time.Duration) { panic("latency") }
+func (panickingMetricsHook) SetLag(uint64)                { panic("lag") }
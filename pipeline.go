@@ -0,0 +1,223 @@
+package diodes
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// StageFunc processes a value of a pipeline stage. The returned value is set
+// on the diode of the next stage. A nil ends the processing of the value,
+// such as for a value that is filtered. The result of the last stage is
+// discarded.
+type StageFunc func(GenericDataType) GenericDataType
+
+// Pipeline connects stages by diodes: every stage reads the diode it owns on
+// its own go-routine and sets the results on the diode of the next stage.
+// A stage that falls behind drops data instead of slowing down the stages
+// before it, unless it applies backpressure with WithBackpressure.
+type Pipeline struct {
+	mu      sync.Mutex
+	stages  []*stage
+	started bool
+}
+
+type stage struct {
+	filtered uint64
+	waits    uint64
+	high     uint64
+
+	name    string
+	fn      StageFunc
+	size    int
+	alerter Alerter
+	d       Diode
+	w       *Waiter
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	// space is signaled by the stage after every read, for the stage
+	// before it to wait on while the stage applies backpressure.
+	space chan struct{}
+}
+
+// StageOption can be used to setup a stage.
+type StageOption func(*stage)
+
+// WithStageSize sets the size of the diode of a stage. The default is 1024.
+func WithStageSize(size int) StageOption {
+	return StageOption(func(s *stage) {
+		s.size = size
+	})
+}
+
+// WithStageAlerter sets the alerter that is invoked when the stage drops
+// data. It is invoked on the stage's go-routine.
+func WithStageAlerter(a Alerter) StageOption {
+	return StageOption(func(s *stage) {
+		s.alerter = a
+	})
+}
+
+// WithBackpressure makes the stage before this one wait while the lag of
+// this stage's diode is at or above high, instead of letting this stage
+// drop data. The wait propagates upstream: the stage before fills up in
+// turn and drops data, or applies backpressure itself. The writers of the
+// first stage never wait, so this option has no effect on it.
+func WithBackpressure(high int) StageOption {
+	return StageOption(func(s *stage) {
+		s.high = uint64(high)
+	})
+}
+
+// NewPipeline returns a new Pipeline without stages.
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// Stage appends a stage with the given name, which is reported by Stats. It
+// returns the pipeline so that the stages can be chained. Stages must be
+// added before Start.
+func (p *Pipeline) Stage(name string, fn StageFunc, opts ...StageOption) *Pipeline {
+	s := &stage{
+		name:  name,
+		fn:    fn,
+		size:  1024,
+		done:  make(chan struct{}),
+		space: make(chan struct{}, 1),
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	// The first stage is set by the writers of the pipeline, the others
+	// only by the stage before them.
+	if len(p.stages) == 0 {
+		s.d = NewManyToOne(s.size, s.alerter)
+	} else {
+		s.d = NewOneToOne(s.size, s.alerter)
+	}
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.w = NewWaiter(s.d, WithWaiterContext(ctx))
+
+	p.stages = append(p.stages, s)
+	return p
+}
+
+// Start starts the go-routines of the stages. It must only be invoked
+// once.
+func (p *Pipeline) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.started = true
+	for i, s := range p.stages {
+		var next *stage
+		if i+1 < len(p.stages) {
+			next = p.stages[i+1]
+		}
+		go s.run(next)
+	}
+}
+
+func (s *stage) run(next *stage) {
+	defer close(s.done)
+
+	for {
+		data := s.w.Next()
+		if data == nil {
+			return
+		}
+
+		select {
+		case s.space <- struct{}{}:
+		default:
+		}
+
+		out := s.fn(data)
+		if out == nil {
+			atomic.AddUint64(&s.filtered, 1)
+			continue
+		}
+		if next != nil {
+			next.wait()
+			next.w.Set(out)
+		}
+	}
+}
+
+// wait blocks while the stage applies backpressure. The stage before it
+// is only closed once it returned, so the stage keeps reading meanwhile.
+func (s *stage) wait() {
+	if s.high == 0 {
+		return
+	}
+
+	lag := s.d.(LagReporter)
+	if lag.Lag() < s.high {
+		return
+	}
+
+	atomic.AddUint64(&s.waits, 1)
+	for lag.Lag() >= s.high {
+		<-s.space
+	}
+}
+
+// Set sets the value on the diode of the first stage. It may be invoked by
+// several go-routines.
+func (p *Pipeline) Set(data GenericDataType) {
+	p.stages[0].w.Set(data)
+}
+
+// StageStats is a snapshot of the statistics of a stage.
+type StageStats struct {
+	// Name is the name of the stage.
+	Name string
+
+	// Filtered is the number of values for which the function of the stage
+	// returned nil.
+	Filtered uint64
+
+	// Waits is the number of times the stage before this one waited for
+	// it with WithBackpressure.
+	Waits uint64
+
+	// Stats are the statistics of the diode of the stage.
+	Stats Stats
+}
+
+// Stats returns a snapshot of the statistics of every stage, in order. It
+// is safe to call from any go-routine.
+func (p *Pipeline) Stats() []StageStats {
+	stats := make([]StageStats, len(p.stages))
+	for i, s := range p.stages {
+		stats[i] = StageStats{
+			Name:     s.name,
+			Filtered: atomic.LoadUint64(&s.filtered),
+			Waits:    atomic.LoadUint64(&s.waits),
+			Stats:    s.d.(StatsReporter).Stats(),
+		}
+	}
+
+	return stats
+}
+
+// Close shuts the stages down in order: every stage processes what is left
+// in its diode and stops before the next stage is told to do the same, so
+// that no value that was set before Close is lost on the way. The values
+// that are set after Close are not processed.
+func (p *Pipeline) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, s := range p.stages {
+		s.cancel()
+		if p.started {
+			<-s.done
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package diodes_test
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pipeline", func() {
+	var (
+		mu  sync.Mutex
+		out []int
+	)
+
+	BeforeEach(func() {
+		out = nil
+	})
+
+	set := func(p *diodes.Pipeline, values ...int) {
+		for _, v := range values {
+			v := v
+			p.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	double := func(data diodes.GenericDataType) diodes.GenericDataType {
+		v := *(*int)(data) * 2
+		return diodes.GenericDataType(&v)
+	}
+
+	odd := func(data diodes.GenericDataType) diodes.GenericDataType {
+		if *(*int)(data)%2 == 0 {
+			return nil
+		}
+		return data
+	}
+
+	sink := func(data diodes.GenericDataType) diodes.GenericDataType {
+		mu.Lock()
+		defer mu.Unlock()
+		out = append(out, *(*int)(data))
+		return nil
+	}
+
+	received := func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), out...)
+	}
+
+	It("passes the values through the stages in order", func() {
+		p := diodes.NewPipeline().
+			Stage("filter", odd).
+			Stage("double", double).
+			Stage("sink", sink)
+		p.Start()
+		defer p.Close()
+
+		set(p, 1, 2, 3)
+		Eventually(received).Should(Equal([]int{2, 6}))
+
+		stats := p.Stats()
+		Expect(stats).To(HaveLen(3))
+		Expect(stats[0].Name).To(Equal("filter"))
+		Expect(stats[0].Filtered).To(Equal(uint64(1)))
+		Expect(stats[0].Stats.Reads).To(Equal(uint64(3)))
+		Expect(stats[1].Stats.Reads).To(Equal(uint64(2)))
+	})
+
+	It("processes the values that were set before Close", func() {
+		p := diodes.NewPipeline().
+			Stage("double", double).
+			Stage("sink", sink)
+
+		set(p, 1, 2, 3)
+		p.Start()
+		p.Close()
+
+		Expect(received()).To(Equal([]int{2, 4, 6}))
+	})
+
+	It("makes the stage before wait with backpressure", func() {
+		block := make(chan struct{})
+		p := diodes.NewPipeline().
+			Stage("first", func(data diodes.GenericDataType) diodes.GenericDataType {
+				return data
+			}).
+			Stage("slow", func(data diodes.GenericDataType) diodes.GenericDataType {
+				<-block
+				return sink(data)
+			}, diodes.WithStageSize(4), diodes.WithBackpressure(2))
+		p.Start()
+
+		set(p, 1, 2, 3, 4, 5, 6, 7, 8)
+		Eventually(func() uint64 { return p.Stats()[1].Waits }).ShouldNot(BeZero())
+		close(block)
+		p.Close()
+
+		Expect(received()).To(Equal([]int{1, 2, 3, 4, 5, 6, 7, 8}))
+		Expect(p.Stats()[1].Stats.Drops).To(BeZero())
+	})
+
+	It("drops data for the stage that falls behind", func() {
+		block := make(chan struct{})
+		spy := newSpyAlerter()
+		p := diodes.NewPipeline().
+			Stage("first", func(data diodes.GenericDataType) diodes.GenericDataType {
+				<-block
+				return data
+			}, diodes.WithStageSize(2), diodes.WithStageAlerter(spy))
+		p.Start()
+		defer p.Close()
+
+		set(p, 1)
+		Eventually(func() uint64 { return p.Stats()[0].Stats.Reads }).Should(Equal(uint64(1)))
+		set(p, 2, 3, 4, 5)
+		close(block)
+
+		Eventually(spy.AlertInput.Missed).Should(Receive())
+	})
+})
@@ -2,6 +2,7 @@ package diodes
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
@@ -11,6 +12,57 @@ type Diode interface {
 	TryNext() (GenericDataType, bool)
 }
 
+// Closer is implemented by diodes that can be closed to communicate that no
+// more data follows. A closed diode drops the data that is set, while its
+// reader drains what is left. Poller and Waiter return nil from Next once
+// their diode is closed and empty.
+type Closer interface {
+	Close() error
+	Closed() bool
+}
+
+// Drainer is implemented by diodes whose writers can wait for the reader to
+// consume what was set so far.
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// ErrNotDrainer is returned when a diode that is not a Drainer is drained.
+var ErrNotDrainer = errors.New("diodes: the diode can not be drained")
+
+// bound is implemented by diodes that can be bound to a context with
+// WithContext. The context is nil for a diode that is not bound.
+type bound interface {
+	Closer
+	boundContext() context.Context
+}
+
+// ErrNotClosed is returned when a diode that is not closed is reopened.
+var ErrNotClosed = errors.New("diodes: the diode is not closed")
+
+// ErrBoundToContext is returned when a diode that is bound to a context is
+// reopened.
+var ErrBoundToContext = errors.New("diodes: the diode is bound to a context")
+
+// ErrNotCloser is returned when a diode that is not a Closer is closed.
+var ErrNotCloser = errors.New("diodes: the diode can not be closed")
+
+// drain waits until the diode is drained.
+func drain(ctx context.Context, d Diode) error {
+	dr, ok := d.(Drainer)
+	if !ok {
+		return ErrNotDrainer
+	}
+
+	return dr.Drain(ctx)
+}
+
+// closed reports whether the diode is closed.
+func closed(d Diode) bool {
+	c, ok := d.(Closer)
+	return ok && c.Closed()
+}
+
 // Poller will poll a diode until a value is available.
 type Poller struct {
 	Diode
@@ -54,7 +106,8 @@ func NewPoller(d Diode, opts ...PollerConfigOption) *Poller {
 }
 
 // Next polls the diode until data is available or until the context is done.
-// If the context is done, then nil will be returned.
+// If the context is done, or the diode is closed and empty, then nil will be
+// returned.
 func (p *Poller) Next() GenericDataType {
 	for {
 		data, ok := p.Diode.TryNext()
@@ -63,6 +116,16 @@ func (p *Poller) Next() GenericDataType {
 				return nil
 			}
 
+			if closed(p.Diode) {
+				// The data that was set before Close might have
+				// landed after the failed read.
+				data, ok = p.Diode.TryNext()
+				if !ok {
+					return nil
+				}
+				return data
+			}
+
 			time.Sleep(p.interval)
 			continue
 		}
@@ -70,6 +133,28 @@ func (p *Poller) Next() GenericDataType {
 	}
 }
 
+// Close closes the wrapped diode. It returns ErrNotCloser if the diode is
+// not a Closer.
+func (p *Poller) Close() error {
+	c, ok := p.Diode.(Closer)
+	if !ok {
+		return ErrNotCloser
+	}
+
+	return c.Close()
+}
+
+// Drain waits until the wrapped diode is drained. It returns ErrNotDrainer
+// if the diode is not a Drainer.
+func (p *Poller) Drain(ctx context.Context) error {
+	return drain(ctx, p.Diode)
+}
+
+// Closed reports whether the wrapped diode is closed.
+func (p *Poller) Closed() bool {
+	return closed(p.Diode)
+}
+
 func (p *Poller) isDone() bool {
 	select {
 	case <-p.ctx.Done():
@@ -52,6 +52,21 @@ var _ = Describe("Poller", func() {
 
 		Eventually(done).Should(BeClosed())
 	})
+
+	It("returns nil once the closed diode is drained", func() {
+		p = diodes.NewPoller(diodes.NewOneToOne(4, nil))
+		data := []byte("a")
+		p.Set(diodes.GenericDataType(&data))
+		Expect(p.Close()).To(Succeed())
+
+		Expect(*(*[]byte)(p.Next())).To(Equal([]byte("a")))
+		Expect(p.Next() == nil).To(BeTrue())
+		Expect(p.Closed()).To(BeTrue())
+	})
+
+	It("does not close a diode that is not a Closer", func() {
+		Expect(p.Close()).To(MatchError(diodes.ErrNotCloser))
+	})
 })
 
 type spyDiode struct {
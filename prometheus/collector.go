@@ -0,0 +1,103 @@
+// Package prometheus provides a Prometheus collector for diodes.
+package prometheus
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector exports the Stats of a diode as Prometheus metrics.
+type Collector struct {
+	d diodes.StatsReporter
+
+	writes    *prom.Desc
+	reads     *prom.Desc
+	drops     *prom.Desc
+	lag       *prom.Desc
+	occupancy *prom.Desc
+	capacity  *prom.Desc
+}
+
+// CollectorOption can be used to setup the collector.
+type CollectorOption func(*collectorConfig)
+
+type collectorConfig struct {
+	namespace string
+	subsystem string
+	labels    prom.Labels
+}
+
+// WithNamespace sets the namespace of the metric names. The default is no
+// namespace.
+func WithNamespace(namespace string) CollectorOption {
+	return CollectorOption(func(c *collectorConfig) {
+		c.namespace = namespace
+	})
+}
+
+// WithSubsystem sets the subsystem of the metric names. The default is
+// "diode".
+func WithSubsystem(subsystem string) CollectorOption {
+	return CollectorOption(func(c *collectorConfig) {
+		c.subsystem = subsystem
+	})
+}
+
+// WithLabels sets constant labels that are applied to all metrics of the
+// collector. They are used to tell several diodes apart.
+func WithLabels(labels prom.Labels) CollectorOption {
+	return CollectorOption(func(c *collectorConfig) {
+		c.labels = labels
+	})
+}
+
+// NewCollector returns a new Collector for the given diode.
+func NewCollector(d diodes.StatsReporter, opts ...CollectorOption) *Collector {
+	c := collectorConfig{
+		subsystem: "diode",
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	desc := func(name, help string) *prom.Desc {
+		return prom.NewDesc(prom.BuildFQName(c.namespace, c.subsystem, name), help, nil, c.labels)
+	}
+
+	return &Collector{
+		d:         d,
+		writes:    desc("writes_total", "Total number of values written to the diode."),
+		reads:     desc("reads_total", "Total number of values read from the diode."),
+		drops:     desc("drops_total", "Total number of values dropped by the diode."),
+		lag:       desc("lag", "Number of values the reader is behind the writer."),
+		occupancy: desc("occupancy", "Number of values held by the diode."),
+		capacity:  desc("capacity", "Number of values the diode can hold."),
+	}
+}
+
+// Register registers a new Collector for the given diode with the registry.
+func Register(reg prom.Registerer, d diodes.StatsReporter, opts ...CollectorOption) error {
+	return reg.Register(NewCollector(d, opts...))
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.writes
+	ch <- c.reads
+	ch <- c.drops
+	ch <- c.lag
+	ch <- c.occupancy
+	ch <- c.capacity
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	s := c.d.Stats()
+
+	ch <- prom.MustNewConstMetric(c.writes, prom.CounterValue, float64(s.Writes))
+	ch <- prom.MustNewConstMetric(c.reads, prom.CounterValue, float64(s.Reads))
+	ch <- prom.MustNewConstMetric(c.drops, prom.CounterValue, float64(s.Drops))
+	ch <- prom.MustNewConstMetric(c.lag, prom.GaugeValue, float64(s.Lag))
+	ch <- prom.MustNewConstMetric(c.occupancy, prom.GaugeValue, float64(s.Occupancy()))
+	ch <- prom.MustNewConstMetric(c.capacity, prom.GaugeValue, float64(s.Capacity))
+}
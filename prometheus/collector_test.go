@@ -0,0 +1,82 @@
+package prometheus_test
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/go-diodes"
+	diodesprom "code.cloudfoundry.org/go-diodes/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Collector", func() {
+	var (
+		d   *diodes.OneToOne
+		reg *prom.Registry
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(4, nil)
+		reg = prom.NewRegistry()
+
+		data := []byte("some-data")
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+	})
+
+	It("exports the stats of the diode", func() {
+		Expect(diodesprom.Register(reg, d, diodesprom.WithLabels(prom.Labels{"source": "a"}))).To(Succeed())
+
+		expected := `
+# HELP diode_drops_total Total number of values dropped by the diode.
+# TYPE diode_drops_total counter
+diode_drops_total{source="a"} 4
+# HELP diode_lag Number of values the reader is behind the writer.
+# TYPE diode_lag gauge
+diode_lag{source="a"} 1
+# HELP diode_reads_total Total number of values read from the diode.
+# TYPE diode_reads_total counter
+diode_reads_total{source="a"} 1
+# HELP diode_writes_total Total number of values written to the diode.
+# TYPE diode_writes_total counter
+diode_writes_total{source="a"} 6
+`
+		Expect(testutil.GatherAndCompare(reg, strings.NewReader(expected),
+			"diode_drops_total", "diode_lag", "diode_reads_total", "diode_writes_total",
+		)).To(Succeed())
+	})
+
+	It("caps the occupancy at the capacity", func() {
+		d = diodes.NewOneToOne(4, nil)
+		data := []byte("some-data")
+		for i := 0; i < 10; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		c := diodesprom.NewCollector(d, diodesprom.WithNamespace("app"), diodesprom.WithSubsystem("buffer"))
+		Expect(reg.Register(c)).To(Succeed())
+
+		expected := `
+# HELP app_buffer_capacity Number of values the diode can hold.
+# TYPE app_buffer_capacity gauge
+app_buffer_capacity 4
+# HELP app_buffer_occupancy Number of values held by the diode.
+# TYPE app_buffer_occupancy gauge
+app_buffer_occupancy 4
+`
+		Expect(testutil.GatherAndCompare(reg, strings.NewReader(expected),
+			"app_buffer_capacity", "app_buffer_occupancy",
+		)).To(Succeed())
+	})
+
+	It("allows several diodes with different labels", func() {
+		Expect(diodesprom.Register(reg, d, diodesprom.WithLabels(prom.Labels{"source": "a"}))).To(Succeed())
+		Expect(diodesprom.Register(reg, diodes.NewManyToOne(4, nil), diodesprom.WithLabels(prom.Labels{"source": "b"}))).To(Succeed())
+
+		Expect(testutil.CollectAndCount(reg, "diode_capacity")).To(Equal(2))
+	})
+})
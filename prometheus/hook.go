@@ -0,0 +1,86 @@
+package prometheus
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsHook is a diodes.MetricsHook that records the measurements of a
+// diode as Prometheus metrics. Unlike the Collector, it also records the
+// distribution of the latencies. It exports drops_total and lag metrics
+// just like the Collector, so the two are not meant to be registered for the
+// same diode.
+type MetricsHook struct {
+	drops   prom.Counter
+	latency prom.Histogram
+	lag     prom.Gauge
+}
+
+// NewMetricsHook returns a new MetricsHook. The options are those of the
+// Collector.
+func NewMetricsHook(opts ...CollectorOption) *MetricsHook {
+	c := collectorConfig{
+		subsystem: "diode",
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	return &MetricsHook{
+		drops: prom.NewCounter(prom.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        "drops_total",
+			Help:        "Total number of values dropped by the diode.",
+			ConstLabels: c.labels,
+		}),
+		latency: prom.NewHistogram(prom.HistogramOpts{
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        "latency_seconds",
+			Help:        "Time values spent in the diode before they were read.",
+			ConstLabels: c.labels,
+			Buckets:     prom.ExponentialBuckets(1e-6, 4, 12),
+		}),
+		lag: prom.NewGauge(prom.GaugeOpts{
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        "lag",
+			Help:        "Number of values the reader is behind the writer.",
+			ConstLabels: c.labels,
+		}),
+	}
+}
+
+// IncDrops implements diodes.MetricsHook.
+func (h *MetricsHook) IncDrops(n uint64) {
+	h.drops.Add(float64(n))
+}
+
+// ObserveLatency implements diodes.MetricsHook.
+func (h *MetricsHook) ObserveLatency(d time.Duration) {
+	h.latency.Observe(d.Seconds())
+}
+
+// SetLag implements diodes.MetricsHook.
+func (h *MetricsHook) SetLag(lag uint64) {
+	h.lag.Set(float64(lag))
+}
+
+// Describe implements prometheus.Collector.
+func (h *MetricsHook) Describe(ch chan<- *prom.Desc) {
+	h.drops.Describe(ch)
+	h.latency.Describe(ch)
+	h.lag.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (h *MetricsHook) Collect(ch chan<- prom.Metric) {
+	h.drops.Collect(ch)
+	h.latency.Collect(ch)
+	h.lag.Collect(ch)
+}
+
+var _ diodes.MetricsHook = (*MetricsHook)(nil)
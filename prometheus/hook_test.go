@@ -0,0 +1,41 @@
+package prometheus_test
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/go-diodes"
+	diodesprom "code.cloudfoundry.org/go-diodes/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MetricsHook", func() {
+	It("records the measurements of the diode", func() {
+		h := diodesprom.NewMetricsHook(diodesprom.WithLabels(prom.Labels{"source": "a"}))
+		reg := prom.NewRegistry()
+		Expect(reg.Register(h)).To(Succeed())
+
+		d := diodes.NewOneToOne(4, nil, diodes.WithMetricsHook(h))
+		data := []byte("some-data")
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+
+		expected := `
+# HELP diode_drops_total Total number of values dropped by the diode.
+# TYPE diode_drops_total counter
+diode_drops_total{source="a"} 4
+# HELP diode_lag Number of values the reader is behind the writer.
+# TYPE diode_lag gauge
+diode_lag{source="a"} 6
+`
+		Expect(testutil.GatherAndCompare(reg, strings.NewReader(expected),
+			"diode_drops_total", "diode_lag",
+		)).To(Succeed())
+		Expect(testutil.CollectAndCount(h, "diode_latency_seconds")).To(Equal(1))
+	})
+})
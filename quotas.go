@@ -0,0 +1,252 @@
+package diodes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when a producer would take the quotas of a
+// Quotas over its capacity.
+var ErrQuotaExceeded = errors.New("diodes: the capacity of the quotas is exceeded")
+
+// ErrInvalidQuota is returned when a producer is registered with less than
+// one slot.
+var ErrInvalidQuota = errors.New("diodes: a quota must hold at least one slot")
+
+// Quotas is a diode for several producers that guarantees each of them a
+// share of its capacity. In a single ManyToOne, a chatty producer overwrites
+// the data of everyone else once the reader falls behind. Here, every
+// registered producer writes to a diode of its own with the slots of its
+// quota, so under overload it only overwrites its own data. The reader takes
+// turns between the producers, so a chatty producer is not read more often
+// either.
+//
+// Like the diodes, a Quotas is meant to be read by a single go-routine.
+type Quotas struct {
+	capacity int
+	alerter  func(name string, missed int)
+	opts     []DiodeConfigOption
+	interval time.Duration
+	ctx      context.Context
+
+	mu   sync.Mutex
+	used int
+
+	// producers holds a []quotaProducer in the order they were registered.
+	// It is replaced on every change, so that the reader does not lock.
+	producers atomic.Value
+
+	// last is the index of the producer that was read last. It is only used
+	// by the reader.
+	last int
+}
+
+type quotaProducer struct {
+	name  string
+	slots int
+	d     *ManyToOne
+}
+
+// QuotaOption can be used to setup the quotas.
+type QuotaOption func(*Quotas)
+
+// WithQuotaAlerter sets the function that is invoked with the name of a
+// producer when its data is dropped. It is invoked on the reader's
+// go-routine.
+func WithQuotaAlerter(fn func(name string, missed int)) QuotaOption {
+	return QuotaOption(func(q *Quotas) {
+		q.alerter = fn
+	})
+}
+
+// WithQuotaDiodeOptions sets the options the diodes of the producers are
+// created with.
+func WithQuotaDiodeOptions(opts ...DiodeConfigOption) QuotaOption {
+	return QuotaOption(func(q *Quotas) {
+		q.opts = opts
+	})
+}
+
+// WithQuotaPollingInterval sets the interval at which Next queries the
+// producers for new data. The default is 10ms.
+func WithQuotaPollingInterval(interval time.Duration) QuotaOption {
+	return QuotaOption(func(q *Quotas) {
+		q.interval = interval
+	})
+}
+
+// WithQuotaContext sets the context to cancel Next. Default is
+// context.Background().
+func WithQuotaContext(ctx context.Context) QuotaOption {
+	return QuotaOption(func(q *Quotas) {
+		q.ctx = ctx
+	})
+}
+
+// NewQuotas returns a new Quotas with the given total capacity and no
+// producers.
+func NewQuotas(capacity int, opts ...QuotaOption) *Quotas {
+	q := &Quotas{
+		capacity: capacity,
+		interval: 10 * time.Millisecond,
+		ctx:      context.Background(),
+		last:     -1,
+	}
+	q.producers.Store([]quotaProducer(nil))
+
+	for _, o := range opts {
+		o(q)
+	}
+
+	return q
+}
+
+// Register returns the diode the producer with the given name sets its data
+// on, which holds the given number of slots of the capacity. The diode of a
+// producer that is registered already is returned as is. It returns
+// ErrQuotaExceeded if the slots would exceed the capacity that is left, and
+// ErrInvalidQuota if they are less than one.
+func (q *Quotas) Register(name string, slots int) (*ManyToOne, error) {
+	if slots <= 0 {
+		return nil, ErrInvalidQuota
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	producers := q.load()
+	for _, p := range producers {
+		if p.name == name {
+			return p.d, nil
+		}
+	}
+	if q.used+slots > q.capacity {
+		return nil, ErrQuotaExceeded
+	}
+
+	var alerter Alerter
+	if q.alerter != nil {
+		alerter = AlertFunc(func(missed int) {
+			q.alerter(name, missed)
+		})
+	}
+
+	d := NewManyToOne(slots, alerter, q.opts...)
+	next := make([]quotaProducer, len(producers), len(producers)+1)
+	copy(next, producers)
+	q.producers.Store(append(next, quotaProducer{name: name, slots: slots, d: d}))
+	q.used += slots
+
+	return d, nil
+}
+
+// Unregister closes the diode of the producer with the given name and
+// frees its slots. The data of the producer that was not read yet is
+// discarded.
+func (q *Quotas) Unregister(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	producers := q.load()
+	next := make([]quotaProducer, 0, len(producers))
+	for _, p := range producers {
+		if p.name != name {
+			next = append(next, p)
+			continue
+		}
+
+		p.d.Close()
+		q.used -= p.slots
+	}
+	q.producers.Store(next)
+}
+
+// Available returns the number of slots that are not held by a producer.
+func (q *Quotas) Available() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.capacity - q.used
+}
+
+// TryNext returns the next value of the producer whose turn it is. If none
+// of the producers has data available, it will return (nil, false).
+func (q *Quotas) TryNext() (GenericDataType, bool) {
+	producers := q.load()
+	n := len(producers)
+	for i := 1; i <= n; i++ {
+		idx := (q.last + i) % n
+		if data, ok := producers[idx].d.TryNext(); ok {
+			q.last = idx
+			return data, true
+		}
+	}
+
+	return nil, false
+}
+
+// Next polls the producers until data is available or until the context is
+// done. If the context is done, then nil will be returned.
+func (q *Quotas) Next() GenericDataType {
+	for {
+		data, ok := q.TryNext()
+		if !ok {
+			if q.ctx.Err() != nil {
+				return nil
+			}
+
+			time.Sleep(q.interval)
+			continue
+		}
+		return data
+	}
+}
+
+// Stats returns the sum of the stats of the producers. It is safe to call
+// from any go-routine.
+func (q *Quotas) Stats() Stats {
+	var total Stats
+	for _, p := range q.load() {
+		s := p.d.Stats()
+		total.Writes += s.Writes
+		total.Reads += s.Reads
+		total.Drops += s.Drops
+		total.Rejected += s.Rejected
+		total.Lag += s.Lag
+		total.Capacity += s.Capacity
+	}
+
+	return total
+}
+
+// ProducerStats returns the stats of every producer by name, so that drops
+// can be accounted to the producer that caused them. It is safe to call
+// from any go-routine.
+func (q *Quotas) ProducerStats() map[string]Stats {
+	producers := q.load()
+	stats := make(map[string]Stats, len(producers))
+	for _, p := range producers {
+		stats[p.name] = p.d.Stats()
+	}
+
+	return stats
+}
+
+// Producers returns the names of the registered producers, in the order
+// they were registered.
+func (q *Quotas) Producers() []string {
+	producers := q.load()
+	names := make([]string, 0, len(producers))
+	for _, p := range producers {
+		names = append(names, p.name)
+	}
+
+	return names
+}
+
+func (q *Quotas) load() []quotaProducer {
+	return q.producers.Load().([]quotaProducer)
+}
@@ -0,0 +1,137 @@
+package diodes_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Quotas", func() {
+	var q *diodes.Quotas
+
+	BeforeEach(func() {
+		q = diodes.NewQuotas(8)
+	})
+
+	register := func(name string, slots int) *diodes.ManyToOne {
+		d, err := q.Register(name, slots)
+		Expect(err).ToNot(HaveOccurred())
+		return d
+	}
+
+	set := func(d *diodes.ManyToOne, values ...int) {
+		for _, v := range values {
+			v := v
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func() []int {
+		var values []int
+		for {
+			data, ok := q.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, *(*int)(data))
+		}
+	}
+
+	It("keeps the data of a producer within its quota when another one overflows", func() {
+		chatty := register("chatty", 4)
+		quiet := register("quiet", 4)
+
+		set(quiet, 100, 101)
+		for i := 0; i < 20; i++ {
+			set(chatty, i)
+		}
+
+		Expect(readAll()).To(ConsistOf(16, 17, 18, 19, 100, 101))
+
+		stats := q.ProducerStats()
+		Expect(stats["chatty"].Drops).To(Equal(uint64(16)))
+		Expect(stats["quiet"].Drops).To(BeZero())
+		Expect(q.Stats().Drops).To(Equal(uint64(16)))
+	})
+
+	It("takes turns between the producers", func() {
+		a := register("a", 4)
+		b := register("b", 4)
+		set(a, 1, 2, 3)
+		set(b, 10, 20)
+
+		Expect(readAll()).To(Equal([]int{1, 10, 2, 20, 3}))
+	})
+
+	It("does not register a producer beyond the capacity", func() {
+		register("a", 6)
+		Expect(q.Available()).To(Equal(2))
+
+		_, err := q.Register("b", 4)
+		Expect(err).To(MatchError(diodes.ErrQuotaExceeded))
+	})
+
+	It("does not register a producer without slots", func() {
+		for _, slots := range []int{0, -1} {
+			_, err := q.Register("a", slots)
+			Expect(err).To(MatchError(diodes.ErrInvalidQuota))
+		}
+		Expect(q.Producers()).To(BeEmpty())
+	})
+
+	It("returns the names of the producers in the order they were registered", func() {
+		register("b", 2)
+		register("a", 2)
+		register("c", 2)
+		q.Unregister("a")
+
+		Expect(q.Producers()).To(Equal([]string{"b", "c"}))
+	})
+
+	It("returns the diode of a producer that is registered already", func() {
+		d := register("a", 4)
+		Expect(register("a", 4)).To(BeIdenticalTo(d))
+		Expect(q.Available()).To(Equal(4))
+	})
+
+	It("frees the slots of a producer that is unregistered", func() {
+		d := register("a", 8)
+		set(d, 1)
+		q.Unregister("a")
+
+		Expect(d.Closed()).To(BeTrue())
+		Expect(q.Available()).To(Equal(8))
+		Expect(q.Producers()).To(BeEmpty())
+		Expect(readAll()).To(BeEmpty())
+
+		register("b", 8)
+		Expect(q.Producers()).To(Equal([]string{"b"}))
+	})
+
+	It("alerts with the name of the producer whose data was dropped", func() {
+		var names []string
+		q = diodes.NewQuotas(8, diodes.WithQuotaAlerter(func(name string, missed int) {
+			names = append(names, name)
+		}))
+		d := register("a", 2)
+		register("b", 2)
+		set(d, 1, 2, 3)
+
+		Expect(readAll()).To(ContainElement(3))
+		Expect(names).To(Equal([]string{"a"}))
+	})
+
+	It("returns nil from Next once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		q = diodes.NewQuotas(8, diodes.WithQuotaContext(ctx))
+		d := register("a", 4)
+		set(d, 1)
+
+		Expect(*(*int)(q.Next())).To(Equal(1))
+		cancel()
+		Expect(q.Next() == nil).To(BeTrue())
+	})
+})
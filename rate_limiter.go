@@ -0,0 +1,71 @@
+package diodes
+
+import "time"
+
+// RateLimiter wraps a diode and releases at most a given number of values
+// per second to the reader, such as to protect a downstream API with a rate
+// limit. It is a token bucket: the values in excess of the rate stay in the
+// diode, where the oldest are overwritten as usual once it is full, so the
+// reader catches up with the most recent data when the rate allows.
+//
+// Like the diodes, a RateLimiter is meant to be read by a single
+// go-routine.
+type RateLimiter struct {
+	Diode
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiterOption can be used to setup the rate limiter.
+type RateLimiterOption func(*RateLimiter)
+
+// WithBurst sets how many values can be released at once after the reader
+// was idle. The default is 1, which spaces the values evenly.
+func WithBurst(n int) RateLimiterOption {
+	return RateLimiterOption(func(r *RateLimiter) {
+		r.burst = float64(n)
+	})
+}
+
+// NewRateLimiter returns a new RateLimiter that wraps the given diode and
+// releases up to perSecond values per second.
+func NewRateLimiter(d Diode, perSecond float64, opts ...RateLimiterOption) *RateLimiter {
+	r := &RateLimiter{
+		Diode: d,
+		rate:  perSecond,
+		burst: 1,
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+	r.tokens = r.burst
+	r.last = time.Now()
+
+	return r
+}
+
+// TryNext returns the next value of the wrapped diode if the rate allows it.
+// Otherwise, or if there is no data available, it will return (nil, false).
+// Wrapped by a Poller, it waits until the rate allows the next value.
+func (r *RateLimiter) TryNext() (GenericDataType, bool) {
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		return nil, false
+	}
+
+	data, ok := r.Diode.TryNext()
+	if ok {
+		r.tokens--
+	}
+
+	return data, ok
+}
@@ -0,0 +1,59 @@
+package diodes_test
+
+import (
+	"context"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RateLimiter", func() {
+	var d *diodes.OneToOne
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(4, nil)
+		for i := 0; i < 4; i++ {
+			i := i
+			d.Set(diodes.GenericDataType(&i))
+		}
+	})
+
+	It("releases up to the burst at once", func() {
+		r := diodes.NewRateLimiter(d, 1, diodes.WithBurst(2))
+
+		_, ok := r.TryNext()
+		Expect(ok).To(BeTrue())
+		_, ok = r.TryNext()
+		Expect(ok).To(BeTrue())
+		_, ok = r.TryNext()
+		Expect(ok).To(BeFalse())
+		Expect(d.Stats().Lag).To(Equal(uint64(2)))
+	})
+
+	It("releases the values at the rate", func() {
+		r := diodes.NewRateLimiter(d, 50)
+		p := diodes.NewPoller(r, diodes.WithPollingInterval(time.Millisecond))
+
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			Expect(*(*int)(p.Next())).To(Equal(i))
+		}
+		Expect(time.Since(start)).To(BeNumerically(">=", 55*time.Millisecond))
+	})
+
+	It("does not spend a token when there is no data", func() {
+		r := diodes.NewRateLimiter(diodes.NewOneToOne(4, nil), 1)
+		_, ok := r.TryNext()
+		Expect(ok).To(BeFalse())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		v := 1
+		r.Set(diodes.GenericDataType(&v))
+		p := diodes.NewPoller(r, diodes.WithPollingContext(ctx))
+		Expect(*(*int)(p.Next())).To(Equal(1))
+	})
+})
@@ -0,0 +1,167 @@
+package diodes
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// defaultRateWindows are the windows used when WithRateWindows is not given.
+var defaultRateWindows = []time.Duration{time.Second, 10 * time.Second, time.Minute}
+
+// Rate is the average throughput of a diode over a rolling window.
+type Rate struct {
+	// Window is the duration the rate is averaged over. While the diode is
+	// younger than the window, the rate is averaged over its lifetime.
+	Window time.Duration
+
+	// Writes is the number of values written per second.
+	Writes float64
+
+	// Reads is the number of values read per second.
+	Reads float64
+}
+
+// rateSample is a snapshot of the diode's counters. The version is odd while
+// the sample is being written.
+type rateSample struct {
+	version uint64
+	writes  uint64
+	reads   uint64
+	ts      int64
+}
+
+// rates keeps a sample of the diode's counters for each of the past seconds.
+// A sample is taken by the first write or read of a second. The writes and
+// reads already take the time at InstrumentationDetailed, so taking a sample
+// only costs an atomic load for most of them.
+type rates struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	last    int64 // last is the second of the newest sample
+	samples []rateSample
+	windows []time.Duration
+	stats   func() Stats
+}
+
+func newRates(windows []time.Duration) *rates {
+	if len(windows) == 0 {
+		windows = defaultRateWindows
+	}
+
+	var longest time.Duration
+	for _, w := range windows {
+		if w > longest {
+			longest = w
+		}
+	}
+
+	// A sample that is at least the longest window old must be kept.
+	n := int((longest+time.Second-1)/time.Second) + 2
+
+	return &rates{
+		last:    time.Now().Unix(),
+		samples: make([]rateSample, n),
+		windows: windows,
+	}
+}
+
+// init records the creation of the diode, whose counters are all zero.
+func (r *rates) init(stats func() Stats) {
+	r.stats = stats
+	s := &r.samples[r.last%int64(len(r.samples))]
+	s.ts = time.Now().UnixNano()
+	s.version = 2
+}
+
+// tick takes a sample at the given time in nanoseconds if there isn't one
+// for its second yet.
+func (r *rates) tick(now int64) {
+	sec := now / int64(time.Second)
+	last := atomic.LoadInt64(&r.last)
+	if sec <= last || !atomic.CompareAndSwapInt64(&r.last, last, sec) {
+		return
+	}
+
+	st := r.stats()
+	s := &r.samples[sec%int64(len(r.samples))]
+	version := atomic.LoadUint64(&s.version)
+	atomic.StoreUint64(&s.version, version+1)
+	atomic.StoreUint64(&s.writes, st.Writes)
+	atomic.StoreUint64(&s.reads, st.Reads)
+	atomic.StoreInt64(&s.ts, now)
+	atomic.StoreUint64(&s.version, version+2)
+}
+
+// snapshot returns the rates for all windows.
+func (r *rates) snapshot() []Rate {
+	now := time.Now().UnixNano()
+	r.tick(now)
+	st := r.stats()
+
+	result := make([]Rate, len(r.windows))
+	for i, w := range r.windows {
+		result[i].Window = w
+
+		s, ok := r.sampleBefore(now - int64(w))
+		if !ok {
+			continue
+		}
+
+		elapsed := float64(now-s.ts) / float64(time.Second)
+		if elapsed <= 0 {
+			continue
+		}
+
+		result[i].Writes = float64(st.Writes-s.writes) / elapsed
+		result[i].Reads = float64(st.Reads-s.reads) / elapsed
+	}
+
+	return result
+}
+
+// sampleBefore returns the newest sample taken at or before the given time.
+// If there is none, it returns the oldest sample.
+func (r *rates) sampleBefore(ts int64) (rateSample, bool) {
+	var (
+		newest, oldest         rateSample
+		haveNewest, haveOldest bool
+	)
+
+	for i := range r.samples {
+		s, ok := r.load(i)
+		if !ok {
+			continue
+		}
+
+		if s.ts <= ts && (!haveNewest || s.ts > newest.ts) {
+			newest, haveNewest = s, true
+		}
+		if !haveOldest || s.ts < oldest.ts {
+			oldest, haveOldest = s, true
+		}
+	}
+
+	if haveNewest {
+		return newest, true
+	}
+
+	return oldest, haveOldest
+}
+
+// load returns a consistent copy of the sample at the given index. It returns
+// false if no sample was taken or a sample is being written.
+func (r *rates) load(i int) (rateSample, bool) {
+	s := &r.samples[i]
+	version := atomic.LoadUint64(&s.version)
+	if version == 0 || version&1 == 1 {
+		return rateSample{}, false
+	}
+
+	c := rateSample{
+		writes: atomic.LoadUint64(&s.writes),
+		reads:  atomic.LoadUint64(&s.reads),
+		ts:     atomic.LoadInt64(&s.ts),
+	}
+
+	return c, atomic.LoadUint64(&s.version) == version
+}
@@ -0,0 +1,79 @@
+package diodes_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("Rates", func(impl diodes.Implementation) {
+	var data []byte
+
+	BeforeEach(func() {
+		data = []byte("some-data")
+	})
+
+	It("does not track rates below the detailed level", func() {
+		d := diodes.NewOneToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithInstrumentation(diodes.InstrumentationBasic),
+		)
+		d.Set(diodes.GenericDataType(&data))
+
+		Expect(d.Instrumentation().Rates).To(BeNil())
+	})
+
+	It("tracks the default windows", func() {
+		d := diodes.NewOneToOne(128, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithInstrumentation(diodes.InstrumentationDetailed),
+		)
+
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		for i := 0; i < 50; i++ {
+			d.TryNext()
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		rates := d.Instrumentation().Rates
+		elapsed := time.Since(start).Seconds()
+
+		Expect(rates).To(HaveLen(3))
+		Expect(rates[0].Window).To(Equal(time.Second))
+		Expect(rates[1].Window).To(Equal(10 * time.Second))
+		Expect(rates[2].Window).To(Equal(time.Minute))
+
+		// The diode is younger than the windows, so they all average over
+		// its lifetime.
+		for _, r := range rates {
+			Expect(r.Writes).To(BeNumerically(">=", 100/elapsed*0.5))
+			Expect(r.Writes).To(BeNumerically("<=", 100/0.05))
+			Expect(r.Reads).To(BeNumerically(">", 0))
+			Expect(r.Reads).To(BeNumerically("<", r.Writes))
+		}
+	})
+
+	It("tracks the configured windows of a ManyToOne", func() {
+		d := diodes.NewManyToOne(128, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithInstrumentation(diodes.InstrumentationDetailed),
+			diodes.WithRateWindows(5*time.Second),
+		)
+		for i := 0; i < 10; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		rates := d.Instrumentation().Rates
+		Expect(rates).To(HaveLen(1))
+		Expect(rates[0].Window).To(Equal(5 * time.Second))
+		Expect(rates[0].Writes).To(BeNumerically(">", 0))
+		Expect(rates[0].Reads).To(BeZero())
+	})
+})
@@ -0,0 +1,177 @@
+package diodes
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of an Event recorded by a Recorder.
+type EventKind int
+
+const (
+	// EventSet is a value that was set.
+	EventSet EventKind = iota
+
+	// EventRead is a value that was read.
+	EventRead
+
+	// EventEmpty is a read that found no value.
+	EventEmpty
+
+	// EventAlert is an alert about values that were dropped.
+	EventAlert
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventSet:
+		return "set"
+	case EventRead:
+		return "read"
+	case EventEmpty:
+		return "empty"
+	case EventAlert:
+		return "alert"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+}
+
+// Event is an operation on a diode recorded by a Recorder.
+type Event struct {
+	Kind EventKind
+
+	// Seq is the sequence number of the value that was set or read. It is
+	// zero for the other kinds.
+	Seq uint64
+
+	// Missed is the number of values an alert reported as dropped. It is
+	// zero for the other kinds.
+	Missed int
+
+	// Time is when the event was recorded.
+	Time time.Time
+}
+
+// Recorder keeps a bounded log of the operations on the diodes it is set up
+// with by WithRecorder, so that integration tests can assert the order of
+// the reads and the drops precisely and bug reports can include the exact
+// history. Once the log is full, the oldest events are evicted. A writer
+// records its set once the value is visible to the reader, so on different
+// go-routines the read of a value may be recorded before its set. The
+// recorder serializes the writers and the reader of the diode, which changes
+// their timing, so use it in tests and while debugging only. It is safe to
+// use from any go-routine.
+type Recorder struct {
+	mu      sync.Mutex
+	events  []Event
+	next    int
+	full    bool
+	evicted uint64
+}
+
+// NewRecorder returns a new Recorder that keeps the given number of the most
+// recent events.
+func NewRecorder(capacity int) *Recorder {
+	return &Recorder{events: make([]Event, capacity)}
+}
+
+// WithRecorder records the operations on the diode into the recorder. It
+// applies to the OneToOne and ManyToOne diodes.
+func WithRecorder(r *Recorder) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.recorder = r
+	})
+}
+
+func (r *Recorder) record(kind EventKind, seq uint64, missed int) {
+	if r == nil {
+		return
+	}
+
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.events) == 0 {
+		r.evicted++
+		return
+	}
+
+	if r.full {
+		r.evicted++
+	}
+	r.events[r.next] = Event{Kind: kind, Seq: seq, Missed: missed, Time: now}
+	r.next++
+	if r.next == len(r.events) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// Events returns the recorded events, oldest first.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]Event(nil), r.events[:r.next]...)
+	}
+
+	events := make([]Event, 0, len(r.events))
+	events = append(events, r.events[r.next:]...)
+	return append(events, r.events[:r.next]...)
+}
+
+// Evicted returns the number of events that were evicted from the full log.
+func (r *Recorder) Evicted() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.evicted
+}
+
+// Reset discards the recorded events.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.next = 0
+	r.full = false
+	r.evicted = 0
+}
+
+// WriteTo writes the recorded events to w, one per line, such as to attach
+// them to a bug report. It implements io.WriterTo.
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	if evicted := r.Evicted(); evicted > 0 {
+		n, err := fmt.Fprintf(w, "(%d earlier events evicted)\n", evicted)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	for _, e := range r.Events() {
+		var n int
+		var err error
+		switch e.Kind {
+		case EventSet, EventRead:
+			n, err = fmt.Fprintf(w, "%s %s seq=%d\n", e.Time.Format(time.RFC3339Nano), e.Kind, e.Seq)
+		case EventAlert:
+			n, err = fmt.Fprintf(w, "%s %s missed=%d\n", e.Time.Format(time.RFC3339Nano), e.Kind, e.Missed)
+		default:
+			n, err = fmt.Fprintf(w, "%s %s\n", e.Time.Format(time.RFC3339Nano), e.Kind)
+		}
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
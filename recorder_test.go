@@ -0,0 +1,102 @@
+package diodes_test
+
+import (
+	"bytes"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("Recorder", func(impl diodes.Implementation) {
+	var r *diodes.Recorder
+
+	BeforeEach(func() {
+		r = diodes.NewRecorder(16)
+	})
+
+	set := func(d diodes.Diode, n int) {
+		for i := 0; i < n; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	type op struct {
+		Kind   diodes.EventKind
+		Seq    uint64
+		Missed int
+	}
+
+	ops := func() []op {
+		var result []op
+		for _, e := range r.Events() {
+			Expect(e.Time).ToNot(BeZero())
+			result = append(result, op{Kind: e.Kind, Seq: e.Seq, Missed: e.Missed})
+		}
+		return result
+	}
+
+	It("records the operations on a OneToOne", func() {
+		d := diodes.NewOneToOne(2, nil, diodes.WithImplementation(impl), diodes.WithRecorder(r))
+		set(d, 3)
+		d.TryNext()
+		d.TryNext()
+		d.TryNext()
+
+		Expect(ops()).To(Equal([]op{
+			{Kind: diodes.EventSet, Seq: 0},
+			{Kind: diodes.EventSet, Seq: 1},
+			{Kind: diodes.EventSet, Seq: 2},
+			{Kind: diodes.EventAlert, Missed: 2},
+			{Kind: diodes.EventRead, Seq: 2},
+			{Kind: diodes.EventEmpty},
+			{Kind: diodes.EventEmpty},
+		}))
+	})
+
+	It("records the operations on a ManyToOne", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl), diodes.WithRecorder(r))
+		set(d, 2)
+		d.TryNext()
+
+		Expect(ops()).To(Equal([]op{
+			{Kind: diodes.EventSet, Seq: 0},
+			{Kind: diodes.EventSet, Seq: 1},
+			{Kind: diodes.EventRead, Seq: 0},
+		}))
+	})
+
+	It("evicts the oldest events once it is full", func() {
+		r = diodes.NewRecorder(2)
+		d := diodes.NewOneToOne(8, nil, diodes.WithImplementation(impl), diodes.WithRecorder(r))
+		set(d, 5)
+
+		Expect(ops()).To(Equal([]op{
+			{Kind: diodes.EventSet, Seq: 3},
+			{Kind: diodes.EventSet, Seq: 4},
+		}))
+		Expect(r.Evicted()).To(Equal(uint64(3)))
+
+		r.Reset()
+		Expect(r.Events()).To(BeEmpty())
+		Expect(r.Evicted()).To(BeZero())
+	})
+
+	It("writes the events for a bug report", func() {
+		r = diodes.NewRecorder(2)
+		d := diodes.NewOneToOne(1, nil, diodes.WithImplementation(impl), diodes.WithRecorder(r))
+		set(d, 2)
+		d.TryNext()
+
+		var buf bytes.Buffer
+		_, err := r.WriteTo(&buf)
+		Expect(err).ToNot(HaveOccurred())
+
+		out := buf.String()
+		Expect(out).To(HavePrefix("(2 earlier events evicted)\n"))
+		Expect(out).To(ContainSubstring(" alert missed=1\n"))
+		Expect(out).To(ContainSubstring(" read seq=1\n"))
+	})
+})
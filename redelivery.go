@@ -0,0 +1,256 @@
+package diodes
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Delivery is a value handed out by a Redelivery. It must be acknowledged
+// by its ID once it was processed.
+type Delivery struct {
+	ID   uint64
+	Data GenericDataType
+
+	// Attempt is 1 for the first delivery of the value and counts up with
+	// every redelivery.
+	Attempt int
+}
+
+// Redelivery wraps a diode as an in-process work queue with at-least-once
+// semantics: a value that was delivered but not acknowledged within a
+// timeout is delivered again, such as to another worker when the first one
+// failed or hung. A value that was delivered a bounded number of times
+// without an acknowledgment is set on a dead-letter diode instead. Values
+// that the diode dropped before they were delivered are lost as usual.
+//
+// A Redelivery can be read and acknowledged by several go-routines.
+type Redelivery struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	acked        uint64
+	redelivered  uint64
+	deadLettered uint64
+
+	d          Diode
+	timeout    time.Duration
+	retries    int
+	deadLetter Diode
+	interval   time.Duration
+	ctx        context.Context
+
+	mu       sync.Mutex
+	nextID   uint64
+	inflight map[uint64]*inflight
+
+	// due holds the deliveries in the order of their deadlines, which is the
+	// order they were delivered in as the timeout is the same for all of
+	// them. Acknowledged deliveries are skipped once they are due.
+	due []*inflight
+}
+
+type inflight struct {
+	delivery Delivery
+	deadline time.Time
+	done     bool
+}
+
+// RedeliveryOption can be used to setup the redelivery.
+type RedeliveryOption func(*Redelivery)
+
+// WithRedeliveries sets how many times a value is redelivered before it is
+// set on the dead-letter diode. The default is 3.
+func WithRedeliveries(n int) RedeliveryOption {
+	return RedeliveryOption(func(r *Redelivery) {
+		r.retries = n
+	})
+}
+
+// WithRedeliveryDeadLetter sets the diode the values are set on once they
+// were redelivered WithRedeliveries times without an acknowledgment. By
+// default they are dropped.
+func WithRedeliveryDeadLetter(d Diode) RedeliveryOption {
+	return RedeliveryOption(func(r *Redelivery) {
+		r.deadLetter = d
+	})
+}
+
+// WithRedeliveryPollingInterval sets the interval at which Next queries for
+// new and due values. The default is 10ms.
+func WithRedeliveryPollingInterval(interval time.Duration) RedeliveryOption {
+	return RedeliveryOption(func(r *Redelivery) {
+		r.interval = interval
+	})
+}
+
+// WithRedeliveryContext sets the context to cancel Next. Default is
+// context.Background().
+func WithRedeliveryContext(ctx context.Context) RedeliveryOption {
+	return RedeliveryOption(func(r *Redelivery) {
+		r.ctx = ctx
+	})
+}
+
+// NewRedelivery returns a new Redelivery that delivers the values of the
+// given diode and redelivers the ones that were not acknowledged within
+// the timeout.
+func NewRedelivery(d Diode, timeout time.Duration, opts ...RedeliveryOption) *Redelivery {
+	r := &Redelivery{
+		d:        d,
+		timeout:  timeout,
+		retries:  3,
+		interval: 10 * time.Millisecond,
+		ctx:      context.Background(),
+		inflight: make(map[uint64]*inflight),
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// Set sets the data on the wrapped diode.
+func (r *Redelivery) Set(data GenericDataType) {
+	r.d.Set(data)
+}
+
+// TryNext returns the first value that is due for a redelivery, or else the
+// next value of the wrapped diode. If there is neither, it will return
+// (Delivery{}, false).
+func (r *Redelivery) TryNext() (Delivery, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for len(r.due) > 0 && !r.due[0].deadline.After(now) {
+		f := r.due[0]
+		r.due[0] = nil
+		r.due = r.due[1:]
+		if f.done {
+			continue
+		}
+
+		if f.delivery.Attempt > r.retries {
+			delete(r.inflight, f.delivery.ID)
+			atomic.AddUint64(&r.deadLettered, 1)
+			if r.deadLetter != nil {
+				r.deadLetter.Set(f.delivery.Data)
+			}
+			continue
+		}
+
+		f.delivery.Attempt++
+		atomic.AddUint64(&r.redelivered, 1)
+		return r.deliver(f, now), true
+	}
+
+	data, ok := r.d.TryNext()
+	if !ok {
+		return Delivery{}, false
+	}
+
+	r.nextID++
+	f := &inflight{delivery: Delivery{ID: r.nextID, Data: data, Attempt: 1}}
+	r.inflight[f.delivery.ID] = f
+	return r.deliver(f, now), true
+}
+
+// deliver sets the deadline of the delivery. It must be invoked with the
+// lock held.
+func (r *Redelivery) deliver(f *inflight, now time.Time) Delivery {
+	f.deadline = now.Add(r.timeout)
+	r.due = append(r.due, f)
+	return f.delivery
+}
+
+// Next polls for a value until one is available or until the context is
+// done. If the context is done, then (Delivery{}, false) will be returned.
+func (r *Redelivery) Next() (Delivery, bool) {
+	for {
+		d, ok := r.TryNext()
+		if !ok {
+			if r.ctx.Err() != nil {
+				return Delivery{}, false
+			}
+
+			time.Sleep(r.interval)
+			continue
+		}
+		return d, true
+	}
+}
+
+// Ack acknowledges that the value of the delivery with the given ID was
+// processed, so that it is not delivered again. It reports whether the
+// value was still in flight; a value that was dead-lettered or acknowledged
+// already is not.
+func (r *Redelivery) Ack(id uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.inflight[id]
+	if !ok {
+		return false
+	}
+
+	f.done = true
+	delete(r.inflight, id)
+	atomic.AddUint64(&r.acked, 1)
+	return true
+}
+
+// Nack reports that the processing of the value of the delivery with the
+// given ID failed, so that it is due for a redelivery right away instead of
+// once its timeout passed. It reports whether the value was still in
+// flight.
+func (r *Redelivery) Nack(id uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.inflight[id]
+	if !ok {
+		return false
+	}
+
+	// The delivery is moved to the front of the due deliveries, and its
+	// entry at its old deadline is skipped.
+	f.done = true
+	next := &inflight{delivery: f.delivery}
+	r.inflight[id] = next
+	r.due = append([]*inflight{next}, r.due...)
+	return true
+}
+
+// InFlight returns the number of values that were delivered and neither
+// acknowledged nor dead-lettered yet.
+func (r *Redelivery) InFlight() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.inflight)
+}
+
+// RedeliveryStats are the counters of a Redelivery.
+type RedeliveryStats struct {
+	// Acked is the number of values that were acknowledged.
+	Acked uint64
+
+	// Redelivered is the number of times a value was delivered again.
+	Redelivered uint64
+
+	// DeadLettered is the number of values that were given up on.
+	DeadLettered uint64
+}
+
+// Stats returns a snapshot of the redelivery's counters. It is safe to call
+// from any go-routine.
+func (r *Redelivery) Stats() RedeliveryStats {
+	return RedeliveryStats{
+		Acked:        atomic.LoadUint64(&r.acked),
+		Redelivered:  atomic.LoadUint64(&r.redelivered),
+		DeadLettered: atomic.LoadUint64(&r.deadLettered),
+	}
+}
@@ -0,0 +1,140 @@
+package diodes_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redelivery", func() {
+	var (
+		deadLetter *diodes.OneToOne
+		r          *diodes.Redelivery
+	)
+
+	BeforeEach(func() {
+		deadLetter = diodes.NewOneToOne(16, nil)
+		r = diodes.NewRedelivery(diodes.NewOneToOne(16, nil), 20*time.Millisecond,
+			diodes.WithRedeliveries(2),
+			diodes.WithRedeliveryDeadLetter(deadLetter),
+		)
+	})
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			r.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	next := func() diodes.Delivery {
+		var d diodes.Delivery
+		Eventually(func() bool {
+			var ok bool
+			d, ok = r.TryNext()
+			return ok
+		}).Should(BeTrue())
+		return d
+	}
+
+	value := func(d diodes.Delivery) int {
+		return *(*int)(d.Data)
+	}
+
+	It("does not redeliver an acknowledged value", func() {
+		set(1)
+		d := next()
+		Expect(value(d)).To(Equal(1))
+		Expect(d.Attempt).To(Equal(1))
+		Expect(r.InFlight()).To(Equal(1))
+
+		Expect(r.Ack(d.ID)).To(BeTrue())
+		Expect(r.Ack(d.ID)).To(BeFalse())
+		Expect(r.InFlight()).To(BeZero())
+
+		Consistently(func() bool {
+			_, ok := r.TryNext()
+			return ok
+		}, 50*time.Millisecond).Should(BeFalse())
+		Expect(r.Stats()).To(Equal(diodes.RedeliveryStats{Acked: 1}))
+	})
+
+	It("redelivers a value that was not acknowledged within the timeout", func() {
+		set(1, 2)
+		first := next()
+		Expect(value(next())).To(Equal(2))
+
+		d := next()
+		Expect(d.ID).To(Equal(first.ID))
+		Expect(value(d)).To(Equal(1))
+		Expect(d.Attempt).To(Equal(2))
+		Expect(r.Stats().Redelivered).To(BeNumerically(">=", 1))
+	})
+
+	It("dead-letters a value once it was redelivered too often", func() {
+		set(1)
+		d := next()
+		Expect(next().Attempt).To(Equal(2))
+		Expect(next().Attempt).To(Equal(3))
+
+		Eventually(func() bool {
+			_, ok := r.TryNext()
+			return ok || r.InFlight() == 0
+		}).Should(BeTrue())
+		Expect(r.InFlight()).To(BeZero())
+		Expect(r.Ack(d.ID)).To(BeFalse())
+
+		data, ok := deadLetter.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(1))
+		Expect(r.Stats()).To(Equal(diodes.RedeliveryStats{Redelivered: 2, DeadLettered: 1}))
+	})
+
+	It("redelivers a value that was not acknowledged right away", func() {
+		set(1, 2)
+		d := next()
+		Expect(r.Nack(d.ID)).To(BeTrue())
+
+		again, ok := r.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(again.ID).To(Equal(d.ID))
+		Expect(again.Attempt).To(Equal(2))
+
+		Expect(r.Ack(again.ID)).To(BeTrue())
+		Expect(value(next())).To(Equal(2))
+	})
+
+	It("is safe to read and acknowledge from several go-routines", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		r = diodes.NewRedelivery(diodes.NewManyToOne(1024, nil), time.Second,
+			diodes.WithRedeliveryContext(ctx),
+			diodes.WithRedeliveryPollingInterval(time.Millisecond),
+		)
+		set(make([]int, 100)...)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					d, ok := r.Next()
+					if !ok {
+						return
+					}
+					r.Ack(d.ID)
+				}
+			}()
+		}
+
+		Eventually(func() uint64 { return r.Stats().Acked }).Should(Equal(uint64(100)))
+		cancel()
+		wg.Wait()
+		Expect(r.Stats().Redelivered).To(BeZero())
+	})
+})
@@ -0,0 +1,21 @@
+package diodes
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// startRegion starts a runtime/trace region of the given type.
+func startRegion(regionType string) *trace.Region {
+	return trace.StartRegion(context.Background(), regionType)
+}
+
+// alert invokes the alerter, within a runtime/trace region if regions are
+// enabled.
+func alert(alerter Alerter, missed int, regions bool) {
+	if regions {
+		defer startRegion("diode.Alert").End()
+	}
+
+	alerter.Alert(missed)
+}
@@ -0,0 +1,59 @@
+package diodes_test
+
+import (
+	"bytes"
+	"runtime/trace"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithTraceRegions", func() {
+	var (
+		buf  *bytes.Buffer
+		data []byte
+	)
+
+	BeforeEach(func() {
+		buf = new(bytes.Buffer)
+		data = []byte("some-data")
+		Expect(trace.Start(buf)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		trace.Stop()
+	})
+
+	It("annotates the OneToOne writes, reads and alerts", func() {
+		d := diodes.NewOneToOne(2, nil, diodes.WithTraceRegions())
+		for i := 0; i < 3; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+		trace.Stop()
+
+		Expect(buf.String()).To(ContainSubstring("diode.Set"))
+		Expect(buf.String()).To(ContainSubstring("diode.TryNext"))
+		Expect(buf.String()).To(ContainSubstring("diode.Alert"))
+	})
+
+	It("annotates the Segmented batch reads", func() {
+		d := diodes.NewSegmented(2, 2, nil, diodes.WithTraceRegions())
+		d.Set(diodes.GenericDataType(&data))
+		d.TryNextBatch(nil)
+		trace.Stop()
+
+		Expect(buf.String()).To(ContainSubstring("diode.TryNextBatch"))
+	})
+
+	It("does not annotate without the option", func() {
+		d := diodes.NewManyToOne(2, nil)
+		d.Set(diodes.GenericDataType(&data))
+		d.TryNext()
+		trace.Stop()
+
+		Expect(buf.String()).ToNot(ContainSubstring("diode.Set"))
+	})
+})
@@ -0,0 +1,107 @@
+package diodes
+
+import "time"
+
+// ReplayResult is the outcome of replaying an arrival trace against a diode
+// of a candidate size and a reader of a candidate speed.
+type ReplayResult struct {
+	// Size is the size of the diode.
+	Size int
+
+	// ServiceTime is the time the reader takes for a value.
+	ServiceTime time.Duration
+
+	// Arrivals is the number of values in the trace.
+	Arrivals uint64
+
+	// Drops is the number of values the diode would have dropped.
+	Drops uint64
+
+	// MaxOccupancy is the highest number of values the diode would have
+	// held.
+	MaxOccupancy uint64
+}
+
+// DropRate returns the fraction of the values that would have been dropped.
+func (r ReplayResult) DropRate() float64 {
+	if r.Arrivals == 0 {
+		return 0
+	}
+
+	return float64(r.Drops) / float64(r.Arrivals)
+}
+
+// Replay replays a recorded trace of arrival times, in order, against a
+// diode of the given size whose reader takes serviceTime for every value.
+// Unlike the queueing model of Advise, it covers the bursts of real traffic,
+// so sizes can be chosen offline from a capture. The diode is simulated the
+// way it drops: once the writer laps the reader, the reader skips ahead to
+// the oldest value that was not overwritten.
+func Replay(arrivals []time.Time, size int, serviceTime time.Duration) ReplayResult {
+	res := ReplayResult{
+		Size:        size,
+		ServiceTime: serviceTime,
+		Arrivals:    uint64(len(arrivals)),
+	}
+	if size <= 0 {
+		res.Drops = res.Arrivals
+		return res
+	}
+
+	var (
+		writes, reads uint64
+		free          time.Time
+		n             = uint64(size)
+	)
+
+	// read performs the reads that start before the deadline. A zero
+	// deadline performs all of them.
+	read := func(deadline time.Time) {
+		for reads < writes && (deadline.IsZero() || free.Before(deadline)) {
+			if writes-reads > n {
+				// The slot of the read index holds the newest value that
+				// was written into it.
+				seq := reads + (writes-1-reads)/n*n
+				res.Drops += seq - reads
+				reads = seq
+			}
+
+			reads++
+			free = free.Add(serviceTime)
+		}
+	}
+
+	for _, t := range arrivals {
+		read(t)
+
+		// An idle reader starts on the value right away.
+		if reads == writes && free.Before(t) {
+			free = t
+		}
+		writes++
+
+		occupancy := writes - reads
+		if occupancy > n {
+			occupancy = n
+		}
+		if occupancy > res.MaxOccupancy {
+			res.MaxOccupancy = occupancy
+		}
+	}
+	read(time.Time{})
+
+	return res
+}
+
+// ReplayAll replays the trace against every combination of the sizes and
+// the service times, ordered by size and then by service time.
+func ReplayAll(arrivals []time.Time, sizes []int, serviceTimes []time.Duration) []ReplayResult {
+	results := make([]ReplayResult, 0, len(sizes)*len(serviceTimes))
+	for _, size := range sizes {
+		for _, st := range serviceTimes {
+			results = append(results, Replay(arrivals, size, st))
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,73 @@
+package diodes_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Replay", func() {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// trace returns n arrivals that are the given interval apart.
+	trace := func(n int, interval time.Duration) []time.Time {
+		arrivals := make([]time.Time, n)
+		for i := range arrivals {
+			arrivals[i] = start.Add(time.Duration(i) * interval)
+		}
+		return arrivals
+	}
+
+	It("does not drop while the reader keeps up", func() {
+		r := diodes.Replay(trace(100, 10*time.Millisecond), 1, time.Millisecond)
+
+		Expect(r.Drops).To(BeZero())
+		Expect(r.DropRate()).To(BeZero())
+		Expect(r.Arrivals).To(Equal(uint64(100)))
+		Expect(r.MaxOccupancy).To(Equal(uint64(1)))
+	})
+
+	It("drops like a diode when a burst laps the reader", func() {
+		r := diodes.Replay(trace(10, 0), 4, time.Millisecond)
+
+		d := diodes.NewOneToOne(4, nil)
+		for i := 0; i < 10; i++ {
+			d.Set(diodes.GenericDataType(&i))
+		}
+		for {
+			if _, ok := d.TryNext(); !ok {
+				break
+			}
+		}
+
+		Expect(r.Drops).To(Equal(d.Stats().Drops))
+		Expect(r.Drops).To(Equal(uint64(8)))
+		Expect(r.MaxOccupancy).To(Equal(uint64(4)))
+	})
+
+	It("drops when the reader is too slow", func() {
+		r := diodes.Replay(trace(1000, time.Millisecond), 8, 2*time.Millisecond)
+
+		Expect(r.DropRate()).To(BeNumerically("~", 0.5, 0.05))
+	})
+
+	It("absorbs a burst with a large enough size", func() {
+		arrivals := append(trace(50, 0), trace(50, 10*time.Millisecond)...)
+
+		Expect(diodes.Replay(arrivals, 16, time.Millisecond).Drops).ToNot(BeZero())
+		Expect(diodes.Replay(arrivals, 64, time.Millisecond).Drops).To(BeZero())
+	})
+
+	It("replays every combination of sizes and service times", func() {
+		results := diodes.ReplayAll(trace(10, time.Millisecond), []int{4, 8}, []time.Duration{time.Microsecond, time.Second})
+
+		Expect(results).To(HaveLen(4))
+		Expect(results[0].Size).To(Equal(4))
+		Expect(results[0].ServiceTime).To(Equal(time.Microsecond))
+		Expect(results[1].ServiceTime).To(Equal(time.Second))
+		Expect(results[2].Size).To(Equal(8))
+	})
+})
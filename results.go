@@ -0,0 +1,64 @@
+package diodes
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Result is a value or the error of a producer that failed to produce one.
+type Result struct {
+	Data GenericDataType
+	Err  error
+}
+
+// Results wraps a diode so that producers can set failures, such as decode
+// errors or upstream disconnects, on the same ring as their data. The reader
+// handles both in one ordered stream, and a failure is dropped like any
+// other value when the reader falls behind. Each value is wrapped in a small
+// allocation.
+type Results struct {
+	errors uint64
+
+	d Diode
+}
+
+// NewResults returns a Results that stores its values in the given diode.
+// The diode must only be written and read through the Results.
+func NewResults(d Diode) *Results {
+	return &Results{d: d}
+}
+
+// Set sets the data as a Result without an error.
+func (r *Results) Set(data GenericDataType) {
+	r.d.Set(GenericDataType(&Result{Data: data}))
+}
+
+// SetError sets the error as a Result without data.
+func (r *Results) SetError(err error) {
+	atomic.AddUint64(&r.errors, 1)
+	r.d.Set(GenericDataType(&Result{Err: err}))
+}
+
+// TryNext will attempt to read the next Result and returns it as a
+// *Result, which makes Results a Diode that can be wrapped by a Poller or a
+// Waiter. If there is no data available, it will return (nil, false).
+func (r *Results) TryNext() (GenericDataType, bool) {
+	return r.d.TryNext()
+}
+
+// TryNextResult will attempt to read the next Result. If there is no data
+// available, it will return (Result{}, false).
+func (r *Results) TryNextResult() (Result, bool) {
+	data, ok := r.d.TryNext()
+	if !ok {
+		return Result{}, false
+	}
+
+	return *(*Result)(unsafe.Pointer(data)), true
+}
+
+// Errors returns the number of errors that were set. It is safe to call from
+// any go-routine.
+func (r *Results) Errors() uint64 {
+	return atomic.LoadUint64(&r.errors)
+}
@@ -0,0 +1,79 @@
+package diodes_test
+
+import (
+	"context"
+	"errors"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Results", func() {
+	var (
+		d *diodes.OneToOne
+		r *diodes.Results
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(4, nil)
+		r = diodes.NewResults(d)
+	})
+
+	set := func(i int) {
+		r.Set(diodes.GenericDataType(&i))
+	}
+
+	It("returns the data and the errors in the order they were set", func() {
+		errDecode := errors.New("decode")
+		set(1)
+		r.SetError(errDecode)
+		set(2)
+
+		result, ok := r.TryNextResult()
+		Expect(ok).To(BeTrue())
+		Expect(result.Err).ToNot(HaveOccurred())
+		Expect(*(*int)(result.Data)).To(Equal(1))
+
+		result, ok = r.TryNextResult()
+		Expect(ok).To(BeTrue())
+		Expect(result.Err).To(MatchError(errDecode))
+		Expect(result.Data == nil).To(BeTrue())
+
+		result, ok = r.TryNextResult()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(result.Data)).To(Equal(2))
+
+		_, ok = r.TryNextResult()
+		Expect(ok).To(BeFalse())
+		Expect(r.Errors()).To(Equal(uint64(1)))
+	})
+
+	It("drops the errors like the data", func() {
+		for i := 0; i < 5; i++ {
+			r.SetError(errors.New("disconnected"))
+		}
+		set(5)
+
+		var n int
+		for {
+			if _, ok := r.TryNextResult(); !ok {
+				break
+			}
+			n++
+		}
+		Expect(n).To(BeNumerically("<", 6))
+		Expect(d.Stats().Drops).To(Equal(uint64(6 - n)))
+	})
+
+	It("can be wrapped by a Poller", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		p := diodes.NewPoller(r, diodes.WithPollingContext(ctx))
+		r.SetError(errors.New("disconnected"))
+
+		result := (*diodes.Result)(p.Next())
+		Expect(result.Err).To(MatchError("disconnected"))
+	})
+})
@@ -0,0 +1,155 @@
+package diodes
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Retry wraps the function that processes the values a reader takes from a
+// diode, such as the export of an exporter. A value whose processing fails
+// is retried with exponential backoff a bounded number of times and then
+// set on a dead-letter diode, so that it can be inspected or processed
+// later instead of being lost.
+type Retry struct {
+	succeeded    uint64
+	retries      uint64
+	deadLettered uint64
+
+	fn         func(GenericDataType) error
+	retryCount int
+	initial    time.Duration
+	max        time.Duration
+	deadLetter Diode
+	ctx        context.Context
+}
+
+// RetryOption can be used to setup the retry.
+type RetryOption func(*Retry)
+
+// WithRetries sets how many times a failed value is retried. The default is
+// 3.
+func WithRetries(n int) RetryOption {
+	return RetryOption(func(r *Retry) {
+		r.retryCount = n
+	})
+}
+
+// WithBackoff sets the wait before the first retry and the longest wait.
+// The wait doubles with every retry. The default is 100ms up to 10s.
+func WithBackoff(initial, maxWait time.Duration) RetryOption {
+	return RetryOption(func(r *Retry) {
+		r.initial = initial
+		r.max = maxWait
+	})
+}
+
+// WithDeadLetter sets the diode the values are set on once they failed
+// every retry. By default they are dropped.
+func WithDeadLetter(d Diode) RetryOption {
+	return RetryOption(func(r *Retry) {
+		r.deadLetter = d
+	})
+}
+
+// WithRetryContext sets the context that ends the retries. Once it is done,
+// a failed value is set on the dead-letter diode without waiting. Default
+// is context.Background().
+func WithRetryContext(ctx context.Context) RetryOption {
+	return RetryOption(func(r *Retry) {
+		r.ctx = ctx
+	})
+}
+
+// NewRetry returns a new Retry that processes the values with fn.
+func NewRetry(fn func(GenericDataType) error, opts ...RetryOption) *Retry {
+	r := &Retry{
+		fn:         fn,
+		retryCount: 3,
+		initial:    100 * time.Millisecond,
+		max:        10 * time.Second,
+		ctx:        context.Background(),
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// Process processes the value and retries it until it succeeds or failed
+// every retry. It blocks for the backoff in between. It may be invoked by
+// several go-routines, such as the workers of a WorkerPool.
+func (r *Retry) Process(data GenericDataType) {
+	wait := r.initial
+	for i := 0; ; i++ {
+		if r.fn(data) == nil {
+			atomic.AddUint64(&r.succeeded, 1)
+			return
+		}
+		if i == r.retryCount || !r.sleep(wait) {
+			break
+		}
+
+		atomic.AddUint64(&r.retries, 1)
+		wait *= 2
+		if wait > r.max {
+			wait = r.max
+		}
+	}
+
+	atomic.AddUint64(&r.deadLettered, 1)
+	if r.deadLetter != nil {
+		r.deadLetter.Set(data)
+	}
+}
+
+// sleep waits for the given time and reports whether the context is still
+// not done.
+func (r *Retry) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-r.ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Run processes the values of the Poller or Waiter until it returns nil.
+// Run must only be invoked once as it is the reader of the diode.
+func (r *Retry) Run(n Nexter) {
+	for {
+		data := n.Next()
+		if data == nil {
+			return
+		}
+
+		r.Process(data)
+	}
+}
+
+// RetryStats is a snapshot of the statistics of a Retry.
+type RetryStats struct {
+	// Succeeded is the number of values that were processed.
+	Succeeded uint64
+
+	// Retries is the number of times processing was retried.
+	Retries uint64
+
+	// DeadLettered is the number of values that failed every retry.
+	DeadLettered uint64
+}
+
+// Stats returns a snapshot of the statistics. It is safe to call from any
+// go-routine.
+func (r *Retry) Stats() RetryStats {
+	return RetryStats{
+		Succeeded:    atomic.LoadUint64(&r.succeeded),
+		Retries:      atomic.LoadUint64(&r.retries),
+		DeadLettered: atomic.LoadUint64(&r.deadLettered),
+	}
+}
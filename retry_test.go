@@ -0,0 +1,83 @@
+package diodes_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry", func() {
+	var (
+		deadLetter *diodes.OneToOne
+		attempts   int
+	)
+
+	BeforeEach(func() {
+		deadLetter = diodes.NewOneToOne(16, nil)
+		attempts = 0
+	})
+
+	// failing fails the first n attempts.
+	failing := func(n int) func(diodes.GenericDataType) error {
+		return func(diodes.GenericDataType) error {
+			attempts++
+			if attempts <= n {
+				return errors.New("some-error")
+			}
+			return nil
+		}
+	}
+
+	value := func(v int) diodes.GenericDataType {
+		return diodes.GenericDataType(&v)
+	}
+
+	It("retries until the value is processed", func() {
+		r := diodes.NewRetry(failing(2), diodes.WithBackoff(time.Millisecond, time.Millisecond))
+		r.Process(value(1))
+
+		Expect(attempts).To(Equal(3))
+		Expect(r.Stats()).To(Equal(diodes.RetryStats{Succeeded: 1, Retries: 2}))
+	})
+
+	It("sets the value on the dead-letter diode once it failed every retry", func() {
+		r := diodes.NewRetry(failing(10),
+			diodes.WithRetries(2),
+			diodes.WithBackoff(time.Millisecond, time.Millisecond),
+			diodes.WithDeadLetter(deadLetter),
+		)
+		r.Process(value(1))
+
+		Expect(attempts).To(Equal(3))
+		data, ok := deadLetter.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(1))
+		Expect(r.Stats()).To(Equal(diodes.RetryStats{Retries: 2, DeadLettered: 1}))
+	})
+
+	It("backs off exponentially", func() {
+		r := diodes.NewRetry(failing(3), diodes.WithBackoff(10*time.Millisecond, time.Second))
+
+		start := time.Now()
+		r.Process(value(1))
+		Expect(time.Since(start)).To(BeNumerically(">=", 70*time.Millisecond))
+	})
+
+	It("stops retrying once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := diodes.NewRetry(failing(10),
+			diodes.WithRetryContext(ctx),
+			diodes.WithDeadLetter(deadLetter),
+		)
+		r.Process(value(1))
+
+		Expect(attempts).To(Equal(1))
+		Expect(r.Stats().DeadLettered).To(Equal(uint64(1)))
+	})
+})
@@ -0,0 +1,458 @@
+package diodes
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+type bucket struct {
+	data GenericDataType
+	seq  uint64 // seq is the recorded write index at the time of writing
+}
+
+// timedBucket is used instead of a bucket when the enqueue time of the data
+// is tracked.
+type timedBucket struct {
+	bucket
+	ts int64 // ts is the time of writing in nanoseconds
+}
+
+// entry is a copy of the data read from a slot.
+type entry struct {
+	data GenericDataType
+	seq  uint64
+	ts   int64
+}
+
+// seqSlot is a slot of a Seqlock ring. The version is odd while a write is in
+// progress and zero while the slot was never written. The padding keeps the size of a slot a multiple
+// of 8 bytes so that the 64-bit fields of every slot in the ring are aligned
+// on 32-bit platforms.
+type seqSlot struct {
+	version uint64
+	seq     uint64
+	_       [(8 - unsafe.Sizeof(uintptr(0))%8) % 8]byte
+	data    unsafe.Pointer
+}
+
+var (
+	pointerSlotType = reflect.TypeOf(unsafe.Pointer(nil))
+	seqSlotType     = reflect.TypeOf(seqSlot{})
+)
+
+// cacheLineSize is the size of a cache line on most platforms.
+const cacheLineSize = 64
+
+// ring is the storage shared by the diodes. Depending on the implementation
+// only one of buffer (PointerSwap) or slots (Seqlock) is set. The slot for an
+// index is found at index*stride, a stride larger than one is used to pad
+// slots.
+//
+// When the enqueue time of data is tracked, PointerSwap rings hold
+// timedBuckets and Seqlock rings keep the times in stamps.
+//
+// The 64-bit fields must stay at the start of the struct so that they are
+// aligned on 32-bit platforms.
+type ring struct {
+	size    uint64
+	stride  uint64
+	start   uint64
+	buffer  []unsafe.Pointer
+	slots   []seqSlot
+	stamps  []int64
+	timed   bool
+	regions bool
+	latest  bool
+	ordered bool
+	instr   *instrumentation
+	hooks   *hooks
+	ctx     context.Context
+	idle    *idle
+	owners  *owners
+	leak    *leakHook
+	rec     *Recorder
+	panics  func(*CallbackPanic)
+}
+
+// newRing allocates the diode of the given type together with its ring in a
+// single block. It returns a pointer to the diode.
+func newRing(diode reflect.Type, size int, c diodeConfig) (unsafe.Pointer, ring) {
+	if c.implementation == Seqlock {
+		stride := c.stride(seqSlotType)
+		p, slots := allocBlock(diode, seqSlotType, size*stride)
+		r := ring{
+			size:   uint64(size),
+			stride: uint64(stride),
+			slots:  unsafe.Slice((*seqSlot)(slots), size*stride),
+		}
+		r.instrument(c)
+		return p, r
+	}
+
+	stride := c.stride(pointerSlotType)
+	p, buffer := allocBlock(diode, pointerSlotType, size*stride)
+	r := ring{
+		size:   uint64(size),
+		stride: uint64(stride),
+		buffer: unsafe.Slice((*unsafe.Pointer)(buffer), size*stride),
+	}
+	r.instrument(c)
+	return p, r
+}
+
+// newBufferRing wraps a caller provided buffer. Only the PointerSwap
+// implementation can use a buffer of pointers as its ring.
+func newBufferRing(buffer []unsafe.Pointer, c diodeConfig) ring {
+	if c.implementation != PointerSwap {
+		panic("diodes: a caller provided buffer requires the PointerSwap implementation")
+	}
+
+	if len(buffer) == 0 {
+		panic("diodes: a caller provided buffer must not be empty")
+	}
+
+	stride := c.stride(pointerSlotType)
+	if len(buffer) < stride {
+		panic("diodes: a caller provided buffer must hold at least one padded slot of " + strconv.Itoa(stride) + " pointers")
+	}
+
+	clearRing(buffer)
+
+	r := ring{
+		size:   uint64(len(buffer) / stride),
+		stride: uint64(stride),
+		buffer: buffer,
+	}
+	r.instrument(c)
+	return r
+}
+
+// instrument sets up the instrumentation of the ring for the configured
+// level.
+func (r *ring) instrument(c diodeConfig) {
+	r.regions = c.traceRegions
+	r.latest = c.catchUp == CatchUpLatest
+	r.ordered = c.strictOrder
+	r.start = c.start
+	r.hooks = c.hooks
+	r.ctx = c.ctx
+	r.idle = c.idle
+	r.owners = newOwners(c.misuse)
+	r.leak = c.leak
+	r.rec = c.recorder
+	r.panics = c.panics
+	r.instr = newInstrumentation(c)
+	r.timed = r.instr.detailed()
+
+	if r.timed && r.slots != nil {
+		r.stamps = make([]int64, r.size)
+	}
+}
+
+// reset empties the ring. It must not be used concurrently with the reader
+// or the writers.
+func (r *ring) reset() {
+	clearRing(r.buffer)
+	for i := range r.slots {
+		r.slots[i] = seqSlot{}
+	}
+	for i := range r.stamps {
+		r.stamps[i] = 0
+	}
+}
+
+// stride returns how many slots of the given type each index occupies.
+func (c diodeConfig) stride(slot reflect.Type) int {
+	if !c.paddedSlots {
+		return 1
+	}
+
+	size := int(slot.Size())
+	return (cacheLineSize + size - 1) / size
+}
+
+// pointer returns the PointerSwap slot for the given index.
+func (r *ring) pointer(idx uint64) *unsafe.Pointer {
+	return &r.buffer[idx*r.stride]
+}
+
+// seqSlot returns the Seqlock slot for the given index.
+func (r *ring) seqSlot(idx uint64) *seqSlot {
+	return &r.slots[idx*r.stride]
+}
+
+// load reads the entry at the given index. For the PointerSwap
+// implementation the bucket is removed from the ring.
+func (r *ring) load(idx uint64) (entry, bool) {
+	if r.slots != nil {
+		return r.loadSlot(idx)
+	}
+
+	result := (*bucket)(atomic.SwapPointer(r.pointer(idx), nil))
+	if result == nil {
+		return entry{}, false
+	}
+
+	e := entry{data: result.data, seq: result.seq}
+	if r.timed {
+		e.ts = (*timedBucket)(unsafe.Pointer(result)).ts
+	}
+
+	return e, true
+}
+
+// store writes data into the slot at the given index. It must only be used
+// when there is a single writer.
+func (r *ring) store(idx, seq uint64, data GenericDataType, ts int64) {
+	if r.slots != nil {
+		s := r.seqSlot(idx)
+		version := atomic.LoadUint64(&s.version)
+		atomic.StoreUint64(&s.version, version+1)
+		r.writeSlot(idx, version, seq, data, ts)
+		return
+	}
+
+	atomic.StorePointer(r.pointer(idx), r.newBucket(seq, data, ts))
+}
+
+// newBucket returns a new bucket for the PointerSwap implementation.
+func (r *ring) newBucket(seq uint64, data GenericDataType, ts int64) unsafe.Pointer {
+	if r.timed {
+		return unsafe.Pointer(&timedBucket{
+			bucket: bucket{data: data, seq: seq},
+			ts:     ts,
+		})
+	}
+
+	return unsafe.Pointer(&bucket{
+		data: data,
+		seq:  seq,
+	})
+}
+
+// loadSlot returns a consistent copy of the Seqlock slot at the given index.
+// It returns false when the slot is empty or a write is in progress.
+func (r *ring) loadSlot(idx uint64) (entry, bool) {
+	s := r.seqSlot(idx)
+	for {
+		version := atomic.LoadUint64(&s.version)
+		if version&1 == 1 {
+			return entry{}, false
+		}
+
+		seq := atomic.LoadUint64(&s.seq)
+		yield()
+		data := atomic.LoadPointer(&s.data)
+
+		var ts int64
+		if r.stamps != nil {
+			ts = atomic.LoadInt64(&r.stamps[idx])
+		}
+
+		if atomic.LoadUint64(&s.version) != version {
+			continue
+		}
+
+		if version == 0 {
+			return entry{}, false
+		}
+
+		return entry{data: GenericDataType(data), seq: seq, ts: ts}, true
+	}
+}
+
+// writeSlot sets the contents of the locked Seqlock slot at the given index
+// and releases it.
+func (r *ring) writeSlot(idx, version, seq uint64, data GenericDataType, ts int64) {
+	s := r.seqSlot(idx)
+	atomic.StoreUint64(&s.seq, seq)
+	yield()
+	atomic.StorePointer(&s.data, unsafe.Pointer(data))
+	if r.stamps != nil {
+		atomic.StoreInt64(&r.stamps[idx], ts)
+	}
+	atomic.StoreUint64(&s.version, version+2)
+}
+
+// tryLock attempts to begin a write into the slot. On success it returns the
+// version the slot had before it was locked.
+func (s *seqSlot) tryLock() (uint64, bool) {
+	version := atomic.LoadUint64(&s.version)
+	if version&1 == 1 {
+		return 0, false
+	}
+
+	return version, atomic.CompareAndSwapUint64(&s.version, version, version+1)
+}
+
+// unlock releases a slot locked with tryLock without writing to it.
+func (s *seqSlot) unlock(version uint64) {
+	atomic.StoreUint64(&s.version, version)
+}
+
+// reader holds the state of the single reader of a diode. Like ring, its
+// 64-bit fields come first and its size is a multiple of 8 bytes.
+//
+// The readIndex and dropped are only written by the reader. The reader may
+// load them without synchronization, but must store them atomically so that
+// they can be observed from other go-routines.
+type reader struct {
+	readIndex uint64
+	dropped   uint64
+	alerter   Alerter
+}
+
+func (r *reader) init(alerter Alerter) {
+	if alerter == nil {
+		alerter = AlertFunc(func(int) {})
+	}
+
+	r.alerter = alerter
+}
+
+func (r *reader) reset(start uint64) {
+	atomic.StoreUint64(&r.readIndex, start)
+	atomic.StoreUint64(&r.dropped, 0)
+}
+
+// tryNext will attempt to read from the next slot of the ring buffer.
+// If there is no data available, it will return (nil, false).
+func (r *reader) tryNext(ring *ring) (data GenericDataType, ok bool) {
+	data, _, ok = r.tryNextGap(ring)
+	return data, ok
+}
+
+// tryNextGap is tryNext that also returns the values the reader skipped
+// before the one it read.
+func (r *reader) tryNextGap(ring *ring) (data GenericDataType, gap Gap, ok bool) {
+	ring.owners.checkReader()
+
+	// Read a value from the ring buffer based on the readIndex.
+	readIndex := r.readIndex
+	idx := readIndex % ring.size
+	result, ok := ring.load(idx)
+	yield()
+
+	// When there is no result that means the writer has not had the
+	// opportunity to write a value into the diode. This value must be ignored
+	// and the read head must not increment.
+	if !ok {
+		ring.instr.emptyRead()
+		ring.rec.record(EventEmpty, 0, 0)
+		return nil, Gap{}, false
+	}
+
+	// When the seq value is less than the current read index that means a
+	// value was read from idx that was previously written but has since has
+	// been dropped (or, for the Seqlock implementation, already read). This
+	// value must be ignored and the read head must not increment.
+	//
+	// The simulation for this scenario assumes the fast forward occurred as
+	// detailed below.
+	//
+	// 5. The reader reads again getting seq 5. It then reads again expecting
+	//    seq 6 but gets seq 2. This is a read of a stale value that was
+	//    effectively "dropped" so the read fails and the read head stays put.
+	//    `| 4 | 5 | 2 | 3 |` r: 7, w: 6
+	//
+	if seqBefore(result.seq, readIndex) {
+		ring.instr.emptyRead()
+		ring.rec.record(EventEmpty, 0, 0)
+		return nil, Gap{}, false
+	}
+
+	// When the seq value is greater than the current read index that means a
+	// value was read from idx that overwrote the value that was expected to
+	// be at this idx. This happens when the writer has lapped the reader. The
+	// reader needs to catch up to the writer so it moves its write head to
+	// the new seq, effectively dropping the messages that were not read in
+	// between the two values.
+	//
+	// Here is a simulation of this scenario:
+	//
+	// 1. Both the read and write heads start at 0.
+	//    `| nil | nil | nil | nil |` r: 0, w: 0
+	// 2. The writer fills the buffer.
+	//    `| 0 | 1 | 2 | 3 |` r: 0, w: 4
+	// 3. The writer laps the read head.
+	//    `| 4 | 5 | 2 | 3 |` r: 0, w: 6
+	// 4. The reader reads the first value, expecting a seq of 0 but reads 4,
+	//    this forces the reader to fast forward to 5.
+	//    `| 4 | 5 | 2 | 3 |` r: 5, w: 6
+	//
+	// With CatchUpLatest, the reader follows the values the writer wrote
+	// after the one it read to the newest one and drops them as well.
+	if seqAfter(result.seq, readIndex) {
+		if ring.latest {
+			result = ring.newest(result)
+		}
+
+		dropped := result.seq - readIndex
+		gap = newGap(readIndex, result.seq)
+		readIndex = result.seq
+		atomic.AddUint64(&r.dropped, dropped)
+		ring.instr.alert(dropped)
+		alert(r.alerter, int(dropped), ring.regions)
+		ring.hooks.drop(int(dropped))
+		ring.rec.record(EventAlert, 0, int(dropped))
+	}
+
+	// Only increment read index if a regular read occurred (where seq was
+	// equal to readIndex) or a value was read that caused a fast forward
+	// (where seq was greater than readIndex).
+	atomic.StoreUint64(&r.readIndex, readIndex+1)
+	ring.instr.observeLatency(result.ts)
+	ring.rec.record(EventRead, readIndex, 0)
+	return result.data, gap, true
+}
+
+// stats returns the reader's part of the diode's statistics.
+func (r *reader) stats(ring *ring, writes, writeIndex uint64) Stats {
+	readIndex := atomic.LoadUint64(&r.readIndex)
+	dropped := atomic.LoadUint64(&r.dropped)
+
+	return Stats{
+		Writes:   writes,
+		Reads:    readIndex - ring.start - dropped,
+		Drops:    dropped,
+		Lag:      lag(writeIndex, readIndex),
+		Capacity: int(ring.size),
+	}
+}
+
+// lag returns how far the reader is behind the given write index.
+func (r *reader) lag(writeIndex uint64) uint64 {
+	return lag(writeIndex, atomic.LoadUint64(&r.readIndex))
+}
+
+// drain blocks until the reader read or dropped everything before the given
+// write index or until the context is done. The reader does not signal its
+// reads, so drain polls, backing off from a few microseconds to 10ms.
+func (r *reader) drain(ctx context.Context, writeIndex uint64) error {
+	wait := 10 * time.Microsecond
+	for seqBefore(atomic.LoadUint64(&r.readIndex), writeIndex) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		if wait < 10*time.Millisecond {
+			wait *= 2
+		}
+	}
+
+	return nil
+}
+
+func lag(writeIndex, readIndex uint64) uint64 {
+	if seqAfter(writeIndex, readIndex) {
+		return writeIndex - readIndex
+	}
+
+	return 0
+}
@@ -0,0 +1,725 @@
+// Package ringfile provides a diode whose values are written to a
+// fixed-size circular file. Like the in-memory diodes, the oldest values are
+// overwritten once the file is full, so the disk usage is bounded. The
+// values that are in the file when it is opened again can be read, which
+// makes it a flight recorder for the last moments before a crash.
+package ringfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/offset"
+)
+
+// The file starts with a header that is followed by the data area. The
+// records are written one after another and wrap around at the end of the
+// data area:
+//
+//	header: magic [8]byte | version uint32 | _ uint32 | capacity uint64 | tail uint64 | tailSeq uint64
+//	record: seq uint64 | ts int64 | length uint32 | crc uint32 | payload [length]byte
+//
+// The highest bit of the length is set for compressed payloads and the next
+// one for encrypted payloads. The crc is the checksum of the payload as it
+// is written.
+//
+// Like the Seqlock implementation of the in-memory diodes, the seq of a
+// record is its write index plus one, so that the zeroes of a new file are
+// not taken for a record.
+//
+// The tail is the position of the oldest record and tailSeq its write
+// index. Positions grow without wrapping; the offset of a position in the
+// data area is the position modulo the capacity. The header is written
+// before a record overwrites the oldest ones, so that it never points into a
+// record that is being written. The end of the records is found by
+// following them from the tail until a sequence number or checksum does not
+// match.
+const (
+	headerSize       = 64
+	recordHeaderSize = 24
+	formatVersion    = 2
+
+	offCapacity = 16
+	offTail     = 24
+
+	compressedFlag = 1 << 31
+	encryptedFlag  = 1 << 30
+	lengthMask     = encryptedFlag - 1
+)
+
+var magic = [8]byte{'g', 'o', 'd', 'r', 'i', 'n', 'g', 0}
+
+var (
+	// ErrTooLarge is returned by Set for payloads that do not fit into the
+	// file.
+	ErrTooLarge = errors.New("ringfile: payload exceeds the capacity")
+
+	// ErrIncompatible is returned by Open for a file that is not a ring
+	// file or has a different capacity.
+	ErrIncompatible = errors.New("ringfile: incompatible file")
+)
+
+// Diode is a diode whose ring buffer is a circular file. It is meant to be
+// used by a single reader and a single writer. The payloads are copied into
+// the file, so they are bytes instead of pointers.
+type Diode struct {
+	capacity          uint64
+	syncEvery         uint64
+	maxAge            time.Duration
+	retentionInterval time.Duration
+	stop              chan struct{}
+	done              chan struct{}
+	alerter           diodes.Alerter
+	compressor        diodes.Compressor
+	cipher            diodes.Cipher
+	recovery          Recovery
+	offsets           *offset.Store
+	consumer          string
+	commitEvery       uint64
+	reads             uint64
+
+	mu         sync.Mutex
+	f          *os.File
+	records    []record
+	tail       uint64
+	head       uint64
+	tailSeq    uint64
+	readSeq    uint64
+	dropped    uint64
+	buf        []byte
+	compressed []byte
+	sealed     []byte
+}
+
+// record is the position, size and time of writing of a record in the
+// file.
+type record struct {
+	pos   uint64
+	size  uint64
+	ts    int64
+	flags uint32
+}
+
+// Recovery describes what Open found in an existing file.
+type Recovery struct {
+	// Records is the number of values found in the file. They are read
+	// before the values that are set after Open.
+	Records uint64
+
+	// Bytes is the size of the records found in the file.
+	Bytes uint64
+
+	// FirstSeq and LastSeq are the sequence numbers of the first and the
+	// last record found in the file, counting from zero. They are zero when
+	// no records were found.
+	FirstSeq uint64
+	LastSeq  uint64
+
+	// Consumed is the number of the records found in the file that the
+	// consumer of WithOffsets had consumed already. They are not read
+	// again.
+	Consumed uint64
+
+	// Truncated reports whether the records ended with one that failed its
+	// checksum or did not fit, such as one that was being written during a
+	// crash. It and anything after it are ignored.
+	Truncated bool
+}
+
+// Option can be used to setup the diode.
+type Option func(*Diode)
+
+// WithSyncEvery flushes the file to stable storage after every n writes. The
+// values written in between survive a crash of the process, but not
+// necessarily a crash of the machine. By default the file is only flushed by
+// Sync and Close.
+func WithSyncEvery(n int) Option {
+	return Option(func(d *Diode) {
+		d.syncEvery = uint64(n)
+	})
+}
+
+// WithMaxAge expires the records that are older than the given age, so that
+// an unattended flight recorder does not return the records of a past
+// incident. Expired records are not read and count as drops. By default
+// records are only overwritten.
+func WithMaxAge(age time.Duration) Option {
+	return Option(func(d *Diode) {
+		d.maxAge = age
+	})
+}
+
+// WithRetentionInterval sets how often the records are expired in the
+// background, so that they are not recovered after a restart either. The
+// reader never reads expired records regardless. The default is one second.
+func WithRetentionInterval(interval time.Duration) Option {
+	return Option(func(d *Diode) {
+		d.retentionInterval = interval
+	})
+}
+
+// WithCompressor compresses the payloads in the file. The payloads are
+// compressed one by one, so the size limit of Set applies to the compressed
+// payload. Compressed payloads are only read by a Diode with a Compressor;
+// without one they are dropped.
+func WithCompressor(c diodes.Compressor) Option {
+	return Option(func(d *Diode) {
+		d.compressor = c
+	})
+}
+
+// WithCipher encrypts the payloads in the file. Like with WithCompressor, the
+// size limit of Set applies to the encrypted payload. Encrypted payloads are
+// only read by a Diode with a Cipher; without one they are dropped.
+func WithCipher(c diodes.Cipher) Option {
+	return Option(func(d *Diode) {
+		d.cipher = c
+	})
+}
+
+// WithOffsets resumes the reader at the offset the consumer committed to the
+// store, instead of at the oldest record of the file, so that a collector
+// that restarts does not read the records it consumed again. The records
+// that were overwritten since are dropped and alerted as usual. The offset
+// of the reader is committed every 100 reads, on Commit and on Close.
+func WithOffsets(s *offset.Store, consumer string) Option {
+	return Option(func(d *Diode) {
+		d.offsets = s
+		d.consumer = consumer
+	})
+}
+
+// WithCommitEvery sets after how many reads the reader commits its offset to
+// the store of WithOffsets. The records read since the last commit are read
+// again after a crash, so delivery is at least once. The default is 100.
+// With 0, only Commit and Close commit.
+func WithCommitEvery(n int) Option {
+	return Option(func(d *Diode) {
+		d.commitEvery = uint64(n)
+	})
+}
+
+// Open opens or creates the file at the given path as a diode whose data
+// area holds capacity bytes of records. Each record takes 24 bytes in
+// addition to its payload. An existing file must have the same capacity.
+// Its values can be read again. The alerter is invoked on the read's
+// go-routine. A nil can be used to ignore alerts.
+func Open(path string, capacity int64, alerter diodes.Alerter, opts ...Option) (*Diode, error) {
+	if alerter == nil {
+		alerter = diodes.AlertFunc(func(int) {})
+	}
+
+	d := &Diode{
+		capacity:          uint64(capacity),
+		alerter:           alerter,
+		retentionInterval: time.Second,
+		commitEvery:       100,
+	}
+	for _, o := range opts {
+		o(d)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	d.f = f
+
+	if err := d.open(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.resume()
+
+	if d.maxAge > 0 {
+		d.stop = make(chan struct{})
+		d.done = make(chan struct{})
+		go d.retain()
+	}
+
+	return d, nil
+}
+
+func (d *Diode) open() error {
+	info, err := d.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := int64(headerSize + d.capacity)
+	if info.Size() == 0 {
+		if err := d.f.Truncate(size); err != nil {
+			return err
+		}
+
+		var header [headerSize]byte
+		copy(header[:], magic[:])
+		binary.LittleEndian.PutUint32(header[8:], formatVersion)
+		binary.LittleEndian.PutUint64(header[offCapacity:], d.capacity)
+		_, err := d.f.WriteAt(header[:], 0)
+		return err
+	}
+
+	var header [headerSize]byte
+	if info.Size() != size {
+		return ErrIncompatible
+	}
+	if _, err := d.f.ReadAt(header[:], 0); err != nil {
+		return err
+	}
+	if *(*[8]byte)(header[:]) != magic ||
+		binary.LittleEndian.Uint32(header[8:]) != formatVersion ||
+		binary.LittleEndian.Uint64(header[offCapacity:]) != d.capacity {
+		return ErrIncompatible
+	}
+
+	d.tail = binary.LittleEndian.Uint64(header[offTail:])
+	d.tailSeq = binary.LittleEndian.Uint64(header[offTail+8:])
+	return d.recover()
+}
+
+// recover finds the records that follow the tail.
+func (d *Diode) recover() error {
+	d.head = d.tail
+	d.readSeq = d.tailSeq
+
+	var header [recordHeaderSize]byte
+	for seq := d.tailSeq; ; seq++ {
+		if d.head+recordHeaderSize > d.tail+d.capacity {
+			break
+		}
+		if err := d.readAt(header[:], d.head); err != nil {
+			return err
+		}
+
+		if binary.LittleEndian.Uint64(header[:]) != seq+1 {
+			break
+		}
+
+		length := uint64(binary.LittleEndian.Uint32(header[16:]) & lengthMask)
+		size := recordHeaderSize + length
+		if d.head+size > d.tail+d.capacity {
+			d.recovery.Truncated = true
+			break
+		}
+
+		payload := make([]byte, length)
+		if err := d.readAt(payload, d.head+recordHeaderSize); err != nil {
+			return err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[20:]) {
+			d.recovery.Truncated = true
+			break
+		}
+
+		d.records = append(d.records, record{
+			pos:   d.head,
+			size:  size,
+			ts:    int64(binary.LittleEndian.Uint64(header[8:])),
+			flags: binary.LittleEndian.Uint32(header[16:]) &^ lengthMask,
+		})
+		d.head += size
+	}
+
+	d.recovery.Records = uint64(len(d.records))
+	d.recovery.Bytes = d.head - d.tail
+	if len(d.records) > 0 {
+		d.recovery.FirstSeq = d.tailSeq
+		d.recovery.LastSeq = d.tailSeq + uint64(len(d.records)) - 1
+	}
+
+	return nil
+}
+
+// resume moves the reader to the offset the consumer committed. An offset
+// past the records is of another file, so the reader starts at the oldest
+// record then.
+func (d *Diode) resume() {
+	if d.offsets == nil {
+		return
+	}
+
+	seq, ok := d.offsets.Offset(d.consumer)
+	if !ok || seq > d.tailSeq+uint64(len(d.records)) {
+		return
+	}
+
+	d.readSeq = seq
+	if seq > d.tailSeq {
+		d.recovery.Consumed = seq - d.tailSeq
+	}
+}
+
+// Recovery returns what Open found in an existing file.
+func (d *Diode) Recovery() Recovery {
+	return d.recovery
+}
+
+// Recover passes the records that Open found in the file to fn, in order,
+// and returns what Open found. It is meant to be invoked by the reader before
+// it reads the values that are set after Open, so that the consumer can
+// handle them apart, such as by reprocessing them. It returns early when the
+// reader has already read them. Records that were overwritten or expired in
+// the meantime are dropped as usual. Recover stops at the first error of fn
+// and returns it; the record passed to fn is read all the same.
+func (d *Diode) Recover(fn func(payload []byte) error) (Recovery, error) {
+	end := d.recovery.FirstSeq + d.recovery.Records
+	for {
+		payload, dropped, ok := d.next(end)
+		if dropped > 0 {
+			d.alerter.Alert(int(dropped))
+		}
+		if !ok {
+			return d.recovery, nil
+		}
+		d.consume()
+
+		if err := fn(payload); err != nil {
+			return d.recovery, err
+		}
+	}
+}
+
+// Set writes the payload after the newest record, overwriting the oldest
+// records it has no room for. It returns ErrTooLarge for payloads that do
+// not fit into the file.
+func (d *Diode) Set(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	payload, flags, err := d.encode(payload)
+	if err != nil {
+		return err
+	}
+
+	size := uint64(recordHeaderSize + len(payload))
+	if size > d.capacity {
+		return ErrTooLarge
+	}
+
+	if err := d.evict(size); err != nil {
+		return err
+	}
+
+	seq := d.tailSeq + uint64(len(d.records))
+	ts := time.Now().UnixNano()
+	var header [recordHeaderSize]byte
+	binary.LittleEndian.PutUint64(header[:], seq+1)
+	binary.LittleEndian.PutUint64(header[8:], uint64(ts))
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(payload))|flags)
+	binary.LittleEndian.PutUint32(header[20:], crc32.ChecksumIEEE(payload))
+	d.buf = append(append(d.buf[:0], header[:]...), payload...)
+
+	if err := d.writeAt(d.buf, d.head); err != nil {
+		return err
+	}
+	d.records = append(d.records, record{pos: d.head, size: size, ts: ts, flags: flags})
+	d.head += size
+
+	if d.syncEvery > 0 && (seq+1)%d.syncEvery == 0 {
+		return d.f.Sync()
+	}
+
+	return nil
+}
+
+// evict moves the tail past the oldest records until there is room for a
+// record of the given size and writes the header if it moved.
+func (d *Diode) evict(size uint64) error {
+	n := 0
+	for d.head+size-d.tail > d.capacity {
+		d.tail += d.records[n].size
+		n++
+	}
+
+	return d.advance(n)
+}
+
+// expire moves the tail past the records that are older than the maximum
+// age and writes the header if it moved.
+func (d *Diode) expire(now time.Time) error {
+	if d.maxAge <= 0 {
+		return nil
+	}
+
+	cutoff := now.Add(-d.maxAge).UnixNano()
+	n := 0
+	for n < len(d.records) && d.records[n].ts < cutoff {
+		d.tail += d.records[n].size
+		n++
+	}
+
+	return d.advance(n)
+}
+
+// advance removes the n oldest records, whose size was already added to the
+// tail, and writes the header.
+func (d *Diode) advance(n int) error {
+	if n == 0 {
+		return nil
+	}
+
+	d.records = d.records[n:]
+	d.tailSeq += uint64(n)
+
+	var b [16]byte
+	binary.LittleEndian.PutUint64(b[:], d.tail)
+	binary.LittleEndian.PutUint64(b[8:], d.tailSeq)
+	_, err := d.f.WriteAt(b[:], offTail)
+	return err
+}
+
+// TryNext will attempt to read a copy of the payload of the next record. If
+// there is no data available, it will return (nil, false). When the records
+// the reader did not read yet were overwritten, the alerter is invoked with
+// their number.
+func (d *Diode) TryNext() ([]byte, bool) {
+	payload, dropped, ok := d.next(math.MaxUint64)
+	if dropped > 0 {
+		d.alerter.Alert(int(dropped))
+	}
+	if ok {
+		d.consume()
+	}
+
+	return payload, ok
+}
+
+// consume counts a read and commits the offset according to
+// WithCommitEvery. A commit that fails is retried by the next one, and Close
+// returns its error.
+func (d *Diode) consume() {
+	if d.offsets == nil {
+		return
+	}
+
+	d.reads++
+	if d.commitEvery > 0 && d.reads%d.commitEvery == 0 {
+		d.Commit()
+	}
+}
+
+// Commit commits the offset of the reader to the store of WithOffsets, so
+// that the records read so far are not read again once the file is opened
+// again. It is meant to be invoked by the reader.
+func (d *Diode) Commit() error {
+	if d.offsets == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	seq := d.readSeq
+	d.mu.Unlock()
+
+	return d.offsets.Commit(d.consumer, seq)
+}
+
+// next reads the next record whose sequence number is below end.
+func (d *Diode) next(end uint64) ([]byte, uint64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// An error of the header is returned by the next Set.
+	d.expire(time.Now())
+
+	// The overwritten records from end on are dropped by a later read.
+	to := d.tailSeq
+	if to > end {
+		to = end
+	}
+
+	var dropped uint64
+	if d.readSeq < to {
+		dropped = to - d.readSeq
+		d.dropped += dropped
+		d.readSeq = to
+	}
+	if d.readSeq < d.tailSeq {
+		return nil, dropped, false
+	}
+
+	i := d.readSeq - d.tailSeq
+	for ; i < uint64(len(d.records)) && d.readSeq < end; i++ {
+		r := d.records[i]
+		payload := make([]byte, r.size-recordHeaderSize)
+		if err := d.readAt(payload, r.pos+recordHeaderSize); err != nil {
+			return nil, dropped, false
+		}
+		d.readSeq++
+
+		payload, err := d.decode(payload, r.flags)
+		if err == nil {
+			return payload, dropped, true
+		}
+
+		// A payload that cannot be decrypted or decompressed is dropped.
+		d.dropped++
+		dropped++
+	}
+
+	return nil, dropped, false
+}
+
+// encode compresses and encrypts the payload as configured. It returns the
+// flags of the record. The returned payload is only valid until the next
+// call.
+func (d *Diode) encode(payload []byte) ([]byte, uint32, error) {
+	var (
+		flags uint32
+		err   error
+	)
+	if d.compressor != nil {
+		d.compressed, err = d.compressor.Compress(d.compressed[:0], payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		payload = d.compressed
+		flags |= compressedFlag
+	}
+
+	if d.cipher != nil {
+		d.sealed, err = d.cipher.Seal(d.sealed[:0], payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		payload = d.sealed
+		flags |= encryptedFlag
+	}
+
+	return payload, flags, nil
+}
+
+// decode reverses encode for a record with the given flags.
+func (d *Diode) decode(payload []byte, flags uint32) ([]byte, error) {
+	var err error
+	if flags&encryptedFlag != 0 {
+		if d.cipher == nil {
+			return nil, diodes.ErrNoCipher
+		}
+
+		payload, err = d.cipher.Open(nil, payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if flags&compressedFlag != 0 {
+		if d.compressor == nil {
+			return nil, diodes.ErrNoCompressor
+		}
+
+		payload, err = d.compressor.Decompress(nil, payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return payload, nil
+}
+
+// Stats returns a snapshot of the diode's statistics. The writes include the
+// values that were recovered from the file. As the number of records the
+// file holds depends on their size, the capacity is the number of records it
+// currently holds. It is safe to call from any go-routine.
+func (d *Diode) Stats() diodes.Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	writes := d.tailSeq + uint64(len(d.records))
+	return diodes.Stats{
+		Writes:   writes,
+		Reads:    d.readSeq - d.dropped,
+		Drops:    d.dropped,
+		Lag:      writes - d.readSeq,
+		Capacity: len(d.records),
+	}
+}
+
+// Sync flushes the file to stable storage.
+func (d *Diode) Sync() error {
+	return d.f.Sync()
+}
+
+// Close commits the offset of the reader with WithOffsets, flushes the file
+// to stable storage and closes it.
+func (d *Diode) Close() error {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+	}
+
+	err := d.Commit()
+	if serr := d.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := d.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// retain expires the records in the background until the diode is closed.
+func (d *Diode) retain() {
+	defer close(d.done)
+
+	t := time.NewTicker(d.retentionInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case now := <-t.C:
+			d.mu.Lock()
+			d.expire(now)
+			d.mu.Unlock()
+		}
+	}
+}
+
+// writeAt writes b at the given position of the data area, wrapping around
+// at its end.
+func (d *Diode) writeAt(b []byte, pos uint64) error {
+	off := pos % d.capacity
+	n := uint64(len(b))
+	if off+n > d.capacity {
+		n = d.capacity - off
+	}
+
+	if _, err := d.f.WriteAt(b[:n], int64(headerSize+off)); err != nil {
+		return err
+	}
+	if n < uint64(len(b)) {
+		_, err := d.f.WriteAt(b[n:], headerSize)
+		return err
+	}
+
+	return nil
+}
+
+// readAt reads b from the given position of the data area, wrapping around
+// at its end.
+func (d *Diode) readAt(b []byte, pos uint64) error {
+	off := pos % d.capacity
+	n := uint64(len(b))
+	if off+n > d.capacity {
+		n = d.capacity - off
+	}
+
+	if _, err := d.f.ReadAt(b[:n], int64(headerSize+off)); err != nil {
+		return err
+	}
+	if n < uint64(len(b)) {
+		_, err := d.f.ReadAt(b[n:], headerSize)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,361 @@
+package ringfile_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/offset"
+	"code.cloudfoundry.org/go-diodes/ringfile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diode", func() {
+	var (
+		dir  string
+		path string
+		spy  *spyAlerter
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "ringfile")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "ring")
+		spy = &spyAlerter{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	// A capacity of 100 bytes holds four records with a payload of one
+	// byte.
+	open := func(opts ...ringfile.Option) *ringfile.Diode {
+		d, err := ringfile.Open(path, 100, spy, opts...)
+		Expect(err).ToNot(HaveOccurred())
+		return d
+	}
+
+	set := func(d *ringfile.Diode, payloads ...string) {
+		for _, p := range payloads {
+			Expect(d.Set([]byte(p))).To(Succeed())
+		}
+	}
+
+	readAll := func(d *ringfile.Diode) []string {
+		var payloads []string
+		for {
+			p, ok := d.TryNext()
+			if !ok {
+				return payloads
+			}
+			payloads = append(payloads, string(p))
+		}
+	}
+
+	It("reads the payloads in order", func() {
+		d := open()
+		defer d.Close()
+
+		set(d, "a", "b", "c")
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c"}))
+		Expect(d.Stats().Reads).To(Equal(uint64(3)))
+	})
+
+	It("overwrites the oldest records and alerts the reader", func() {
+		d := open()
+		defer d.Close()
+
+		set(d, "a", "b", "c", "d", "e", "f")
+		Expect(readAll(d)).To(Equal([]string{"c", "d", "e", "f"}))
+		Expect(spy.missed).To(Equal(2))
+
+		stats := d.Stats()
+		Expect(stats.Writes).To(Equal(uint64(6)))
+		Expect(stats.Drops).To(Equal(uint64(2)))
+		Expect(stats.Capacity).To(Equal(4))
+	})
+
+	It("overwrites as many records as a larger payload needs", func() {
+		d := open()
+		defer d.Close()
+
+		set(d, "a", "b", "c", "d", "efghijklmnopqrstu")
+		Expect(readAll(d)).To(Equal([]string{"c", "d", "efghijklmnopqrstu"}))
+		Expect(spy.missed).To(Equal(2))
+	})
+
+	It("recovers the records of an existing file", func() {
+		d := open()
+		set(d, "a", "b", "c", "d", "e", "f")
+		Expect(d.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		Expect(d.Recovery()).To(Equal(ringfile.Recovery{Records: 4, Bytes: 100, FirstSeq: 2, LastSeq: 5}))
+
+		set(d, "g")
+		Expect(readAll(d)).To(Equal([]string{"d", "e", "f", "g"}))
+	})
+
+	Context("with offsets", func() {
+		var store *offset.Store
+
+		BeforeEach(func() {
+			var err error
+			store, err = offset.Open(filepath.Join(dir, "offsets"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("resumes where the consumer left off", func() {
+			d := open(ringfile.WithOffsets(store, "collector"))
+			set(d, "a", "b", "c")
+			p, _ := d.TryNext()
+			Expect(string(p)).To(Equal("a"))
+			Expect(d.Close()).To(Succeed())
+
+			d = open(ringfile.WithOffsets(store, "collector"))
+			defer d.Close()
+			Expect(d.Recovery()).To(Equal(ringfile.Recovery{Records: 3, Bytes: 75, LastSeq: 2, Consumed: 1}))
+			Expect(readAll(d)).To(Equal([]string{"b", "c"}))
+		})
+
+		It("keeps the offsets of the consumers apart", func() {
+			d := open(ringfile.WithOffsets(store, "a"))
+			set(d, "a", "b", "c")
+			readAll(d)
+			Expect(d.Close()).To(Succeed())
+
+			d = open(ringfile.WithOffsets(store, "b"))
+			defer d.Close()
+			Expect(readAll(d)).To(Equal([]string{"a", "b", "c"}))
+		})
+
+		It("drops the records that were overwritten since the offset", func() {
+			d := open(ringfile.WithOffsets(store, "collector"))
+			set(d, "a")
+			d.TryNext()
+			Expect(d.Close()).To(Succeed())
+
+			d = open()
+			set(d, "b", "c", "d", "e", "f", "g")
+			Expect(d.Close()).To(Succeed())
+
+			d = open(ringfile.WithOffsets(store, "collector"))
+			defer d.Close()
+			Expect(readAll(d)).To(Equal([]string{"d", "e", "f", "g"}))
+			Expect(spy.missed).To(Equal(2))
+		})
+
+		It("commits every n reads", func() {
+			d := open(ringfile.WithOffsets(store, "collector"), ringfile.WithCommitEvery(2))
+			defer d.Close()
+			set(d, "a", "b", "c")
+
+			d.TryNext()
+			_, ok := store.Offset("collector")
+			Expect(ok).To(BeFalse())
+
+			d.TryNext()
+			d.TryNext()
+			seq, _ := store.Offset("collector")
+			Expect(seq).To(Equal(uint64(2)))
+
+			Expect(d.Commit()).To(Succeed())
+			seq, _ = store.Offset("collector")
+			Expect(seq).To(Equal(uint64(3)))
+		})
+
+		It("starts at the oldest record for an offset of another file", func() {
+			Expect(store.Commit("collector", 10)).To(Succeed())
+
+			d := open(ringfile.WithOffsets(store, "collector"))
+			defer d.Close()
+			set(d, "a")
+			Expect(readAll(d)).To(Equal([]string{"a"}))
+		})
+	})
+
+	It("recovers the records that wrap around the end of the file", func() {
+		d := open()
+		for i := 0; i < 11; i++ {
+			set(d, fmt.Sprint(i%10))
+		}
+		set(d, "xx")
+		Expect(d.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		Expect(d.Recovery()).To(Equal(ringfile.Recovery{Records: 3, Bytes: 76, FirstSeq: 9, LastSeq: 11}))
+		Expect(readAll(d)).To(Equal([]string{"9", "0", "xx"}))
+	})
+
+	It("ignores a record that was being written during a crash", func() {
+		d := open()
+		set(d, "a", "b")
+		Expect(d.Close()).To(Succeed())
+
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		Expect(err).ToNot(HaveOccurred())
+		record := make([]byte, 25)
+		record[0] = 2
+		record[16] = 1
+		record[24] = 'x'
+		_, err = f.WriteAt(record, 64+25)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		Expect(d.Recovery()).To(Equal(ringfile.Recovery{Records: 1, Bytes: 25, Truncated: true}))
+		Expect(readAll(d)).To(Equal([]string{"a"}))
+	})
+
+	It("passes the recovered records to Recover before the new ones", func() {
+		d := open()
+		set(d, "a", "b", "c", "d", "e")
+		Expect(d.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		set(d, "f")
+
+		var recovered []string
+		recovery, err := d.Recover(func(p []byte) error {
+			recovered = append(recovered, string(p))
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(recovery).To(Equal(ringfile.Recovery{Records: 4, Bytes: 100, FirstSeq: 1, LastSeq: 4}))
+		Expect(spy.missed).To(Equal(1))
+		Expect(recovered).To(Equal([]string{"c", "d", "e"}))
+		Expect(readAll(d)).To(Equal([]string{"f"}))
+	})
+
+	It("stops Recover at the first error", func() {
+		d := open()
+		set(d, "a", "b", "c")
+		Expect(d.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		_, err := d.Recover(func(p []byte) error {
+			return errors.New("some-error")
+		})
+		Expect(err).To(MatchError("some-error"))
+		Expect(readAll(d)).To(Equal([]string{"b", "c"}))
+	})
+
+	It("does not recover records from a new file", func() {
+		d := open()
+		Expect(d.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		Expect(d.Recovery().Records).To(BeZero())
+		Expect(readAll(d)).To(BeEmpty())
+	})
+
+	It("compresses the payloads", func() {
+		c, err := diodes.NewFlateCompressor(flate.BestSpeed)
+		Expect(err).ToNot(HaveOccurred())
+		d := open(ringfile.WithCompressor(c))
+
+		payload := string(bytes.Repeat([]byte("a"), 1000))
+		set(d, payload)
+		Expect(d.Close()).To(Succeed())
+
+		d, err = ringfile.Open(path, 100, spy)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readAll(d)).To(BeEmpty())
+		Expect(d.Stats().Drops).To(Equal(uint64(1)))
+		Expect(d.Close()).To(Succeed())
+
+		d = open(ringfile.WithCompressor(c))
+		defer d.Close()
+		Expect(readAll(d)).To(Equal([]string{payload}))
+	})
+
+	It("encrypts the payloads", func() {
+		c := newCipher("0123456789abcdef")
+		d, err := ringfile.Open(path, 256, spy, ringfile.WithCipher(c))
+		Expect(err).ToNot(HaveOccurred())
+		set(d, "some-secret")
+		Expect(d.Close()).To(Succeed())
+
+		b, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).ToNot(ContainSubstring("some-secret"))
+
+		d, err = ringfile.Open(path, 256, spy, ringfile.WithCipher(c))
+		Expect(err).ToNot(HaveOccurred())
+		defer d.Close()
+		Expect(readAll(d)).To(Equal([]string{"some-secret"}))
+	})
+
+	It("does not read records that are older than the max age", func() {
+		d := open(ringfile.WithMaxAge(50*time.Millisecond), ringfile.WithRetentionInterval(time.Hour))
+		defer d.Close()
+
+		set(d, "a", "b")
+		time.Sleep(100 * time.Millisecond)
+		set(d, "c")
+
+		Expect(readAll(d)).To(Equal([]string{"c"}))
+		Expect(spy.missed).To(Equal(2))
+	})
+
+	It("expires the records in the background", func() {
+		d := open(ringfile.WithMaxAge(50*time.Millisecond), ringfile.WithRetentionInterval(10*time.Millisecond))
+		set(d, "a", "b")
+		Eventually(func() int { return d.Stats().Capacity }).Should(BeZero())
+		Expect(d.Close()).To(Succeed())
+
+		d = open()
+		defer d.Close()
+		Expect(d.Recovery().Records).To(BeZero())
+	})
+
+	It("refuses payloads that do not fit into the file", func() {
+		d := open()
+		defer d.Close()
+
+		Expect(d.Set(make([]byte, 77))).To(MatchError(ringfile.ErrTooLarge))
+		Expect(d.Set(make([]byte, 76))).To(Succeed())
+	})
+
+	It("refuses a file with a different capacity", func() {
+		d := open()
+		Expect(d.Close()).To(Succeed())
+
+		_, err := ringfile.Open(path, 128, nil)
+		Expect(err).To(MatchError(ringfile.ErrIncompatible))
+	})
+})
+
+func newCipher(key string) *diodes.AEADCipher {
+	block, err := aes.NewCipher([]byte(key))
+	Expect(err).ToNot(HaveOccurred())
+	aead, err := cipher.NewGCM(block)
+	Expect(err).ToNot(HaveOccurred())
+
+	return diodes.NewAEADCipher(aead)
+}
+
+type spyAlerter struct {
+	missed int
+}
+
+func (s *spyAlerter) Alert(missed int) {
+	s.missed += missed
+}
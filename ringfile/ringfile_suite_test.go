@@ -0,0 +1,13 @@
+package ringfile_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRingfile(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ringfile Suite")
+}
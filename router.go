@@ -0,0 +1,87 @@
+package diodes
+
+import "sync/atomic"
+
+// Router reads from a diode and sets every value on the diode of the first
+// route that matches it, such as by severity, tenant or type. Each route has
+// its own diode with its own capacity and alerter, so a slow reader of one
+// route drops its own data instead of slowing down the source or the other
+// routes.
+type Router struct {
+	unrouted uint64
+
+	n        Nexter
+	routes   []Route
+	fallback Diode
+}
+
+// Route is an output of a Router.
+type Route struct {
+	// Match reports whether the value is set on the diode of the route. A
+	// nil Match matches every value.
+	Match func(GenericDataType) bool
+
+	// Diode receives the values of the route. Use a Waiter for a reader
+	// that blocks until there is a value.
+	Diode Diode
+}
+
+// RouterOption can be used to setup the router.
+type RouterOption func(*Router)
+
+// WithFallback sets the diode that receives the values that do not match a
+// route. By default they are dropped and counted by Unrouted.
+func WithFallback(d Diode) RouterOption {
+	return RouterOption(func(r *Router) {
+		r.fallback = d
+	})
+}
+
+// NewRouter returns a new Router that reads from the given Poller or Waiter
+// and dispatches to the given routes. The routes are matched in order.
+func NewRouter(n Nexter, routes []Route, opts ...RouterOption) *Router {
+	r := &Router{
+		n:      n,
+		routes: routes,
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// Run routes the data until the Poller or Waiter returns nil. Run must only
+// be invoked once as it is the reader of the diode.
+func (r *Router) Run() {
+	for {
+		data := r.n.Next()
+		if data == nil {
+			return
+		}
+
+		r.route(data)
+	}
+}
+
+func (r *Router) route(data GenericDataType) {
+	for _, route := range r.routes {
+		if route.Match == nil || route.Match(data) {
+			route.Diode.Set(data)
+			return
+		}
+	}
+
+	if r.fallback != nil {
+		r.fallback.Set(data)
+		return
+	}
+	atomic.AddUint64(&r.unrouted, 1)
+}
+
+// Unrouted returns the number of values that did not match a route and were
+// dropped as there is no fallback.
+func (r *Router) Unrouted() uint64 {
+	return atomic.LoadUint64(&r.unrouted)
+}
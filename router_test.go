@@ -0,0 +1,97 @@
+package diodes_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Router", func() {
+	var (
+		ctx       context.Context
+		cancel    context.CancelFunc
+		w         *diodes.Waiter
+		even, big *diodes.OneToOne
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(16, nil), diodes.WithWaiterContext(ctx))
+		even = diodes.NewOneToOne(2, nil)
+		big = diodes.NewOneToOne(16, nil)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			w.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	value := func(data diodes.GenericDataType) int {
+		return *(*int)(data)
+	}
+
+	routes := func() []diodes.Route {
+		return []diodes.Route{
+			{Match: func(data diodes.GenericDataType) bool { return value(data) >= 100 }, Diode: big},
+			{Match: func(data diodes.GenericDataType) bool { return value(data)%2 == 0 }, Diode: even},
+		}
+	}
+
+	readAll := func(d diodes.Diode) []int {
+		var values []int
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, value(data))
+		}
+	}
+
+	// run routes the values that were set. The waiter returns them before it
+	// returns nil for the canceled context.
+	run := func(r *diodes.Router) {
+		cancel()
+		r.Run()
+	}
+
+	It("sets every value on the first route that matches", func() {
+		r := diodes.NewRouter(w, routes())
+		set(1, 2, 100, 102, 3)
+		run(r)
+
+		Expect(readAll(big)).To(Equal([]int{100, 102}))
+		Expect(readAll(even)).To(Equal([]int{2}))
+		Expect(r.Unrouted()).To(Equal(uint64(2)))
+	})
+
+	It("sets the values that match no route on the fallback", func() {
+		fallback := diodes.NewOneToOne(16, nil)
+		r := diodes.NewRouter(w, routes(), diodes.WithFallback(fallback))
+		set(1, 2, 3)
+		run(r)
+
+		Expect(readAll(fallback)).To(Equal([]int{1, 3}))
+		Expect(r.Unrouted()).To(BeZero())
+	})
+
+	It("drops data for the route that falls behind only", func() {
+		r := diodes.NewRouter(w, routes())
+		set(2, 4, 6, 8, 100)
+		run(r)
+
+		Expect(readAll(even)).To(Equal([]int{6, 8}))
+		Expect(even.Stats().Drops).To(Equal(uint64(2)))
+		Expect(readAll(big)).To(Equal([]int{100}))
+		Expect(big.Stats().Drops).To(BeZero())
+	})
+})
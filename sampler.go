@@ -0,0 +1,188 @@
+package diodes
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SamplingAlerter is notified by a Sampler when it changes its mode.
+type SamplingAlerter interface {
+	// AlertSampling is invoked with n when the sampler starts to keep only 1
+	// in n values, and with 1 when it returns to full fidelity.
+	AlertSampling(n int)
+}
+
+// SamplingAlertFunc type is an adapter to allow the use of ordinary
+// functions as SamplingAlerters.
+type SamplingAlertFunc func(n int)
+
+// AlertSampling calls f(n)
+func (f SamplingAlertFunc) AlertSampling(n int) {
+	f(n)
+}
+
+// Sampler wraps a diode and switches it to 1-in-n sampling while it is
+// overloaded: once the share of the writes that the diode dropped exceeds a
+// threshold, only every n-th value is set on it and the others are
+// discarded. Sampling sheds the load evenly across the stream, where the
+// diode would drop whole stretches of it once its reader is lapped.
+//
+// The sampler returns to full fidelity once the pressure subsides, which is
+// when the values set on the sampler no longer outnumber the values the
+// reader was observed to read while it was overloaded. The mode is checked
+// by Check, which Run invokes at an interval.
+type Sampler struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	offered uint64
+	sampled uint64
+	n       uint64 // n is the current rate, 1 at full fidelity
+
+	Diode
+	stats     StatsReporter
+	rate      uint64
+	threshold float64
+	alerter   SamplingAlerter
+
+	mu       sync.Mutex
+	prev     Stats
+	prevSets uint64
+	capacity uint64 // capacity is the most reads between two checks
+
+	// notify serializes the alerts. It is acquired while mu is held, so the
+	// alerts are invoked in the order of the mode changes.
+	notify sync.Mutex
+}
+
+// SamplerOption can be used to setup the sampler.
+type SamplerOption func(*Sampler)
+
+// WithSamplingThreshold sets the share of the writes (between 0 and 1) the
+// diode may drop between two checks before the sampler starts sampling. The
+// default is 0.1.
+func WithSamplingThreshold(rate float64) SamplerOption {
+	return SamplerOption(func(s *Sampler) {
+		s.threshold = rate
+	})
+}
+
+// NewSampler returns a new Sampler that wraps the given diode and keeps 1 in
+// n values while it is overloaded. The diode must be a StatsReporter, such
+// as OneToOne or ManyToOne, for the sampler to tell its drops; otherwise it
+// never samples. The alerter is invoked on the go-routine of Check when the
+// mode changes. A nil can be used to ignore mode changes.
+func NewSampler(d Diode, n int, alerter SamplingAlerter, opts ...SamplerOption) *Sampler {
+	s := &Sampler{
+		Diode:     d,
+		n:         1,
+		rate:      uint64(n),
+		threshold: 0.1,
+		alerter:   alerter,
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	if r, ok := d.(StatsReporter); ok {
+		s.stats = r
+		s.prev = r.Stats()
+	}
+
+	return s
+}
+
+// Set sets the data on the wrapped diode, unless the sampler is sampling and
+// the data is not the n-th value. It may be invoked by several go-routines
+// if the wrapped diode can.
+func (s *Sampler) Set(data GenericDataType) {
+	n := atomic.LoadUint64(&s.n)
+	offered := atomic.AddUint64(&s.offered, 1)
+	if n > 1 && offered%n != 0 {
+		atomic.AddUint64(&s.sampled, 1)
+		return
+	}
+
+	s.Diode.Set(data)
+}
+
+// Check measures the pressure on the diode since the previous check and
+// switches the mode accordingly. It returns the current rate, which is 1 at
+// full fidelity. It is safe to call from any go-routine.
+func (s *Sampler) Check() int {
+	s.mu.Lock()
+
+	n := atomic.LoadUint64(&s.n)
+	if s.stats == nil || s.rate <= 1 {
+		s.mu.Unlock()
+		return int(n)
+	}
+
+	stats := s.stats.Stats()
+	offered := atomic.LoadUint64(&s.offered)
+	writes := stats.Writes - s.prev.Writes
+	drops := stats.Drops - s.prev.Drops
+	reads := stats.Reads - s.prev.Reads
+	sets := offered - s.prevSets
+	s.prev = stats
+	s.prevSets = offered
+
+	next := n
+	switch {
+	case n == 1 && writes > 0 && float64(drops)/float64(writes) > s.threshold:
+		// The reads of an overloaded diode are the most its reader can
+		// take, which tells when the full stream fits again.
+		s.capacity = reads
+		next = s.rate
+	case n > 1:
+		if reads > s.capacity {
+			s.capacity = reads
+		}
+		if drops == 0 && sets <= s.capacity {
+			next = 1
+		}
+	}
+	atomic.StoreUint64(&s.n, next)
+
+	notify := next != n && s.alerter != nil
+	if notify {
+		s.notify.Lock()
+	}
+	s.mu.Unlock()
+
+	if notify {
+		defer s.notify.Unlock()
+		s.alerter.AlertSampling(int(next))
+	}
+
+	return int(next)
+}
+
+// Run checks the diode at the given interval until the context is done.
+func (s *Sampler) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Check()
+		}
+	}
+}
+
+// Rate returns n while the sampler keeps 1 in n values, and 1 at full
+// fidelity. It is safe to call from any go-routine.
+func (s *Sampler) Rate() int {
+	return int(atomic.LoadUint64(&s.n))
+}
+
+// Sampled returns the number of values that were discarded by the sampling.
+// It is safe to call from any go-routine.
+func (s *Sampler) Sampled() uint64 {
+	return atomic.LoadUint64(&s.sampled)
+}
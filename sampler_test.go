@@ -0,0 +1,181 @@
+package diodes_test
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sampler", func() {
+	var (
+		d       *diodes.OneToOne
+		s       *diodes.Sampler
+		changes chan int
+	)
+
+	BeforeEach(func() {
+		changes = make(chan int, 10)
+		d = diodes.NewOneToOne(4, nil)
+		s = diodes.NewSampler(d, 4, diodes.SamplingAlertFunc(func(n int) {
+			changes <- n
+		}))
+	})
+
+	set := func(n int) {
+		for i := 0; i < n; i++ {
+			v := i
+			s.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	read := func() []int {
+		var values []int
+		for {
+			data, ok := s.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, *(*int)(data))
+		}
+	}
+
+	overload := func() {
+		set(20)
+		_, ok := s.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(s.Check()).To(Equal(4))
+	}
+
+	It("keeps every value while the diode does not drop", func() {
+		set(4)
+		Expect(s.Check()).To(Equal(1))
+
+		Expect(read()).To(Equal([]int{0, 1, 2, 3}))
+		Expect(s.Sampled()).To(BeZero())
+		Expect(changes).ToNot(Receive())
+	})
+
+	It("samples 1 in n values once the drops exceed the threshold", func() {
+		overload()
+		Expect(s.Rate()).To(Equal(4))
+		Expect(changes).To(Receive(Equal(4)))
+
+		read()
+		set(8)
+		Expect(read()).To(Equal([]int{3, 7}))
+		Expect(s.Sampled()).To(Equal(uint64(6)))
+	})
+
+	It("keeps sampling while the diode still drops", func() {
+		overload()
+		read()
+
+		set(40)
+		read()
+		Expect(s.Check()).To(Equal(4))
+		Expect(changes).To(Receive(Equal(4)))
+		Expect(changes).ToNot(Receive())
+	})
+
+	It("returns to full fidelity once the reader keeps up with every value", func() {
+		overload()
+		Expect(read()).To(HaveLen(3))
+
+		set(2)
+		Expect(s.Check()).To(Equal(1))
+		Expect(changes).To(Receive(Equal(4)))
+		Expect(changes).To(Receive(Equal(1)))
+
+		set(2)
+		Expect(read()).To(Equal([]int{0, 1}))
+	})
+
+	It("does not sample below the threshold", func() {
+		s = diodes.NewSampler(d, 4, nil, diodes.WithSamplingThreshold(0.9))
+		set(20)
+		s.TryNext()
+
+		Expect(s.Check()).To(Equal(1))
+	})
+
+	It("never samples a diode that does not report stats", func() {
+		s = diodes.NewSampler(diodes.NewRateLimiter(d, 1), 4, nil)
+		set(20)
+		d.TryNext()
+
+		Expect(s.Check()).To(Equal(1))
+	})
+
+	It("checks the diode at the interval", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.Run(ctx, time.Millisecond)
+
+		set(20)
+		s.TryNext()
+		Eventually(changes).Should(Receive(Equal(4)))
+
+		read()
+		Eventually(changes).Should(Receive(Equal(1)))
+		Expect(s.Rate()).To(Equal(1))
+	})
+
+	It("delivers the alerts of concurrent checks in order", func() {
+		var (
+			mu     sync.Mutex
+			alerts []int
+		)
+		s = diodes.NewSampler(&togglingDrops{OneToOne: d}, 4, diodes.SamplingAlertFunc(func(n int) {
+			runtime.Gosched()
+			mu.Lock()
+			defer mu.Unlock()
+			alerts = append(alerts, n)
+		}))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					s.Check()
+				}
+			}()
+		}
+		wg.Wait()
+
+		Expect(alerts).To(HaveLen(800))
+		for i, n := range alerts {
+			if i%2 == 0 {
+				Expect(n).To(Equal(4), "alert %d", i)
+			} else {
+				Expect(n).To(Equal(1), "alert %d", i)
+			}
+		}
+	})
+})
+
+// togglingDrops reports stats that alternate between a check in which every
+// write was dropped and a check without writes, starting with the initial
+// stats of a Sampler.
+type togglingDrops struct {
+	*diodes.OneToOne
+
+	mu    sync.Mutex
+	calls uint64
+}
+
+func (t *togglingDrops) Stats() diodes.Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.calls++
+	n := t.calls / 2 * 10
+	return diodes.Stats{Writes: n, Drops: n}
+}
@@ -0,0 +1,286 @@
+package diodes
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// segmentDetached is set in a segment's state once the reader has detached
+// the segment from the ring. The remaining bits hold the number of items
+// written into the segment.
+const segmentDetached = 1 << 63
+
+// segment is a fixed size run of consecutive items of a Segmented diode.
+type segment struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	state uint64 // the number of items written, see segmentDetached
+	num   uint64 // num is the position of the segment in the write order
+	first uint64 // first is the write index of data[0]
+	data  []GenericDataType
+}
+
+// Segmented diode is meant to be used by a single reader and a single writer.
+// It is not thread safe if used otherwise.
+//
+// Unlike OneToOne, the ring is made up of segments of several items. The
+// writer fills one segment at a time while the reader atomically detaches a
+// whole segment from the ring and iterates it privately. This reduces the
+// number of atomic operations on the read path by roughly the size of a
+// segment, which pays off for bursty drains.
+type Segmented struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	writeIndex   uint64
+	readIndex    uint64
+	dropped      uint64
+	writeSegment uint64
+	readSegment  uint64
+
+	segments    []unsafe.Pointer
+	segmentSize int
+	pool        sync.Pool
+	alerter     Alerter
+	batchSizes  *histogram
+	regions     bool
+	hooks       *hooks
+
+	// current is only used by the writer.
+	current *segment
+
+	// pending is the detached segment that is iterated by the reader.
+	pending    *segment
+	pendingLen int
+	pendingPos int
+	pendingGap Gap
+}
+
+// NewSegmented creates a new diode that holds up to segments*segmentSize
+// items. It is meant to be used by a single reader and a single writer. The
+// alerter is invoked on the read's go-routine. It is called when it notices
+// that the writer go-routine has passed it and wrote over data. A nil can be
+// used to ignore alerts. Of the options, only WithBatchSizeHistogram,
+// WithTraceRegions, WithHooks and WithPanicHandler apply.
+func NewSegmented(segments, segmentSize int, alerter Alerter, opts ...DiodeConfigOption) *Segmented {
+	c := newDiodeConfig(opts)
+	if alerter == nil {
+		alerter = AlertFunc(func(int) {})
+	}
+
+	d := &Segmented{
+		segments:    make([]unsafe.Pointer, segments),
+		segmentSize: segmentSize,
+		alerter:     recoverAlerter(alerter, c.panics),
+	}
+	if c.batchSizes {
+		d.batchSizes = new(histogram)
+	}
+	d.regions = c.traceRegions
+	d.hooks = c.hooks
+	d.pool.New = func() interface{} {
+		return &segment{data: make([]GenericDataType, segmentSize)}
+	}
+
+	return d
+}
+
+// Set sets the data in the next slot of the current segment. Once the
+// segment is full, or the reader detached it, the next segment is started.
+func (d *Segmented) Set(data GenericDataType) {
+	if d.regions {
+		defer startRegion("diode.Set").End()
+	}
+
+	if s := d.current; s != nil {
+		n := atomic.LoadUint64(&s.state)
+		if n&segmentDetached == 0 && n < uint64(len(s.data)) {
+			s.data[n] = data
+
+			// The reader may have detached the segment before the item was
+			// counted. In that case the item was not seen by the reader and
+			// goes into the next segment instead.
+			if atomic.AddUint64(&s.state, 1)&segmentDetached == 0 {
+				atomic.StoreUint64(&d.writeIndex, d.writeIndex+1)
+				return
+			}
+		}
+	}
+
+	s := d.pool.Get().(*segment)
+	s.num = d.writeSegment
+	s.first = d.writeIndex
+	s.data[0] = data
+	atomic.StoreUint64(&s.state, 1)
+
+	atomic.StoreUint64(&d.writeIndex, d.writeIndex+1)
+	d.writeSegment++
+	d.current = s
+
+	// When a segment was still in the ring, the writer has lapped the
+	// reader. The reader notices the gap in the write indices and alerts.
+	idx := s.num % uint64(len(d.segments))
+	if old := atomic.SwapPointer(&d.segments[idx], unsafe.Pointer(s)); old != nil {
+		old := (*segment)(old)
+		d.recycle(old, len(old.data))
+	}
+
+	// The first value always starts a segment.
+	d.hooks.start()
+}
+
+// TryNext will attempt to read the next item. Items of a detached segment
+// are read without any atomic operations. If there is no data available, it
+// will return (nil, false).
+func (d *Segmented) TryNext() (data GenericDataType, ok bool) {
+	if d.regions {
+		defer startRegion("diode.TryNext").End()
+	}
+
+	if d.pendingPos == d.pendingLen && !d.detach() {
+		return nil, false
+	}
+
+	data = d.pending.data[d.pendingPos]
+	d.pendingPos++
+	d.pendingGap = Gap{}
+
+	if d.pendingPos == d.pendingLen {
+		d.release()
+	}
+
+	return data, true
+}
+
+// TryNextBatch will attempt to read the remaining items of the current
+// segment, or if there aren't any, of the next segment. The items are
+// appended to dst and the resulting slice is returned. If there is no data
+// available, it will return (dst, false).
+func (d *Segmented) TryNextBatch(dst []GenericDataType) ([]GenericDataType, bool) {
+	dst, _, ok := d.TryNextBatchGap(dst)
+	return dst, ok
+}
+
+// TryNextBatchGap is TryNextBatch that also returns the items that were
+// overwritten before the ones it read, so that the reader knows exactly
+// which part of the stream it lost. The gap is also alerted as usual.
+func (d *Segmented) TryNextBatchGap(dst []GenericDataType) ([]GenericDataType, Gap, bool) {
+	if d.regions {
+		defer startRegion("diode.TryNextBatch").End()
+	}
+
+	if d.pendingPos == d.pendingLen && !d.detach() {
+		return dst, Gap{}, false
+	}
+
+	dst = append(dst, d.pending.data[d.pendingPos:d.pendingLen]...)
+	d.batchSizes.observe(uint64(d.pendingLen - d.pendingPos))
+	d.pendingPos = d.pendingLen
+	gap := d.pendingGap
+	d.pendingGap = Gap{}
+	d.release()
+
+	return dst, gap, true
+}
+
+// BatchSizes returns the distribution of the number of items returned by
+// TryNextBatch. It is only recorded with WithBatchSizeHistogram. It is safe
+// to call from any go-routine.
+func (d *Segmented) BatchSizes() Histogram {
+	return d.batchSizes.snapshot()
+}
+
+// detach atomically removes the next segment from the ring so that it can be
+// iterated privately.
+func (d *Segmented) detach() bool {
+	idx := d.readSegment % uint64(len(d.segments))
+	s := (*segment)(atomic.SwapPointer(&d.segments[idx], nil))
+
+	// When there is no segment that means the writer has not started it yet.
+	if s == nil {
+		return false
+	}
+
+	// When the segment is older than the one the reader expects, the reader
+	// has fast forwarded past it. Just like OneToOne, it is a stale segment
+	// whose items were already reported as dropped.
+	if s.num < d.readSegment {
+		d.recycle(s, len(s.data))
+		return false
+	}
+
+	// When the segment is newer than the one the reader expects, the writer
+	// has lapped the reader and the reader fast forwards to the segment.
+	if s.num > d.readSegment {
+		d.readSegment = s.num
+	}
+
+	var n uint64
+	for {
+		state := atomic.LoadUint64(&s.state)
+		if atomic.CompareAndSwapUint64(&s.state, state, state|segmentDetached) {
+			n = state
+			break
+		}
+	}
+
+	d.pendingGap = newGap(d.readIndex, s.first)
+	if seqAfter(s.first, d.readIndex) {
+		dropped := s.first - d.readIndex
+		atomic.AddUint64(&d.dropped, dropped)
+		alert(d.alerter, int(dropped), d.regions)
+		d.hooks.drop(int(dropped))
+	}
+
+	atomic.StoreUint64(&d.readIndex, s.first+n)
+	d.readSegment++
+	d.pending = s
+	d.pendingLen = int(n)
+	d.pendingPos = 0
+
+	return true
+}
+
+// Lag returns how many values the reader is behind the writer. The items of a
+// segment no longer count once the reader detached the segment. It is safe
+// to call from any go-routine.
+func (d *Segmented) Lag() uint64 {
+	return lag(atomic.LoadUint64(&d.writeIndex), atomic.LoadUint64(&d.readIndex))
+}
+
+// Stats returns a snapshot of the diode's statistics. It is safe to call from
+// any go-routine. The items of a segment are counted as read once the reader
+// detached the segment.
+func (d *Segmented) Stats() Stats {
+	writeIndex := atomic.LoadUint64(&d.writeIndex)
+	readIndex := atomic.LoadUint64(&d.readIndex)
+	dropped := atomic.LoadUint64(&d.dropped)
+
+	return Stats{
+		Writes:   writeIndex,
+		Reads:    readIndex - dropped,
+		Drops:    dropped,
+		Lag:      lag(writeIndex, readIndex),
+		Capacity: len(d.segments) * d.segmentSize,
+	}
+}
+
+// release hands a fully read segment back to the writer.
+func (d *Segmented) release() {
+	s, n := d.pending, d.pendingLen
+	d.pending = nil
+	d.pendingLen = 0
+	d.pendingPos = 0
+
+	d.recycle(s, n)
+}
+
+// recycle clears the first n items of a segment and puts it back into the
+// pool. The writer may still be writing past the first n items of a segment
+// the reader detached, so only those are cleared for a detached segment.
+func (d *Segmented) recycle(s *segment, n int) {
+	for i := 0; i < n; i++ {
+		s.data[i] = nil
+	}
+	d.pool.Put(s)
+}
@@ -0,0 +1,158 @@
+package diodes_test
+
+import (
+	"runtime"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Segmented", func() {
+	var (
+		d   *diodes.Segmented
+		spy *spyAlerter
+	)
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func() []int {
+		var read []int
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				return read
+			}
+			read = append(read, *(*int)(data))
+		}
+	}
+
+	BeforeEach(func() {
+		spy = newSpyAlerter()
+		d = diodes.NewSegmented(3, 4, spy)
+	})
+
+	Describe("TryNext()", func() {
+		It("returns false when empty", func() {
+			_, ok := d.TryNext()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns the data in order", func() {
+			set(0, 1, 2, 3, 4, 5)
+			Expect(readAll()).To(Equal([]int{0, 1, 2, 3, 4, 5}))
+		})
+
+		It("returns data written after the segment was detached", func() {
+			set(0, 1)
+			Expect(readAll()).To(Equal([]int{0, 1}))
+
+			set(2, 3)
+			Expect(readAll()).To(Equal([]int{2, 3}))
+			Expect(spy.AlertInput.Missed).ToNot(Receive())
+		})
+
+		It("alerts when the writer laps the reader", func() {
+			set(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13)
+
+			Expect(readAll()).To(Equal([]int{12, 13}))
+			Expect(spy.AlertInput.Missed).To(Receive(Equal(12)))
+		})
+
+		It("ignores stale segments after fast forwarding", func() {
+			set(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+
+			data, ok := d.TryNext()
+			Expect(ok).To(BeTrue())
+			Expect(*(*int)(data)).To(Equal(12))
+			Expect(spy.AlertInput.Missed).To(Receive(Equal(12)))
+
+			_, ok = d.TryNext()
+			Expect(ok).To(BeFalse())
+
+			set(13)
+			Expect(readAll()).To(Equal([]int{13}))
+		})
+	})
+
+	Describe("TryNextBatch()", func() {
+		It("returns a segment at a time", func() {
+			set(0, 1, 2, 3, 4, 5)
+
+			batch, ok := d.TryNextBatch(nil)
+			Expect(ok).To(BeTrue())
+			Expect(batch).To(HaveLen(4))
+
+			batch, ok = d.TryNextBatch(batch[:0])
+			Expect(ok).To(BeTrue())
+			Expect(batch).To(HaveLen(2))
+
+			_, ok = d.TryNextBatch(batch[:0])
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns the rest of a partially read segment", func() {
+			set(0, 1, 2)
+			d.TryNext()
+
+			batch, ok := d.TryNextBatch(nil)
+			Expect(ok).To(BeTrue())
+			Expect(*(*int)(batch[0])).To(Equal(1))
+			Expect(*(*int)(batch[1])).To(Equal(2))
+		})
+
+		It("does not record batch sizes without the option", func() {
+			set(0, 1, 2)
+			d.TryNextBatch(nil)
+
+			Expect(d.BatchSizes().Buckets).To(BeEmpty())
+		})
+
+		It("records the batch sizes", func() {
+			d = diodes.NewSegmented(3, 4, spy, diodes.WithBatchSizeHistogram())
+			set(0, 1, 2, 3, 4, 5)
+
+			for {
+				if _, ok := d.TryNextBatch(nil); !ok {
+					break
+				}
+			}
+
+			Expect(d.BatchSizes().Buckets).To(Equal([]diodes.HistogramBucket{
+				{UpperBound: 2, Count: 1},
+				{UpperBound: 4, Count: 1},
+			}))
+		})
+	})
+
+	It("reads every value or reports it as dropped under load", func() {
+		const writes = 50000
+		alerter := new(countingAlerter)
+		d = diodes.NewSegmented(4, 16, alerter)
+
+		var done int32
+		go func() {
+			defer atomic.StoreInt32(&done, 1)
+			for i := 0; i < writes; i++ {
+				v := i
+				d.Set(diodes.GenericDataType(&v))
+				if i%128 == 0 {
+					runtime.Gosched()
+				}
+			}
+		}()
+
+		read := drainWhileWriting(d, &done, func(prev, next int) {
+			Expect(next).To(BeNumerically(">", prev))
+		})
+
+		Expect(read + alerter.missed()).To(Equal(writes))
+	})
+})
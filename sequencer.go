@@ -0,0 +1,100 @@
+package diodes
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Sequencer supplies the sequence numbers of the values set on a Sequenced,
+// such as a global event ID generator. It must be safe to call from the
+// go-routines of all writers.
+type Sequencer interface {
+	Next() uint64
+}
+
+// SequencerFunc type is an adapter to allow the use of ordinary functions as
+// Sequencers.
+type SequencerFunc func() uint64
+
+// Next calls f()
+func (f SequencerFunc) Next() uint64 {
+	return f()
+}
+
+// Sequenced wraps a diode and numbers its values with the sequence of an
+// external source instead of the write index of the diode, so that the
+// sequence numbers the reader sees line up with the identifiers used
+// elsewhere in the system. The diode still detects laps by its own write
+// index, as an external sequence need not be contiguous. Each value is
+// wrapped in a small allocation.
+//
+// Like the diodes, a Sequenced is meant to be read by a single go-routine.
+type Sequenced struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	outOfOrder uint64
+	last       uint64 // last is the sequence number that was read last
+
+	read bool
+	d    Diode
+	seq  Sequencer
+}
+
+// sequencedValue is the value stored in the wrapped diode.
+type sequencedValue struct {
+	seq  uint64
+	data GenericDataType
+}
+
+// NewSequenced returns a Sequenced that stores its values in the given diode
+// and numbers them with the given Sequencer. A nil Sequencer requires every
+// value to be set with SetSeq. The diode must only be written and read
+// through the Sequenced.
+func NewSequenced(d Diode, s Sequencer) *Sequenced {
+	return &Sequenced{d: d, seq: s}
+}
+
+// Set sets the data with the next sequence number of the Sequencer.
+func (s *Sequenced) Set(data GenericDataType) {
+	s.SetSeq(s.seq.Next(), data)
+}
+
+// SetSeq sets the data with the given sequence number, such as the ID an
+// event was assigned already.
+func (s *Sequenced) SetSeq(seq uint64, data GenericDataType) {
+	s.d.Set(GenericDataType(&sequencedValue{seq: seq, data: data}))
+}
+
+// TryNext will attempt to read the next value. If there is no data
+// available, it will return (nil, false).
+func (s *Sequenced) TryNext() (GenericDataType, bool) {
+	_, data, ok := s.TryNextSeq()
+	return data, ok
+}
+
+// TryNextSeq is like TryNext, and also returns the sequence number the value
+// was set with. If there is no data available, it will return
+// (0, nil, false).
+func (s *Sequenced) TryNextSeq() (uint64, GenericDataType, bool) {
+	data, ok := s.d.TryNext()
+	if !ok {
+		return 0, nil, false
+	}
+
+	v := (*sequencedValue)(unsafe.Pointer(data))
+	if s.read && !seqAfter(v.seq, s.last) {
+		atomic.AddUint64(&s.outOfOrder, 1)
+	}
+	s.last = v.seq
+	s.read = true
+
+	return v.seq, v.data, true
+}
+
+// OutOfOrder returns the number of values that were read after a value with
+// the same or a higher sequence number, such as when the writers of a
+// ManyToOne without WithStrictOrder raced between taking a sequence number
+// and setting the value. It is safe to call from any go-routine.
+func (s *Sequenced) OutOfOrder() uint64 {
+	return atomic.LoadUint64(&s.outOfOrder)
+}
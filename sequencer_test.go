@@ -0,0 +1,83 @@
+package diodes_test
+
+import (
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sequenced", func() {
+	var (
+		d   *diodes.OneToOne
+		s   *diodes.Sequenced
+		ids uint64
+	)
+
+	BeforeEach(func() {
+		ids = 1000
+		d = diodes.NewOneToOne(4, nil)
+		s = diodes.NewSequenced(d, diodes.SequencerFunc(func() uint64 {
+			return atomic.AddUint64(&ids, 10)
+		}))
+	})
+
+	set := func(i int) {
+		s.Set(diodes.GenericDataType(&i))
+	}
+
+	It("numbers the values with the sequence of the sequencer", func() {
+		set(1)
+		set(2)
+
+		seq, data, ok := s.TryNextSeq()
+		Expect(ok).To(BeTrue())
+		Expect(seq).To(Equal(uint64(1010)))
+		Expect(*(*int)(data)).To(Equal(1))
+
+		seq, data, ok = s.TryNextSeq()
+		Expect(ok).To(BeTrue())
+		Expect(seq).To(Equal(uint64(1020)))
+		Expect(*(*int)(data)).To(Equal(2))
+
+		_, _, ok = s.TryNextSeq()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("sets the data with a given sequence number", func() {
+		v := 1
+		s.SetSeq(42, diodes.GenericDataType(&v))
+
+		seq, _, ok := s.TryNextSeq()
+		Expect(ok).To(BeTrue())
+		Expect(seq).To(Equal(uint64(42)))
+	})
+
+	It("still detects laps by the write index of the diode", func() {
+		for i := 0; i < 6; i++ {
+			set(i)
+		}
+
+		seq, data, ok := s.TryNextSeq()
+		Expect(ok).To(BeTrue())
+		Expect(seq).To(Equal(uint64(1050)))
+		Expect(*(*int)(data)).To(Equal(4))
+		Expect(d.Stats().Drops).To(Equal(uint64(4)))
+	})
+
+	It("counts the values that are read out of order", func() {
+		v := 1
+		s.SetSeq(2, diodes.GenericDataType(&v))
+		s.SetSeq(1, diodes.GenericDataType(&v))
+		s.SetSeq(3, diodes.GenericDataType(&v))
+
+		for {
+			if _, ok := s.TryNext(); !ok {
+				break
+			}
+		}
+		Expect(s.OutOfOrder()).To(Equal(uint64(1)))
+	})
+})
@@ -0,0 +1,200 @@
+// Package shm provides diodes whose ring buffers live in a shared memory
+// segment, so that a process, such as a sidecar agent, can consume what
+// other processes set without sockets.
+package shm
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// The segment of a Diode starts with a header that is followed by the slots
+// of its ring:
+//
+//	header: magic uint64 | version uint32 | slotSize uint32 | slots uint64 |
+//	        writeIndex uint64 | readIndex uint64 | dropped uint64
+//
+// The magic is published last, once the process that created the segment
+// set up the header.
+const (
+	headerSize     = 64
+	slotHeaderSize = 24
+	formatVersion  = 1
+
+	offIndices = 24
+
+	magic        = 0x0065646f69646873 // "shdiode\x00" in little endian
+	initializing = 1
+)
+
+var (
+	// ErrTooLarge is returned by Set for payloads larger than the slot size.
+	ErrTooLarge = errors.New("shm: payload exceeds the slot size")
+
+	// ErrIncompatible is returned by Open for a segment that is not a diode
+	// or has a different number or size of slots.
+	ErrIncompatible = errors.New("shm: incompatible segment")
+
+	// ErrInvalidName is returned by Open and Remove for a name that is
+	// empty or contains a path separator.
+	ErrInvalidName = errors.New("shm: invalid segment name")
+
+	// ErrUnsupported is returned by Open on platforms without shared memory.
+	ErrUnsupported = errors.New("shm: unsupported platform")
+)
+
+// Diode is a diode whose ring buffer is a shared memory segment. It is meant
+// to be used by a single writer and a single reader, which usually are in
+// different processes that open the segment by the same name. The payloads
+// are copied into the segment, so they are bytes instead of pointers. Like
+// the OneToOne diode, the writer overwrites the data the reader did not read
+// yet when it laps it, and the reader alerts about the values it missed.
+type Diode struct {
+	seg *segment
+	ring
+}
+
+// Option can be used to setup the diode.
+type Option func(*config)
+
+type config struct {
+	writerAlerter diodes.Alerter
+}
+
+// WithWriterAlerter sets an alerter for the writer's process, which is
+// invoked on the go-routine of Set with the number of values the reader,
+// usually in another process, dropped since the previous Set. Together with
+// the alerter of the reader, it makes the writer observe the loss end to end
+// instead of only what it overwrote. Only the drops after Open are reported.
+func WithWriterAlerter(a diodes.Alerter) Option {
+	return Option(func(c *config) {
+		c.writerAlerter = a
+	})
+}
+
+// Open opens the shared memory segment with the given name as a diode with
+// the given number of slots, each holding a payload of up to slotSize
+// bytes. The segment is created if it does not exist yet, otherwise it must
+// have the same number and size of slots. On Linux the segment is a file in
+// /dev/shm, as with shm_open, and it remains until Remove is invoked. On
+// Windows it is a named file mapping, which is released once every process
+// closed it. The alerter is invoked on the read's go-routine. A nil can be
+// used to ignore alerts.
+func Open(name string, slots, slotSize int, alerter diodes.Alerter, opts ...Option) (*Diode, error) {
+	var c config
+	for _, o := range opts {
+		o(&c)
+	}
+
+	seg, err := open(name, headerSize+slots*slotStride(slotSize), magic, header(slots, slotSize))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Diode{
+		seg:  seg,
+		ring: newRing(seg.data, offIndices, headerSize, slots, slotSize, orNop(alerter)),
+	}
+	if c.writerAlerter != nil {
+		d.watchDrops(c.writerAlerter)
+	}
+
+	return d, nil
+}
+
+// header returns the words of the header that follow the magic of a
+// segment with the given layout.
+func header(slots, slotSize int, more ...uint64) []uint64 {
+	return append([]uint64{formatVersion | uint64(slotSize)<<32, uint64(slots)}, more...)
+}
+
+// open opens the named segment and sets up its header or validates the
+// header of an existing one. Of the processes that open a new segment at the
+// same time, the one that claims the magic sets up the header and the others
+// wait for it.
+func open(name string, size int, magic uint64, header []uint64) (*segment, error) {
+	if !validName(name) {
+		return nil, ErrInvalidName
+	}
+
+	seg, err := openSegment(name, size)
+	if err != nil {
+		return nil, err
+	}
+
+	m := word(seg.data, 0)
+	if atomic.CompareAndSwapUint64(m, 0, initializing) {
+		for i, v := range header {
+			atomic.StoreUint64(word(seg.data, 8+8*i), v)
+		}
+		atomic.StoreUint64(m, magic)
+		return seg, nil
+	}
+
+	for atomic.LoadUint64(m) == initializing {
+		runtime.Gosched()
+	}
+
+	compatible := atomic.LoadUint64(m) == magic
+	for i, v := range header {
+		compatible = compatible && atomic.LoadUint64(word(seg.data, 8+8*i)) == v
+	}
+	if !compatible {
+		seg.close()
+		return nil, ErrIncompatible
+	}
+
+	return seg, nil
+}
+
+func orNop(alerter diodes.Alerter) diodes.Alerter {
+	if alerter == nil {
+		return diodes.AlertFunc(func(int) {})
+	}
+
+	return alerter
+}
+
+// Set copies the payload into the next slot of the ring buffer. It returns
+// ErrTooLarge for payloads that exceed the slot size.
+func (d *Diode) Set(payload []byte) error {
+	return d.set(payload)
+}
+
+// TryNext will attempt to read a copy of the payload of the next slot of the
+// ring buffer. If there is no data available, it will return (nil, false).
+func (d *Diode) TryNext() ([]byte, bool) {
+	return d.tryNext()
+}
+
+// Stats returns a snapshot of the diode's statistics, as recorded in the
+// segment by the writer and the reader of either process. It is safe to call
+// from any go-routine.
+func (d *Diode) Stats() diodes.Stats {
+	return d.stats()
+}
+
+// Close unmaps the segment. The segment itself remains for the other
+// process.
+func (d *Diode) Close() error {
+	return d.seg.close()
+}
+
+// Remove removes the shared memory segment with the given name, such as
+// once both processes are done with it. The processes that still have it
+// open keep using it.
+func Remove(name string) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+
+	return removeSegment(name)
+}
+
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`)
+}
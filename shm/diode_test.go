@@ -0,0 +1,183 @@
+package shm_test
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-diodes/shm"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var segments uint64
+
+var _ = Describe("Diode", func() {
+	var (
+		name           string
+		spy            *spyAlerter
+		writer, reader *shm.Diode
+	)
+
+	open := func(slots, slotSize int) *shm.Diode {
+		d, err := shm.Open(name, slots, slotSize, spy)
+		Expect(err).ToNot(HaveOccurred())
+		return d
+	}
+
+	BeforeEach(func() {
+		name = fmt.Sprintf("go-diodes-test-%d-%d", os.Getpid(), atomic.AddUint64(&segments, 1))
+		spy = &spyAlerter{}
+
+		// The writer and the reader map the segment on their own, like two
+		// processes would.
+		writer = open(4, 16)
+		reader = open(4, 16)
+	})
+
+	AfterEach(func() {
+		writer.Close()
+		reader.Close()
+		shm.Remove(name)
+	})
+
+	readAll := func(d *shm.Diode) []string {
+		var payloads []string
+		for {
+			p, ok := d.TryNext()
+			if !ok {
+				return payloads
+			}
+			payloads = append(payloads, string(p))
+		}
+	}
+
+	It("reads what the other mapping set, in order", func() {
+		Expect(writer.Set([]byte("a"))).To(Succeed())
+		Expect(writer.Set([]byte("b"))).To(Succeed())
+
+		Expect(readAll(reader)).To(Equal([]string{"a", "b"}))
+		Expect(reader.TryNext()).To(BeEmpty())
+	})
+
+	It("rejects payloads larger than the slot size", func() {
+		Expect(writer.Set(make([]byte, 17))).To(MatchError(shm.ErrTooLarge))
+	})
+
+	It("drops the oldest entries when the writer laps the reader", func() {
+		for _, p := range []string{"a", "b", "c", "d", "e", "f"} {
+			Expect(writer.Set([]byte(p))).To(Succeed())
+		}
+
+		Expect(readAll(reader)).To(Equal([]string{"e", "f"}))
+		Expect(spy.missed).To(Equal(4))
+	})
+
+	It("alerts the writer with the values the reader dropped", func() {
+		writerSpy := &spyAlerter{}
+		d, err := shm.Open(name, 4, 16, nil, shm.WithWriterAlerter(writerSpy))
+		Expect(err).ToNot(HaveOccurred())
+		defer d.Close()
+
+		for _, p := range []string{"a", "b", "c", "d", "e", "f"} {
+			Expect(d.Set([]byte(p))).To(Succeed())
+		}
+		Expect(writerSpy.missed).To(BeZero())
+
+		Expect(readAll(reader)).To(Equal([]string{"e", "f"}))
+		Expect(d.Set([]byte("g"))).To(Succeed())
+		Expect(writerSpy.missed).To(Equal(4))
+
+		Expect(d.Set([]byte("h"))).To(Succeed())
+		Expect(writerSpy.missed).To(Equal(4))
+	})
+
+	It("does not alert the writer with what was dropped before it opened", func() {
+		for _, p := range []string{"a", "b", "c", "d", "e", "f"} {
+			writer.Set([]byte(p))
+		}
+		readAll(reader)
+
+		writerSpy := &spyAlerter{}
+		d, err := shm.Open(name, 4, 16, nil, shm.WithWriterAlerter(writerSpy))
+		Expect(err).ToNot(HaveOccurred())
+		defer d.Close()
+
+		Expect(d.Set([]byte("g"))).To(Succeed())
+		Expect(writerSpy.missed).To(BeZero())
+	})
+
+	It("shares the statistics between the mappings", func() {
+		for _, p := range []string{"a", "b", "c", "d", "e", "f"} {
+			writer.Set([]byte(p))
+		}
+		reader.TryNext()
+
+		s := writer.Stats()
+		Expect(s.Writes).To(Equal(uint64(6)))
+		Expect(s.Reads).To(Equal(uint64(1)))
+		Expect(s.Drops).To(Equal(uint64(4)))
+		Expect(s.Lag).To(Equal(uint64(1)))
+		Expect(s.Capacity).To(Equal(4))
+		Expect(reader.Stats()).To(Equal(s))
+	})
+
+	It("resumes where the reader left off when it is opened again", func() {
+		writer.Set([]byte("a"))
+		writer.Set([]byte("b"))
+		reader.TryNext()
+		Expect(reader.Close()).To(Succeed())
+
+		reader = open(4, 16)
+		Expect(readAll(reader)).To(Equal([]string{"b"}))
+	})
+
+	It("fails for a segment with a different layout", func() {
+		_, err := shm.Open(name, 8, 16, nil)
+		Expect(err).To(MatchError(shm.ErrIncompatible))
+
+		_, err = shm.Open(name, 4, 8, nil)
+		Expect(err).To(MatchError(shm.ErrIncompatible))
+	})
+
+	It("fails for a name with a path separator", func() {
+		_, err := shm.Open("../diode", 4, 16, nil)
+		Expect(err).To(MatchError(shm.ErrInvalidName))
+		Expect(shm.Remove("")).To(MatchError(shm.ErrInvalidName))
+	})
+
+	It("passes data between go-routines through the segment", func() {
+		const n = 10000
+		go func() {
+			defer GinkgoRecover()
+			for i := 0; i < n; i++ {
+				Expect(writer.Set([]byte(fmt.Sprint(i)))).To(Succeed())
+			}
+		}()
+
+		last := -1
+		Eventually(func() int {
+			for {
+				p, ok := reader.TryNext()
+				if !ok {
+					return last
+				}
+
+				var v int
+				fmt.Sscan(string(p), &v)
+				Expect(v).To(BeNumerically(">", last))
+				last = v
+			}
+		}).Should(Equal(n - 1))
+		Expect(uint64(spy.missed) + reader.Stats().Reads).To(Equal(uint64(n)))
+	})
+})
+
+type spyAlerter struct {
+	missed int
+}
+
+func (s *spyAlerter) Alert(missed int) {
+	s.missed += missed
+}
@@ -0,0 +1,261 @@
+package shm
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// The segment of a Collector starts with a header that is followed by the
+// lanes. Every lane is a ring with a header of its own:
+//
+//	header: magic uint64 | version uint32 | slotSize uint32 | slots uint64 | lanes uint64
+//	lane:   owner uint64 | writeIndex uint64 | readIndex uint64 | dropped uint64 | slots
+//
+// The owner is the process ID of the Producer that claimed the lane, or zero
+// for a free lane. The lane headers are padded to 64 bytes.
+const (
+	laneHeaderSize = 64
+	lanesMagic     = 0x0073656e616c6873 // "shlanes\x00" in little endian
+)
+
+// ErrNoFreeLane is returned by OpenProducer when every lane of the segment
+// is claimed by a running producer.
+var ErrNoFreeLane = errors.New("shm: no free lane")
+
+// Layout is the layout of a segment with lanes. The processes that open the
+// segment must agree on it.
+type Layout struct {
+	// Lanes is the number of producers the segment holds.
+	Lanes int
+
+	// Slots is the number of slots of every lane.
+	Slots int
+
+	// SlotSize is the largest payload a slot holds.
+	SlotSize int
+}
+
+func (l Layout) size() int {
+	return headerSize + l.Lanes*l.laneSize()
+}
+
+func (l Layout) laneSize() int {
+	return laneHeaderSize + l.Slots*slotStride(l.SlotSize)
+}
+
+func (l Layout) lane(i int) int {
+	return headerSize + i*l.laneSize()
+}
+
+func (l Layout) open(name string) (*segment, error) {
+	return open(name, l.size(), lanesMagic, header(l.Slots, l.SlotSize, uint64(l.Lanes)))
+}
+
+// Producer writes into a lane of a shared memory segment that is read by a
+// single Collector, so that several processes, such as the applications on a
+// node, can feed one agent. Every producer has a lane of its own, which makes
+// its writes as cheap as the ones of a Diode. It is meant to be used by a
+// single go-routine.
+type Producer struct {
+	seg   *segment
+	owner *uint64
+	pid   uint64
+	lane  int
+	ring
+}
+
+// ProducerOption can be used to setup the producer.
+type ProducerOption func(*producerConfig)
+
+type producerConfig struct {
+	lane    int
+	alerter diodes.Alerter
+}
+
+// WithLane claims the given lane, even if another producer claimed it,
+// instead of the first free one, such as for deployments that assign a lane
+// to every producer. This is also how a lane is taken over from a producer
+// that ran in another PID namespace, where its process cannot be checked.
+func WithLane(i int) ProducerOption {
+	return ProducerOption(func(c *producerConfig) {
+		c.lane = i
+	})
+}
+
+// WithProducerAlerter sets an alerter that is invoked on the go-routine of
+// Set with the number of values of the lane the Collector dropped since the
+// previous Set, so that the producer observes the loss of its lane end to
+// end. Only the drops after OpenProducer are reported.
+func WithProducerAlerter(a diodes.Alerter) ProducerOption {
+	return ProducerOption(func(c *producerConfig) {
+		c.alerter = a
+	})
+}
+
+// OpenProducer opens the shared memory segment with the given name and
+// claims a lane for the process. The segment is created if it does not exist
+// yet. A lane whose producer is no longer running, such as after a crash, is
+// free again; what it wrote before and the Collector did not read yet is
+// kept. It returns ErrNoFreeLane if there is no free lane.
+func OpenProducer(name string, l Layout, opts ...ProducerOption) (*Producer, error) {
+	c := producerConfig{lane: -1}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	seg, err := l.open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Producer{seg: seg, pid: uint64(os.Getpid()), lane: c.lane}
+	if p.lane < 0 {
+		p.lane = p.claim(l)
+	} else if p.lane < l.Lanes {
+		atomic.StoreUint64(word(seg.data, l.lane(p.lane)), p.pid)
+	}
+	if p.lane < 0 || p.lane >= l.Lanes {
+		seg.close()
+		return nil, ErrNoFreeLane
+	}
+
+	off := l.lane(p.lane)
+	p.owner = word(seg.data, off)
+	p.ring = newRing(seg.data, off+8, off+laneHeaderSize, l.Slots, l.SlotSize, orNop(nil))
+	p.repair()
+	if c.alerter != nil {
+		p.watchDrops(c.alerter)
+	}
+
+	return p, nil
+}
+
+// claim claims the first free lane and returns it, or -1.
+func (p *Producer) claim(l Layout) int {
+	for i := 0; i < l.Lanes; i++ {
+		owner := word(p.seg.data, l.lane(i))
+		pid := atomic.LoadUint64(owner)
+		if pid != 0 && (pid == p.pid || alive(int(pid))) {
+			continue
+		}
+
+		if atomic.CompareAndSwapUint64(owner, pid, p.pid) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// repair unlocks the slot a producer that crashed while it wrote left
+// locked. The entry of the slot is discarded.
+func (p *Producer) repair() {
+	off := p.slot(atomic.LoadUint64(p.writeIndex()) % p.slots)
+	lock := p.word(off)
+	if version := atomic.LoadUint64(lock); version&1 == 1 {
+		atomic.StoreUint64(p.word(off+8), 0)
+		atomic.StoreUint64(lock, version+1)
+	}
+}
+
+// Lane returns the lane the producer claimed.
+func (p *Producer) Lane() int {
+	return p.lane
+}
+
+// Set copies the payload into the next slot of the lane. It returns
+// ErrTooLarge for payloads that exceed the slot size.
+func (p *Producer) Set(payload []byte) error {
+	return p.set(payload)
+}
+
+// Stats returns a snapshot of the statistics of the lane. It is safe to call
+// from any go-routine.
+func (p *Producer) Stats() diodes.Stats {
+	return p.stats()
+}
+
+// Close frees the lane and unmaps the segment. What the producer wrote and
+// the Collector did not read yet is kept for the Collector.
+func (p *Producer) Close() error {
+	atomic.CompareAndSwapUint64(p.owner, p.pid, 0)
+	return p.seg.close()
+}
+
+// Collector reads the lanes of a shared memory segment that are written by
+// Producers in other processes. It is meant to be used by a single reader.
+type Collector struct {
+	seg   *segment
+	lanes []ring
+	next  int
+}
+
+// OpenCollector opens the shared memory segment with the given name for
+// reading. The segment is created if it does not exist yet, otherwise it
+// must have the same layout. The alerter is invoked on the read's go-routine
+// with the values a producer's lane missed. A nil can be used to ignore
+// alerts.
+func OpenCollector(name string, l Layout, alerter diodes.Alerter) (*Collector, error) {
+	seg, err := l.open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Collector{seg: seg}
+	alerter = orNop(alerter)
+	for i := 0; i < l.Lanes; i++ {
+		off := l.lane(i)
+		c.lanes = append(c.lanes, newRing(seg.data, off+8, off+laneHeaderSize, l.Slots, l.SlotSize, alerter))
+	}
+
+	return c, nil
+}
+
+// TryNext will attempt to read a copy of the next payload of the lanes. The
+// lanes take turns, so a busy producer does not starve the others. If there
+// is no data available, it will return (nil, false).
+func (c *Collector) TryNext() ([]byte, bool) {
+	for i := range c.lanes {
+		lane := (c.next + i) % len(c.lanes)
+		if payload, ok := c.lanes[lane].tryNext(); ok {
+			c.next = lane + 1
+			return payload, true
+		}
+	}
+
+	return nil, false
+}
+
+// Stats returns a snapshot of the statistics of all lanes combined. It is
+// safe to call from any go-routine.
+func (c *Collector) Stats() diodes.Stats {
+	var total diodes.Stats
+	for _, s := range c.LaneStats() {
+		total.Writes += s.Writes
+		total.Reads += s.Reads
+		total.Drops += s.Drops
+		total.Lag += s.Lag
+		total.Capacity += s.Capacity
+	}
+
+	return total
+}
+
+// LaneStats returns a snapshot of the statistics of every lane, in order.
+// It is safe to call from any go-routine.
+func (c *Collector) LaneStats() []diodes.Stats {
+	stats := make([]diodes.Stats, len(c.lanes))
+	for i := range c.lanes {
+		stats[i] = c.lanes[i].stats()
+	}
+
+	return stats
+}
+
+// Close unmaps the segment. The segment itself remains for the producers.
+func (c *Collector) Close() error {
+	return c.seg.close()
+}
@@ -0,0 +1,182 @@
+package shm_test
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-diodes/shm"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Producer and Collector", func() {
+	var (
+		name      string
+		layout    shm.Layout
+		spy       *spyAlerter
+		collector *shm.Collector
+	)
+
+	BeforeEach(func() {
+		name = fmt.Sprintf("go-diodes-test-%d-%d", os.Getpid(), atomic.AddUint64(&segments, 1))
+		layout = shm.Layout{Lanes: 3, Slots: 4, SlotSize: 16}
+		spy = &spyAlerter{}
+
+		var err error
+		collector, err = shm.OpenCollector(name, layout, spy)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		collector.Close()
+		shm.Remove(name)
+	})
+
+	producer := func(opts ...shm.ProducerOption) *shm.Producer {
+		p, err := shm.OpenProducer(name, layout, opts...)
+		Expect(err).ToNot(HaveOccurred())
+		return p
+	}
+
+	readAll := func() []string {
+		var payloads []string
+		for {
+			p, ok := collector.TryNext()
+			if !ok {
+				return payloads
+			}
+			payloads = append(payloads, string(p))
+		}
+	}
+
+	It("gives every producer a lane of its own", func() {
+		a, b := producer(), producer()
+		defer a.Close()
+		defer b.Close()
+		Expect(a.Lane()).To(Equal(0))
+		Expect(b.Lane()).To(Equal(1))
+
+		a.Set([]byte("a1"))
+		a.Set([]byte("a2"))
+		b.Set([]byte("b1"))
+
+		Expect(readAll()).To(Equal([]string{"a1", "b1", "a2"}))
+	})
+
+	It("fails once every lane is claimed", func() {
+		for i := 0; i < layout.Lanes; i++ {
+			defer producer().Close()
+		}
+
+		_, err := shm.OpenProducer(name, layout)
+		Expect(err).To(MatchError(shm.ErrNoFreeLane))
+	})
+
+	It("frees the lane of a producer that is closed and keeps its entries", func() {
+		a := producer()
+		a.Set([]byte("a"))
+		Expect(a.Close()).To(Succeed())
+
+		b := producer()
+		defer b.Close()
+		Expect(b.Lane()).To(Equal(0))
+		b.Set([]byte("b"))
+
+		Expect(readAll()).To(Equal([]string{"a", "b"}))
+	})
+
+	It("claims the given lane", func() {
+		a := producer()
+		defer a.Close()
+
+		b := producer(shm.WithLane(0))
+		defer b.Close()
+		Expect(b.Lane()).To(Equal(0))
+
+		_, err := shm.OpenProducer(name, layout, shm.WithLane(3))
+		Expect(err).To(MatchError(shm.ErrNoFreeLane))
+	})
+
+	It("drops the oldest entries of a lane that laps the collector", func() {
+		a, b := producer(), producer()
+		defer a.Close()
+		defer b.Close()
+
+		for _, p := range []string{"a", "b", "c", "d", "e", "f"} {
+			a.Set([]byte(p))
+		}
+		b.Set([]byte("x"))
+
+		Expect(readAll()).To(ConsistOf("e", "f", "x"))
+		Expect(spy.missed).To(Equal(4))
+
+		stats := collector.LaneStats()
+		Expect(stats).To(HaveLen(3))
+		Expect(stats[0].Drops).To(Equal(uint64(4)))
+		Expect(stats[1].Reads).To(Equal(uint64(1)))
+		Expect(collector.Stats().Writes).To(Equal(uint64(7)))
+		Expect(collector.Stats().Capacity).To(Equal(12))
+		Expect(a.Stats()).To(Equal(stats[0]))
+	})
+
+	It("alerts a producer with the values of its lane the collector dropped", func() {
+		aSpy, bSpy := &spyAlerter{}, &spyAlerter{}
+		a, b := producer(shm.WithProducerAlerter(aSpy)), producer(shm.WithProducerAlerter(bSpy))
+		defer a.Close()
+		defer b.Close()
+
+		for _, p := range []string{"a", "b", "c", "d", "e", "f"} {
+			a.Set([]byte(p))
+		}
+		b.Set([]byte("x"))
+		readAll()
+
+		a.Set([]byte("g"))
+		b.Set([]byte("y"))
+		Expect(aSpy.missed).To(Equal(4))
+		Expect(bSpy.missed).To(BeZero())
+	})
+
+	It("fails for a segment with a different layout", func() {
+		_, err := shm.OpenProducer(name, shm.Layout{Lanes: 2, Slots: 4, SlotSize: 16})
+		Expect(err).To(MatchError(shm.ErrIncompatible))
+
+		_, err = shm.Open(name, 4, 16, nil)
+		Expect(err).To(MatchError(shm.ErrIncompatible))
+	})
+
+	It("collects from concurrent producers", func() {
+		const n = 5000
+		var wg sync.WaitGroup
+		for i := 0; i < layout.Lanes; i++ {
+			p := producer()
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				defer p.Close()
+				for j := 0; j < n; j++ {
+					Expect(p.Set([]byte(fmt.Sprint(j)))).To(Succeed())
+				}
+			}()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		var read int
+		Eventually(func() uint64 {
+			read += len(readAll())
+			s := collector.Stats()
+			return s.Reads + s.Drops
+		}).Should(Equal(uint64(layout.Lanes * n)))
+		Eventually(done).Should(BeClosed())
+		Expect(uint64(read)).To(Equal(collector.Stats().Reads))
+	})
+})
@@ -0,0 +1,195 @@
+package shm
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"sync/atomic"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// ring is a single producer and single consumer ring buffer in a segment.
+// Its indices are three consecutive words, the write index, the read index
+// and the number of dropped entries, and its slots follow one another from
+// base:
+//
+//	slot: lock uint64 | seq uint64 | length uint32 | crc uint32 | payload [slotSize]byte
+//
+// Like the Seqlock implementation of the in-memory diodes, the lock of a slot
+// is odd while the slot is written and seq is the write index plus one, or
+// zero for an empty slot. The crc is the checksum of the payload.
+type ring struct {
+	data     []byte
+	indices  int
+	base     int
+	slots    uint64
+	slotSize int
+	stride   int
+	alerter  diodes.Alerter
+
+	// writerAlerter and reported are only used by the writer, which is
+	// alerted with the entries the reader dropped since it last looked.
+	writerAlerter diodes.Alerter
+	reported      uint64
+}
+
+func newRing(data []byte, indices, base, slots, slotSize int, alerter diodes.Alerter) ring {
+	return ring{
+		data:     data,
+		indices:  indices,
+		base:     base,
+		slots:    uint64(slots),
+		slotSize: slotSize,
+		stride:   slotStride(slotSize),
+		alerter:  alerter,
+	}
+}
+
+func slotStride(slotSize int) int {
+	return slotHeaderSize + (slotSize+7)&^7
+}
+
+func (r *ring) writeIndex() *uint64 { return r.word(r.indices) }
+func (r *ring) readIndex() *uint64  { return r.word(r.indices + 8) }
+func (r *ring) dropped() *uint64    { return r.word(r.indices + 16) }
+
+// set copies the payload into the next slot. It returns ErrTooLarge for
+// payloads that exceed the slot size.
+func (r *ring) set(payload []byte) error {
+	r.reportDrops()
+
+	if len(payload) > r.slotSize {
+		return ErrTooLarge
+	}
+
+	// The writeIndex is only written by the writer, but it is kept in the
+	// segment so that the reader can observe it from its process.
+	seq := atomic.LoadUint64(r.writeIndex())
+	off := r.slot(seq % r.slots)
+	lock := r.word(off)
+	version := atomic.LoadUint64(lock)
+
+	atomic.StoreUint64(lock, version+1)
+	binary.LittleEndian.PutUint32(r.data[off+16:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(r.data[off+20:], crc32.ChecksumIEEE(payload))
+	copy(r.data[off+slotHeaderSize:], payload)
+	atomic.StoreUint64(r.word(off+8), seq+1)
+	atomic.StoreUint64(lock, version+2)
+
+	atomic.StoreUint64(r.writeIndex(), seq+1)
+	return nil
+}
+
+// tryNext reads a copy of the payload of the next slot. If there is no data
+// available, it will return (nil, false).
+func (r *ring) tryNext() ([]byte, bool) {
+	for {
+		readIndex := atomic.LoadUint64(r.readIndex())
+		off := r.slot(readIndex % r.slots)
+		lock := r.word(off)
+
+		version := atomic.LoadUint64(lock)
+		if version&1 == 1 {
+			return nil, false
+		}
+
+		seq := atomic.LoadUint64(r.word(off + 8))
+		if seq == 0 || seq-1 < readIndex {
+			return nil, false
+		}
+
+		n := int(binary.LittleEndian.Uint32(r.data[off+16:]))
+		if n > r.slotSize {
+			n = r.slotSize
+		}
+		payload := make([]byte, n)
+		copy(payload, r.data[off+slotHeaderSize:])
+		crc := binary.LittleEndian.Uint32(r.data[off+20:])
+
+		if atomic.LoadUint64(lock) != version {
+			return nil, false
+		}
+
+		// Like the in-memory diodes, the reader fast forwards when the
+		// writer has lapped it.
+		if seq-1 > readIndex {
+			r.drop(readIndex, seq-1-readIndex)
+			readIndex = seq - 1
+		}
+
+		atomic.StoreUint64(r.readIndex(), readIndex+1)
+		if crc32.ChecksumIEEE(payload) != crc {
+			// A payload that was corrupted in the segment is dropped. The
+			// read index already moved past it.
+			r.drop(readIndex, 1)
+			continue
+		}
+
+		return payload, true
+	}
+}
+
+// watchDrops alerts the writer with the entries the reader drops from now
+// on.
+func (r *ring) watchDrops(alerter diodes.Alerter) {
+	r.writerAlerter = alerter
+	r.reported = atomic.LoadUint64(r.dropped())
+}
+
+// reportDrops alerts the writer with the entries the reader dropped since the
+// previous report. The dropped counter is in the segment, so this includes
+// the drops of a reader in another process.
+func (r *ring) reportDrops() {
+	if r.writerAlerter == nil {
+		return
+	}
+
+	dropped := atomic.LoadUint64(r.dropped())
+	if dropped > r.reported {
+		missed := dropped - r.reported
+		r.reported = dropped
+		r.writerAlerter.Alert(int(missed))
+	}
+}
+
+// drop moves the reader past the n entries that follow the read index and
+// alerts.
+func (r *ring) drop(readIndex, n uint64) {
+	atomic.AddUint64(r.dropped(), n)
+	atomic.StoreUint64(r.readIndex(), readIndex+n)
+	r.alerter.Alert(int(n))
+}
+
+func (r *ring) stats() diodes.Stats {
+	writeIndex := atomic.LoadUint64(r.writeIndex())
+	readIndex := atomic.LoadUint64(r.readIndex())
+	dropped := atomic.LoadUint64(r.dropped())
+
+	var lag uint64
+	if writeIndex > readIndex {
+		lag = writeIndex - readIndex
+	}
+
+	return diodes.Stats{
+		Writes:   writeIndex,
+		Reads:    readIndex - dropped,
+		Drops:    dropped,
+		Lag:      lag,
+		Capacity: int(r.slots),
+	}
+}
+
+func (r *ring) slot(idx uint64) int {
+	return r.base + int(idx)*r.stride
+}
+
+// word returns the 64-bit word at the given offset, which must be a multiple
+// of 8.
+func (r *ring) word(off int) *uint64 {
+	return word(r.data, off)
+}
+
+func word(data []byte, off int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&data[off]))
+}
@@ -0,0 +1,23 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package shm
+
+type segment struct {
+	data []byte
+}
+
+func openSegment(name string, size int) (*segment, error) {
+	return nil, ErrUnsupported
+}
+
+func (s *segment) close() error {
+	return nil
+}
+
+func removeSegment(name string) error {
+	return ErrUnsupported
+}
+
+func alive(pid int) bool {
+	return true
+}
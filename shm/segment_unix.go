@@ -0,0 +1,71 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package shm
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// segment is a mapping of a shared memory segment.
+type segment struct {
+	data []byte
+}
+
+// shmDir is where the segments are kept. Linux backs /dev/shm by memory,
+// which is what shm_open uses as well. Other platforms fall back to the
+// temporary directory.
+func shmDir() string {
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		return "/dev/shm"
+	}
+
+	return os.TempDir()
+}
+
+func openSegment(name string, size int) (*segment, error) {
+	f, err := os.OpenFile(filepath.Join(shmDir(), name), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Processes that create the segment at the same time truncate it to
+	// the same size.
+	switch info.Size() {
+	case 0:
+		if err := f.Truncate(int64(size)); err != nil {
+			return nil, err
+		}
+	case int64(size):
+	default:
+		return nil, ErrIncompatible
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	return &segment{data: data}, nil
+}
+
+func (s *segment) close() error {
+	return syscall.Munmap(s.data)
+}
+
+func removeSegment(name string) error {
+	return os.Remove(filepath.Join(shmDir(), name))
+}
+
+// alive reports whether the process with the given ID is running.
+func alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
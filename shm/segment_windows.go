@@ -0,0 +1,83 @@
+//go:build windows
+
+package shm
+
+import (
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+// segment is a view of a named file mapping that is backed by the paging
+// file.
+type segment struct {
+	data   []byte
+	handle syscall.Handle
+	addr   uintptr
+}
+
+func openSegment(name string, size int) (*segment, error) {
+	n, err := syscall.UTF16PtrFromString(`Local\` + name)
+	if err != nil {
+		return nil, err
+	}
+
+	// CreateFileMapping opens the mapping if it exists already. Its size
+	// is then the one it was created with.
+	h, err := syscall.CreateFileMapping(syscall.InvalidHandle, nil, syscall.PAGE_READWRITE, uint32(uint64(size)>>32), uint32(size), n)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_READ|syscall.FILE_MAP_WRITE, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(h)
+		return nil, err
+	}
+
+	// The view is not managed by Go, so its address is turned into a slice
+	// through the slice header.
+	s := &segment{handle: h, addr: addr}
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&s.data))
+	hdr.Data = addr
+	hdr.Len = size
+	hdr.Cap = size
+
+	return s, nil
+}
+
+func (s *segment) close() error {
+	err := syscall.UnmapViewOfFile(s.addr)
+	if cerr := syscall.CloseHandle(s.handle); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// removeSegment does nothing as a file mapping is released once every
+// process closed it.
+func removeSegment(name string) error {
+	return nil
+}
+
+// alive reports whether the process with the given ID is running.
+func alive(pid int) bool {
+	const (
+		queryLimitedInformation = 0x1000
+		stillActive             = 259
+	)
+
+	h, err := syscall.OpenProcess(queryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+
+	return code == stillActive
+}
@@ -0,0 +1,13 @@
+package shm_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestShm(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Shm Suite")
+}
@@ -0,0 +1,94 @@
+package diodes
+
+import (
+	"context"
+	"time"
+)
+
+// ShutdownResult is the outcome of Shutdown for one of the diodes.
+type ShutdownResult struct {
+	// Abandoned is the number of values that were left in the diode when
+	// the context was done. It is zero for a diode that was drained.
+	Abandoned uint64
+
+	// Err is the error that closing the diode returned, such as
+	// ErrNotCloser.
+	Err error
+}
+
+// ShutdownOption can be used to setup Shutdown.
+type ShutdownOption func(*shutdown)
+
+type shutdown struct {
+	polling time.Duration
+}
+
+// WithShutdownPollingInterval sets the interval at which Shutdown checks
+// whether the diodes are drained. The default is 10ms.
+func WithShutdownPollingInterval(interval time.Duration) ShutdownOption {
+	return ShutdownOption(func(s *shutdown) {
+		s.polling = interval
+	})
+}
+
+// Shutdown closes the given diodes and waits until their readers drained
+// them or the context is done, such as on SIGTERM with a deadline. It
+// returns the result of every diode, in order. The values that are left in a
+// diode are the values its Stats report as occupied, or its Lag when it only
+// is a LagReporter. A diode that reports neither is treated as drained. A
+// Waiter or Poller reports for the diode it wraps.
+func Shutdown(ctx context.Context, ds []Diode, opts ...ShutdownOption) []ShutdownResult {
+	s := shutdown{polling: 10 * time.Millisecond}
+	for _, o := range opts {
+		o(&s)
+	}
+
+	results := make([]ShutdownResult, len(ds))
+	for i, d := range ds {
+		c, ok := d.(Closer)
+		if !ok {
+			results[i].Err = ErrNotCloser
+			continue
+		}
+		results[i].Err = c.Close()
+	}
+
+	for {
+		drained := true
+		for i, d := range ds {
+			results[i].Abandoned = pending(d)
+			if results[i].Abandoned > 0 {
+				drained = false
+			}
+		}
+
+		if drained {
+			return results
+		}
+
+		select {
+		case <-ctx.Done():
+			return results
+		case <-time.After(s.polling):
+		}
+	}
+}
+
+// pending returns the number of values that are left in the diode.
+func pending(d Diode) uint64 {
+	switch v := d.(type) {
+	case *Waiter:
+		d = v.Diode
+	case *Poller:
+		d = v.Diode
+	}
+
+	switch v := d.(type) {
+	case StatsReporter:
+		return v.Stats().Occupancy()
+	case LagReporter:
+		return v.Lag()
+	}
+
+	return 0
+}
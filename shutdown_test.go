@@ -0,0 +1,62 @@
+package diodes_test
+
+import (
+	"context"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shutdown", func() {
+	set := func(d diodes.Diode, n int) {
+		for i := 0; i < n; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("waits for the readers to drain the diodes", func() {
+		w := diodes.NewWaiter(diodes.NewManyToOne(8, nil))
+		set(w, 3)
+
+		var read int
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for w.Next() != nil {
+				read++
+			}
+		}()
+
+		results := diodes.Shutdown(context.Background(), []diodes.Diode{w})
+		Expect(results).To(Equal([]diodes.ShutdownResult{{}}))
+		Eventually(done).Should(BeClosed())
+		Expect(read).To(Equal(3))
+	})
+
+	It("reports the values that were abandoned at the deadline", func() {
+		drained := diodes.NewOneToOne(8, nil)
+		stuck := diodes.NewOneToOne(8, nil)
+		set(drained, 2)
+		set(stuck, 5)
+		drained.TryNext()
+		drained.TryNext()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		results := diodes.Shutdown(ctx, []diodes.Diode{drained, stuck}, diodes.WithShutdownPollingInterval(time.Millisecond))
+
+		Expect(results).To(Equal([]diodes.ShutdownResult{{}, {Abandoned: 5}}))
+		Expect(stuck.Closed()).To(BeTrue())
+	})
+
+	It("reports the diodes that can not be closed", func() {
+		results := diodes.Shutdown(context.Background(), []diodes.Diode{&spyDiode{}})
+
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Err).To(MatchError(diodes.ErrNotCloser))
+	})
+})
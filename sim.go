@@ -0,0 +1,141 @@
+//go:build diodessim
+
+package diodes
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Scheduler runs simulated go-routines one at a time and switches between
+// them at the points where the diodes' writers and readers race, such as
+// between claiming a slot and writing it. Which go-routine runs next is
+// chosen by a seeded random source, so a seed always reproduces the same
+// interleaving. This is meant to explore the interleavings of writers and
+// readers and to reproduce rare lap detection bugs, and is only available
+// with the diodessim build tag:
+//
+//	go test -tags diodessim ./...
+type Scheduler struct {
+	rand  *rand.Rand
+	tasks []*simTask
+	trace []int
+
+	// parked is signaled by the running task when it yields or returns.
+	parked chan struct{}
+}
+
+type simTask struct {
+	fn   func()
+	run  chan struct{}
+	done bool
+}
+
+// active is the scheduler of the simulation that is running, by the ids of
+// its go-routines. Only one simulation runs at a time.
+var active struct {
+	mu    sync.Mutex
+	run   sync.Mutex
+	s     *Scheduler
+	tasks map[uint64]*simTask
+}
+
+// NewScheduler returns a new Scheduler that chooses the go-routines with the
+// given seed.
+func NewScheduler(seed int64) *Scheduler {
+	return &Scheduler{
+		rand:   rand.New(rand.NewSource(seed)),
+		parked: make(chan struct{}),
+	}
+}
+
+// Go adds a simulated go-routine that runs fn. It must be invoked before
+// Run.
+func (s *Scheduler) Go(fn func()) {
+	s.tasks = append(s.tasks, &simTask{fn: fn, run: make(chan struct{})})
+}
+
+// Run runs the simulated go-routines until all of them returned. A
+// go-routine must not block on the others, e.g. by waiting on a channel or
+// a Waiter, as only one of them runs at a time.
+func (s *Scheduler) Run() {
+	active.run.Lock()
+	defer active.run.Unlock()
+
+	active.mu.Lock()
+	active.s = s
+	active.tasks = make(map[uint64]*simTask)
+	active.mu.Unlock()
+
+	defer func() {
+		active.mu.Lock()
+		active.s = nil
+		active.tasks = nil
+		active.mu.Unlock()
+	}()
+
+	for _, t := range s.tasks {
+		go s.start(t)
+	}
+
+	for {
+		var runnable []int
+		for i, t := range s.tasks {
+			if !t.done {
+				runnable = append(runnable, i)
+			}
+		}
+		if len(runnable) == 0 {
+			return
+		}
+
+		i := runnable[s.rand.Intn(len(runnable))]
+		s.trace = append(s.trace, i)
+		s.tasks[i].run <- struct{}{}
+		<-s.parked
+	}
+}
+
+func (s *Scheduler) start(t *simTask) {
+	id := goroutineID()
+	active.mu.Lock()
+	active.tasks[id] = t
+	active.mu.Unlock()
+
+	<-t.run
+	defer func() {
+		active.mu.Lock()
+		delete(active.tasks, id)
+		active.mu.Unlock()
+
+		t.done = true
+		s.parked <- struct{}{}
+	}()
+
+	t.fn()
+}
+
+// Trace returns the indexes of the go-routines in the order the scheduler
+// ran them, one for every stretch between two yield points.
+func (s *Scheduler) Trace() []int {
+	return append([]int(nil), s.trace...)
+}
+
+// yield hands control back to the scheduler when it is invoked by a
+// simulated go-routine, which continues once it is chosen again.
+func yield() {
+	active.mu.Lock()
+	s := active.s
+	var t *simTask
+	if s != nil {
+		t = active.tasks[goroutineID()]
+	}
+	active.mu.Unlock()
+
+	if t == nil {
+		return
+	}
+
+	s.parked <- struct{}{}
+	<-t.run
+}
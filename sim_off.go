@@ -0,0 +1,8 @@
+//go:build !diodessim
+
+package diodes
+
+// yield marks a point where the simulation mode may switch to another
+// go-routine. Without the diodessim build tag it does nothing and is inlined
+// away.
+func yield() {}
@@ -0,0 +1,74 @@
+//go:build diodessim
+
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("Scheduler", func(impl diodes.Implementation) {
+	// simulate runs two writers and a reader of a ManyToOne and returns the
+	// trace of the scheduler, the values that were read and the drops.
+	simulate := func(seed int64) ([]int, []int, int) {
+		var (
+			read   []int
+			missed int
+		)
+		d := diodes.NewManyToOne(4, diodes.AlertFunc(func(n int) {
+			missed += n
+		}), diodes.WithImplementation(impl))
+
+		s := diodes.NewScheduler(seed)
+		for w := 0; w < 2; w++ {
+			w := w
+			s.Go(func() {
+				for i := 0; i < 10; i++ {
+					v := w*100 + i
+					d.Set(diodes.GenericDataType(&v))
+				}
+			})
+		}
+		s.Go(func() {
+			for i := 0; i < 30; i++ {
+				if data, ok := d.TryNext(); ok {
+					read = append(read, *(*int)(data))
+				}
+			}
+		})
+		s.Run()
+
+		return s.Trace(), read, missed
+	}
+
+	It("reproduces the interleaving of a seed", func() {
+		trace, read, missed := simulate(7)
+		again, readAgain, missedAgain := simulate(7)
+
+		Expect(again).To(Equal(trace))
+		Expect(readAgain).To(Equal(read))
+		Expect(missedAgain).To(Equal(missed))
+	})
+
+	It("explores other interleavings with other seeds", func() {
+		trace, _, _ := simulate(7)
+		other, _, _ := simulate(8)
+
+		Expect(other).ToNot(Equal(trace))
+	})
+
+	It("keeps the values of a writer in order under every seed", func() {
+		for seed := int64(0); seed < 50; seed++ {
+			_, read, _ := simulate(seed)
+
+			last := map[int]int{0: -1, 1: -1}
+			for _, v := range read {
+				w, i := v/100, v%100
+				Expect(i).To(BeNumerically(">", last[w]), "seed %d", seed)
+				last[w] = i
+			}
+		}
+	})
+})
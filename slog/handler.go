@@ -0,0 +1,182 @@
+//go:build go1.21
+
+// Package slog provides a log/slog Handler that never blocks the logging
+// go-routine.
+package slog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Handler is a slog.Handler that sets the records on a diode. A background
+// go-routine formats and writes them with the wrapped handler. When the
+// background go-routine falls behind, records are dropped and the number of
+// dropped records is logged periodically.
+type Handler struct {
+	next slog.Handler
+	w    *worker
+}
+
+// HandlerOption can be used to setup the handler.
+type HandlerOption func(*handlerConfig)
+
+type handlerConfig struct {
+	size         int
+	dropInterval time.Duration
+}
+
+// WithSize sets the number of records the diode holds. The default is 1024.
+func WithSize(size int) HandlerOption {
+	return HandlerOption(func(c *handlerConfig) {
+		c.size = size
+	})
+}
+
+// WithDropInterval sets the interval at which the number of dropped records
+// is logged. Nothing is logged for an interval without drops. The default is
+// 10s.
+func WithDropInterval(interval time.Duration) HandlerOption {
+	return HandlerOption(func(c *handlerConfig) {
+		c.dropInterval = interval
+	})
+}
+
+// entry is a record together with the handler that handles it, which carries
+// the attributes and groups of the logger.
+type entry struct {
+	h slog.Handler
+	r slog.Record
+}
+
+// worker is the background go-routine that is shared by a Handler and the
+// handlers derived from it.
+type worker struct {
+	dropped uint64
+
+	next   slog.Handler
+	waiter *diodes.Waiter
+	cancel context.CancelFunc
+	once   sync.Once
+	done   chan struct{}
+}
+
+// NewHandler returns a new Handler that writes the records with next on a
+// background go-routine. Close must be called to stop the go-routine.
+func NewHandler(next slog.Handler, opts ...HandlerOption) *Handler {
+	c := handlerConfig{
+		size:         1024,
+		dropInterval: 10 * time.Second,
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		next:   next,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	w.waiter = diodes.NewWaiter(
+		diodes.NewManyToOne(c.size, diodes.AlertFunc(func(missed int) {
+			atomic.AddUint64(&w.dropped, uint64(missed))
+		})),
+		diodes.WithWaiterContext(ctx),
+	)
+
+	go w.run(ctx, c.dropInterval)
+
+	return &Handler{next: next, w: w}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given
+// level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle sets a copy of the record on the diode. It never blocks and always
+// returns nil. The record is handled without the context, which may be done
+// by the time the record is written.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	h.w.waiter.Set(diodes.GenericDataType(&entry{h: h.next, r: r.Clone()}))
+	return nil
+}
+
+// WithAttrs returns a Handler whose records carry the given attributes. It
+// shares the diode and background go-routine of h.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs), w: h.w}
+}
+
+// WithGroup returns a Handler whose attributes are in the given group. It
+// shares the diode and background go-routine of h.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), w: h.w}
+}
+
+// Close writes the records that are still in the diode and stops the
+// background go-routine. Records handled after Close are dropped. Close
+// stops the handlers derived from h as well.
+func (h *Handler) Close() {
+	h.w.once.Do(h.w.cancel)
+	<-h.w.done
+}
+
+func (w *worker) run(ctx context.Context, dropInterval time.Duration) {
+	defer close(w.done)
+
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		w.reportDrops(ctx, dropInterval)
+	}()
+
+	// Next keeps returning the records that are in the diode until it is
+	// empty, even after the context is done.
+	for {
+		data := w.waiter.Next()
+		if data == nil {
+			break
+		}
+
+		e := (*entry)(unsafe.Pointer(data))
+		_ = e.h.Handle(context.Background(), e.r)
+	}
+
+	<-reported
+	w.logDrops()
+}
+
+func (w *worker) reportDrops(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.logDrops()
+		}
+	}
+}
+
+// logDrops logs the number of records dropped since it was last invoked.
+func (w *worker) logDrops() {
+	dropped := atomic.SwapUint64(&w.dropped, 0)
+	if dropped == 0 {
+		return
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelWarn, "dropped log records", 0)
+	r.AddAttrs(slog.Uint64("dropped", dropped))
+	_ = w.next.Handle(context.Background(), r)
+}
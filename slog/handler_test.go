@@ -0,0 +1,132 @@
+//go:build go1.21
+
+package slog_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	diodesslog "code.cloudfoundry.org/go-diodes/slog"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var (
+		buf *syncBuffer
+		h   *diodesslog.Handler
+	)
+
+	BeforeEach(func() {
+		buf = new(syncBuffer)
+	})
+
+	AfterEach(func() {
+		h.Close()
+	})
+
+	newTextHandler := func() slog.Handler {
+		return slog.NewTextHandler(buf, &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey && len(groups) == 0 {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})
+	}
+
+	It("writes the records on a background go-routine", func() {
+		h = diodesslog.NewHandler(newTextHandler())
+		logger := slog.New(h).With("diode", "ingress").WithGroup("req")
+
+		logger.Info("some message", "id", 1)
+
+		Eventually(buf.String).Should(Equal("level=INFO msg=\"some message\" diode=ingress req.id=1\n"))
+	})
+
+	It("writes the remaining records on Close", func() {
+		h = diodesslog.NewHandler(newTextHandler())
+		logger := slog.New(h)
+		for i := 0; i < 3; i++ {
+			logger.Info("some message", "i", i)
+		}
+		h.Close()
+
+		Expect(buf.String()).To(Equal(
+			"level=INFO msg=\"some message\" i=0\n" +
+				"level=INFO msg=\"some message\" i=1\n" +
+				"level=INFO msg=\"some message\" i=2\n",
+		))
+	})
+
+	It("delegates the level to the wrapped handler", func() {
+		h = diodesslog.NewHandler(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+		Expect(h.Enabled(context.Background(), slog.LevelInfo)).To(BeFalse())
+		Expect(h.Enabled(context.Background(), slog.LevelWarn)).To(BeTrue())
+	})
+
+	It("logs the number of dropped records", func() {
+		blocked := &blockingHandler{Handler: newTextHandler(), release: make(chan struct{})}
+		h = diodesslog.NewHandler(blocked, diodesslog.WithSize(2), diodesslog.WithDropInterval(time.Millisecond))
+		logger := slog.New(h)
+
+		logger.Info("first")
+		Eventually(blocked.started).Should(BeTrue())
+		for i := 0; i < 6; i++ {
+			logger.Info("some message", "i", i)
+		}
+		close(blocked.release)
+
+		Eventually(buf.String).Should(ContainSubstring("level=WARN msg=\"dropped log records\" dropped=4\n"))
+	})
+})
+
+// blockingHandler blocks the first record until it is released.
+type blockingHandler struct {
+	slog.Handler
+	release chan struct{}
+
+	mu      sync.Mutex
+	blocked bool
+}
+
+func (h *blockingHandler) started() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.blocked
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	first := !h.blocked
+	h.blocked = true
+	h.mu.Unlock()
+
+	if first {
+		<-h.release
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
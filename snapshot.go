@@ -0,0 +1,354 @@
+package diodes
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// Codec converts the data of a diode to and from bytes.
+type Codec interface {
+	Marshal(data GenericDataType) ([]byte, error)
+	Unmarshal(b []byte) (GenericDataType, error)
+}
+
+// BytesCodec is the Codec of diodes whose values are *[]byte, such as the
+// ones set by a Writer.
+type BytesCodec struct{}
+
+// Marshal returns the bytes the data points to.
+func (BytesCodec) Marshal(data GenericDataType) ([]byte, error) {
+	return *(*[]byte)(data), nil
+}
+
+// Unmarshal returns a pointer to the bytes.
+func (BytesCodec) Unmarshal(b []byte) (GenericDataType, error) {
+	return GenericDataType(&b), nil
+}
+
+// Encoder encodes a value, such as *gob.Encoder or *json.Encoder.
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+// Decoder decodes a value, such as *gob.Decoder or *json.Decoder.
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+// Snapshot holds the unread contents of a diode in the order they were
+// written.
+type Snapshot struct {
+	Payloads [][]byte
+}
+
+// Encode reads the unread contents of the diode and encodes them as a
+// Snapshot, e.g. to checkpoint in-flight data before a restart or to attach
+// a buffer to a bug report. The contents are consumed, so Encode must be
+// invoked by the reader of the diode.
+func Encode(enc Encoder, d Diode, c Codec) error {
+	var s Snapshot
+	for {
+		data, ok := d.TryNext()
+		if !ok {
+			break
+		}
+
+		b, err := c.Marshal(data)
+		if err != nil {
+			return err
+		}
+		s.Payloads = append(s.Payloads, b)
+	}
+
+	return enc.Encode(s)
+}
+
+// Decode decodes a Snapshot and sets its contents on the diode. It stops at
+// the first payload that cannot be unmarshaled. A diode that is smaller than
+// the snapshot drops the oldest contents as usual.
+func Decode(dec Decoder, d Diode, c Codec) error {
+	var s Snapshot
+	if err := dec.Decode(&s); err != nil {
+		return err
+	}
+
+	for _, b := range s.Payloads {
+		data, err := c.Unmarshal(b)
+		if err != nil {
+			return err
+		}
+		d.Set(data)
+	}
+
+	return nil
+}
+
+// The format of SaveTo starts with a header that is followed by a record for
+// every payload and a trailer:
+//
+//	header:  magic [8]byte
+//	record:  length uint32 | crc uint32 | payload [length]byte
+//	trailer: 0xffffffff uint32 | records uint64
+//
+// The trailer tells a complete snapshot from one that was cut short. The
+// highest bit of the length is set for compressed payloads and the next one
+// for encrypted payloads. The crc is the checksum of the payload as it is
+// written. A length of lengthMask is reserved, as with both flags set it
+// would read as the trailer.
+const (
+	snapshotTrailer = 0xffffffff
+	compressedFlag  = 1 << 31
+	encryptedFlag   = 1 << 30
+	lengthMask      = encryptedFlag - 1
+)
+
+var snapshotMagic = [8]byte{'g', 'o', 'd', 's', 'n', 'p', 0, 1}
+
+// ErrCorruptSnapshot is returned by LoadFrom for data that is not a snapshot
+// or whose checksums do not match.
+var ErrCorruptSnapshot = errors.New("diodes: corrupt snapshot")
+
+// ErrSnapshotPayloadTooLarge is returned by SaveTo for a value whose payload
+// is 1 GiB minus one byte or larger once it is compressed and encrypted, as
+// its length would not fit the length of the record.
+var ErrSnapshotPayloadTooLarge = errors.New("diodes: snapshot payload too large")
+
+// SnapshotOption can be used to setup SaveTo and LoadFrom.
+type SnapshotOption func(*snapshotConfig)
+
+type snapshotConfig struct {
+	compressor Compressor
+	cipher     Cipher
+
+	// compressed and sealed are reused by encode.
+	compressed []byte
+	sealed     []byte
+}
+
+// WithSnapshotCompressor compresses the payloads of SaveTo. LoadFrom
+// requires it to read compressed payloads.
+func WithSnapshotCompressor(comp Compressor) SnapshotOption {
+	return SnapshotOption(func(c *snapshotConfig) {
+		c.compressor = comp
+	})
+}
+
+// WithSnapshotCipher encrypts the payloads of SaveTo. LoadFrom requires it
+// to read encrypted payloads.
+func WithSnapshotCipher(cipher Cipher) SnapshotOption {
+	return SnapshotOption(func(c *snapshotConfig) {
+		c.cipher = cipher
+	})
+}
+
+func newSnapshotConfig(opts []SnapshotOption) snapshotConfig {
+	var c snapshotConfig
+	for _, o := range opts {
+		o(&c)
+	}
+
+	return c
+}
+
+// encode compresses and encrypts the payload as configured. It returns the
+// flags of the record. The returned payload is only valid until the next
+// call.
+func (c *snapshotConfig) encode(b []byte) ([]byte, uint32, error) {
+	var (
+		flags uint32
+		err   error
+	)
+	if c.compressor != nil {
+		c.compressed, err = c.compressor.Compress(c.compressed[:0], b)
+		if err != nil {
+			return nil, 0, err
+		}
+		b = c.compressed
+		flags |= compressedFlag
+	}
+
+	if c.cipher != nil {
+		c.sealed, err = c.cipher.Seal(c.sealed[:0], b)
+		if err != nil {
+			return nil, 0, err
+		}
+		b = c.sealed
+		flags |= encryptedFlag
+	}
+
+	return b, flags, nil
+}
+
+// decode decrypts and decompresses the payload of a record with the given
+// flags.
+func (c *snapshotConfig) decode(b []byte, flags uint32) ([]byte, error) {
+	var err error
+	if flags&encryptedFlag != 0 {
+		if c.cipher == nil {
+			return nil, ErrNoCipher
+		}
+
+		b, err = c.cipher.Open(nil, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if flags&compressedFlag != 0 {
+		if c.compressor == nil {
+			return nil, ErrNoCompressor
+		}
+
+		b, err = c.compressor.Decompress(nil, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// SaveTo reads the unread contents of the diode and writes them to w, e.g.
+// to a file before a planned restart. Unlike Encode, the payloads are
+// written as they are read, so the contents are never held in memory at
+// once. The contents are consumed, so SaveTo must be invoked by the reader
+// of the diode, and the ones that were read before an error are lost.
+func SaveTo(w io.Writer, d Diode, c Codec, opts ...SnapshotOption) error {
+	conf := newSnapshotConfig(opts)
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(snapshotMagic[:]); err != nil {
+		return err
+	}
+
+	var (
+		header  [8]byte
+		records uint64
+	)
+	for {
+		data, ok := d.TryNext()
+		if !ok {
+			break
+		}
+
+		b, err := c.Marshal(data)
+		if err != nil {
+			return err
+		}
+
+		var flags uint32
+		b, flags, err = conf.encode(b)
+		if err != nil {
+			return err
+		}
+		if len(b) >= lengthMask {
+			return ErrSnapshotPayloadTooLarge
+		}
+
+		binary.LittleEndian.PutUint32(header[:], uint32(len(b))|flags)
+		binary.LittleEndian.PutUint32(header[4:], crc32.ChecksumIEEE(b))
+		if _, err := bw.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := bw.Write(b); err != nil {
+			return err
+		}
+		records++
+	}
+
+	var trailer [12]byte
+	binary.LittleEndian.PutUint32(trailer[:], snapshotTrailer)
+	binary.LittleEndian.PutUint64(trailer[4:], records)
+	if _, err := bw.Write(trailer[:]); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// LoadFrom reads a snapshot written by SaveTo and sets its contents on the
+// diode. A snapshot that was cut short returns io.ErrUnexpectedEOF and one
+// that fails its checksums ErrCorruptSnapshot. The payloads before the
+// error have already been set. A diode that is smaller than the snapshot
+// drops the oldest contents as usual. Compressed payloads require
+// WithSnapshotCompressor and return ErrNoCompressor otherwise, and encrypted
+// payloads require WithSnapshotCipher and return ErrNoCipher otherwise.
+func LoadFrom(r io.Reader, d Diode, c Codec, opts ...SnapshotOption) error {
+	conf := newSnapshotConfig(opts)
+	br := bufio.NewReader(r)
+
+	var magic [8]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	if magic != snapshotMagic {
+		return ErrCorruptSnapshot
+	}
+
+	var (
+		header  [8]byte
+		records uint64
+	)
+	for {
+		if _, err := io.ReadFull(br, header[:4]); err != nil {
+			return unexpectedEOF(err)
+		}
+
+		length := binary.LittleEndian.Uint32(header[:])
+		if length == snapshotTrailer {
+			break
+		}
+
+		if _, err := io.ReadFull(br, header[4:]); err != nil {
+			return unexpectedEOF(err)
+		}
+
+		// The payload is read as it arrives rather than into a buffer of
+		// the length from the header, so that a corrupt length does not
+		// allocate up to a gigabyte.
+		b, err := io.ReadAll(io.LimitReader(br, int64(length&lengthMask)))
+		if err != nil {
+			return err
+		}
+		if len(b) != int(length&lengthMask) {
+			return io.ErrUnexpectedEOF
+		}
+		if crc32.ChecksumIEEE(b) != binary.LittleEndian.Uint32(header[4:]) {
+			return ErrCorruptSnapshot
+		}
+
+		b, err = conf.decode(b, length&^lengthMask)
+		if err != nil {
+			return err
+		}
+
+		data, err := c.Unmarshal(b)
+		if err != nil {
+			return err
+		}
+		d.Set(data)
+		records++
+	}
+
+	var count [8]byte
+	if _, err := io.ReadFull(br, count[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	if binary.LittleEndian.Uint64(count[:]) != records {
+		return ErrCorruptSnapshot
+	}
+
+	return nil
+}
+
+// unexpectedEOF turns the io.EOF of a snapshot that ended early into
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
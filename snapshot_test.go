@@ -0,0 +1,239 @@
+package diodes_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snapshot", func() {
+	var (
+		d      *diodes.OneToOne
+		writer *diodes.Writer
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(4, nil)
+		writer = diodes.NewWriter(d)
+	})
+
+	read := func(d diodes.Diode) []string {
+		var payloads []string
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				return payloads
+			}
+			payloads = append(payloads, string(*(*[]byte)(data)))
+		}
+	}
+
+	It("round trips the unread contents with gob", func() {
+		writer.Write([]byte("a"))
+		writer.Write([]byte("b"))
+		writer.Write([]byte("c"))
+		d.TryNext()
+
+		var buf bytes.Buffer
+		Expect(diodes.Encode(gob.NewEncoder(&buf), d, diodes.BytesCodec{})).To(Succeed())
+		_, ok := d.TryNext()
+		Expect(ok).To(BeFalse())
+
+		restored := diodes.NewOneToOne(4, nil)
+		Expect(diodes.Decode(gob.NewDecoder(&buf), restored, diodes.BytesCodec{})).To(Succeed())
+		Expect(read(restored)).To(Equal([]string{"b", "c"}))
+	})
+
+	It("encodes the snapshot as JSON", func() {
+		writer.Write([]byte("a"))
+
+		var buf bytes.Buffer
+		Expect(diodes.Encode(json.NewEncoder(&buf), d, diodes.BytesCodec{})).To(Succeed())
+		Expect(buf.String()).To(MatchJSON(`{"Payloads": ["YQ=="]}`))
+	})
+
+	It("encodes an empty diode", func() {
+		var buf bytes.Buffer
+		Expect(diodes.Encode(json.NewEncoder(&buf), d, diodes.BytesCodec{})).To(Succeed())
+
+		Expect(diodes.Decode(json.NewDecoder(&buf), d, diodes.BytesCodec{})).To(Succeed())
+		Expect(read(d)).To(BeEmpty())
+	})
+
+	It("returns the errors of the codec", func() {
+		writer.Write([]byte("a"))
+
+		var buf bytes.Buffer
+		err := diodes.Encode(json.NewEncoder(&buf), d, failingCodec{})
+		Expect(err).To(MatchError("some-error"))
+		Expect(buf.Len()).To(BeZero())
+	})
+
+	Describe("SaveTo and LoadFrom", func() {
+		It("round trips the unread contents", func() {
+			writer.Write([]byte("a"))
+			writer.Write([]byte("b"))
+			writer.Write([]byte("c"))
+			d.TryNext()
+
+			var buf bytes.Buffer
+			Expect(diodes.SaveTo(&buf, d, diodes.BytesCodec{})).To(Succeed())
+			_, ok := d.TryNext()
+			Expect(ok).To(BeFalse())
+
+			restored := diodes.NewOneToOne(4, nil)
+			Expect(diodes.LoadFrom(&buf, restored, diodes.BytesCodec{})).To(Succeed())
+			Expect(read(restored)).To(Equal([]string{"b", "c"}))
+		})
+
+		It("rejects a payload that does not fit the length of a record", func() {
+			const maxPayload = 1<<30 - 2
+
+			writer.Write([]byte("a"))
+			Expect(diodes.SaveTo(io.Discard, d, sizedCodec(maxPayload))).To(Succeed())
+
+			writer.Write([]byte("a"))
+			err := diodes.SaveTo(io.Discard, d, sizedCodec(maxPayload+1))
+			Expect(err).To(MatchError(diodes.ErrSnapshotPayloadTooLarge))
+		})
+
+		It("round trips an empty diode", func() {
+			var buf bytes.Buffer
+			Expect(diodes.SaveTo(&buf, d, diodes.BytesCodec{})).To(Succeed())
+
+			Expect(diodes.LoadFrom(&buf, d, diodes.BytesCodec{})).To(Succeed())
+			Expect(read(d)).To(BeEmpty())
+		})
+
+		It("fails for a snapshot that was cut short", func() {
+			writer.Write([]byte("a"))
+			writer.Write([]byte("b"))
+
+			var buf bytes.Buffer
+			Expect(diodes.SaveTo(&buf, d, diodes.BytesCodec{})).To(Succeed())
+			b := buf.Bytes()[:buf.Len()-1]
+
+			restored := diodes.NewOneToOne(4, nil)
+			err := diodes.LoadFrom(bytes.NewReader(b), restored, diodes.BytesCodec{})
+			Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+			Expect(read(restored)).To(Equal([]string{"a", "b"}))
+		})
+
+		It("fails for a record that is longer than the snapshot", func() {
+			writer.Write([]byte("a"))
+
+			var buf bytes.Buffer
+			Expect(diodes.SaveTo(&buf, d, diodes.BytesCodec{})).To(Succeed())
+			b := buf.Bytes()
+			binary.LittleEndian.PutUint32(b[8:], 1<<29)
+
+			err := diodes.LoadFrom(bytes.NewReader(b), d, diodes.BytesCodec{})
+			Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+		})
+
+		It("fails for a payload that does not match its checksum", func() {
+			writer.Write([]byte("a"))
+
+			var buf bytes.Buffer
+			Expect(diodes.SaveTo(&buf, d, diodes.BytesCodec{})).To(Succeed())
+			b := buf.Bytes()
+			b[16] = 'x'
+
+			err := diodes.LoadFrom(bytes.NewReader(b), d, diodes.BytesCodec{})
+			Expect(err).To(MatchError(diodes.ErrCorruptSnapshot))
+			Expect(read(d)).To(BeEmpty())
+		})
+
+		It("fails for data that is not a snapshot", func() {
+			err := diodes.LoadFrom(bytes.NewReader([]byte("some-data")), d, diodes.BytesCodec{})
+			Expect(err).To(MatchError(diodes.ErrCorruptSnapshot))
+		})
+
+		It("returns the errors of the codec", func() {
+			writer.Write([]byte("a"))
+
+			var buf bytes.Buffer
+			Expect(diodes.SaveTo(&buf, d, failingCodec{})).To(MatchError("some-error"))
+		})
+
+		It("compresses the payloads", func() {
+			c, err := diodes.NewFlateCompressor(flate.BestSpeed)
+			Expect(err).ToNot(HaveOccurred())
+			payload := bytes.Repeat([]byte("a"), 1000)
+			writer.Write(payload)
+
+			var buf bytes.Buffer
+			Expect(diodes.SaveTo(&buf, d, diodes.BytesCodec{}, diodes.WithSnapshotCompressor(c))).To(Succeed())
+			Expect(buf.Len()).To(BeNumerically("<", 100))
+			b := buf.Bytes()
+
+			err = diodes.LoadFrom(bytes.NewReader(b), d, diodes.BytesCodec{})
+			Expect(err).To(MatchError(diodes.ErrNoCompressor))
+
+			Expect(diodes.LoadFrom(bytes.NewReader(b), d, diodes.BytesCodec{}, diodes.WithSnapshotCompressor(c))).To(Succeed())
+			Expect(read(d)).To(Equal([]string{string(payload)}))
+		})
+
+		It("encrypts the payloads", func() {
+			c := newCipher("0123456789abcdef")
+			writer.Write([]byte("some-secret"))
+
+			var buf bytes.Buffer
+			Expect(diodes.SaveTo(&buf, d, diodes.BytesCodec{}, diodes.WithSnapshotCipher(c))).To(Succeed())
+			Expect(buf.String()).ToNot(ContainSubstring("some-secret"))
+			b := buf.Bytes()
+
+			err := diodes.LoadFrom(bytes.NewReader(b), d, diodes.BytesCodec{})
+			Expect(err).To(MatchError(diodes.ErrNoCipher))
+
+			Expect(diodes.LoadFrom(bytes.NewReader(b), d, diodes.BytesCodec{}, diodes.WithSnapshotCipher(c))).To(Succeed())
+			Expect(read(d)).To(Equal([]string{"some-secret"}))
+		})
+
+		It("compresses the payloads before it encrypts them", func() {
+			comp, err := diodes.NewFlateCompressor(flate.BestSpeed)
+			Expect(err).ToNot(HaveOccurred())
+			c := newCipher("0123456789abcdef")
+			payload := bytes.Repeat([]byte("a"), 1000)
+			writer.Write(payload)
+
+			var buf bytes.Buffer
+			opts := []diodes.SnapshotOption{diodes.WithSnapshotCompressor(comp), diodes.WithSnapshotCipher(c)}
+			Expect(diodes.SaveTo(&buf, d, diodes.BytesCodec{}, opts...)).To(Succeed())
+			Expect(buf.Len()).To(BeNumerically("<", 100))
+
+			Expect(diodes.LoadFrom(&buf, d, diodes.BytesCodec{}, opts...)).To(Succeed())
+			Expect(read(d)).To(Equal([]string{string(payload)}))
+		})
+	})
+})
+
+// sizedCodec marshals every value to a payload of the given size.
+type sizedCodec int
+
+func (c sizedCodec) Marshal(diodes.GenericDataType) ([]byte, error) {
+	return make([]byte, int(c)), nil
+}
+
+func (sizedCodec) Unmarshal(b []byte) (diodes.GenericDataType, error) {
+	return diodes.GenericDataType(&b), nil
+}
+
+type failingCodec struct{}
+
+func (failingCodec) Marshal(diodes.GenericDataType) ([]byte, error) {
+	return nil, errors.New("some-error")
+}
+
+func (failingCodec) Unmarshal([]byte) (diodes.GenericDataType, error) {
+	return nil, errors.New("some-error")
+}
@@ -0,0 +1,274 @@
+// Package spill provides a diode that spills the data its reader has fallen
+// too far behind for to a bounded queue on disk, instead of overwriting it.
+package spill
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Buffer is the in-memory diode that is read first, such as a OneToOne or
+// ManyToOne diode.
+type Buffer interface {
+	diodes.Diode
+	diodes.LagReporter
+	diodes.StatsReporter
+}
+
+// Diode wraps a Buffer. While the lag of the buffer is below the high
+// watermark, data is set on the buffer. Once the lag reaches it, data is
+// marshaled and appended to a file instead, until the reader drained the
+// buffer and the file. The order of the data is kept: the reader reads the
+// file once the buffer is empty.
+//
+// The file is bounded. The data that does not fit into it is dropped, so
+// the newest data is lost once the file is full instead of the oldest.
+type Diode struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	spilled  uint64
+	restored uint64
+	dropped  uint64
+	maxBytes int64
+	high     uint64
+	spilling int32
+
+	d          Buffer
+	codec      diodes.Codec
+	compressor diodes.Compressor
+	cipher     diodes.Cipher
+
+	mu         sync.Mutex
+	q          *queue
+	compressed []byte
+	sealed     []byte
+	err        error
+}
+
+// Option can be used to setup the diode.
+type Option func(*Diode)
+
+// WithHighWatermark sets the lag of the buffer at which data is spilled to
+// the file. The default is the capacity of the buffer, so nothing is
+// overwritten.
+func WithHighWatermark(n int) Option {
+	return Option(func(d *Diode) {
+		d.high = uint64(n)
+	})
+}
+
+// WithMaxBytes sets the maximum size of the file. The default is 64 MiB.
+func WithMaxBytes(n int64) Option {
+	return Option(func(d *Diode) {
+		d.maxBytes = n
+	})
+}
+
+// WithCompressor compresses the values in the file.
+func WithCompressor(c diodes.Compressor) Option {
+	return Option(func(d *Diode) {
+		d.compressor = c
+	})
+}
+
+// WithCipher encrypts the values in the file.
+func WithCipher(c diodes.Cipher) Option {
+	return Option(func(d *Diode) {
+		d.cipher = c
+	})
+}
+
+// New returns a Diode that wraps the buffer and spills to the file at the
+// given path. Any existing file is truncated, as the file only holds the
+// data of a running diode. The codec converts the data to and from the
+// bytes of the file.
+func New(b Buffer, path string, c diodes.Codec, opts ...Option) (*Diode, error) {
+	d := &Diode{
+		d:        b,
+		codec:    c,
+		high:     uint64(b.Stats().Capacity),
+		maxBytes: 64 << 20,
+	}
+
+	for _, o := range opts {
+		o(d)
+	}
+
+	q, err := openQueue(path, d.maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	d.q = q
+
+	return d, nil
+}
+
+// Set sets the data on the buffer, or appends it to the file while the
+// reader is behind.
+func (d *Diode) Set(data diodes.GenericDataType) {
+	if atomic.LoadInt32(&d.spilling) == 0 && d.d.Lag() < d.high {
+		d.d.Set(data)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The reader may have drained the file in the meantime.
+	if atomic.LoadInt32(&d.spilling) == 0 && d.d.Lag() < d.high {
+		d.d.Set(data)
+		return
+	}
+
+	b, err := d.encode(data)
+	if err != nil {
+		atomic.AddUint64(&d.dropped, 1)
+		return
+	}
+
+	ok, err := d.q.push(b)
+	if err != nil && d.err == nil {
+		d.err = err
+	}
+	if !ok {
+		atomic.AddUint64(&d.dropped, 1)
+		return
+	}
+
+	atomic.AddUint64(&d.spilled, 1)
+	atomic.StoreInt32(&d.spilling, 1)
+}
+
+// TryNext returns the next value of the buffer. Once the buffer is empty,
+// it returns the values of the file. Values that fail to be read back are
+// dropped.
+func (d *Diode) TryNext() (diodes.GenericDataType, bool) {
+	if data, ok := d.d.TryNext(); ok {
+		return data, true
+	}
+
+	if atomic.LoadInt32(&d.spilling) == 0 {
+		return nil, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for {
+		b, ok, err := d.q.pop()
+		if err != nil {
+			if d.err == nil {
+				d.err = err
+			}
+			d.discard()
+			return nil, false
+		}
+
+		if !ok {
+			atomic.StoreInt32(&d.spilling, 0)
+			return nil, false
+		}
+
+		data, err := d.decode(b)
+		if err != nil {
+			atomic.AddUint64(&d.dropped, 1)
+			continue
+		}
+
+		atomic.AddUint64(&d.restored, 1)
+		return data, true
+	}
+}
+
+// encode marshals, compresses and encrypts the data as configured. The
+// returned bytes are only valid until the next call.
+func (d *Diode) encode(data diodes.GenericDataType) ([]byte, error) {
+	b, err := d.codec.Marshal(data)
+	if err == nil && d.compressor != nil {
+		d.compressed, err = d.compressor.Compress(d.compressed[:0], b)
+		b = d.compressed
+	}
+	if err == nil && d.cipher != nil {
+		d.sealed, err = d.cipher.Seal(d.sealed[:0], b)
+		b = d.sealed
+	}
+
+	return b, err
+}
+
+// decode reverses encode.
+func (d *Diode) decode(b []byte) (diodes.GenericDataType, error) {
+	var err error
+	if d.cipher != nil {
+		b, err = d.cipher.Open(nil, b)
+	}
+	if err == nil && d.compressor != nil {
+		b, err = d.compressor.Decompress(nil, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d.codec.Unmarshal(b)
+}
+
+// discard drops the records of a file that cannot be read.
+func (d *Diode) discard() {
+	atomic.AddUint64(&d.dropped, d.q.records)
+	d.q.records = 0
+	d.q.reset()
+	atomic.StoreInt32(&d.spilling, 0)
+}
+
+// Stats is a snapshot of the statistics of a Diode.
+type Stats struct {
+	// Spilled is the total number of values appended to the file.
+	Spilled uint64
+
+	// Restored is the total number of values read from the file.
+	Restored uint64
+
+	// Dropped is the total number of values that did not fit into the file
+	// or failed to be written or read back.
+	Dropped uint64
+
+	// Pending is the number of values in the file.
+	Pending uint64
+
+	// Bytes is the size of the file.
+	Bytes int64
+}
+
+// Stats returns a snapshot of the diode's statistics. The statistics of the
+// buffer are available from the buffer. It is safe to call from any
+// go-routine.
+func (d *Diode) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return Stats{
+		Spilled:  atomic.LoadUint64(&d.spilled),
+		Restored: atomic.LoadUint64(&d.restored),
+		Dropped:  atomic.LoadUint64(&d.dropped),
+		Pending:  d.q.records,
+		Bytes:    d.q.writeOff,
+	}
+}
+
+// Err returns the first error of the file, if any.
+func (d *Diode) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.err
+}
+
+// Close closes the file. The values that were not read from it are lost.
+func (d *Diode) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.q.close()
+}
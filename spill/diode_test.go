@@ -0,0 +1,189 @@
+package spill_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+	"code.cloudfoundry.org/go-diodes/spill"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diode", func() {
+	var (
+		dir    string
+		path   string
+		buffer *diodes.OneToOne
+		d      *spill.Diode
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "spill")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "spill")
+		buffer = diodes.NewOneToOne(4, nil)
+	})
+
+	AfterEach(func() {
+		if d != nil {
+			d.Close()
+			d = nil
+		}
+		os.RemoveAll(dir)
+	})
+
+	newDiode := func(opts ...spill.Option) *spill.Diode {
+		var err error
+		d, err = spill.New(buffer, path, diodes.BytesCodec{}, opts...)
+		Expect(err).ToNot(HaveOccurred())
+		return d
+	}
+
+	set := func(d *spill.Diode, payloads ...string) {
+		for _, p := range payloads {
+			b := []byte(p)
+			d.Set(diodes.GenericDataType(&b))
+		}
+	}
+
+	readAll := func(d *spill.Diode) []string {
+		var payloads []string
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				return payloads
+			}
+			payloads = append(payloads, string(*(*[]byte)(data)))
+		}
+	}
+
+	It("sets the data on the buffer while the reader keeps up", func() {
+		d := newDiode()
+		set(d, "a", "b", "c")
+
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c"}))
+		Expect(d.Stats().Spilled).To(BeZero())
+	})
+
+	It("spills the data the buffer has no room for and reads it back in order", func() {
+		d := newDiode()
+		set(d, "a", "b", "c", "d", "e", "f")
+
+		Expect(d.Stats().Spilled).To(Equal(uint64(2)))
+		Expect(d.Stats().Pending).To(Equal(uint64(2)))
+
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c", "d", "e", "f"}))
+		Expect(buffer.Stats().Drops).To(BeZero())
+
+		stats := d.Stats()
+		Expect(stats.Restored).To(Equal(uint64(2)))
+		Expect(stats.Pending).To(BeZero())
+		Expect(stats.Bytes).To(BeZero())
+	})
+
+	It("drops the file once a record in it is corrupt", func() {
+		d := newDiode()
+		set(d, "a", "b", "c", "d", "e", "f")
+
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.WriteAt([]byte("x"), 16)
+		Expect(err).ToNot(HaveOccurred())
+		f.Close()
+
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c", "d"}))
+		Expect(d.Stats().Dropped).To(Equal(uint64(2)))
+		Expect(d.Err()).To(MatchError(framing.ErrChecksum))
+	})
+
+	It("keeps spilling until the file is drained", func() {
+		d := newDiode()
+		set(d, "a", "b", "c", "d", "e")
+
+		data, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(string(*(*[]byte)(data))).To(Equal("a"))
+
+		set(d, "f")
+		Expect(d.Stats().Spilled).To(Equal(uint64(2)))
+		Expect(readAll(d)).To(Equal([]string{"b", "c", "d", "e", "f"}))
+
+		set(d, "g")
+		Expect(d.Stats().Spilled).To(Equal(uint64(2)))
+		Expect(readAll(d)).To(Equal([]string{"g"}))
+	})
+
+	It("spills from the high watermark", func() {
+		d := newDiode(spill.WithHighWatermark(2))
+		set(d, "a", "b", "c")
+
+		Expect(d.Stats().Spilled).To(Equal(uint64(1)))
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("drops the data that does not fit into the file", func() {
+		// Every record of a single byte takes 17 bytes with its frame header.
+		d := newDiode(spill.WithMaxBytes(34))
+		set(d, "a", "b", "c", "d", "e", "f", "g")
+
+		stats := d.Stats()
+		Expect(stats.Spilled).To(Equal(uint64(2)))
+		Expect(stats.Dropped).To(Equal(uint64(1)))
+		Expect(stats.Bytes).To(Equal(int64(34)))
+
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c", "d", "e", "f"}))
+	})
+
+	It("compresses the values in the file", func() {
+		c, err := diodes.NewFlateCompressor(flate.BestSpeed)
+		Expect(err).ToNot(HaveOccurred())
+		d := newDiode(spill.WithCompressor(c))
+
+		payload := string(bytes.Repeat([]byte("e"), 1000))
+		set(d, "a", "b", "c", "d", payload)
+
+		Expect(d.Stats().Bytes).To(BeNumerically("<", 100))
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c", "d", payload}))
+	})
+
+	It("encrypts the values in the file", func() {
+		d := newDiode(spill.WithCipher(newCipher("0123456789abcdef")))
+		set(d, "a", "b", "c", "d", "some-secret")
+
+		b, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(b)).To(BeNumerically(">", 0))
+		Expect(string(b)).ToNot(ContainSubstring("some-secret"))
+
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c", "d", "some-secret"}))
+	})
+
+	It("truncates an existing file", func() {
+		Expect(os.WriteFile(path, []byte("stale"), 0o600)).To(Succeed())
+
+		d := newDiode()
+		Expect(d.Stats().Bytes).To(BeZero())
+		Expect(readAll(d)).To(BeEmpty())
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Size()).To(BeZero())
+	})
+})
+
+func newCipher(key string) *diodes.AEADCipher {
+	block, err := aes.NewCipher([]byte(key))
+	Expect(err).ToNot(HaveOccurred())
+	aead, err := cipher.NewGCM(block)
+	Expect(err).ToNot(HaveOccurred())
+
+	return diodes.NewAEADCipher(aead)
+}
@@ -0,0 +1,94 @@
+package spill
+
+import (
+	"io"
+	"os"
+
+	"code.cloudfoundry.org/go-diodes/framing"
+)
+
+// queue is a FIFO of records appended to a file. Every record is a frame of
+// the framing package, numbered by the order it was pushed in, so that a
+// record that was corrupted in the file is detected. The file is truncated
+// whenever the queue is drained, so its size only grows while the reader is
+// behind. It is not safe for concurrent use.
+type queue struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	readOff  int64
+	writeOff int64
+	maxBytes int64
+	records  uint64
+	pushed   uint64
+
+	f   *os.File
+	buf []byte
+}
+
+func openQueue(path string, maxBytes int64) (*queue, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queue{f: f, maxBytes: maxBytes}, nil
+}
+
+// push appends a record. It returns false without writing when the record
+// does not fit into the file.
+func (q *queue) push(b []byte) (bool, error) {
+	n := int64(framing.HeaderSize + len(b))
+	if q.writeOff+n > q.maxBytes {
+		return false, nil
+	}
+
+	q.buf = framing.Append(q.buf[:0], q.pushed, b)
+	if _, err := q.f.WriteAt(q.buf, q.writeOff); err != nil {
+		return false, err
+	}
+
+	q.writeOff += n
+	q.records++
+	q.pushed++
+
+	return true, nil
+}
+
+// pop removes the oldest record. It returns false when the queue is empty,
+// and framing.ErrChecksum for a record that is corrupt. As its length cannot
+// be trusted either, the records after it are unreadable.
+func (q *queue) pop() ([]byte, bool, error) {
+	if q.records == 0 {
+		return nil, false, nil
+	}
+
+	size := q.writeOff - q.readOff
+	_, b, err := framing.Read(io.NewSectionReader(q.f, q.readOff, size), int(size-framing.HeaderSize))
+	if err != nil {
+		return nil, false, err
+	}
+
+	q.readOff += int64(framing.HeaderSize + len(b))
+	q.records--
+
+	if q.records == 0 {
+		if err := q.reset(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return b, true, nil
+}
+
+// reset truncates the file of a drained queue.
+func (q *queue) reset() error {
+	q.readOff = 0
+	q.writeOff = 0
+	q.pushed = 0
+
+	return q.f.Truncate(0)
+}
+
+func (q *queue) close() error {
+	return q.f.Close()
+}
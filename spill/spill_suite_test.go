@@ -0,0 +1,13 @@
+package spill_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSpill(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Spill Suite")
+}
@@ -0,0 +1,128 @@
+// Package sse serves the data of a diode to HTTP subscribers as Server-Sent
+// Events.
+package sse
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Publisher reads events from a diode and broadcasts them to every
+// subscriber. Each subscriber has a diode of its own that is only read by
+// its request, so a slow subscriber drops events instead of slowing down
+// the source or the other subscribers.
+type Publisher struct {
+	b    *diodes.Broadcast
+	size int
+}
+
+// PublisherOption can be used to setup the publisher.
+type PublisherOption func(*Publisher)
+
+// WithSubscriberSize sets the size of the diode of each subscriber. The
+// default is 1024.
+func WithSubscriberSize(size int) PublisherOption {
+	return PublisherOption(func(p *Publisher) {
+		p.size = size
+	})
+}
+
+// NewPublisher returns a new Publisher that reads events from the given
+// Poller or Waiter. The values of the diode must be *[]byte, such as the ones
+// set by a diodes.Writer. The values are shared by all subscribers and must
+// not be modified once they are set.
+func NewPublisher(n diodes.Nexter, opts ...PublisherOption) *Publisher {
+	p := &Publisher{size: 1024}
+	for _, o := range opts {
+		o(p)
+	}
+	p.b = diodes.NewBroadcast(n, diodes.WithSubscriptionSize(p.size))
+
+	return p
+}
+
+// Run broadcasts events until the Poller or Waiter returns nil.
+func (p *Publisher) Run() {
+	p.b.Run()
+}
+
+// ServeHTTP streams the events to the client until the request is done.
+// Each event is sent as the data of a message. When the subscriber fell
+// behind and events were dropped, it is told so with a comment such as
+// ": dropped 3".
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var missed int
+	ctx := r.Context()
+	s := p.b.Subscribe(ctx, diodes.AlertFunc(func(n int) {
+		missed += n
+	}))
+	defer s.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	var buf []byte
+	for {
+		data := s.Next()
+		if data == nil || ctx.Err() != nil {
+			return
+		}
+
+		buf = buf[:0]
+		if missed > 0 {
+			buf = append(buf, ": dropped "...)
+			buf = strconv.AppendInt(buf, int64(missed), 10)
+			buf = append(buf, "\n\n"...)
+			missed = 0
+		}
+		buf = appendEvent(buf, *(*[]byte)(unsafe.Pointer(data)))
+
+		if _, err := w.Write(buf); err != nil {
+			return
+		}
+		f.Flush()
+	}
+}
+
+// Subscribers returns the number of connected subscribers.
+func (p *Publisher) Subscribers() int {
+	return p.b.Subscriptions()
+}
+
+// Drops returns the number of events dropped by all subscribers.
+func (p *Publisher) Drops() uint64 {
+	return p.b.Drops()
+}
+
+// appendEvent appends an event as a message. Every line of the event is a
+// data field, since a field cannot span lines.
+func appendEvent(b, event []byte) []byte {
+	event = bytes.TrimRight(event, "\n")
+	for {
+		b = append(b, "data: "...)
+
+		i := bytes.IndexByte(event, '\n')
+		if i < 0 {
+			b = append(b, event...)
+			return append(b, "\n\n"...)
+		}
+
+		b = append(b, event[:i]...)
+		b = append(b, '\n')
+		event = event[i+1:]
+	}
+}
@@ -0,0 +1,155 @@
+package sse_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/sse"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Publisher", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		writer *diodes.Writer
+		p      *sse.Publisher
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		w := diodes.NewWaiter(diodes.NewOneToOne(16, nil), diodes.WithWaiterContext(ctx))
+		writer = diodes.NewWriter(w)
+		p = sse.NewPublisher(w, sse.WithSubscriberSize(2))
+		go p.Run()
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("streams the events to the subscribers", func() {
+		server := httptest.NewServer(p)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+		Eventually(p.Subscribers).Should(Equal(1))
+
+		writer.Write([]byte("first\n"))
+		writer.Write([]byte("second\nline"))
+
+		r := bufio.NewReader(resp.Body)
+		var lines []string
+		for i := 0; i < 5; i++ {
+			line, err := r.ReadString('\n')
+			Expect(err).ToNot(HaveOccurred())
+			lines = append(lines, line)
+		}
+		Expect(lines).To(Equal([]string{
+			"data: first\n",
+			"\n",
+			"data: second\n",
+			"data: line\n",
+			"\n",
+		}))
+	})
+
+	It("removes subscribers once their requests are done", func() {
+		reqCtx, reqCancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("GET", "/", nil).WithContext(reqCtx)
+		done := make(chan struct{})
+		go func() {
+			p.ServeHTTP(newSpyWriter(), req)
+			close(done)
+		}()
+		Eventually(p.Subscribers).Should(Equal(1))
+
+		reqCancel()
+		Eventually(done).Should(BeClosed())
+		Expect(p.Subscribers()).To(Equal(0))
+	})
+
+	It("drops events for a slow subscriber without affecting the others", func() {
+		slow := newSpyWriter()
+		slow.block = make(chan struct{})
+		fast := newSpyWriter()
+
+		for _, w := range []*spyWriter{slow, fast} {
+			go p.ServeHTTP(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+		}
+		Eventually(p.Subscribers).Should(Equal(2))
+
+		var expected string
+		for _, e := range []string{"a", "b", "c", "d", "e", "f"} {
+			writer.Write([]byte(e))
+			expected += "data: " + e + "\n\n"
+			Eventually(fast.String).Should(Equal(expected))
+		}
+
+		close(slow.block)
+		Eventually(slow.String).Should(ContainSubstring(": dropped "))
+		Expect(slow.String()).To(HaveSuffix("data: f\n\n"))
+		Expect(p.Drops()).To(BeNumerically(">", 0))
+	})
+
+	It("rejects response writers that cannot flush", func() {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(struct{ http.ResponseWriter }{rec}, httptest.NewRequest("GET", "/", nil))
+
+		Expect(rec.Code).To(Equal(http.StatusInternalServerError))
+		Expect(p.Subscribers()).To(Equal(0))
+	})
+})
+
+// spyWriter is a flushing response writer that records the body. When block
+// is set, the first write blocks until it is closed.
+type spyWriter struct {
+	mu     sync.Mutex
+	header http.Header
+	body   bytes.Buffer
+	block  chan struct{}
+	writes int
+}
+
+func newSpyWriter() *spyWriter {
+	return &spyWriter{header: make(http.Header)}
+}
+
+func (w *spyWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *spyWriter) WriteHeader(int) {}
+
+func (w *spyWriter) Flush() {}
+
+func (w *spyWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	w.writes++
+	first := w.writes == 1
+	w.mu.Unlock()
+
+	if first && w.block != nil {
+		<-w.block
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.Write(b)
+}
+
+func (w *spyWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.String()
+}
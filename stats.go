@@ -0,0 +1,47 @@
+package diodes
+
+// Stats is a snapshot of a diode's statistics.
+type Stats struct {
+	// Writes is the total number of values written.
+	Writes uint64
+
+	// Reads is the total number of values read.
+	Reads uint64
+
+	// Drops is the total number of values that were overwritten before they
+	// were read, as reported to the alerter.
+	Drops uint64
+
+	// Rejected is the total number of values that were set after the diode
+	// was closed and therefore dropped.
+	Rejected uint64
+
+	// Lag is how many values the reader is behind the writer. When it
+	// exceeds the capacity, the reader has been lapped and will drop data.
+	Lag uint64
+
+	// Capacity is the number of values the diode can hold.
+	Capacity int
+}
+
+// StatsReporter is implemented by diodes that report Stats.
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// LagReporter is implemented by diodes that report how far their reader is
+// behind. Lag is cheaper than Stats and the earliest warning of upcoming
+// drops.
+type LagReporter interface {
+	Lag() uint64
+}
+
+// Occupancy returns the number of values the diode holds, which is the lag
+// capped at the capacity.
+func (s Stats) Occupancy() uint64 {
+	if s.Lag > uint64(s.Capacity) {
+		return uint64(s.Capacity)
+	}
+
+	return s.Lag
+}
@@ -0,0 +1,159 @@
+package diodes_test
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("Stats()", func(impl diodes.Implementation) {
+	var data []byte
+
+	BeforeEach(func() {
+		data = []byte("some-data")
+	})
+
+	It("reports the writes, reads, drops and lag of a OneToOne", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl))
+		Expect(d.Stats()).To(Equal(diodes.Stats{Capacity: 4}))
+
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		Expect(d.Stats().Lag).To(Equal(uint64(6)))
+
+		d.TryNext()
+		d.TryNext()
+
+		Expect(d.Stats()).To(Equal(diodes.Stats{
+			Writes:   6,
+			Reads:    2,
+			Drops:    4,
+			Lag:      0,
+			Capacity: 4,
+		}))
+	})
+
+	It("reports the writes, reads, drops and lag of a ManyToOne", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl))
+		Expect(d.Stats()).To(Equal(diodes.Stats{Capacity: 4}))
+
+		for i := 0; i < 3; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+
+		Expect(d.Stats()).To(Equal(diodes.Stats{
+			Writes:   3,
+			Reads:    1,
+			Lag:      2,
+			Capacity: 4,
+		}))
+	})
+
+	It("is safe to call from any go-routine", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl))
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				d.Set(diodes.GenericDataType(&data))
+				d.TryNext()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				s := d.Stats()
+				Expect(s.Reads + s.Drops).To(BeNumerically("<=", s.Writes+s.Drops))
+			}
+		}()
+		wg.Wait()
+
+		Expect(d.Stats().Writes).To(Equal(uint64(1000)))
+	})
+})
+
+var _ = Describe("Segmented Stats()", func() {
+	It("counts the items of detached segments as read", func() {
+		d := diodes.NewSegmented(2, 2, nil)
+		data := []byte("some-data")
+		for i := 0; i < 7; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+
+		Expect(d.Stats()).To(Equal(diodes.Stats{
+			Writes:   7,
+			Reads:    2,
+			Drops:    4,
+			Lag:      1,
+			Capacity: 4,
+		}))
+	})
+})
+
+var _ = Describe("Stats.Occupancy()", func() {
+	It("returns the lag", func() {
+		Expect(diodes.Stats{Lag: 3, Capacity: 4}.Occupancy()).To(Equal(uint64(3)))
+	})
+
+	It("caps the lag at the capacity", func() {
+		Expect(diodes.Stats{Lag: 9, Capacity: 4}.Occupancy()).To(Equal(uint64(4)))
+	})
+})
+
+var _ = forEachImplementation("Lag()", func(impl diodes.Implementation) {
+	var data []byte
+
+	BeforeEach(func() {
+		data = []byte("some-data")
+	})
+
+	It("reports the lag of a OneToOne", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl))
+		Expect(d.Lag()).To(BeZero())
+
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		Expect(d.Lag()).To(Equal(uint64(6)))
+
+		d.TryNext()
+		Expect(d.Lag()).To(Equal(uint64(1)))
+		Expect(d.Lag()).To(Equal(d.Stats().Lag))
+	})
+
+	It("reports the lag of a ManyToOne", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl))
+		Expect(d.Lag()).To(BeZero())
+
+		for i := 0; i < 3; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		Expect(d.Lag()).To(Equal(uint64(3)))
+
+		d.TryNext()
+		Expect(d.Lag()).To(Equal(uint64(2)))
+		Expect(d.Lag()).To(Equal(d.Stats().Lag))
+	})
+})
+
+var _ = Describe("Segmented Lag()", func() {
+	It("reports the items of the segments that are not detached", func() {
+		d := diodes.NewSegmented(4, 2, nil)
+		data := []byte("some-data")
+		for i := 0; i < 3; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		Expect(d.Lag()).To(Equal(uint64(3)))
+
+		d.TryNext()
+		Expect(d.Lag()).To(Equal(uint64(1)))
+	})
+})
@@ -0,0 +1,163 @@
+// Package statsd emits the drops, latencies and lag of diodes to a statsd or
+// DogStatsD endpoint.
+package statsd
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Emitter writes statsd metrics. Every metric is written with a single Write
+// call so that each one results in a single packet when writing to a UDP
+// connection. It is safe to use from several go-routines.
+type Emitter struct {
+	prefix     string
+	tags       string
+	sampleRate float64
+
+	mu   sync.Mutex
+	w    io.Writer
+	rand *rand.Rand
+	buf  []byte
+}
+
+// EmitterOption can be used to setup the emitter.
+type EmitterOption func(*Emitter)
+
+// WithPrefix sets the prefix of the metric names. The default is "diode".
+func WithPrefix(prefix string) EmitterOption {
+	return EmitterOption(func(e *Emitter) {
+		e.prefix = prefix
+	})
+}
+
+// WithTags sets DogStatsD tags (e.g. "diode:ingress") that are added to
+// every metric. Plain statsd servers do not support tags.
+func WithTags(tags ...string) EmitterOption {
+	return EmitterOption(func(e *Emitter) {
+		e.tags = strings.Join(tags, ",")
+	})
+}
+
+// WithSampleRate sets the rate at which drop counts, latencies and lags are
+// sampled. A rate of 0.1 emits one in ten events, the server scales the
+// counts accordingly. The default is 1, which emits every event.
+func WithSampleRate(rate float64) EmitterOption {
+	return EmitterOption(func(e *Emitter) {
+		e.sampleRate = rate
+	})
+}
+
+// NewEmitter returns an Emitter that writes metrics to w.
+func NewEmitter(w io.Writer, opts ...EmitterOption) *Emitter {
+	e := &Emitter{
+		prefix:     "diode",
+		sampleRate: 1,
+		w:          w,
+		rand:       rand.New(rand.NewSource(rand.Int63())),
+	}
+	for _, o := range opts {
+		o(e)
+	}
+
+	return e
+}
+
+// Dial returns an Emitter that sends metrics to the statsd server at the
+// given UDP address.
+func Dial(addr string, opts ...EmitterOption) (*Emitter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEmitter(conn, opts...), nil
+}
+
+// Alert implements diodes.Alerter. It emits the number of missed values as a
+// sampled drops counter.
+func (e *Emitter) Alert(missed int) {
+	e.IncDrops(uint64(missed))
+}
+
+// IncDrops implements diodes.MetricsHook. It emits the number of dropped
+// values as a sampled drops counter.
+func (e *Emitter) IncDrops(n uint64) {
+	e.sampled("drops", strconv.FormatUint(n, 10), "c", e.sampleRate)
+}
+
+// ObserveLatency implements diodes.MetricsHook. It emits the latency as a
+// sampled timer in milliseconds.
+func (e *Emitter) ObserveLatency(d time.Duration) {
+	ms := strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+	e.sampled("latency", ms, "ms", e.sampleRate)
+}
+
+// SetLag implements diodes.MetricsHook. It emits the lag as a sampled gauge.
+// As it is invoked after every write, a low sample rate is recommended.
+func (e *Emitter) SetLag(lag uint64) {
+	e.sampled("lag", strconv.FormatUint(lag, 10), "g", 1)
+}
+
+// Report emits the lag and occupancy of the diode as gauges. It is meant to
+// be called periodically.
+func (e *Emitter) Report(d diodes.StatsReporter) {
+	s := d.Stats()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.write("lag", strconv.FormatUint(s.Lag, 10), "g", 1)
+	e.write("occupancy", strconv.FormatUint(s.Occupancy(), 10), "g", 1)
+}
+
+// sampled writes a metric at the sample rate. The rate is only added to the
+// metric when the server is meant to scale the value.
+func (e *Emitter) sampled(name, value, typ string, rate float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.sampleRate < 1 && e.rand.Float64() >= e.sampleRate {
+		return
+	}
+
+	e.write(name, value, typ, rate)
+}
+
+// write formats a metric as name:value|type|@rate|#tags and writes it. Write
+// errors are ignored as statsd is a best effort protocol.
+func (e *Emitter) write(name, value, typ string, rate float64) {
+	b := e.buf[:0]
+	if e.prefix != "" {
+		b = append(b, e.prefix...)
+		b = append(b, '.')
+	}
+	b = append(b, name...)
+	b = append(b, ':')
+	b = append(b, value...)
+	b = append(b, '|')
+	b = append(b, typ...)
+	if rate < 1 {
+		b = append(b, "|@"...)
+		b = strconv.AppendFloat(b, rate, 'f', -1, 64)
+	}
+	if e.tags != "" {
+		b = append(b, "|#"...)
+		b = append(b, e.tags...)
+	}
+	e.buf = b
+
+	_, _ = e.w.Write(b)
+}
+
+var (
+	_ diodes.Alerter     = (*Emitter)(nil)
+	_ diodes.MetricsHook = (*Emitter)(nil)
+)
@@ -0,0 +1,104 @@
+package statsd_test
+
+import (
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/statsd"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Emitter", func() {
+	var w *spyWriter
+
+	BeforeEach(func() {
+		w = new(spyWriter)
+	})
+
+	It("emits drops as a counter", func() {
+		e := statsd.NewEmitter(w)
+		d := diodes.NewOneToOne(4, e)
+
+		data := []byte("some-data")
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		d.TryNext()
+
+		Expect(w.packets).To(Equal([]string{"diode.drops:4|c"}))
+	})
+
+	It("emits the lag and occupancy as gauges", func() {
+		e := statsd.NewEmitter(w, statsd.WithPrefix("app.buffer"))
+		d := diodes.NewOneToOne(4, nil)
+
+		data := []byte("some-data")
+		for i := 0; i < 6; i++ {
+			d.Set(diodes.GenericDataType(&data))
+		}
+		e.Report(d)
+
+		Expect(w.packets).To(Equal([]string{
+			"app.buffer.lag:6|g",
+			"app.buffer.occupancy:4|g",
+		}))
+	})
+
+	It("emits the measurements of a metrics hook", func() {
+		e := statsd.NewEmitter(w)
+		d := diodes.NewOneToOne(4, nil, diodes.WithMetricsHook(e))
+
+		data := []byte("some-data")
+		d.Set(diodes.GenericDataType(&data))
+		d.TryNext()
+
+		Expect(w.packets).To(HaveLen(2))
+		Expect(w.packets[0]).To(Equal("diode.lag:1|g"))
+		Expect(w.packets[1]).To(MatchRegexp(`^diode\.latency:[0-9.]+\|ms$`))
+	})
+
+	It("adds the tags to every metric", func() {
+		e := statsd.NewEmitter(w, statsd.WithTags("diode:ingress", "env:test"))
+		e.Alert(3)
+
+		Expect(w.packets).To(Equal([]string{"diode.drops:3|c|#diode:ingress,env:test"}))
+	})
+
+	It("samples drop events", func() {
+		e := statsd.NewEmitter(w, statsd.WithSampleRate(0.25))
+		for i := 0; i < 1000; i++ {
+			e.Alert(1)
+		}
+
+		Expect(len(w.packets)).To(BeNumerically("~", 250, 100))
+		Expect(w.packets[0]).To(Equal("diode.drops:1|c|@0.25"))
+	})
+
+	It("sends metrics over UDP", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		e, err := statsd.Dial(conn.LocalAddr().String())
+		Expect(err).ToNot(HaveOccurred())
+		e.Alert(2)
+
+		buf := make([]byte, 512)
+		Expect(conn.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		n, _, err := conn.ReadFrom(buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(buf[:n])).To(Equal("diode.drops:2|c"))
+	})
+})
+
+type spyWriter struct {
+	packets []string
+}
+
+func (w *spyWriter) Write(p []byte) (int, error) {
+	w.packets = append(w.packets, string(p))
+	return len(p), nil
+}
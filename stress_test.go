@@ -0,0 +1,115 @@
+package diodes_test
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// The stress tests hammer small diodes from several go-routines. They are
+// most meaningful on weakly ordered platforms (e.g. ARM64) and with the race
+// detector enabled.
+var _ = forEachImplementation("Stress", func(impl diodes.Implementation) {
+	const writes = 50000
+
+	It("reads every OneToOne value exactly once or reports it as dropped", func() {
+		alerter := new(countingAlerter)
+		d := diodes.NewOneToOne(8, alerter, diodes.WithImplementation(impl))
+
+		var done int32
+		go func() {
+			defer atomic.StoreInt32(&done, 1)
+			for i := 0; i < writes; i++ {
+				v := i
+				d.Set(diodes.GenericDataType(&v))
+			}
+		}()
+
+		read := drainWhileWriting(d, &done, func(prev, next int) {
+			Expect(next).To(BeNumerically(">", prev))
+		})
+
+		Expect(read + alerter.missed()).To(Equal(writes))
+	})
+
+	It("never returns a ManyToOne value more than once", func() {
+		const writers = 4
+		alerter := new(countingAlerter)
+		d := diodes.NewManyToOne(8, alerter, diodes.WithImplementation(impl))
+
+		var (
+			wg   sync.WaitGroup
+			done int32
+		)
+		wg.Add(writers)
+		for w := 0; w < writers; w++ {
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < writes/writers; i++ {
+					v := w*writes + i
+					d.Set(diodes.GenericDataType(&v))
+					if i%64 == 0 {
+						runtime.Gosched()
+					}
+				}
+			}(w)
+		}
+		go func() {
+			wg.Wait()
+			atomic.StoreInt32(&done, 1)
+		}()
+
+		seen := make(map[int]bool)
+		read := drainWhileWriting(d, &done, func(_, next int) {
+			Expect(seen).ToNot(HaveKey(next))
+			seen[next] = true
+		})
+
+		Expect(read + alerter.missed()).To(BeNumerically("<=", writes))
+	})
+})
+
+// drainWhileWriting reads from the diode until done is set and the diode is
+// empty. It invokes check with the previous and next value read and returns
+// the number of values read.
+func drainWhileWriting(d diodes.Diode, done *int32, check func(prev, next int)) int {
+	var (
+		read int
+		prev = -1
+	)
+
+	for {
+		finished := atomic.LoadInt32(done) == 1
+
+		data, ok := d.TryNext()
+		if !ok {
+			if finished {
+				return read
+			}
+			runtime.Gosched()
+			continue
+		}
+
+		next := *(*int)(data)
+		check(prev, next)
+		prev = next
+		read++
+	}
+}
+
+type countingAlerter struct {
+	count int64
+}
+
+func (a *countingAlerter) Alert(missed int) {
+	atomic.AddInt64(&a.count, int64(missed))
+}
+
+func (a *countingAlerter) missed() int {
+	return int(atomic.LoadInt64(&a.count))
+}
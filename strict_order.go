@@ -0,0 +1,40 @@
+package diodes
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// WithStrictOrder makes the writers of a ManyToOne diode write in the order
+// they claimed their index, so that the reader sees the values in strict
+// FIFO order across the writers. By default a writer that is descheduled
+// after it claimed its index may find its slot taken by a writer that lapped
+// it, and its value then surfaces after the ones that were claimed later.
+// With strict order a writer waits until the writers before it wrote, which
+// serializes the writers: a writer that is descheduled holds up the ones
+// after it. It does not apply to the other diodes.
+func WithStrictOrder() DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.strictOrder = true
+	})
+}
+
+// setOrdered is Set with WithStrictOrder. The published index is the index
+// of the next writer to write, so the writers take turns in the order of
+// their indices and every slot only has a single writer at a time.
+func (d *ManyToOne) setOrdered(data GenericDataType) {
+	ts := d.instr.now()
+	writeIndex := atomic.AddUint64(&d.writeIndex, 1)
+	for atomic.LoadUint64(&d.published) != writeIndex {
+		runtime.Gosched()
+	}
+
+	d.ring.store(writeIndex%d.size, writeIndex, data, ts)
+	yield()
+	atomic.StoreUint64(&d.published, writeIndex+1)
+
+	d.instr.observeOccupancy(writeIndex, &d.readIndex, d.size)
+	d.instr.tick(ts)
+	d.hooks.start()
+	d.rec.record(EventSet, writeIndex, 0)
+}
@@ -0,0 +1,89 @@
+package diodes_test
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("WithStrictOrder", func(impl diodes.Implementation) {
+	readAll := func(d diodes.Diode) []int {
+		var values []int
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, *(*int)(data))
+		}
+	}
+
+	set := func(d diodes.Diode, from, to int) {
+		for i := from; i < to; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("reads the values in order", func() {
+		d := diodes.NewManyToOne(8, nil, diodes.WithImplementation(impl), diodes.WithStrictOrder())
+		set(d, 0, 5)
+
+		Expect(readAll(d)).To(Equal([]int{0, 1, 2, 3, 4}))
+	})
+
+	It("drops the oldest values once the writers lap the reader", func() {
+		spy := newSpyAlerter()
+		d := diodes.NewManyToOne(4, spy, diodes.WithImplementation(impl), diodes.WithStrictOrder())
+		set(d, 0, 10)
+
+		Expect(readAll(d)).To(Equal([]int{8, 9}))
+		Expect(spy.AlertInput.Missed).To(Receive(Equal(8)))
+	})
+
+	It("starts over once it is reopened", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl), diodes.WithStrictOrder())
+		set(d, 0, 3)
+		d.Close()
+		Expect(d.Reopen()).To(Succeed())
+
+		set(d, 3, 5)
+		Expect(readAll(d)).To(Equal([]int{3, 4}))
+	})
+
+	It("keeps every value of concurrent writers without collisions", func() {
+		const writers, perWriter = 8, 500
+		d := diodes.NewManyToOne(writers*perWriter, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithStrictOrder(),
+			diodes.WithInstrumentation(diodes.InstrumentationBasic),
+		)
+
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				set(d, w*perWriter, (w+1)*perWriter)
+			}(w)
+		}
+		wg.Wait()
+
+		values := readAll(d)
+		Expect(values).To(HaveLen(writers * perWriter))
+
+		last := make(map[int]int)
+		for _, v := range values {
+			w := v / perWriter
+			if prev, ok := last[w]; ok {
+				Expect(v).To(BeNumerically(">", prev))
+			}
+			last[w] = v
+		}
+		Expect(d.Instrumentation().Collisions).To(BeZero())
+		Expect(d.Stats().Drops).To(BeZero())
+	})
+})
@@ -0,0 +1,163 @@
+package diodes
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Supervisor owns the consumer of a diode. It reads the values of a Poller
+// or Waiter and processes them with a function. When the function panics,
+// the Supervisor recovers, reports the restart and resumes reading after a
+// backoff, so that one bad value does not end the consumption for good.
+type Supervisor struct {
+	restarts uint64
+
+	n         Nexter
+	fn        func(GenericDataType)
+	initial   time.Duration
+	max       time.Duration
+	onRestart func(Restart)
+	ctx       context.Context
+}
+
+// Restart describes a restart of the consumer of a Supervisor.
+type Restart struct {
+	// Count is the number of restarts so far, including this one.
+	Count uint64
+
+	// Data is the value whose processing panicked. It is not processed
+	// again.
+	Data GenericDataType
+
+	// Recovered is the value that was recovered from the panic.
+	Recovered interface{}
+
+	// Backoff is how long the Supervisor waits before it resumes reading.
+	Backoff time.Duration
+}
+
+// SupervisorOption can be used to setup the supervisor.
+type SupervisorOption func(*Supervisor)
+
+// WithRestartBackoff sets the wait before the first restart and the longest
+// wait. The wait doubles with every restart that follows a panic without a
+// value being processed in between. The default is 100ms up to 10s.
+func WithRestartBackoff(initial, maxWait time.Duration) SupervisorOption {
+	return SupervisorOption(func(s *Supervisor) {
+		s.initial = initial
+		s.max = maxWait
+	})
+}
+
+// WithRestartHandler sets the function that is invoked for every restart,
+// such as to log the panic or count it. It is invoked on the go-routine of
+// Run before the backoff.
+func WithRestartHandler(fn func(Restart)) SupervisorOption {
+	return SupervisorOption(func(s *Supervisor) {
+		s.onRestart = fn
+	})
+}
+
+// WithSupervisorContext sets the context that ends the backoff. Once it is
+// done, Run returns instead of restarting. Default is context.Background().
+func WithSupervisorContext(ctx context.Context) SupervisorOption {
+	return SupervisorOption(func(s *Supervisor) {
+		s.ctx = ctx
+	})
+}
+
+// NewSupervisor returns a new Supervisor that processes the values of the
+// Poller or Waiter with fn.
+func NewSupervisor(n Nexter, fn func(GenericDataType), opts ...SupervisorOption) *Supervisor {
+	s := &Supervisor{
+		n:         n,
+		fn:        fn,
+		initial:   100 * time.Millisecond,
+		max:       10 * time.Second,
+		onRestart: func(Restart) {},
+		ctx:       context.Background(),
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+// Run processes the values until the Poller or Waiter returns nil or the
+// context is done during a backoff. Run must only be invoked once as it is
+// the reader of the diode.
+func (s *Supervisor) Run() {
+	wait := s.initial
+	for {
+		processed, data, recovered, panicked := s.consume()
+		if !panicked {
+			return
+		}
+
+		if processed > 0 {
+			wait = s.initial
+		}
+
+		s.onRestart(Restart{
+			Count:     atomic.AddUint64(&s.restarts, 1),
+			Data:      data,
+			Recovered: recovered,
+			Backoff:   wait,
+		})
+
+		if !s.sleep(wait) {
+			return
+		}
+
+		wait *= 2
+		if wait > s.max {
+			wait = s.max
+		}
+	}
+}
+
+// consume processes the values until the Nexter returns nil or the function
+// panics. It returns how many values were processed and, for a panic, the
+// value and what was recovered.
+func (s *Supervisor) consume() (processed int, data GenericDataType, recovered interface{}, panicked bool) {
+	defer func() {
+		if panicked {
+			recovered = recover()
+		}
+	}()
+
+	for {
+		data = s.n.Next()
+		if data == nil {
+			return processed, nil, nil, false
+		}
+
+		panicked = true
+		s.fn(data)
+		panicked = false
+		processed++
+	}
+}
+
+// sleep waits for the given time and reports whether the context is still
+// not done.
+func (s *Supervisor) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Restarts returns the number of restarts. It is safe to call from any
+// go-routine.
+func (s *Supervisor) Restarts() uint64 {
+	return atomic.LoadUint64(&s.restarts)
+}
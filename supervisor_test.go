@@ -0,0 +1,101 @@
+package diodes_test
+
+import (
+	"context"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Supervisor", func() {
+	var (
+		w      *diodes.Waiter
+		cancel context.CancelFunc
+	)
+
+	BeforeEach(func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(16, nil), diodes.WithWaiterContext(ctx))
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			w.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("restarts the consumer after a panic", func() {
+		var (
+			processed []int
+			restarts  []diodes.Restart
+		)
+		s := diodes.NewSupervisor(w, func(data diodes.GenericDataType) {
+			v := *(*int)(data)
+			if v == 2 {
+				panic("bad payload")
+			}
+			processed = append(processed, v)
+		},
+			diodes.WithRestartBackoff(time.Millisecond, time.Millisecond),
+			diodes.WithRestartHandler(func(r diodes.Restart) {
+				restarts = append(restarts, r)
+			}),
+		)
+
+		set(1, 2, 3)
+		w.Close()
+		s.Run()
+
+		Expect(processed).To(Equal([]int{1, 3}))
+		Expect(restarts).To(HaveLen(1))
+		Expect(restarts[0].Count).To(Equal(uint64(1)))
+		Expect(*(*int)(restarts[0].Data)).To(Equal(2))
+		Expect(restarts[0].Recovered).To(Equal("bad payload"))
+		Expect(s.Restarts()).To(Equal(uint64(1)))
+	})
+
+	It("backs off exponentially while the consumer keeps panicking", func() {
+		var backoffs []time.Duration
+		s := diodes.NewSupervisor(w, func(diodes.GenericDataType) {
+			panic("bad payload")
+		},
+			diodes.WithRestartBackoff(time.Millisecond, 4*time.Millisecond),
+			diodes.WithRestartHandler(func(r diodes.Restart) {
+				backoffs = append(backoffs, r.Backoff)
+			}),
+		)
+
+		set(1, 2, 3, 4)
+		w.Close()
+		s.Run()
+
+		Expect(backoffs).To(Equal([]time.Duration{
+			time.Millisecond,
+			2 * time.Millisecond,
+			4 * time.Millisecond,
+			4 * time.Millisecond,
+		}))
+	})
+
+	It("stops once the context is done during the backoff", func() {
+		ctx, cancelBackoff := context.WithCancel(context.Background())
+		cancelBackoff()
+		s := diodes.NewSupervisor(w, func(diodes.GenericDataType) {
+			panic("bad payload")
+		}, diodes.WithSupervisorContext(ctx))
+
+		set(1, 2)
+		s.Run()
+
+		Expect(s.Restarts()).To(Equal(uint64(1)))
+	})
+})
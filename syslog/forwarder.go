@@ -0,0 +1,269 @@
+// Package syslog forwards the entries of a diode to a syslog server.
+package syslog
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Priority is the facility and severity of a syslog message.
+type Priority int
+
+// The facilities and severities used by default.
+const (
+	User    Priority = 1 << 3
+	Info    Priority = 6
+	Warning Priority = 4
+)
+
+// timestampFormat is the RFC5424 timestamp, which allows at most six digits
+// for the fraction of a second.
+const timestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Forwarder reads entries from a diode and ships them to a syslog server as
+// RFC5424 messages. Over stream connections (e.g. TCP) the messages are
+// framed by octet counting (RFC6587). When the server is unavailable, the
+// forwarder reconnects while the diode drops entries, so a flaky server
+// never blocks the writers of the diode. A server that stops reading only
+// holds up a write until the write timeout, or until the context is done.
+type Forwarder struct {
+	network string
+	addr    string
+	n       diodes.Nexter
+
+	priority Priority
+	hostname string
+	appName  string
+	procID   string
+	backoff  time.Duration
+	timeout  time.Duration
+	dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// mu guards conn, which is closed by Run's watcher once the context is
+	// done so that a blocked write returns.
+	mu   sync.Mutex
+	conn net.Conn
+	buf  []byte
+}
+
+// ForwarderOption can be used to setup the forwarder.
+type ForwarderOption func(*Forwarder)
+
+// WithPriority sets the facility and severity of the messages. The default
+// is User|Info.
+func WithPriority(p Priority) ForwarderOption {
+	return ForwarderOption(func(f *Forwarder) {
+		f.priority = p
+	})
+}
+
+// WithHostname sets the hostname of the messages. The default is the
+// hostname reported by the kernel.
+func WithHostname(hostname string) ForwarderOption {
+	return ForwarderOption(func(f *Forwarder) {
+		f.hostname = hostname
+	})
+}
+
+// WithAppName sets the app name of the messages. The default is the name of
+// the executable.
+func WithAppName(name string) ForwarderOption {
+	return ForwarderOption(func(f *Forwarder) {
+		f.appName = name
+	})
+}
+
+// WithReconnectBackoff sets the time to wait between attempts to connect to
+// the server. The default is 1s.
+func WithReconnectBackoff(d time.Duration) ForwarderOption {
+	return ForwarderOption(func(f *Forwarder) {
+		f.backoff = d
+	})
+}
+
+// WithWriteTimeout sets how long a write may take before the connection is
+// given up and the message is written again once the forwarder has
+// reconnected. The default is 10s. A timeout of 0 disables it.
+func WithWriteTimeout(d time.Duration) ForwarderOption {
+	return ForwarderOption(func(f *Forwarder) {
+		f.timeout = d
+	})
+}
+
+// NewForwarder returns a new Forwarder that reads entries from the given
+// Poller or Waiter. The values of the diode must be *[]byte, such as the ones
+// set by a diodes.Writer. The network and address are those of net.Dial.
+func NewForwarder(network, addr string, n diodes.Nexter, opts ...ForwarderOption) *Forwarder {
+	hostname, _ := os.Hostname()
+
+	f := &Forwarder{
+		network:  network,
+		addr:     addr,
+		n:        n,
+		priority: User | Info,
+		hostname: hostname,
+		appName:  appName(),
+		procID:   strconv.Itoa(os.Getpid()),
+		backoff:  time.Second,
+		timeout:  10 * time.Second,
+		dial:     new(net.Dialer).DialContext,
+	}
+
+	for _, o := range opts {
+		o(f)
+	}
+
+	return f
+}
+
+// Run forwards entries until the Poller or Waiter returns nil. An entry whose
+// write fails is written again once the forwarder has reconnected, until the
+// context is done. Run waits for entries in the Poller or Waiter, so for the
+// context to also stop an idle Run, the Poller or Waiter must be created with
+// the same context, such as with diodes.WithWaiterContext.
+func (f *Forwarder) Run(ctx context.Context) {
+	defer f.close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.mu.Lock()
+			if f.conn != nil {
+				f.conn.Close()
+			}
+			f.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	for {
+		data := f.n.Next()
+		if data == nil {
+			return
+		}
+
+		msg := f.format(*(*[]byte)(unsafe.Pointer(data)))
+		for !f.write(ctx, msg) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(f.backoff):
+			}
+		}
+	}
+}
+
+// write writes a message and reports whether it succeeded. It connects to
+// the server if there is no connection.
+func (f *Forwarder) write(ctx context.Context, msg []byte) bool {
+	if f.conn == nil {
+		conn, err := f.dial(ctx, f.network, f.addr)
+		if err != nil {
+			return false
+		}
+
+		f.mu.Lock()
+		f.conn = conn
+		f.mu.Unlock()
+
+		// The watcher may have missed the connection if the context was
+		// done while it was dialed.
+		if ctx.Err() != nil {
+			f.close()
+			return false
+		}
+	}
+
+	if f.timeout > 0 {
+		if err := f.conn.SetWriteDeadline(time.Now().Add(f.timeout)); err != nil {
+			f.close()
+			return false
+		}
+	}
+
+	if _, err := f.conn.Write(msg); err != nil {
+		f.close()
+		return false
+	}
+
+	return true
+}
+
+func (f *Forwarder) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+}
+
+// format renders an entry as an RFC5424 message without structured data.
+// Messages for stream connections are prefixed with their length.
+func (f *Forwarder) format(entry []byte) []byte {
+	msg := f.buf[:0]
+	msg = append(msg, '<')
+	msg = strconv.AppendInt(msg, int64(f.priority), 10)
+	msg = append(msg, ">1 "...)
+	msg = time.Now().AppendFormat(msg, timestampFormat)
+	msg = append(msg, ' ')
+	msg = appendField(msg, f.hostname)
+	msg = appendField(msg, f.appName)
+	msg = appendField(msg, f.procID)
+	msg = append(msg, "- - "...)
+
+	// Trailing newlines of log lines are not part of the message.
+	for len(entry) > 0 && entry[len(entry)-1] == '\n' {
+		entry = entry[:len(entry)-1]
+	}
+	msg = append(msg, entry...)
+	f.buf = msg
+
+	if !isStream(f.network) {
+		return msg
+	}
+
+	framed := strconv.AppendInt(make([]byte, 0, len(msg)+8), int64(len(msg)), 10)
+	framed = append(framed, ' ')
+	return append(framed, msg...)
+}
+
+// appendField appends a header field followed by a space. Empty fields are
+// rendered as the nil value "-".
+func appendField(b []byte, field string) []byte {
+	if field == "" {
+		field = "-"
+	}
+
+	b = append(b, field...)
+	return append(b, ' ')
+}
+
+func isStream(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return false
+	default:
+		return true
+	}
+}
+
+func appName() string {
+	name, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Base(name)
+}
@@ -0,0 +1,194 @@
+package syslog_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/syslog"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forwarder", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		w      *diodes.Waiter
+		writer *diodes.Writer
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(16, nil), diodes.WithWaiterContext(ctx))
+		writer = diodes.NewWriter(w)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	// readFrames reads octet counted messages from the connections accepted
+	// by the listener.
+	readFrames := func(l net.Listener) <-chan string {
+		frames := make(chan string, 16)
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					r := bufio.NewReader(conn)
+					for {
+						length, err := r.ReadString(' ')
+						if err != nil {
+							return
+						}
+						n, _ := strconv.Atoi(strings.TrimSpace(length))
+						msg := make([]byte, n)
+						if _, err := io.ReadFull(r, msg); err != nil {
+							return
+						}
+						frames <- string(msg)
+					}
+				}()
+			}
+		}()
+		return frames
+	}
+
+	It("forwards the entries as RFC5424 messages over TCP", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer l.Close()
+		frames := readFrames(l)
+
+		f := syslog.NewForwarder("tcp", l.Addr().String(), w,
+			syslog.WithPriority(syslog.User|syslog.Warning),
+			syslog.WithHostname("some-host"),
+			syslog.WithAppName("some-app"),
+		)
+		go f.Run(ctx)
+
+		_, _ = writer.Write([]byte("some message\n"))
+
+		var msg string
+		Eventually(frames).Should(Receive(&msg))
+		Expect(msg).To(MatchRegexp(`^<12>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}\S+ some-host some-app \d+ - - some message$`))
+	})
+
+	It("reconnects once the server is available", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		addr := l.Addr().String()
+		l.Close()
+
+		f := syslog.NewForwarder("tcp", addr, w, syslog.WithReconnectBackoff(time.Millisecond))
+		go f.Run(ctx)
+		_, _ = writer.Write([]byte("first"))
+		time.Sleep(10 * time.Millisecond)
+
+		l, err = net.Listen("tcp", addr)
+		Expect(err).ToNot(HaveOccurred())
+		defer l.Close()
+		frames := readFrames(l)
+
+		var msg string
+		Eventually(frames).Should(Receive(&msg))
+		Expect(msg).To(HaveSuffix(" - - first"))
+	})
+
+	It("sends unframed messages over UDP", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		f := syslog.NewForwarder("udp", conn.LocalAddr().String(), w, syslog.WithHostname(""))
+		go f.Run(ctx)
+		_, _ = writer.Write([]byte("some message"))
+
+		buf := make([]byte, 1024)
+		Expect(conn.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		n, _, err := conn.ReadFrom(buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(buf[:n])).To(MatchRegexp(`^<14>1 \S+ - \S+ \d+ - - some message$`))
+	})
+
+	// stalled accepts the connections of the listener and never reads from
+	// them, so that the writes block once the socket buffers are full.
+	stalled := func(l net.Listener) <-chan net.Conn {
+		conns := make(chan net.Conn, 16)
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				conns <- conn
+			}
+		}()
+		return conns
+	}
+
+	fill := func() {
+		entry := make([]byte, 4<<20)
+		for i := 0; i < 8; i++ {
+			_, _ = writer.Write(entry)
+		}
+	}
+
+	It("gives up a write to a server that does not read", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer l.Close()
+		conns := stalled(l)
+
+		f := syslog.NewForwarder("tcp", l.Addr().String(), w,
+			syslog.WithWriteTimeout(50*time.Millisecond),
+			syslog.WithReconnectBackoff(time.Millisecond),
+		)
+		go f.Run(ctx)
+		fill()
+
+		Eventually(conns).Should(HaveLen(2))
+	})
+
+	It("stops a blocked write once the context is done", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer l.Close()
+		conns := stalled(l)
+
+		f := syslog.NewForwarder("tcp", l.Addr().String(), w)
+		done := make(chan struct{})
+		go func() {
+			f.Run(ctx)
+			close(done)
+		}()
+		fill()
+		Eventually(conns).Should(HaveLen(1))
+		Consistently(done, 100*time.Millisecond).ShouldNot(BeClosed())
+
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("stops once the waiter's context is done", func() {
+		f := syslog.NewForwarder("tcp", "127.0.0.1:1", w)
+		done := make(chan struct{})
+		go func() {
+			f.Run(context.Background())
+			close(done)
+		}()
+		cancel()
+
+		Eventually(done).Should(BeClosed())
+	})
+})
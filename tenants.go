@@ -0,0 +1,147 @@
+package diodes
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Tenants wraps a diode that is shared by several tenants, such as the
+// sources of a multi-tenant agent, and accounts the writes and drops to the
+// tenant each value is tagged with. Every value is numbered in the sequence
+// of its tenant, so once the reader reads a value, the gap to the previous
+// value of the tenant tells how many of the tenant's values were dropped.
+// The drops of a tenant are therefore only counted once a later value of
+// it is read, like the drops of a diode are only counted by its reader.
+//
+// Like the diodes, a Tenants is meant to be read by a single go-routine.
+type Tenants struct {
+	d       Diode
+	tenants sync.Map // tenants maps the name of a tenant to its *tenant
+}
+
+type tenant struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	writes uint64
+	reads  uint64
+	drops  uint64
+	next   uint64 // next is the sequence the reader expects next
+
+	name string
+}
+
+// tenantValue is the value that is set on the wrapped diode.
+type tenantValue struct {
+	t    *tenant
+	seq  uint64
+	data GenericDataType
+}
+
+// NewTenants returns a new Tenants that wraps the given diode.
+func NewTenants(d Diode) *Tenants {
+	return &Tenants{d: d}
+}
+
+// Set sets the data for the tenant with the empty name, and makes Tenants a
+// Diode as well.
+func (t *Tenants) Set(data GenericDataType) {
+	t.SetTenant("", data)
+}
+
+// SetTenant sets the data tagged with the given tenant. It may be invoked by
+// several go-routines if the wrapped diode can.
+func (t *Tenants) SetTenant(name string, data GenericDataType) {
+	tt := t.tenant(name)
+	seq := atomic.AddUint64(&tt.writes, 1) - 1
+	t.d.Set(GenericDataType(&tenantValue{t: tt, seq: seq, data: data}))
+}
+
+// TryNext returns the next value of the wrapped diode. If there is no data
+// available, it will return (nil, false).
+func (t *Tenants) TryNext() (GenericDataType, bool) {
+	_, data, ok := t.TryNextTenant()
+	return data, ok
+}
+
+// TryNextTenant is like TryNext, and also returns the tenant the value is
+// tagged with.
+func (t *Tenants) TryNextTenant() (string, GenericDataType, bool) {
+	data, ok := t.d.TryNext()
+	if !ok {
+		return "", nil, false
+	}
+
+	v := (*tenantValue)(unsafe.Pointer(data))
+	tt := v.t
+	atomic.AddUint64(&tt.reads, 1)
+
+	switch {
+	case seqAfter(v.seq, tt.next):
+		atomic.AddUint64(&tt.drops, v.seq-tt.next)
+		tt.next = v.seq + 1
+	case v.seq == tt.next:
+		tt.next++
+	default:
+		// The writers of a tenant raced, so a value was read after a later
+		// one of the same tenant and it was counted as dropped already.
+		if atomic.LoadUint64(&tt.drops) > 0 {
+			atomic.AddUint64(&tt.drops, ^uint64(0))
+		}
+	}
+
+	return tt.name, v.data, true
+}
+
+// TenantStats are the counters of a single tenant of a Tenants.
+type TenantStats struct {
+	// Writes is the number of values that were set for the tenant.
+	Writes uint64
+
+	// Reads is the number of values of the tenant that were read.
+	Reads uint64
+
+	// Drops is the number of values of the tenant that were dropped.
+	Drops uint64
+}
+
+// TenantStats returns the counters of every tenant by name. It is safe to
+// call from any go-routine.
+func (t *Tenants) TenantStats() map[string]TenantStats {
+	stats := make(map[string]TenantStats)
+	t.tenants.Range(func(_, value interface{}) bool {
+		tt := value.(*tenant)
+		stats[tt.name] = TenantStats{
+			Writes: atomic.LoadUint64(&tt.writes),
+			Reads:  atomic.LoadUint64(&tt.reads),
+			Drops:  atomic.LoadUint64(&tt.drops),
+		}
+		return true
+	})
+
+	return stats
+}
+
+// Names returns the names of the tenants that set data, in order.
+func (t *Tenants) Names() []string {
+	var names []string
+	t.tenants.Range(func(key, _ interface{}) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	sort.Strings(names)
+
+	return names
+}
+
+// tenant returns the counters of the tenant, which are created on first
+// use.
+func (t *Tenants) tenant(name string) *tenant {
+	if v, ok := t.tenants.Load(name); ok {
+		return v.(*tenant)
+	}
+
+	v, _ := t.tenants.LoadOrStore(name, &tenant{name: name})
+	return v.(*tenant)
+}
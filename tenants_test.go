@@ -0,0 +1,87 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tenants", func() {
+	var t *diodes.Tenants
+
+	BeforeEach(func() {
+		t = diodes.NewTenants(diodes.NewOneToOne(4, nil))
+	})
+
+	set := func(name string, values ...int) {
+		for _, v := range values {
+			v := v
+			t.SetTenant(name, diodes.GenericDataType(&v))
+		}
+	}
+
+	type read struct {
+		name  string
+		value int
+	}
+
+	readAll := func() []read {
+		var values []read
+		for {
+			name, data, ok := t.TryNextTenant()
+			if !ok {
+				return values
+			}
+			values = append(values, read{name: name, value: *(*int)(data)})
+		}
+	}
+
+	It("returns the data with its tenant", func() {
+		set("a", 1)
+		set("b", 2)
+
+		Expect(readAll()).To(Equal([]read{{"a", 1}, {"b", 2}}))
+		Expect(t.TenantStats()).To(Equal(map[string]diodes.TenantStats{
+			"a": {Writes: 1, Reads: 1},
+			"b": {Writes: 1, Reads: 1},
+		}))
+		Expect(t.Names()).To(Equal([]string{"a", "b"}))
+	})
+
+	It("accounts the drops to the tenants whose values were dropped", func() {
+		set("quiet", 100)
+		set("chatty", 1, 2, 3, 4, 5, 6)
+		set("quiet", 101)
+
+		Expect(readAll()).To(Equal([]read{{"chatty", 4}, {"chatty", 5}, {"chatty", 6}, {"quiet", 101}}))
+
+		stats := t.TenantStats()
+		Expect(stats["chatty"]).To(Equal(diodes.TenantStats{Writes: 6, Reads: 3, Drops: 3}))
+		Expect(stats["quiet"]).To(Equal(diodes.TenantStats{Writes: 2, Reads: 1, Drops: 1}))
+	})
+
+	It("counts the drops of a tenant once a later value of it is read", func() {
+		set("a", 1, 2, 3, 4)
+		set("b", 10)
+		Expect(readAll()).To(Equal([]read{{"b", 10}}))
+		Expect(t.TenantStats()["a"].Drops).To(BeZero())
+
+		set("a", 5)
+		Expect(readAll()).To(Equal([]read{{"a", 5}}))
+		Expect(t.TenantStats()["a"].Drops).To(Equal(uint64(4)))
+	})
+
+	It("is a diode for the tenant with the empty name", func() {
+		v := 1
+		t.Set(diodes.GenericDataType(&v))
+
+		data, ok := t.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(1))
+		Expect(t.TenantStats()[""].Reads).To(Equal(uint64(1)))
+
+		_, ok = t.TryNext()
+		Expect(ok).To(BeFalse())
+	})
+})
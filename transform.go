@@ -0,0 +1,87 @@
+package diodes
+
+// Transform wraps a diode and applies a function to the values that pass
+// through it, such as to redact or enrich them. By default the function is
+// applied by Set, on the writer's go-routine. With WithTransformOnRead it is
+// applied by TryNext instead, so that the values that are dropped are never
+// transformed. Like any diode, a Transform can be wrapped by a Filter or
+// another Transform.
+type Transform struct {
+	Diode
+	fn     func(GenericDataType) GenericDataType
+	onRead bool
+	panics func(*CallbackPanic)
+}
+
+// TransformOption can be used to setup the transform.
+type TransformOption func(*Transform)
+
+// WithTransformOnRead applies the function when the values are read instead
+// of when they are set.
+func WithTransformOnRead() TransformOption {
+	return TransformOption(func(t *Transform) {
+		t.onRead = true
+	})
+}
+
+// WithTransformPanicHandler recovers the panics of the function and hands
+// them to fn. A value the function panicked on is dropped.
+func WithTransformPanicHandler(fn func(*CallbackPanic)) TransformOption {
+	return TransformOption(func(t *Transform) {
+		t.panics = fn
+	})
+}
+
+// NewTransform returns a new Transform that wraps the given diode and
+// applies fn to its values.
+func NewTransform(d Diode, fn func(GenericDataType) GenericDataType, opts ...TransformOption) *Transform {
+	t := &Transform{
+		Diode: d,
+		fn:    fn,
+	}
+
+	for _, o := range opts {
+		o(t)
+	}
+
+	return t
+}
+
+// Set sets the data on the wrapped diode, transformed unless the function
+// is applied on read.
+func (t *Transform) Set(data GenericDataType) {
+	if !t.onRead {
+		var ok bool
+		if data, ok = t.apply(data); !ok {
+			return
+		}
+	}
+
+	t.Diode.Set(data)
+}
+
+// TryNext returns the next value of the wrapped diode, transformed when the
+// function is applied on read. The values the function panicked on are
+// skipped.
+func (t *Transform) TryNext() (GenericDataType, bool) {
+	for {
+		data, ok := t.Diode.TryNext()
+		if !ok || !t.onRead {
+			return data, ok
+		}
+
+		if data, ok = t.apply(data); ok {
+			return data, true
+		}
+	}
+}
+
+// apply applies the function, recovering its panics if there is a panic
+// handler. It reports false if the function panicked.
+func (t *Transform) apply(data GenericDataType) (_ GenericDataType, ok bool) {
+	if t.panics != nil {
+		defer recoverCallback(t.panics, "Transform")
+	}
+
+	return t.fn(data), true
+}
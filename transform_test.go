@@ -0,0 +1,94 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transform", func() {
+	var (
+		d     *diodes.OneToOne
+		calls int
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(2, nil)
+		calls = 0
+	})
+
+	double := func(data diodes.GenericDataType) diodes.GenericDataType {
+		calls++
+		v := *(*int)(data) * 2
+		return diodes.GenericDataType(&v)
+	}
+
+	set := func(dd diodes.Diode, values ...int) {
+		for _, v := range values {
+			v := v
+			dd.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func(dd diodes.Diode) []int {
+		var values []int
+		for {
+			data, ok := dd.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, *(*int)(data))
+		}
+	}
+
+	It("transforms the values when they are set", func() {
+		t := diodes.NewTransform(d, double)
+		set(t, 1, 2)
+
+		Expect(calls).To(Equal(2))
+		Expect(readAll(d)).To(Equal([]int{2, 4}))
+	})
+
+	It("transforms the values when they are read", func() {
+		t := diodes.NewTransform(d, double, diodes.WithTransformOnRead())
+		set(t, 1, 2, 3, 4)
+
+		Expect(readAll(t)).To(Equal([]int{6, 8}))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("composes with a filter", func() {
+		odd := func(data diodes.GenericDataType) bool {
+			return *(*int)(data)%2 == 1
+		}
+		f := diodes.NewFilter(diodes.NewTransform(d, double), odd)
+		set(f, 1, 2, 3)
+
+		Expect(readAll(f)).To(Equal([]int{2, 6}))
+		Expect(f.Filtered()).To(Equal(uint64(1)))
+	})
+
+	It("drops the values the function panicked on", func() {
+		var panics []*diodes.CallbackPanic
+		explode := func(data diodes.GenericDataType) diodes.GenericDataType {
+			if *(*int)(data) == 2 {
+				panic("boom")
+			}
+			return double(data)
+		}
+		handler := diodes.WithTransformPanicHandler(func(p *diodes.CallbackPanic) {
+			panics = append(panics, p)
+		})
+
+		set(diodes.NewTransform(d, explode, handler), 1, 2)
+		Expect(readAll(d)).To(Equal([]int{2}))
+
+		t := diodes.NewTransform(d, explode, handler, diodes.WithTransformOnRead())
+		set(t, 2, 3)
+		Expect(readAll(t)).To(Equal([]int{6}))
+
+		Expect(panics).To(HaveLen(2))
+		Expect(panics[0].Callback).To(Equal("Transform"))
+	})
+})
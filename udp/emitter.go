@@ -0,0 +1,131 @@
+// Package udp emits the data of a diode as UDP or multicast datagrams, which
+// matches the fire-and-forget semantics of the diodes: neither a slow
+// receiver nor a lost datagram holds up the writers. Every datagram is a
+// frame of the framing package. The frames number the payloads of an
+// Emitter, including the ones it failed to send, so that a Receiver tells
+// from a gap how many were lost.
+package udp
+
+import (
+	"net"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+)
+
+// maxDatagramSize is the largest UDP payload over IPv4.
+const maxDatagramSize = 65507
+
+// Emitter reads from a diode and sends every value as a datagram to a UDP
+// address, which may be a multicast group.
+type Emitter struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	sent     uint64
+	failed   uint64
+	tooLarge uint64
+
+	n       diodes.Nexter
+	conn    net.Conn
+	maxSize int
+	seq     uint64
+	buf     []byte
+}
+
+// EmitterOption can be used to setup the emitter.
+type EmitterOption func(*Emitter)
+
+// WithMaxDatagramSize sets the size of the largest datagram, including its
+// header. Larger payloads are dropped. The default is 65507 bytes, the
+// largest UDP payload over IPv4; a size that fits the MTU of the network,
+// such as 1472 bytes for Ethernet, avoids fragmentation.
+func WithMaxDatagramSize(n int) EmitterOption {
+	return EmitterOption(func(e *Emitter) {
+		e.maxSize = n
+	})
+}
+
+// NewEmitter returns a new Emitter that reads from the given Poller or
+// Waiter and sends to the UDP address, such as "239.0.0.1:9999" for a
+// multicast group. The values of the diode must be *[]byte, such as the ones
+// set by a diodes.Writer.
+func NewEmitter(n diodes.Nexter, addr string, opts ...EmitterOption) (*Emitter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Emitter{
+		n:       n,
+		conn:    conn,
+		maxSize: maxDatagramSize,
+	}
+
+	for _, o := range opts {
+		o(e)
+	}
+
+	return e, nil
+}
+
+// Run sends the payloads until the Poller or Waiter returns nil and closes
+// the socket. It must only be invoked once as it is the reader of the diode.
+func (e *Emitter) Run() {
+	defer e.conn.Close()
+
+	for {
+		data := e.n.Next()
+		if data == nil {
+			return
+		}
+
+		e.send(*(*[]byte)(data))
+	}
+}
+
+func (e *Emitter) send(payload []byte) {
+	seq := e.seq
+	e.seq++
+
+	if framing.HeaderSize+len(payload) > e.maxSize {
+		atomic.AddUint64(&e.tooLarge, 1)
+		return
+	}
+
+	e.buf = framing.Append(e.buf[:0], seq, payload)
+
+	// A datagram that cannot be sent, such as while the network is down or
+	// the receiver refuses it, is lost like any other datagram.
+	if _, err := e.conn.Write(e.buf); err != nil {
+		atomic.AddUint64(&e.failed, 1)
+		return
+	}
+
+	atomic.AddUint64(&e.sent, 1)
+}
+
+// EmitterStats are the counters of an Emitter. The drops of the diode are
+// found in the diode's Stats.
+type EmitterStats struct {
+	// Sent is the number of datagrams that were sent. They may still be
+	// lost on the way.
+	Sent uint64
+
+	// Failed is the number of datagrams that could not be sent.
+	Failed uint64
+
+	// TooLarge is the number of payloads that were dropped as they do not
+	// fit into a datagram.
+	TooLarge uint64
+}
+
+// Stats returns a snapshot of the emitter's counters. It is safe to call from
+// any go-routine.
+func (e *Emitter) Stats() EmitterStats {
+	return EmitterStats{
+		Sent:     atomic.LoadUint64(&e.sent),
+		Failed:   atomic.LoadUint64(&e.failed),
+		TooLarge: atomic.LoadUint64(&e.tooLarge),
+	}
+}
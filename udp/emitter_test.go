@@ -0,0 +1,92 @@
+package udp_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+	"code.cloudfoundry.org/go-diodes/udp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Emitter", func() {
+	var (
+		cancel context.CancelFunc
+		w      *diodes.Waiter
+		writer *diodes.Writer
+		conn   net.PacketConn
+	)
+
+	BeforeEach(func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(64, nil), diodes.WithWaiterContext(ctx))
+		writer = diodes.NewWriter(w)
+
+		var err error
+		conn, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+		conn.Close()
+	})
+
+	read := func() (uint64, string) {
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		Expect(err).ToNot(HaveOccurred())
+		seq, payload, err := framing.Parse(buf[:n])
+		Expect(err).ToNot(HaveOccurred())
+		return seq, string(payload)
+	}
+
+	It("sends the payloads as numbered datagrams", func() {
+		e, err := udp.NewEmitter(w, conn.LocalAddr().String())
+		Expect(err).ToNot(HaveOccurred())
+		done := make(chan struct{})
+		go func() {
+			e.Run()
+			close(done)
+		}()
+
+		writer.Write([]byte("a"))
+		writer.Write([]byte("b"))
+
+		seq, payload := read()
+		Expect(seq).To(BeZero())
+		Expect(payload).To(Equal("a"))
+		seq, payload = read()
+		Expect(seq).To(Equal(uint64(1)))
+		Expect(payload).To(Equal("b"))
+		Expect(e.Stats()).To(Equal(udp.EmitterStats{Sent: 2}))
+
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("drops the payloads that do not fit into a datagram", func() {
+		e, err := udp.NewEmitter(w, conn.LocalAddr().String(), udp.WithMaxDatagramSize(framing.HeaderSize+2))
+		Expect(err).ToNot(HaveOccurred())
+		go e.Run()
+
+		writer.Write([]byte("too large"))
+		writer.Write([]byte("ab"))
+
+		seq, payload := read()
+		Expect(seq).To(Equal(uint64(1)))
+		Expect(payload).To(Equal("ab"))
+		Expect(e.Stats().TooLarge).To(Equal(uint64(1)))
+	})
+
+	It("fails for an invalid address", func() {
+		_, err := udp.NewEmitter(w, "not an address")
+		Expect(err).To(HaveOccurred())
+	})
+})
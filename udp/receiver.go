@@ -0,0 +1,172 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+)
+
+// Receiver reads the datagrams of Emitters and sets their payloads on a
+// diode. It tracks the sequence of every emitter by its address: a gap is
+// counted as missed and reported to the alerter, and a datagram that arrives
+// after a later one is dropped and counted as late, as it was reported as
+// missed already. A sequence that starts over at zero is taken to be
+// that of an emitter that restarted.
+type Receiver struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	received uint64
+	missed   uint64
+	late     uint64
+	corrupt  uint64
+
+	conn    net.PacketConn
+	d       diodes.Diode
+	alerter diodes.Alerter
+	next    map[string]uint64
+}
+
+// ReceiverOption can be used to setup the receiver.
+type ReceiverOption func(*Receiver)
+
+// WithAlerter sets the alerter that is invoked with the number of datagrams
+// that are missing from the sequence of an emitter. It is invoked on the
+// go-routine of Run.
+func WithAlerter(a diodes.Alerter) ReceiverOption {
+	return ReceiverOption(func(r *Receiver) {
+		r.alerter = a
+	})
+}
+
+// Listen listens on the UDP address and returns a Receiver that sets the
+// payloads on the given diode. For a multicast address, it joins the group
+// on the default interface.
+func Listen(addr string, d diodes.Diode, opts ...ReceiverOption) (*Receiver, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn *net.UDPConn
+	if udpAddr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", nil, udpAddr)
+	} else {
+		conn, err = net.ListenUDP("udp", udpAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReceiver(conn, d, opts...), nil
+}
+
+// NewReceiver returns a new Receiver that reads the given connection. The
+// payloads are set as *[]byte.
+func NewReceiver(conn net.PacketConn, d diodes.Diode, opts ...ReceiverOption) *Receiver {
+	r := &Receiver{
+		conn:    conn,
+		d:       d,
+		alerter: diodes.AlertFunc(func(int) {}),
+		next:    make(map[string]uint64),
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// Addr returns the local address of the receiver.
+func (r *Receiver) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Run reads datagrams until the receiver is closed, which makes it return
+// nil. It returns the error of the connection otherwise. It must only be
+// invoked once as it tracks the sequences of the emitters.
+func (r *Receiver) Run() error {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, from, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		// Datagrams that are not a frame, such as the ones that are not from
+		// an Emitter or were corrupted on the way, are dropped.
+		seq, payload, err := framing.Parse(buf[:n])
+		if err != nil {
+			atomic.AddUint64(&r.corrupt, 1)
+			continue
+		}
+
+		r.receive(from.String(), seq, payload)
+	}
+}
+
+func (r *Receiver) receive(from string, seq uint64, data []byte) {
+	// An emitter that restarted starts its sequence over.
+	next, known := r.next[from]
+	if seq == 0 {
+		known = false
+	}
+
+	switch {
+	case known && seq < next:
+		atomic.AddUint64(&r.late, 1)
+		return
+	case known && seq > next:
+		missed := seq - next
+		atomic.AddUint64(&r.missed, missed)
+		r.alerter.Alert(int(missed))
+	}
+	r.next[from] = seq + 1
+
+	payload := make([]byte, len(data))
+	copy(payload, data)
+	r.d.Set(diodes.GenericDataType(unsafe.Pointer(&payload)))
+	atomic.AddUint64(&r.received, 1)
+}
+
+// Close closes the connection, which makes Run return.
+func (r *Receiver) Close() error {
+	return r.conn.Close()
+}
+
+// ReceiverStats are the counters of a Receiver. The drops of the diode are
+// found in the diode's Stats.
+type ReceiverStats struct {
+	// Received is the number of payloads that were set on the diode.
+	Received uint64
+
+	// Missed is the number of datagrams that were missing from the
+	// sequences of the emitters.
+	Missed uint64
+
+	// Late is the number of datagrams that arrived after a later one and
+	// were dropped.
+	Late uint64
+
+	// Corrupt is the number of datagrams that were dropped as they were not
+	// a frame or failed its checksum.
+	Corrupt uint64
+}
+
+// Stats returns a snapshot of the receiver's counters. It is safe to call
+// from any go-routine.
+func (r *Receiver) Stats() ReceiverStats {
+	return ReceiverStats{
+		Received: atomic.LoadUint64(&r.received),
+		Missed:   atomic.LoadUint64(&r.missed),
+		Late:     atomic.LoadUint64(&r.late),
+		Corrupt:  atomic.LoadUint64(&r.corrupt),
+	}
+}
@@ -0,0 +1,127 @@
+package udp_test
+
+import (
+	"context"
+	"net"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+	"code.cloudfoundry.org/go-diodes/udp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Receiver", func() {
+	var (
+		d    *diodes.OneToOne
+		spy  *spyAlerter
+		r    *udp.Receiver
+		conn net.Conn
+	)
+
+	BeforeEach(func() {
+		d = diodes.NewOneToOne(64, nil)
+		spy = &spyAlerter{missed: make(chan int, 10)}
+
+		var err error
+		r, err = udp.Listen("127.0.0.1:0", d, udp.WithAlerter(spy))
+		Expect(err).ToNot(HaveOccurred())
+		go r.Run()
+
+		conn, err = net.Dial("udp", r.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		conn.Close()
+		r.Close()
+	})
+
+	send := func(seq uint64, payload string) {
+		conn.Write(framing.Append(nil, seq, []byte(payload)))
+	}
+
+	read := func() string {
+		var data diodes.GenericDataType
+		Eventually(func() bool {
+			var ok bool
+			data, ok = d.TryNext()
+			return ok
+		}).Should(BeTrue())
+		return string(*(*[]byte)(data))
+	}
+
+	It("sets the payloads on the diode", func() {
+		send(0, "a")
+		send(1, "b")
+
+		Expect(read()).To(Equal("a"))
+		Expect(read()).To(Equal("b"))
+		Expect(r.Stats()).To(Equal(udp.ReceiverStats{Received: 2}))
+	})
+
+	It("reports the gaps and drops the late datagrams", func() {
+		send(3, "a")
+		send(6, "b")
+		send(5, "late")
+		send(7, "c")
+
+		Expect(read()).To(Equal("a"))
+		Expect(read()).To(Equal("b"))
+		Expect(read()).To(Equal("c"))
+		Expect(spy.missed).To(Receive(Equal(2)))
+		Expect(r.Stats()).To(Equal(udp.ReceiverStats{Received: 3, Missed: 2, Late: 1}))
+	})
+
+	It("starts over for an emitter that restarted", func() {
+		send(5, "a")
+		send(0, "b")
+
+		Expect(read()).To(Equal("a"))
+		Expect(read()).To(Equal("b"))
+		Expect(r.Stats().Late).To(BeZero())
+	})
+
+	It("receives from an Emitter", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := diodes.NewWaiter(diodes.NewOneToOne(8, nil), diodes.WithWaiterContext(ctx))
+		e, err := udp.NewEmitter(w, r.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		go e.Run()
+
+		diodes.NewWriter(w).Write([]byte("a"))
+
+		Expect(read()).To(Equal("a"))
+	})
+
+	It("drops the datagrams that are not a frame or are corrupt", func() {
+		conn.Write([]byte("abc"))
+		b := framing.Append(nil, 0, []byte("x"))
+		b[len(b)-1] ^= 0xff
+		conn.Write(b)
+		send(0, "a")
+
+		Expect(read()).To(Equal("a"))
+		Expect(r.Stats()).To(Equal(udp.ReceiverStats{Received: 1, Corrupt: 2}))
+	})
+
+	It("returns from Run once it is closed", func() {
+		r2, err := udp.Listen("127.0.0.1:0", d)
+		Expect(err).ToNot(HaveOccurred())
+		done := make(chan error)
+		go func() { done <- r2.Run() }()
+
+		Expect(r2.Close()).To(Succeed())
+		Eventually(done).Should(Receive(BeNil()))
+	})
+})
+
+type spyAlerter struct {
+	missed chan int
+}
+
+func (s *spyAlerter) Alert(missed int) {
+	s.missed <- missed
+}
@@ -0,0 +1,13 @@
+package udp_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestUdp(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Udp Suite")
+}
@@ -0,0 +1,214 @@
+package uds
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+)
+
+// Receiver accepts the connections of Senders on a Unix domain socket and
+// sets the payloads it receives on a diode. A gap in the sequence numbers of
+// a connection is counted as missed and reported to the alerter, so the
+// payloads a Sender dropped after it connected are accounted for on this
+// side too.
+type Receiver struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	received uint64
+	missed   uint64
+	corrupt  uint64
+
+	l        net.Listener
+	d        diodes.Diode
+	alerter  diodes.Alerter
+	maxFrame int
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// ReceiverOption can be used to setup the receiver.
+type ReceiverOption func(*Receiver)
+
+// WithAlerter sets the alerter that is invoked with the number of payloads
+// that are missing from a connection. It is invoked on the go-routine of the
+// connection.
+func WithAlerter(a diodes.Alerter) ReceiverOption {
+	return ReceiverOption(func(r *Receiver) {
+		r.alerter = a
+	})
+}
+
+// WithMaxFrameSize sets the size of the largest payload the receiver
+// accepts. A connection that sends a larger one is closed. The default is
+// 1 MiB.
+func WithMaxFrameSize(n int) ReceiverOption {
+	return ReceiverOption(func(r *Receiver) {
+		r.maxFrame = n
+	})
+}
+
+// Listen listens on the socket at the given path and returns a Receiver that
+// sets the payloads on the given diode. A socket file that was left behind
+// by an earlier process is removed first.
+func Listen(path string, d diodes.Diode, opts ...ReceiverOption) (*Receiver, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReceiver(l, d, opts...), nil
+}
+
+// NewReceiver returns a new Receiver that accepts the connections of the
+// given listener. The payloads are set as *[]byte. With more than one
+// Sender, the diode is set by several go-routines and must be a
+// diodes.ManyToOne.
+func NewReceiver(l net.Listener, d diodes.Diode, opts ...ReceiverOption) *Receiver {
+	r := &Receiver{
+		l:        l,
+		d:        d,
+		alerter:  diodes.AlertFunc(func(int) {}),
+		maxFrame: 1 << 20,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// Serve accepts connections until the receiver is closed, which makes it
+// return nil. It returns the error of the listener otherwise.
+func (r *Receiver) Serve() error {
+	for {
+		conn, err := r.l.Accept()
+		if err != nil {
+			if r.isClosed() || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		if !r.track(conn) {
+			conn.Close()
+			return nil
+		}
+
+		go r.receive(conn)
+	}
+}
+
+// receive sets the payloads of the connection on the diode until the
+// connection is closed or sends a corrupt frame. The stream cannot be
+// trusted past a corrupt frame, so the connection is closed.
+func (r *Receiver) receive(conn net.Conn) {
+	defer r.untrack(conn)
+
+	var next uint64
+	first := true
+	for {
+		seq, payload, err := framing.Read(conn, r.maxFrame)
+		if err != nil {
+			if err == framing.ErrTooLarge || err == framing.ErrChecksum {
+				atomic.AddUint64(&r.corrupt, 1)
+			}
+			return
+		}
+
+		if !first && seq > next {
+			missed := seq - next
+			atomic.AddUint64(&r.missed, missed)
+			r.alerter.Alert(int(missed))
+		}
+		first = false
+		next = seq + 1
+
+		r.d.Set(diodes.GenericDataType(unsafe.Pointer(&payload)))
+		atomic.AddUint64(&r.received, 1)
+	}
+}
+
+func (r *Receiver) track(conn net.Conn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return false
+	}
+
+	r.conns[conn] = struct{}{}
+	r.wg.Add(1)
+	return true
+}
+
+func (r *Receiver) untrack(conn net.Conn) {
+	r.mu.Lock()
+	delete(r.conns, conn)
+	r.mu.Unlock()
+
+	conn.Close()
+	r.wg.Done()
+}
+
+func (r *Receiver) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closed
+}
+
+// Close closes the listener and the connections and waits for their
+// go-routines to return.
+func (r *Receiver) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	err := r.l.Close()
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return err
+}
+
+// ReceiverStats is a snapshot of the statistics of a Receiver.
+type ReceiverStats struct {
+	// Received is the number of payloads that were set on the diode.
+	Received uint64
+
+	// Missed is the number of payloads that were missing from the
+	// sequences of the connections.
+	Missed uint64
+
+	// Corrupt is the number of connections that were closed as they sent a
+	// frame larger than the maximum frame size or one that failed its
+	// checksum.
+	Corrupt uint64
+}
+
+// Stats returns a snapshot of the receiver's statistics. The drops of the
+// diode it sets are reported by the diode. It is safe to call from any
+// go-routine.
+func (r *Receiver) Stats() ReceiverStats {
+	return ReceiverStats{
+		Received: atomic.LoadUint64(&r.received),
+		Missed:   atomic.LoadUint64(&r.missed),
+		Corrupt:  atomic.LoadUint64(&r.corrupt),
+	}
+}
@@ -0,0 +1,141 @@
+package uds_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+	"code.cloudfoundry.org/go-diodes/uds"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Receiver", func() {
+	var (
+		dir  string
+		path string
+		d    *diodes.ManyToOne
+		spy  *spyAlerter
+		r    *uds.Receiver
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "uds")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "sock")
+
+		d = diodes.NewManyToOne(64, nil)
+		spy = &spyAlerter{missed: make(chan int, 10)}
+		r, err = uds.Listen(path, d, uds.WithAlerter(spy), uds.WithMaxFrameSize(8))
+		Expect(err).ToNot(HaveOccurred())
+		go r.Serve()
+	})
+
+	AfterEach(func() {
+		r.Close()
+		os.RemoveAll(dir)
+	})
+
+	frame := func(seq uint64, payload string) []byte {
+		return framing.Append(nil, seq, []byte(payload))
+	}
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("unix", path)
+		Expect(err).ToNot(HaveOccurred())
+		return conn
+	}
+
+	read := func() string {
+		var data diodes.GenericDataType
+		Eventually(func() bool {
+			var ok bool
+			data, ok = d.TryNext()
+			return ok
+		}).Should(BeTrue())
+		return string(*(*[]byte)(data))
+	}
+
+	It("sets the payloads on the diode", func() {
+		conn := dial()
+		defer conn.Close()
+
+		conn.Write(frame(0, "a"))
+		conn.Write(frame(1, "b"))
+
+		Expect(read()).To(Equal("a"))
+		Expect(read()).To(Equal("b"))
+		Expect(r.Stats()).To(Equal(uds.ReceiverStats{Received: 2}))
+	})
+
+	It("reports the gaps in the sequence of a connection", func() {
+		conn := dial()
+		defer conn.Close()
+
+		conn.Write(frame(5, "a"))
+		conn.Write(frame(8, "b"))
+
+		Expect(read()).To(Equal("a"))
+		Expect(read()).To(Equal("b"))
+		Expect(spy.missed).To(Receive(Equal(2)))
+		Expect(r.Stats().Missed).To(Equal(uint64(2)))
+	})
+
+	It("closes a connection that sends a frame that is too large", func() {
+		conn := dial()
+		defer conn.Close()
+
+		conn.Write(frame(0, "too large"))
+
+		Eventually(func() uint64 { return r.Stats().Corrupt }).Should(Equal(uint64(1)))
+		_, err := conn.Read(make([]byte, 1))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("closes a connection that sends a corrupt frame", func() {
+		conn := dial()
+		defer conn.Close()
+
+		b := frame(0, "a")
+		b[len(b)-1] ^= 0xff
+		conn.Write(b)
+
+		Eventually(func() uint64 { return r.Stats().Corrupt }).Should(Equal(uint64(1)))
+		_, err := conn.Read(make([]byte, 1))
+		Expect(err).To(HaveOccurred())
+		Expect(r.Stats().Received).To(BeZero())
+	})
+
+	It("removes a socket file that was left behind", func() {
+		Expect(r.Close()).To(Succeed())
+		Expect(os.WriteFile(path, nil, 0o600)).To(Succeed())
+
+		var err error
+		r, err = uds.Listen(path, d)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("closes the connections once it is closed", func() {
+		conn := dial()
+		defer conn.Close()
+		conn.Write(frame(0, "a"))
+		Expect(read()).To(Equal("a"))
+
+		Expect(r.Close()).To(Succeed())
+
+		_, err := conn.Read(make([]byte, 1))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+type spyAlerter struct {
+	missed chan int
+}
+
+func (s *spyAlerter) Alert(missed int) {
+	s.missed <- missed
+}
@@ -0,0 +1,160 @@
+// Package uds bridges diodes in different processes over a Unix domain
+// socket: a Sender drains a diode into the socket and a Receiver sets what
+// it receives on a diode, so that a producer and its shipper can run as
+// separate processes. The payloads are sent as the frames of the framing
+// package.
+package uds
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/framing"
+)
+
+// Sender drains a diode into a Unix domain socket. While the socket is
+// unavailable, the payloads are dropped instead of piling up, and the
+// Sender dials again every reconnect interval.
+type Sender struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	sent    uint64
+	dropped uint64
+
+	n            diodes.Nexter
+	path         string
+	seq          uint64
+	reconnect    time.Duration
+	writeTimeout time.Duration
+	conn         net.Conn
+	next         time.Time
+	buf          []byte
+}
+
+// SenderOption can be used to setup the sender.
+type SenderOption func(*Sender)
+
+// WithReconnectInterval sets how long the sender waits before it dials
+// again once dialing or writing failed. The default is one second.
+func WithReconnectInterval(interval time.Duration) SenderOption {
+	return SenderOption(func(s *Sender) {
+		s.reconnect = interval
+	})
+}
+
+// WithWriteTimeout sets the write deadline of each frame. A receiver that
+// does not read for that long fails the connection, and the sender
+// reconnects. By default there is no deadline.
+func WithWriteTimeout(d time.Duration) SenderOption {
+	return SenderOption(func(s *Sender) {
+		s.writeTimeout = d
+	})
+}
+
+// NewSender returns a new Sender that reads from the given Poller or Waiter
+// and writes to the socket at the given path. The values of the diode must
+// be *[]byte, such as the ones set by a diodes.Writer.
+func NewSender(n diodes.Nexter, path string, opts ...SenderOption) *Sender {
+	s := &Sender{
+		n:         n,
+		path:      path,
+		reconnect: time.Second,
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+// Run sends the payloads until the Poller or Waiter returns nil and closes
+// the connection. It must only be invoked once as it is the reader of the
+// diode.
+func (s *Sender) Run() {
+	for {
+		data := s.n.Next()
+		if data == nil {
+			s.disconnect()
+			return
+		}
+
+		s.send(*(*[]byte)(data))
+	}
+}
+
+// send writes the frame of the payload, or drops it when there is no
+// connection.
+func (s *Sender) send(payload []byte) {
+	seq := s.seq
+	s.seq++
+
+	if !s.connect() {
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+
+	if s.writeTimeout > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	}
+
+	s.buf = framing.Append(s.buf[:0], seq, payload)
+	if _, err := s.conn.Write(s.buf); err != nil {
+		s.disconnect()
+		s.next = time.Now().Add(s.reconnect)
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+
+	atomic.AddUint64(&s.sent, 1)
+}
+
+// connect dials the socket unless it is connected already or it is not time
+// to dial again yet. It reports whether there is a connection.
+func (s *Sender) connect() bool {
+	if s.conn != nil {
+		return true
+	}
+
+	if time.Now().Before(s.next) {
+		return false
+	}
+
+	conn, err := net.Dial("unix", s.path)
+	if err != nil {
+		s.next = time.Now().Add(s.reconnect)
+		return false
+	}
+
+	s.conn = conn
+	return true
+}
+
+func (s *Sender) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// SenderStats is a snapshot of the statistics of a Sender.
+type SenderStats struct {
+	// Sent is the number of payloads that were written to the socket.
+	Sent uint64
+
+	// Dropped is the number of payloads that were dropped as there was no
+	// connection or the write failed.
+	Dropped uint64
+}
+
+// Stats returns a snapshot of the sender's statistics. The drops of the
+// diode it reads are reported by the diode. It is safe to call from any
+// go-routine.
+func (s *Sender) Stats() SenderStats {
+	return SenderStats{
+		Sent:    atomic.LoadUint64(&s.sent),
+		Dropped: atomic.LoadUint64(&s.dropped),
+	}
+}
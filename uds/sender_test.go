@@ -0,0 +1,122 @@
+package uds_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/uds"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sender", func() {
+	var (
+		dir    string
+		path   string
+		cancel context.CancelFunc
+		d      *diodes.ManyToOne
+		w      *diodes.Waiter
+		writer *diodes.Writer
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "uds")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "sock")
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		d = diodes.NewManyToOne(64, nil)
+		w = diodes.NewWaiter(d, diodes.WithWaiterContext(ctx))
+		writer = diodes.NewWriter(w)
+	})
+
+	AfterEach(func() {
+		cancel()
+		os.RemoveAll(dir)
+	})
+
+	listen := func() (*uds.Receiver, *diodes.Poller) {
+		out := diodes.NewManyToOne(64, nil)
+		r, err := uds.Listen(path, out)
+		Expect(err).ToNot(HaveOccurred())
+		go r.Serve()
+		return r, diodes.NewPoller(out, diodes.WithPollingInterval(time.Millisecond))
+	}
+
+	receive := func(p *diodes.Poller) []string {
+		var payloads []string
+		for {
+			data, ok := p.TryNext()
+			if !ok {
+				return payloads
+			}
+			payloads = append(payloads, string(*(*[]byte)(data)))
+		}
+	}
+
+	It("sends the payloads to the receiver in order", func() {
+		r, out := listen()
+		defer r.Close()
+
+		s := uds.NewSender(w, path)
+		done := make(chan struct{})
+		go func() {
+			s.Run()
+			close(done)
+		}()
+
+		writer.Write([]byte("a"))
+		writer.Write([]byte("b"))
+
+		var received []string
+		Eventually(func() []string {
+			received = append(received, receive(out)...)
+			return received
+		}).Should(Equal([]string{"a", "b"}))
+		Expect(s.Stats()).To(Equal(uds.SenderStats{Sent: 2}))
+
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("drops the payloads while there is no receiver and reconnects", func() {
+		s := uds.NewSender(w, path, uds.WithReconnectInterval(10*time.Millisecond))
+		go s.Run()
+
+		writer.Write([]byte("lost"))
+		Eventually(s.Stats).Should(Equal(uds.SenderStats{Dropped: 1}))
+
+		r, out := listen()
+		defer r.Close()
+		time.Sleep(20 * time.Millisecond)
+
+		writer.Write([]byte("a"))
+		Eventually(func() []string { return receive(out) }).Should(Equal([]string{"a"}))
+		Expect(s.Stats()).To(Equal(uds.SenderStats{Sent: 1, Dropped: 1}))
+	})
+
+	It("reconnects once the receiver restarted", func() {
+		r, out := listen()
+
+		s := uds.NewSender(w, path, uds.WithReconnectInterval(10*time.Millisecond))
+		go s.Run()
+
+		writer.Write([]byte("a"))
+		Eventually(func() []string { return receive(out) }).Should(Equal([]string{"a"}))
+		Expect(r.Close()).To(Succeed())
+
+		r, out = listen()
+		defer r.Close()
+		Eventually(func() []string {
+			writer.Write([]byte("b"))
+			return receive(out)
+		}).ShouldNot(BeEmpty())
+		Expect(s.Stats().Dropped).ToNot(BeZero())
+	})
+})
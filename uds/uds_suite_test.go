@@ -0,0 +1,13 @@
+package uds_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestUds(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Uds Suite")
+}
@@ -3,11 +3,16 @@ package diodes
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 )
 
 // Waiter will use a conditional mutex to alert the reader to when data is
 // available.
 type Waiter struct {
+	// waiting counts the readers in Next, so that Set only takes the mutex
+	// to wake them up when there are any.
+	waiting int32
+
 	Diode
 	mu  sync.Mutex
 	c   *sync.Cond
@@ -37,28 +42,87 @@ func NewWaiter(d Diode, opts ...WaiterConfigOption) *Waiter {
 		opt(w)
 	}
 
+	// A diode that is bound to a context is closed once it is done, which
+	// must happen before the readers are woken up.
+	var closing <-chan struct{}
+	b, ok := d.(bound)
+	if ok && b.boundContext() != nil {
+		closing = b.boundContext().Done()
+	}
+
 	go func() {
-		<-w.ctx.Done()
-		w.c.Broadcast()
+		select {
+		case <-w.ctx.Done():
+		case <-closing:
+			b.Close()
+		}
+		w.broadcast()
 	}()
 
 	return w
 }
 
 // Set invokes the wrapped diode's Set with the given data and uses Broadcast
-// to wake up any readers.
+// to wake up any readers. While a reader is in Next, Set briefly takes the
+// mutex the reader waits with so that the wake up cannot be missed, and so
+// contends with the reader and the other writers. While there is no reader,
+// Set does not take it.
 func (w *Waiter) Set(data GenericDataType) {
 	w.Diode.Set(data)
+
+	// A reader counts itself as waiting before its TryNext, so either it
+	// reads the data or the writer sees it waiting.
+	if atomic.LoadInt32(&w.waiting) > 0 {
+		w.broadcast()
+	}
+}
+
+// broadcast wakes up any readers. Acquiring the mutex before broadcasting
+// pairs with the reader, which holds it from its failed TryNext until it is
+// parked in Wait. Without it the broadcast could happen in between the two
+// and the reader would miss the wake up.
+func (w *Waiter) broadcast() {
+	w.mu.Lock()
+	w.mu.Unlock()
 	w.c.Broadcast()
 }
 
+// Close closes the wrapped diode and wakes up any readers. It returns
+// ErrNotCloser if the diode is not a Closer. The diode must be closed through
+// the Waiter, otherwise a waiting reader is not woken up.
+func (w *Waiter) Close() error {
+	c, ok := w.Diode.(Closer)
+	if !ok {
+		return ErrNotCloser
+	}
+
+	err := c.Close()
+	w.broadcast()
+	return err
+}
+
+// Drain waits until the wrapped diode is drained. It returns ErrNotDrainer
+// if the diode is not a Drainer.
+func (w *Waiter) Drain(ctx context.Context) error {
+	return drain(ctx, w.Diode)
+}
+
+// Closed reports whether the wrapped diode is closed.
+func (w *Waiter) Closed() bool {
+	return closed(w.Diode)
+}
+
 // Next returns the next data point on the wrapped diode. If there is not any
 // new data, it will Wait for set to be called or the context to be done.
-// If the context is done, then nil will be returned.
+// If the context is done, or the diode is closed and empty, then nil will be
+// returned.
 func (w *Waiter) Next() GenericDataType {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	atomic.AddInt32(&w.waiting, 1)
+	defer atomic.AddInt32(&w.waiting, -1)
+
 	for {
 		data, ok := w.Diode.TryNext()
 		if !ok {
@@ -66,6 +130,16 @@ func (w *Waiter) Next() GenericDataType {
 				return nil
 			}
 
+			if closed(w.Diode) {
+				// The data that was set before Close might have
+				// landed after the failed read.
+				data, ok = w.Diode.TryNext()
+				if !ok {
+					return nil
+				}
+				return data
+			}
+
 			w.c.Wait()
 			continue
 		}
@@ -2,6 +2,8 @@ package diodes_test
 
 import (
 	"context"
+	"runtime"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/go-diodes"
@@ -38,6 +40,55 @@ var _ = Describe("Waiter", func() {
 		Expect(*(*[]byte)(w.Next())).To(Equal([]byte("a")))
 	})
 
+	It("does not miss wake ups", func() {
+		w = diodes.NewWaiter(diodes.NewOneToOne(1024, nil))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 1000; i++ {
+				w.Next()
+			}
+		}()
+
+		for i := 0; i < 1000; i++ {
+			data := []byte("a")
+			w.Set(diodes.GenericDataType(&data))
+			runtime.Gosched()
+		}
+
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("does not miss wake ups of many writers", func() {
+		const writers, writes = 8, 2000
+		w = diodes.NewWaiter(diodes.NewManyToOne(writers*writes, nil))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < writers*writes; i++ {
+				w.Next()
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < writes; j++ {
+					data := []byte("a")
+					w.Set(diodes.GenericDataType(&data))
+					if j%64 == 0 {
+						runtime.Gosched()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		Eventually(done, 5*time.Second).Should(BeClosed())
+	})
+
 	It("cancels Next() with context", func() {
 		ctx, cancel := context.WithCancel(context.Background())
 		w = diodes.NewWaiter(spy, diodes.WithWaiterContext(ctx))
@@ -61,4 +112,59 @@ var _ = Describe("Waiter", func() {
 
 		Expect(w.Next() == nil).To(BeTrue())
 	})
+
+	It("returns nil once the closed diode is drained", func() {
+		w = diodes.NewWaiter(diodes.NewOneToOne(4, nil))
+		data := []byte("a")
+		w.Set(diodes.GenericDataType(&data))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(*(*[]byte)(w.Next())).To(Equal([]byte("a")))
+		Expect(w.Next() == nil).To(BeTrue())
+		Expect(w.Closed()).To(BeTrue())
+	})
+
+	It("wakes up the current Next() on Close", func() {
+		w = diodes.NewWaiter(diodes.NewOneToOne(4, nil))
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			w.Close()
+		}()
+
+		Expect(w.Next() == nil).To(BeTrue())
+	})
+
+	It("does not close a diode that is not a Closer", func() {
+		Expect(w.Close()).To(MatchError(diodes.ErrNotCloser))
+	})
+
+	It("drains the wrapped diode", func() {
+		d := diodes.NewOneToOne(4, nil)
+		w = diodes.NewWaiter(d)
+		data := []byte("a")
+		w.Set(diodes.GenericDataType(&data))
+		go w.Next()
+
+		Expect(w.Drain(context.Background())).To(Succeed())
+		Expect(d.Lag()).To(BeZero())
+	})
+
+	It("does not drain a diode that is not a Drainer", func() {
+		Expect(w.Drain(context.Background())).To(MatchError(diodes.ErrNotDrainer))
+	})
+
+	It("drains and wakes up Next() once the context of the diode is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewManyToOne(4, nil, diodes.WithContext(ctx)))
+		data := []byte("a")
+		w.Set(diodes.GenericDataType(&data))
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+		}()
+
+		Expect(*(*[]byte)(w.Next())).To(Equal([]byte("a")))
+		Expect(w.Next() == nil).To(BeTrue())
+		Expect(w.Closed()).To(BeTrue())
+	})
 })
@@ -0,0 +1,583 @@
+// Package wal provides a diode that appends every value to a write-ahead log
+// before it is set, so that the values that were not consumed survive a
+// crash and are replayed when the log is opened again.
+package wal
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Diode wraps a diode and logs every value before it is set on it. The
+// reader checkpoints the sequence number of the values it consumed, and the
+// log is truncated once everything in it was consumed.
+//
+// The values the wrapped diode drops are considered consumed and are not
+// replayed. A log is meant to be used by a single Diode at a time.
+type Diode struct {
+	// The 64-bit fields must stay first so that they are aligned on 32-bit
+	// platforms.
+	nextSeq         uint64
+	readSeq         uint64
+	checkpointSeq   uint64
+	reads           uint64
+	appended        uint64
+	failed          uint64
+	expired         uint64
+	syncEvery       uint64
+	checkpointEvery uint64
+	maxBytes        int64
+
+	maxAge            time.Duration
+	retentionInterval time.Duration
+	stop              chan struct{}
+	done              chan struct{}
+
+	d              diodes.Diode
+	codec          diodes.Codec
+	compressor     diodes.Compressor
+	cipher         diodes.Cipher
+	checkpointPath string
+	recovery       Recovery
+	pending        *record
+
+	mu         sync.Mutex
+	l          *log
+	compressed []byte
+	sealed     []byte
+	err        error
+
+	checkpointMu sync.Mutex
+}
+
+// record is the value set on the wrapped diode. It carries the sequence
+// number of the value so that the reader can checkpoint it.
+type record struct {
+	seq  uint64
+	data diodes.GenericDataType
+}
+
+// Recovery describes what Open found in an existing log.
+type Recovery struct {
+	// Replayed is the number of values that were not consumed before the
+	// log was closed and were set on the diode again.
+	Replayed uint64
+
+	// Skipped is the number of values that could not be decrypted,
+	// decompressed or unmarshaled.
+	Skipped uint64
+
+	// Truncated reports whether the log ended with a record that was cut
+	// short or failed its checksum, such as one that was being written
+	// during a crash. It and anything after it were removed.
+	Truncated bool
+
+	// FirstSeq and LastSeq are the sequence numbers of the first and the
+	// last replayed value. They are zero when nothing was replayed.
+	FirstSeq uint64
+	LastSeq  uint64
+}
+
+// Option can be used to setup the diode.
+type Option func(*Diode)
+
+// WithSyncEvery flushes the log to stable storage after every n values. With
+// 1, a value that was acknowledged by Append survives a crash of the
+// machine. By default the log is only flushed by Close, so the values
+// survive a crash of the process but not necessarily of the machine.
+func WithSyncEvery(n int) Option {
+	return Option(func(d *Diode) {
+		d.syncEvery = uint64(n)
+	})
+}
+
+// WithCheckpointEvery sets after how many reads the reader checkpoints. The
+// values read since the last checkpoint are replayed after a crash, so
+// delivery is at least once. The default is 100. With 0, only Checkpoint
+// and Close checkpoint.
+func WithCheckpointEvery(n int) Option {
+	return Option(func(d *Diode) {
+		d.checkpointEvery = uint64(n)
+	})
+}
+
+// WithMaxBytes bounds the size of the log. Once the log grows beyond it, the
+// oldest values are removed from it even though they were not consumed yet.
+// By default the log grows until the values are consumed.
+func WithMaxBytes(n int64) Option {
+	return Option(func(d *Diode) {
+		d.maxBytes = n
+	})
+}
+
+// WithMaxAge removes the values that are older than the given age from the
+// log even though they were not consumed yet. By default values are kept
+// until they are consumed.
+func WithMaxAge(age time.Duration) Option {
+	return Option(func(d *Diode) {
+		d.maxAge = age
+	})
+}
+
+// WithRetentionInterval sets how often WithMaxBytes and WithMaxAge are
+// enforced in the background. The default is one minute.
+func WithRetentionInterval(interval time.Duration) Option {
+	return Option(func(d *Diode) {
+		d.retentionInterval = interval
+	})
+}
+
+// WithCompressor compresses the values in the log. Compressed values are
+// only replayed by a Diode with a Compressor.
+func WithCompressor(c diodes.Compressor) Option {
+	return Option(func(d *Diode) {
+		d.compressor = c
+	})
+}
+
+// WithCipher encrypts the values in the log. Encrypted values are only
+// replayed by a Diode with a Cipher.
+func WithCipher(c diodes.Cipher) Option {
+	return Option(func(d *Diode) {
+		d.cipher = c
+	})
+}
+
+// Open opens or creates the log at the given path and returns a Diode that
+// wraps the given diode, which must be empty. The checkpoint is kept in a
+// file next to the log with a ".checkpoint" suffix. The values of an
+// existing log that were not consumed are replayed into the diode before
+// Open returns. A diode that is smaller than the replayed values drops the
+// oldest of them as usual. The codec converts the values to and from the
+// bytes of the log.
+func Open(path string, d diodes.Diode, c diodes.Codec, opts ...Option) (*Diode, error) {
+	w := &Diode{
+		d:                 d,
+		codec:             c,
+		checkpointPath:    path + ".checkpoint",
+		checkpointEvery:   100,
+		retentionInterval: time.Minute,
+	}
+
+	for _, o := range opts {
+		o(w)
+	}
+
+	from, err := readCheckpoint(w.checkpointPath)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.replay(l, from); err != nil {
+		l.f.Close()
+		return nil, err
+	}
+	w.l = l
+
+	if w.maxBytes > 0 || w.maxAge > 0 {
+		w.stop = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.retain()
+	}
+
+	return w, nil
+}
+
+// replay sets the values of the log that come after the checkpoint on the
+// wrapped diode.
+func (d *Diode) replay(l *log, from uint64) error {
+	d.nextSeq = from
+	d.readSeq = from
+	d.checkpointSeq = from
+
+	truncated, err := l.scan(func(seq uint64, _ int64, payload []byte, flags uint32) {
+		if seq >= d.nextSeq {
+			d.nextSeq = seq + 1
+		}
+		if seq < from {
+			return
+		}
+
+		data, err := d.decode(payload, flags)
+		if err != nil {
+			d.recovery.Skipped++
+			return
+		}
+
+		d.d.Set(diodes.GenericDataType(&record{seq: seq, data: data}))
+		if d.recovery.Replayed == 0 {
+			d.recovery.FirstSeq = seq
+		}
+		d.recovery.LastSeq = seq
+		d.recovery.Replayed++
+	})
+	if err != nil {
+		return err
+	}
+	d.recovery.Truncated = truncated
+
+	// Skipped values are kept for a Diode that can unmarshal them, until the
+	// reader checkpoints past them.
+	if d.recovery.Replayed == 0 && d.recovery.Skipped == 0 {
+		return l.truncate(logHeaderSize)
+	}
+
+	return nil
+}
+
+// encode marshals, compresses and encrypts the data as configured. It
+// returns the flags of the record. The returned bytes are only valid until
+// the next call.
+func (d *Diode) encode(data diodes.GenericDataType) ([]byte, uint32, error) {
+	b, err := d.codec.Marshal(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var flags uint32
+	if d.compressor != nil {
+		d.compressed, err = d.compressor.Compress(d.compressed[:0], b)
+		if err != nil {
+			return nil, 0, err
+		}
+		b = d.compressed
+		flags |= compressedFlag
+	}
+
+	if d.cipher != nil {
+		d.sealed, err = d.cipher.Seal(d.sealed[:0], b)
+		if err != nil {
+			return nil, 0, err
+		}
+		b = d.sealed
+		flags |= encryptedFlag
+	}
+
+	return b, flags, nil
+}
+
+// decode reverses encode for a record with the given flags.
+func (d *Diode) decode(b []byte, flags uint32) (diodes.GenericDataType, error) {
+	var err error
+	if flags&encryptedFlag != 0 {
+		if d.cipher == nil {
+			return nil, diodes.ErrNoCipher
+		}
+
+		b, err = d.cipher.Open(nil, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if flags&compressedFlag != 0 {
+		if d.compressor == nil {
+			return nil, diodes.ErrNoCompressor
+		}
+
+		b, err = d.compressor.Decompress(nil, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return d.codec.Unmarshal(b)
+}
+
+// Recovery returns what Open found in an existing log.
+func (d *Diode) Recovery() Recovery {
+	return d.recovery
+}
+
+// Recover passes the values that Open replayed to fn, in order, and returns
+// what Open found. It is meant to be invoked by the reader before it reads
+// the values that are appended after Open, so that the consumer can handle
+// them apart, such as by reprocessing them. It returns early when the reader
+// has already read them. Values that the wrapped diode dropped in the
+// meantime are not passed. Recover checkpoints like TryNext, stops at the
+// first error of fn and returns it; the value passed to fn is read all the
+// same.
+func (d *Diode) Recover(fn func(data diodes.GenericDataType) error) (Recovery, error) {
+	if d.recovery.Replayed == 0 {
+		return d.recovery, nil
+	}
+
+	for {
+		r, ok := d.next()
+		if !ok {
+			return d.recovery, nil
+		}
+		if r.seq > d.recovery.LastSeq {
+			// The value was appended after Open and is returned by the
+			// next TryNext.
+			d.pending = r
+			return d.recovery, nil
+		}
+
+		d.consume(r)
+		if err := fn(r.data); err != nil {
+			return d.recovery, err
+		}
+	}
+}
+
+// Append logs the value and sets it on the wrapped diode. It only sets the
+// value once it was written to the log, and returns the error otherwise.
+// It may be invoked by several go-routines.
+func (d *Diode) Append(data diodes.GenericDataType) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, flags, err := d.encode(data)
+	if err == nil && len(b) > lengthMask {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		err = d.l.append(d.nextSeq, time.Now().UnixNano(), b, flags)
+	}
+	if err == nil && d.syncEvery > 0 && (d.appended+1)%d.syncEvery == 0 {
+		err = d.l.f.Sync()
+	}
+	if err != nil {
+		atomic.AddUint64(&d.failed, 1)
+		if d.err == nil {
+			d.err = err
+		}
+		return err
+	}
+
+	d.d.Set(diodes.GenericDataType(&record{seq: d.nextSeq, data: data}))
+	d.nextSeq++
+	atomic.AddUint64(&d.appended, 1)
+
+	return nil
+}
+
+// Set appends the value like Append. Values that fail to be logged are
+// counted in the stats and the first error is available from Err.
+func (d *Diode) Set(data diodes.GenericDataType) {
+	d.Append(data)
+}
+
+// TryNext returns the next value of the wrapped diode, if any, and
+// checkpoints according to WithCheckpointEvery. An error of the checkpoint
+// is available from Err.
+func (d *Diode) TryNext() (diodes.GenericDataType, bool) {
+	r, ok := d.next()
+	if !ok {
+		return nil, false
+	}
+
+	d.consume(r)
+	return r.data, true
+}
+
+// next returns the value that Recover left behind or the next value of the
+// wrapped diode.
+func (d *Diode) next() (*record, bool) {
+	if r := d.pending; r != nil {
+		d.pending = nil
+		return r, true
+	}
+
+	data, ok := d.d.TryNext()
+	if !ok {
+		return nil, false
+	}
+
+	return (*record)(unsafe.Pointer(data)), true
+}
+
+// consume records that the value was read and checkpoints according to
+// WithCheckpointEvery.
+func (d *Diode) consume(r *record) {
+	atomic.StoreUint64(&d.readSeq, r.seq+1)
+
+	d.reads++
+	if d.checkpointEvery > 0 && d.reads%d.checkpointEvery == 0 {
+		if err := d.Checkpoint(); err != nil {
+			d.setErr(err)
+		}
+	}
+}
+
+// Checkpoint records that the values read so far were consumed, so that
+// they are not replayed. When all the logged values were consumed, the log
+// is truncated. It is safe to call from any go-routine.
+func (d *Diode) Checkpoint() error {
+	d.checkpointMu.Lock()
+	defer d.checkpointMu.Unlock()
+
+	seq := atomic.LoadUint64(&d.readSeq)
+	if seq == d.checkpointSeq {
+		return nil
+	}
+
+	if err := writeCheckpoint(d.checkpointPath, seq, d.syncEvery > 0); err != nil {
+		return err
+	}
+	d.checkpointSeq = seq
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The writers set values while they hold the lock, so nothing that was
+	// logged can still be on its way to the wrapped diode.
+	if seq == d.nextSeq {
+		return d.l.truncate(logHeaderSize)
+	}
+
+	return nil
+}
+
+// Enforce removes the values from the log that are past WithMaxBytes or
+// WithMaxAge, along with the values that were consumed. It is invoked in the
+// background every WithRetentionInterval. The values that were already set
+// on the wrapped diode can still be read; they are only not replayed.
+func (d *Diode) Enforce() error {
+	d.checkpointMu.Lock()
+	defer d.checkpointMu.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var cutoff int64
+	if d.maxAge > 0 {
+		cutoff = time.Now().Add(-d.maxAge).UnixNano()
+	}
+
+	// Everything that is removed comes before what is kept, as the values
+	// are logged in order.
+	start, end := int64(logHeaderSize), int64(logHeaderSize)
+	var expired uint64
+	var ends []int64
+	var seqs []uint64
+	_, err := d.l.scan(func(seq uint64, ts int64, payload []byte, _ uint32) {
+		end += recordHeaderSize + int64(len(payload))
+		if len(ends) == 0 && (seq < d.checkpointSeq || ts < cutoff) {
+			start = end
+			if seq >= d.checkpointSeq {
+				expired++
+			}
+			return
+		}
+		ends = append(ends, end)
+		seqs = append(seqs, seq)
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := 0; d.maxBytes > 0 && i < len(ends) && logHeaderSize+d.l.size-start > d.maxBytes; i++ {
+		start = ends[i]
+		if seqs[i] >= d.checkpointSeq {
+			expired++
+		}
+	}
+
+	if err := d.l.compact(start); err != nil {
+		return err
+	}
+	atomic.AddUint64(&d.expired, expired)
+
+	return nil
+}
+
+// retain enforces the retention in the background until the diode is
+// closed.
+func (d *Diode) retain() {
+	defer close(d.done)
+
+	t := time.NewTicker(d.retentionInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-t.C:
+			if err := d.Enforce(); err != nil {
+				d.setErr(err)
+			}
+		}
+	}
+}
+
+// Stats is a snapshot of the statistics of a Diode.
+type Stats struct {
+	// Appended is the total number of values that were logged.
+	Appended uint64
+
+	// Failed is the total number of values that failed to be logged.
+	Failed uint64
+
+	// Expired is the total number of values that were removed from the log
+	// by WithMaxBytes or WithMaxAge before they were consumed.
+	Expired uint64
+
+	// Bytes is the size of the log.
+	Bytes int64
+}
+
+// Stats returns a snapshot of the log's statistics. The statistics of the
+// wrapped diode are available from the wrapped diode. It is safe to call
+// from any go-routine.
+func (d *Diode) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return Stats{
+		Appended: atomic.LoadUint64(&d.appended),
+		Failed:   atomic.LoadUint64(&d.failed),
+		Expired:  atomic.LoadUint64(&d.expired),
+		Bytes:    d.l.size,
+	}
+}
+
+// Err returns the first error of the log or the checkpoint, if any.
+func (d *Diode) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.err
+}
+
+func (d *Diode) setErr(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// Close checkpoints, flushes the log to stable storage and closes it.
+func (d *Diode) Close() error {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+	}
+
+	err := d.Checkpoint()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if serr := d.l.f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := d.l.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,302 @@
+package wal_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/wal"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diode", func() {
+	var (
+		dir  string
+		path string
+		open []*wal.Diode
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "wal")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "log")
+		open = nil
+	})
+
+	AfterEach(func() {
+		for _, d := range open {
+			d.Close()
+		}
+		os.RemoveAll(dir)
+	})
+
+	// openLog does not close the diodes before the test ends, so that a
+	// diode that is opened again sees the log as it was left by a crash.
+	openLog := func(opts ...wal.Option) *wal.Diode {
+		d, err := wal.Open(path, diodes.NewOneToOne(8, nil), diodes.BytesCodec{}, opts...)
+		Expect(err).ToNot(HaveOccurred())
+		open = append(open, d)
+		return d
+	}
+
+	set := func(d *wal.Diode, payloads ...string) {
+		for _, p := range payloads {
+			b := []byte(p)
+			Expect(d.Append(diodes.GenericDataType(&b))).To(Succeed())
+		}
+	}
+
+	readAll := func(d *wal.Diode) []string {
+		var payloads []string
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				return payloads
+			}
+			payloads = append(payloads, string(*(*[]byte)(data)))
+		}
+	}
+
+	It("logs the values before it sets them", func() {
+		d := openLog()
+		set(d, "a", "b")
+
+		Expect(d.Stats().Appended).To(Equal(uint64(2)))
+		Expect(d.Stats().Bytes).To(Equal(int64(8 + 2*25)))
+		Expect(readAll(d)).To(Equal([]string{"a", "b"}))
+	})
+
+	It("replays the values that were not consumed before a crash", func() {
+		d := openLog(wal.WithCheckpointEvery(1))
+		set(d, "a", "b", "c")
+		data, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(string(*(*[]byte)(data))).To(Equal("a"))
+
+		d = openLog(wal.WithCheckpointEvery(1))
+		Expect(d.Recovery()).To(Equal(wal.Recovery{Replayed: 2, FirstSeq: 1, LastSeq: 2}))
+		Expect(readAll(d)).To(Equal([]string{"b", "c"}))
+	})
+
+	It("replays the values read since the last checkpoint", func() {
+		d := openLog(wal.WithCheckpointEvery(0))
+		set(d, "a", "b")
+		Expect(readAll(d)).To(Equal([]string{"a", "b"}))
+
+		d = openLog()
+		Expect(readAll(d)).To(Equal([]string{"a", "b"}))
+	})
+
+	It("passes the replayed values to Recover before the new ones", func() {
+		d := openLog(wal.WithCheckpointEvery(1))
+		set(d, "a", "b", "c")
+		d.TryNext()
+
+		d = openLog(wal.WithCheckpointEvery(1))
+		set(d, "d")
+
+		var recovered []string
+		recovery, err := d.Recover(func(data diodes.GenericDataType) error {
+			recovered = append(recovered, string(*(*[]byte)(data)))
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(recovery).To(Equal(wal.Recovery{Replayed: 2, FirstSeq: 1, LastSeq: 2}))
+		Expect(recovered).To(Equal([]string{"b", "c"}))
+		Expect(readAll(d)).To(Equal([]string{"d"}))
+
+		d = openLog()
+		Expect(d.Recovery().Replayed).To(BeZero())
+	})
+
+	It("continues the sequence of a replayed log", func() {
+		d := openLog()
+		set(d, "a", "b")
+
+		d = openLog()
+		set(d, "c")
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c"}))
+		Expect(d.Checkpoint()).To(Succeed())
+
+		d = openLog()
+		Expect(d.Recovery().Replayed).To(BeZero())
+		Expect(readAll(d)).To(BeEmpty())
+	})
+
+	It("truncates the log once everything was consumed", func() {
+		d := openLog()
+		set(d, "a", "b")
+		readAll(d)
+		Expect(d.Close()).To(Succeed())
+
+		d = openLog()
+		Expect(d.Recovery().Replayed).To(BeZero())
+		Expect(d.Stats().Bytes).To(Equal(int64(8)))
+	})
+
+	It("truncates a record that was cut short", func() {
+		d := openLog()
+		set(d, "a", "b")
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.Write([]byte{2, 0, 0, 0, 0, 0, 0, 0, 9})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+
+		d = openLog()
+		Expect(d.Recovery()).To(Equal(wal.Recovery{Replayed: 2, Truncated: true, LastSeq: 1}))
+		Expect(d.Stats().Bytes).To(Equal(int64(8 + 2*25)))
+		Expect(readAll(d)).To(Equal([]string{"a", "b"}))
+	})
+
+	It("compresses the values in the log", func() {
+		c, err := diodes.NewFlateCompressor(flate.BestSpeed)
+		Expect(err).ToNot(HaveOccurred())
+
+		payload := string(bytes.Repeat([]byte("a"), 1000))
+		d := openLog(wal.WithCompressor(c))
+		set(d, payload)
+		Expect(d.Stats().Bytes).To(BeNumerically("<", 100))
+
+		d = openLog()
+		Expect(d.Recovery()).To(Equal(wal.Recovery{Skipped: 1}))
+
+		set(d, "b")
+		d = openLog(wal.WithCompressor(c))
+		Expect(readAll(d)).To(Equal([]string{payload, "b"}))
+	})
+
+	It("encrypts the values in the log", func() {
+		c := newCipher("0123456789abcdef")
+		d := openLog(wal.WithCipher(c))
+		set(d, "some-secret")
+
+		b, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).ToNot(ContainSubstring("some-secret"))
+
+		d = openLog()
+		Expect(d.Recovery()).To(Equal(wal.Recovery{Skipped: 1}))
+
+		d = openLog(wal.WithCipher(c))
+		Expect(readAll(d)).To(Equal([]string{"some-secret"}))
+	})
+
+	It("removes the oldest values beyond the max bytes", func() {
+		d := openLog(wal.WithMaxBytes(8+2*25), wal.WithCheckpointEvery(1))
+		set(d, "a", "b", "c", "d")
+		Expect(d.Enforce()).To(Succeed())
+		Expect(d.Stats().Bytes).To(Equal(int64(8 + 2*25)))
+		Expect(d.Stats().Expired).To(Equal(uint64(2)))
+
+		// The values that were already set are still read.
+		Expect(readAll(d)).To(Equal([]string{"a", "b", "c", "d"}))
+
+		set(d, "e", "f")
+		d = openLog()
+		Expect(readAll(d)).To(Equal([]string{"e", "f"}))
+	})
+
+	It("removes the values that are older than the max age in the background", func() {
+		d := openLog(wal.WithMaxAge(50*time.Millisecond), wal.WithRetentionInterval(10*time.Millisecond))
+		set(d, "a", "b")
+		Eventually(func() int64 { return d.Stats().Bytes }).Should(Equal(int64(8)))
+		Expect(d.Stats().Expired).To(Equal(uint64(2)))
+		Expect(d.Err()).ToNot(HaveOccurred())
+
+		set(d, "c")
+		d = openLog()
+		Expect(d.Recovery()).To(Equal(wal.Recovery{Replayed: 1, FirstSeq: 2, LastSeq: 2}))
+		Expect(readAll(d)).To(Equal([]string{"c"}))
+	})
+
+	It("removes the consumed values when it enforces the retention", func() {
+		d := openLog(wal.WithCheckpointEvery(1))
+		set(d, "a", "b", "c")
+		_, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+
+		Expect(d.Enforce()).To(Succeed())
+		Expect(d.Stats().Bytes).To(Equal(int64(8 + 2*25)))
+		Expect(d.Stats().Expired).To(BeZero())
+	})
+
+	It("does not set values that fail to be logged", func() {
+		d, err := wal.Open(path, diodes.NewOneToOne(8, nil), failingCodec{})
+		Expect(err).ToNot(HaveOccurred())
+		defer d.Close()
+
+		b := []byte("a")
+		Expect(d.Append(diodes.GenericDataType(&b))).To(MatchError("some-error"))
+		d.Set(diodes.GenericDataType(&b))
+
+		Expect(d.Stats().Failed).To(Equal(uint64(2)))
+		Expect(d.Err()).To(MatchError("some-error"))
+		_, ok := d.TryNext()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("does not log a payload that does not fit the length of a record", func() {
+		d, err := wal.Open(path, diodes.NewOneToOne(8, nil), sizedCodec(1<<30))
+		Expect(err).ToNot(HaveOccurred())
+		defer d.Close()
+
+		before, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		b := []byte("a")
+		Expect(d.Append(diodes.GenericDataType(&b))).To(MatchError(wal.ErrTooLarge))
+		Expect(d.Stats().Failed).To(Equal(uint64(1)))
+
+		after, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(after.Size()).To(Equal(before.Size()))
+	})
+
+	It("refuses a file that is not a log", func() {
+		Expect(os.WriteFile(path, []byte("some-data"), 0o600)).To(Succeed())
+
+		_, err := wal.Open(path, diodes.NewOneToOne(8, nil), diodes.BytesCodec{})
+		Expect(err).To(MatchError(wal.ErrIncompatible))
+	})
+})
+
+func newCipher(key string) *diodes.AEADCipher {
+	block, err := aes.NewCipher([]byte(key))
+	Expect(err).ToNot(HaveOccurred())
+	aead, err := cipher.NewGCM(block)
+	Expect(err).ToNot(HaveOccurred())
+
+	return diodes.NewAEADCipher(aead)
+}
+
+// sizedCodec marshals every value to a payload of the given size.
+type sizedCodec int
+
+func (c sizedCodec) Marshal(diodes.GenericDataType) ([]byte, error) {
+	return make([]byte, int(c)), nil
+}
+
+func (sizedCodec) Unmarshal(b []byte) (diodes.GenericDataType, error) {
+	return diodes.GenericDataType(&b), nil
+}
+
+type failingCodec struct{}
+
+func (failingCodec) Marshal(diodes.GenericDataType) ([]byte, error) {
+	return nil, errors.New("some-error")
+}
+
+func (failingCodec) Unmarshal([]byte) (diodes.GenericDataType, error) {
+	return nil, errors.New("some-error")
+}
@@ -0,0 +1,240 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// The log starts with a header that is followed by a record for every
+// value that was set:
+//
+//	header: magic [8]byte
+//	record: seq uint64 | ts int64 | length uint32 | crc uint32 | payload [length]byte
+//
+// The ts is the time the value was appended, in nanoseconds since the
+// epoch.
+// The highest bit of the length is set for compressed payloads and the next
+// one for encrypted payloads. The crc is the checksum of the payload as it
+// is written.
+//
+// The checkpoint file next to the log holds the sequence number of the
+// first value that was not consumed yet:
+//
+//	checkpoint: seq uint64 | crc uint32
+const (
+	logHeaderSize    = 8
+	recordHeaderSize = 24
+	checkpointSize   = 12
+
+	compressedFlag = 1 << 31
+	encryptedFlag  = 1 << 30
+	lengthMask     = encryptedFlag - 1
+)
+
+var logMagic = [8]byte{'g', 'o', 'd', 'w', 'a', 'l', 0, 2}
+
+// ErrIncompatible is returned by Open for a file that is not a log.
+var ErrIncompatible = errors.New("wal: incompatible file")
+
+// ErrTooLarge is returned by Append for a value whose payload is 1 GiB or
+// larger once it is compressed and encrypted, as its length would not fit
+// the length of the record.
+var ErrTooLarge = errors.New("wal: payload too large")
+
+// log is the file the records are appended to. It is not safe for
+// concurrent use.
+type log struct {
+	size int64
+	path string
+	f    *os.File
+	buf  []byte
+}
+
+// openLog opens or creates the log at the given path.
+func openLog(path string) (*log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &log{path: path, f: f}
+	if err := l.init(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *log) init() error {
+	info, err := l.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() == 0 {
+		if _, err := l.f.WriteAt(logMagic[:], 0); err != nil {
+			return err
+		}
+		l.size = logHeaderSize
+		return nil
+	}
+
+	var magic [8]byte
+	if _, err := l.f.ReadAt(magic[:], 0); err != nil || magic != logMagic {
+		return ErrIncompatible
+	}
+	l.size = info.Size()
+
+	return nil
+}
+
+// scan invokes fn for every valid record. A record that was cut short or
+// fails its checksum ends the log: it and everything after it are truncated
+// and scan reports that the log was corrupt.
+func (l *log) scan(fn func(seq uint64, ts int64, payload []byte, flags uint32)) (bool, error) {
+	r := bufio.NewReader(io.NewSectionReader(l.f, logHeaderSize, l.size-logHeaderSize))
+	off := int64(logHeaderSize)
+
+	var header [recordHeaderSize]byte
+	for off < l.size {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return true, l.truncate(off)
+		}
+
+		length := int64(binary.LittleEndian.Uint32(header[16:]) & lengthMask)
+		if off+recordHeaderSize+length > l.size {
+			return true, l.truncate(off)
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return true, l.truncate(off)
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[20:]) {
+			return true, l.truncate(off)
+		}
+
+		flags := binary.LittleEndian.Uint32(header[16:]) &^ lengthMask
+		ts := int64(binary.LittleEndian.Uint64(header[8:]))
+		fn(binary.LittleEndian.Uint64(header[:]), ts, payload, flags)
+		off += recordHeaderSize + length
+	}
+
+	return false, nil
+}
+
+// append writes a record with the given flags at the end of the log.
+func (l *log) append(seq uint64, ts int64, payload []byte, flags uint32) error {
+	var header [recordHeaderSize]byte
+	binary.LittleEndian.PutUint64(header[:], seq)
+	binary.LittleEndian.PutUint64(header[8:], uint64(ts))
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(payload))|flags)
+	binary.LittleEndian.PutUint32(header[20:], crc32.ChecksumIEEE(payload))
+	l.buf = append(append(l.buf[:0], header[:]...), payload...)
+
+	if _, err := l.f.WriteAt(l.buf, l.size); err != nil {
+		// Leave no partial record behind for the next append to follow.
+		l.f.Truncate(l.size)
+		return err
+	}
+	l.size += int64(len(l.buf))
+
+	return nil
+}
+
+// truncate removes everything after the given offset.
+func (l *log) truncate(off int64) error {
+	l.size = off
+	return l.f.Truncate(off)
+}
+
+// compact removes the records before the given offset. The remaining
+// records are copied to a file next to the log that replaces it, so a crash
+// leaves either the old or the new log.
+func (l *log) compact(off int64) error {
+	if off == logHeaderSize {
+		return nil
+	}
+	if off == l.size {
+		return l.truncate(logHeaderSize)
+	}
+
+	tmp := l.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(logMagic[:])
+	if err == nil {
+		_, err = io.Copy(f, io.NewSectionReader(l.f, off, l.size-off))
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	l.f.Close()
+	l.f = f
+	l.size -= off - logHeaderSize
+
+	return nil
+}
+
+// readCheckpoint returns the sequence number stored in the checkpoint file
+// at the given path. It returns zero when there is no valid checkpoint.
+func readCheckpoint(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if len(b) != checkpointSize || crc32.ChecksumIEEE(b[:8]) != binary.LittleEndian.Uint32(b[8:]) {
+		return 0, nil
+	}
+
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// writeCheckpoint replaces the checkpoint file at the given path. The file
+// is written next to it and renamed, so a crash leaves either the old or the
+// new checkpoint.
+func writeCheckpoint(path string, seq uint64, sync bool) error {
+	var b [checkpointSize]byte
+	binary.LittleEndian.PutUint64(b[:], seq)
+	binary.LittleEndian.PutUint32(b[8:], crc32.ChecksumIEEE(b[:8]))
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b[:])
+	if err == nil && sync {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
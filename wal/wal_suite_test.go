@@ -0,0 +1,13 @@
+package wal_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestWAL(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "WAL Suite")
+}
@@ -0,0 +1,104 @@
+// Package websocket fans the data of a diode out to WebSocket clients. It
+// does not depend on a WebSocket library: a small adapter turns a connection
+// of gorilla/websocket or nhooyr.io/websocket into a Conn.
+package websocket
+
+import (
+	"context"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+)
+
+// Conn writes messages to a WebSocket client. A gorilla adapter would invoke
+// WriteMessage with websocket.TextMessage.
+type Conn interface {
+	WriteMessage(ctx context.Context, msg []byte) error
+}
+
+// Hub reads messages from a diode and broadcasts them to every client. Each
+// client has a cursor of its own, so a slow client drops messages instead of
+// slowing down the source or the other clients.
+type Hub struct {
+	b       *diodes.Broadcast
+	size    int
+	dropped func(missed int) []byte
+}
+
+// HubOption can be used to setup the hub.
+type HubOption func(*Hub)
+
+// WithClientSize sets the number of messages buffered for each client. The
+// default is 1024.
+func WithClientSize(size int) HubOption {
+	return HubOption(func(h *Hub) {
+		h.size = size
+	})
+}
+
+// WithDropMessage sets a function that renders the message that tells a
+// client how many messages were dropped for it. It is sent before the next
+// message. By default clients are not told.
+func WithDropMessage(f func(missed int) []byte) HubOption {
+	return HubOption(func(h *Hub) {
+		h.dropped = f
+	})
+}
+
+// NewHub returns a new Hub that reads messages from the given Poller or
+// Waiter. The values of the diode must be *[]byte, such as the ones set by a
+// diodes.Writer. The values are shared by all clients and must not be
+// modified once they are set.
+func NewHub(n diodes.Nexter, opts ...HubOption) *Hub {
+	h := &Hub{size: 1024}
+	for _, o := range opts {
+		o(h)
+	}
+	h.b = diodes.NewBroadcast(n, diodes.WithSubscriptionSize(h.size))
+
+	return h
+}
+
+// Run broadcasts messages until the Poller or Waiter returns nil.
+func (h *Hub) Run() {
+	h.b.Run()
+}
+
+// Serve writes the messages to the client until the context is done or a
+// write fails, typically on the go-routine of the handler that upgraded the
+// connection. It returns the error of the failed write.
+func (h *Hub) Serve(ctx context.Context, c Conn) error {
+	var missed int
+	s := h.b.Subscribe(ctx, diodes.AlertFunc(func(n int) {
+		missed += n
+	}))
+	defer s.Close()
+
+	for {
+		data := s.Next()
+		if data == nil || ctx.Err() != nil {
+			return nil
+		}
+
+		if missed > 0 && h.dropped != nil {
+			if err := c.WriteMessage(ctx, h.dropped(missed)); err != nil {
+				return err
+			}
+		}
+		missed = 0
+
+		if err := c.WriteMessage(ctx, *(*[]byte)(unsafe.Pointer(data))); err != nil {
+			return err
+		}
+	}
+}
+
+// Clients returns the number of connected clients.
+func (h *Hub) Clients() int {
+	return h.b.Subscriptions()
+}
+
+// Drops returns the number of messages dropped by all clients.
+func (h *Hub) Drops() uint64 {
+	return h.b.Drops()
+}
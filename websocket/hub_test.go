@@ -0,0 +1,147 @@
+package websocket_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	"code.cloudfoundry.org/go-diodes"
+	"code.cloudfoundry.org/go-diodes/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hub", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		writer *diodes.Writer
+		w      *diodes.Waiter
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		w = diodes.NewWaiter(diodes.NewOneToOne(16, nil), diodes.WithWaiterContext(ctx))
+		writer = diodes.NewWriter(w)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("writes the messages to every client", func() {
+		h := websocket.NewHub(w)
+		go h.Run()
+
+		a, b := newSpyConn(), newSpyConn()
+		go h.Serve(ctx, a)
+		go h.Serve(ctx, b)
+		Eventually(h.Clients).Should(Equal(2))
+
+		writer.Write([]byte("a"))
+		writer.Write([]byte("b"))
+
+		Eventually(a.messages).Should(Equal([]string{"a", "b"}))
+		Eventually(b.messages).Should(Equal([]string{"a", "b"}))
+	})
+
+	It("tells a slow client how many messages were dropped", func() {
+		h := websocket.NewHub(w,
+			websocket.WithClientSize(2),
+			websocket.WithDropMessage(func(missed int) []byte {
+				return []byte("dropped " + strconv.Itoa(missed))
+			}),
+		)
+		go h.Run()
+
+		slow, fast := newSpyConn(), newSpyConn()
+		release := make(chan struct{})
+		slow.block = release
+		go h.Serve(ctx, slow)
+		go h.Serve(ctx, fast)
+		Eventually(h.Clients).Should(Equal(2))
+
+		var expected []string
+		for _, m := range []string{"a", "b", "c", "d", "e", "f"} {
+			writer.Write([]byte(m))
+			expected = append(expected, m)
+			Eventually(fast.messages).Should(Equal(expected))
+		}
+
+		close(release)
+		Eventually(slow.messages).Should(Equal([]string{"a", "dropped 4", "f"}))
+		Expect(h.Drops()).To(Equal(uint64(4)))
+	})
+
+	It("returns the error of a failed write", func() {
+		h := websocket.NewHub(w)
+		go h.Run()
+
+		c := newSpyConn()
+		c.err = errors.New("connection reset")
+		errs := make(chan error)
+		go func() {
+			errs <- h.Serve(ctx, c)
+		}()
+		Eventually(h.Clients).Should(Equal(1))
+
+		writer.Write([]byte("a"))
+		Eventually(errs).Should(Receive(MatchError("connection reset")))
+		Expect(h.Clients()).To(Equal(0))
+	})
+
+	It("stops serving once the context is done", func() {
+		h := websocket.NewHub(w)
+		clientCtx, clientCancel := context.WithCancel(ctx)
+		errs := make(chan error)
+		go func() {
+			errs <- h.Serve(clientCtx, newSpyConn())
+		}()
+		Eventually(h.Clients).Should(Equal(1))
+
+		clientCancel()
+		Eventually(errs).Should(Receive(BeNil()))
+		Expect(h.Clients()).To(Equal(0))
+	})
+})
+
+// spyConn records the messages written to it. When block is set, the first
+// write blocks until it is closed.
+type spyConn struct {
+	mu    sync.Mutex
+	msgs  []string
+	err   error
+	block chan struct{}
+}
+
+func newSpyConn() *spyConn {
+	return &spyConn{}
+}
+
+func (c *spyConn) WriteMessage(ctx context.Context, msg []byte) error {
+	c.mu.Lock()
+	block := c.block
+	c.block = nil
+	c.mu.Unlock()
+
+	if block != nil {
+		<-block
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	c.msgs = append(c.msgs, string(msg))
+	return nil
+}
+
+func (c *spyConn) messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.msgs
+}
@@ -0,0 +1,68 @@
+package diodes
+
+import "sync/atomic"
+
+// WeightedFair is a FanInPolicy that reads the sources in proportion to
+// their weights while they have data: a source with weight 3 is read three
+// times as often as a source with weight 1. A busy source therefore cannot
+// starve a quiet one with a higher weight. A source without data is skipped
+// and the others are read instead.
+//
+// The weights are spread evenly over the turns, like the smooth weighted
+// round-robin of nginx, so a source with a high weight is not read in long
+// streaks either.
+type WeightedFair struct {
+	weights []int32
+	current []int64
+}
+
+// NewWeightedFair returns a new WeightedFair with the weights of the sources
+// in the order they are given to NewFanIn. Sources without a weight have a
+// weight of 1.
+func NewWeightedFair(weights ...int) *WeightedFair {
+	w := &WeightedFair{weights: make([]int32, len(weights))}
+	for i, weight := range weights {
+		w.weights[i] = int32(weight)
+	}
+
+	return w
+}
+
+// SetWeight changes the weight of the source with the given index, which
+// must have been given a weight to NewWeightedFair. It is safe to call from
+// any go-routine while the FanIn is read. A weight of 0
+// only reads the source when the others are empty.
+func (w *WeightedFair) SetWeight(i, weight int) {
+	atomic.StoreInt32(&w.weights[i], int32(weight))
+}
+
+// Weight returns the weight of the source with the given index.
+func (w *WeightedFair) Weight(i int) int {
+	if i >= len(w.weights) {
+		return 1
+	}
+
+	return int(atomic.LoadInt32(&w.weights[i]))
+}
+
+// Start returns the source whose turn it is. It is invoked by the reader of
+// the FanIn.
+func (w *WeightedFair) Start(last, n int) int {
+	if len(w.current) != n {
+		w.current = make([]int64, n)
+	}
+
+	var total int64
+	best := 0
+	for i := range w.current {
+		weight := int64(w.Weight(i))
+		w.current[i] += weight
+		total += weight
+		if w.current[i] > w.current[best] {
+			best = i
+		}
+	}
+	w.current[best] -= total
+
+	return best
+}
@@ -0,0 +1,75 @@
+package diodes_test
+
+import (
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WeightedFair", func() {
+	var (
+		busy, quiet *diodes.OneToOne
+		w           *diodes.WeightedFair
+		f           *diodes.FanIn
+	)
+
+	BeforeEach(func() {
+		busy = diodes.NewOneToOne(1024, nil)
+		quiet = diodes.NewOneToOne(1024, nil)
+		w = diodes.NewWeightedFair(1, 3)
+		f = diodes.NewFanIn([]diodes.Diode{busy, quiet}, diodes.WithFanInPolicy(w))
+	})
+
+	fill := func(d diodes.Diode, n int) {
+		for i := 0; i < n; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	// count reads n values and counts them by source.
+	count := func(n int) []int {
+		counts := make([]int, 2)
+		for i := 0; i < n; i++ {
+			_, idx, ok := f.TryNextSource()
+			Expect(ok).To(BeTrue())
+			counts[idx]++
+		}
+		return counts
+	}
+
+	It("reads the sources in proportion to their weights", func() {
+		fill(busy, 500)
+		fill(quiet, 500)
+
+		Expect(count(400)).To(Equal([]int{100, 300}))
+	})
+
+	It("spreads the turns of a source", func() {
+		fill(busy, 10)
+		fill(quiet, 10)
+
+		var order []int
+		for i := 0; i < 8; i++ {
+			_, idx, _ := f.TryNextSource()
+			order = append(order, idx)
+		}
+		Expect(order).To(Equal([]int{1, 0, 1, 1, 1, 0, 1, 1}))
+	})
+
+	It("reads the other sources while one is empty", func() {
+		fill(busy, 10)
+
+		Expect(count(10)).To(Equal([]int{10, 0}))
+	})
+
+	It("applies weights that are changed at runtime", func() {
+		fill(busy, 500)
+		fill(quiet, 500)
+		w.SetWeight(0, 3)
+		w.SetWeight(1, 1)
+
+		Expect(count(400)).To(Equal([]int{300, 100}))
+	})
+})
@@ -0,0 +1,156 @@
+package diodes
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ReduceFunc folds a value into the accumulator of a window. The accumulator
+// is nil for the first value of a window.
+type ReduceFunc func(acc, data GenericDataType) GenericDataType
+
+// Window is the result of a window of a WindowReducer.
+type Window struct {
+	// Start and End are the times the window covers. A value belongs to the
+	// window when it was read at or after Start and before End.
+	Start time.Time
+	End   time.Time
+
+	// Count is the number of values in the window.
+	Count uint64
+
+	// Value is the accumulator that was returned by the reduce function for
+	// the last value.
+	Value GenericDataType
+}
+
+// WindowReducer reads from a diode and aggregates the values into time
+// windows, such as to pre-aggregate metrics right at the buffer. Windows
+// are tumbling by default: every value belongs to one window. With
+// WithSlide, they overlap and a value belongs to every window that covers
+// the time it was read. The windows are aligned to multiples of the slide.
+type WindowReducer struct {
+	d       Diode
+	size    time.Duration
+	slide   time.Duration
+	polling time.Duration
+	reduce  ReduceFunc
+	emit    func(Window)
+	windows map[int64]*Window
+}
+
+// WindowOption can be used to setup the window reducer.
+type WindowOption func(*WindowReducer)
+
+// WithSlide sets the time between the start of two windows. A slide smaller
+// than the size makes the windows slide, e.g. windows of a minute every ten
+// seconds. The default is the size, which makes them tumble.
+func WithSlide(slide time.Duration) WindowOption {
+	return WindowOption(func(r *WindowReducer) {
+		r.slide = slide
+	})
+}
+
+// WithWindowPollingInterval sets the interval at which the diode is queried
+// for new data while it is empty. The default is 10ms.
+func WithWindowPollingInterval(interval time.Duration) WindowOption {
+	return WindowOption(func(r *WindowReducer) {
+		r.polling = interval
+	})
+}
+
+// NewWindowReducer returns a new WindowReducer that reads from the given
+// diode, reduces the values into windows of the given size and passes every
+// window to emit once it ended. Windows without values are not emitted.
+func NewWindowReducer(d Diode, size time.Duration, reduce ReduceFunc, emit func(Window), opts ...WindowOption) *WindowReducer {
+	r := &WindowReducer{
+		d:       d,
+		size:    size,
+		slide:   size,
+		polling: 10 * time.Millisecond,
+		reduce:  reduce,
+		emit:    emit,
+		windows: make(map[int64]*Window),
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// Run reads from the diode and emits the windows until the context is done.
+// The values that are left in the diode are then reduced and the windows
+// that did not end yet are emitted as they are. Run must only be invoked
+// once as it is the reader of the diode.
+func (r *WindowReducer) Run(ctx context.Context) {
+	for {
+		data, ok := r.d.TryNext()
+		if ok {
+			r.add(time.Now(), data)
+			continue
+		}
+
+		r.flush(time.Now())
+
+		select {
+		case <-ctx.Done():
+			r.drain()
+			return
+		case <-time.After(r.polling):
+		}
+	}
+}
+
+// add reduces the value into every window that covers the given time.
+func (r *WindowReducer) add(now time.Time, data GenericDataType) {
+	for start := now.Truncate(r.slide); start.Add(r.size).After(now); start = start.Add(-r.slide) {
+		w, ok := r.windows[start.UnixNano()]
+		if !ok {
+			w = &Window{Start: start, End: start.Add(r.size)}
+			r.windows[start.UnixNano()] = w
+		}
+
+		w.Value = r.reduce(w.Value, data)
+		w.Count++
+	}
+}
+
+// flush emits the windows that ended by the given time, in order.
+func (r *WindowReducer) flush(now time.Time) {
+	var ended []*Window
+	for start, w := range r.windows {
+		if !w.End.After(now) {
+			ended = append(ended, w)
+			delete(r.windows, start)
+		}
+	}
+
+	sort.Slice(ended, func(i, j int) bool {
+		return ended[i].Start.Before(ended[j].Start)
+	})
+	for _, w := range ended {
+		r.emit(*w)
+	}
+}
+
+// drain reduces what is left in the diode and emits all the windows.
+func (r *WindowReducer) drain() {
+	for {
+		data, ok := r.d.TryNext()
+		if !ok {
+			break
+		}
+		r.add(time.Now(), data)
+	}
+
+	var last time.Time
+	for _, w := range r.windows {
+		if w.End.After(last) {
+			last = w.End
+		}
+	}
+	r.flush(last)
+}
@@ -0,0 +1,100 @@
+package diodes_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WindowReducer", func() {
+	var (
+		ctx     context.Context
+		cancel  context.CancelFunc
+		d       *diodes.OneToOne
+		mu      sync.Mutex
+		windows []diodes.Window
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		d = diodes.NewOneToOne(16, nil)
+		windows = nil
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	sum := func(acc, data diodes.GenericDataType) diodes.GenericDataType {
+		total := *(*int)(data)
+		if acc != nil {
+			total += *(*int)(acc)
+		}
+		return diodes.GenericDataType(&total)
+	}
+
+	emit := func(w diodes.Window) {
+		mu.Lock()
+		defer mu.Unlock()
+		windows = append(windows, w)
+	}
+
+	emitted := func() []diodes.Window {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]diodes.Window(nil), windows...)
+	}
+
+	set := func(values ...int) {
+		for _, v := range values {
+			v := v
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("emits a window once it ended", func() {
+		r := diodes.NewWindowReducer(d, 20*time.Millisecond, sum, emit,
+			diodes.WithWindowPollingInterval(time.Millisecond),
+		)
+		go r.Run(ctx)
+		set(1)
+
+		Eventually(emitted).Should(HaveLen(1))
+		w := emitted()[0]
+		Expect(w.Count).To(Equal(uint64(1)))
+		Expect(*(*int)(w.Value)).To(Equal(1))
+		Expect(w.End.Sub(w.Start)).To(Equal(20 * time.Millisecond))
+		Expect(w.End.After(time.Now())).To(BeFalse())
+	})
+
+	It("reduces the values into tumbling windows", func() {
+		r := diodes.NewWindowReducer(d, time.Hour, sum, emit)
+		set(1, 2, 3)
+		cancel()
+		r.Run(ctx)
+
+		Expect(emitted()).To(HaveLen(1))
+		Expect(emitted()[0].Count).To(Equal(uint64(3)))
+		Expect(*(*int)(emitted()[0].Value)).To(Equal(6))
+	})
+
+	It("reduces every value into the windows that slide over it", func() {
+		r := diodes.NewWindowReducer(d, time.Hour, sum, emit, diodes.WithSlide(30*time.Minute))
+		set(1, 2)
+		cancel()
+		r.Run(ctx)
+
+		ws := emitted()
+		Expect(ws).To(HaveLen(2))
+		Expect(ws[0].Start.Before(ws[1].Start)).To(BeTrue())
+		Expect(ws[1].Start.Sub(ws[0].Start)).To(Equal(30 * time.Minute))
+		for _, w := range ws {
+			Expect(*(*int)(w.Value)).To(Equal(3))
+		}
+	})
+})
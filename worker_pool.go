@@ -0,0 +1,214 @@
+package diodes
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerPool processes the values of one or more diodes on several
+// go-routines. The diodes have a single reader, so a worker takes a batch of
+// values from a diode while it holds the diode and processes them once it
+// let go of it. Every worker starts at its own diode. Once that is empty or
+// held by another worker, it steals a batch from the diode with the deepest
+// backlog, so the processing spreads over the cores without sharding the
+// writers by hand.
+type WorkerPool struct {
+	sources []*poolSource
+	fn      func(GenericDataType)
+	workers int
+	batch   int
+	polling time.Duration
+	stats   []workerStats
+}
+
+type poolSource struct {
+	mu sync.Mutex
+	d  Diode
+}
+
+type workerStats struct {
+	processed uint64
+	batches   uint64
+	stolen    uint64
+}
+
+// WorkerPoolOption can be used to setup the worker pool.
+type WorkerPoolOption func(*WorkerPool)
+
+// WithWorkers sets the number of workers. The default is GOMAXPROCS.
+func WithWorkers(n int) WorkerPoolOption {
+	return WorkerPoolOption(func(p *WorkerPool) {
+		p.workers = n
+	})
+}
+
+// WithStealBatch sets how many values a worker takes from a diode at once.
+// The default is 16.
+func WithStealBatch(n int) WorkerPoolOption {
+	return WorkerPoolOption(func(p *WorkerPool) {
+		p.batch = n
+	})
+}
+
+// WithWorkerPollingInterval sets the interval at which an idle worker
+// queries the diodes for new data. The default is 10ms.
+func WithWorkerPollingInterval(interval time.Duration) WorkerPoolOption {
+	return WorkerPoolOption(func(p *WorkerPool) {
+		p.polling = interval
+	})
+}
+
+// NewWorkerPool returns a new WorkerPool that reads the given diodes and
+// invokes fn for every value. The backlog of a diode is its Lag when it is
+// a LagReporter.
+func NewWorkerPool(sources []Diode, fn func(GenericDataType), opts ...WorkerPoolOption) *WorkerPool {
+	p := &WorkerPool{
+		fn:      fn,
+		workers: runtime.GOMAXPROCS(0),
+		batch:   16,
+		polling: 10 * time.Millisecond,
+	}
+
+	for _, d := range sources {
+		p.sources = append(p.sources, &poolSource{d: d})
+	}
+
+	for _, o := range opts {
+		o(p)
+	}
+	p.stats = make([]workerStats, p.workers)
+
+	return p
+}
+
+// Run processes the values until the context is done. It returns once the
+// workers finished the batches they took. Run must only be invoked once as
+// the pool is the reader of the diodes.
+func (p *WorkerPool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p.work(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (p *WorkerPool) work(ctx context.Context, worker int) {
+	if len(p.sources) == 0 {
+		return
+	}
+
+	home := worker % len(p.sources)
+	stats := &p.stats[worker]
+	batch := make([]GenericDataType, 0, p.batch)
+
+	for {
+		batch = p.take(home, batch[:0])
+		if len(batch) == 0 {
+			if from := p.deepest(home); from >= 0 {
+				batch = p.take(from, batch)
+				if len(batch) > 0 {
+					atomic.AddUint64(&stats.stolen, uint64(len(batch)))
+				}
+			}
+		}
+
+		if len(batch) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.polling):
+			}
+			continue
+		}
+
+		for i, data := range batch {
+			p.fn(data)
+			batch[i] = nil
+		}
+		atomic.AddUint64(&stats.processed, uint64(len(batch)))
+		atomic.AddUint64(&stats.batches, 1)
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// take reads up to a batch of values from the source, unless another worker
+// holds it.
+func (p *WorkerPool) take(i int, batch []GenericDataType) []GenericDataType {
+	s := p.sources[i]
+	if !s.mu.TryLock() {
+		return batch
+	}
+	defer s.mu.Unlock()
+
+	for len(batch) < p.batch {
+		data, ok := s.d.TryNext()
+		if !ok {
+			break
+		}
+		batch = append(batch, data)
+	}
+
+	return batch
+}
+
+// deepest returns the source other than home with the deepest backlog, or
+// -1 when there is no other source. Sources that do not report their lag
+// are assumed to have a backlog of one.
+func (p *WorkerPool) deepest(home int) int {
+	best := -1
+	var bestLag uint64
+	for i, s := range p.sources {
+		if i == home {
+			continue
+		}
+
+		lag := uint64(1)
+		if r, ok := s.d.(LagReporter); ok {
+			lag = r.Lag()
+		}
+		if best < 0 || lag > bestLag {
+			best, bestLag = i, lag
+		}
+	}
+
+	return best
+}
+
+// WorkerStats is a snapshot of the statistics of a worker of a WorkerPool.
+type WorkerStats struct {
+	// Processed is the number of values the worker processed.
+	Processed uint64
+
+	// Batches is the number of batches the worker processed.
+	Batches uint64
+
+	// Stolen is the number of the processed values the worker took from a
+	// diode other than its own.
+	Stolen uint64
+}
+
+// WorkerStats returns a snapshot of the statistics of every worker. It is
+// safe to call from any go-routine.
+func (p *WorkerPool) WorkerStats() []WorkerStats {
+	stats := make([]WorkerStats, len(p.stats))
+	for i := range p.stats {
+		s := &p.stats[i]
+		stats[i] = WorkerStats{
+			Processed: atomic.LoadUint64(&s.processed),
+			Batches:   atomic.LoadUint64(&s.batches),
+			Stolen:    atomic.LoadUint64(&s.stolen),
+		}
+	}
+
+	return stats
+}
@@ -0,0 +1,110 @@
+package diodes_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WorkerPool", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		mu     sync.Mutex
+		seen   map[int]bool
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		seen = make(map[int]bool)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	process := func(data diodes.GenericDataType) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[*(*int)(data)] = true
+	}
+
+	processed := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen)
+	}
+
+	fill := func(d diodes.Diode, from, to int) {
+		for i := from; i < to; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	It("processes the values of every diode", func() {
+		a := diodes.NewOneToOne(1024, nil)
+		b := diodes.NewOneToOne(1024, nil)
+		fill(a, 0, 100)
+		fill(b, 100, 200)
+
+		p := diodes.NewWorkerPool([]diodes.Diode{a, b}, process,
+			diodes.WithWorkers(4),
+			diodes.WithWorkerPollingInterval(time.Millisecond),
+		)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			p.Run(ctx)
+		}()
+
+		Eventually(processed).Should(Equal(200))
+		cancel()
+		Eventually(done).Should(BeClosed())
+
+		var total uint64
+		for _, s := range p.WorkerStats() {
+			total += s.Processed
+		}
+		Expect(total).To(Equal(uint64(200)))
+	})
+
+	It("steals from the diode with the deepest backlog", func() {
+		busy := diodes.NewOneToOne(1024, nil)
+		idle := diodes.NewOneToOne(1024, nil)
+		fill(busy, 0, 500)
+
+		block := make(chan struct{})
+		var blocked int32
+		p := diodes.NewWorkerPool([]diodes.Diode{busy, idle}, func(data diodes.GenericDataType) {
+			// The worker of the first batch is stuck with it.
+			if atomic.CompareAndSwapInt32(&blocked, 0, 1) {
+				<-block
+			}
+			process(data)
+		},
+			diodes.WithWorkers(2),
+			diodes.WithStealBatch(10),
+			diodes.WithWorkerPollingInterval(time.Millisecond),
+		)
+		go p.Run(ctx)
+
+		// Whichever worker took the first batch, the other one processes
+		// the rest of the busy diode.
+		Eventually(processed).Should(Equal(490))
+		close(block)
+		Eventually(processed).Should(Equal(500))
+
+		var stolen uint64
+		for _, s := range p.WorkerStats() {
+			stolen += s.Stolen
+		}
+		Expect(stolen).To(BeNumerically(">=", 10))
+	})
+})
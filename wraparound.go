@@ -0,0 +1,38 @@
+package diodes
+
+// The write and read indices of the diodes are uint64 counters that wrap
+// around to zero after 2^64 values. They are compared by serial number
+// arithmetic (RFC 1982): an index is after another one when the distance from
+// the other one to it is less than 2^63. The lap detection, the gaps and the
+// lag therefore stay correct across the wrap, as long as the reader is less
+// than 2^63 values behind.
+//
+// The slot of an index is the index modulo the size of the diode. For a size
+// that is a power of two, the slots continue seamlessly across the wrap. For
+// any other size, the slots of the values written right after the wrap
+// overlap those of the values written right before it, so a reader that
+// lags behind at the wrap drops up to a lap of values early. The drops are
+// reported like any other.
+
+// seqAfter reports whether the index a comes after the index b.
+func seqAfter(a, b uint64) bool {
+	return int64(a-b) > 0
+}
+
+// seqBefore reports whether the index a comes before the index b.
+func seqBefore(a, b uint64) bool {
+	return int64(a-b) < 0
+}
+
+// WithStartIndex starts the write and read indices of the diode at i
+// instead of zero, so that the sequence numbers of its Gaps, Leaks and
+// recorded events continue from i, such as for a diode that takes over from
+// one that wrote i values before. It is also how the handling of the
+// wraparound of the indices is exercised without writing 2^64 values. The
+// Stats still count from zero. It applies to the OneToOne and ManyToOne
+// diodes.
+func WithStartIndex(i uint64) DiodeConfigOption {
+	return DiodeConfigOption(func(c *diodeConfig) {
+		c.start = i
+	})
+}
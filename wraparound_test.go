@@ -0,0 +1,140 @@
+package diodes_test
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"code.cloudfoundry.org/go-diodes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = forEachImplementation("wraparound", func(impl diodes.Implementation) {
+	const start = math.MaxUint64 - 1
+
+	set := func(d diodes.Diode, from, to int) {
+		for i := from; i < to; i++ {
+			v := i
+			d.Set(diodes.GenericDataType(&v))
+		}
+	}
+
+	readAll := func(d diodes.Diode) []int {
+		var values []int
+		for {
+			data, ok := d.TryNext()
+			if !ok {
+				return values
+			}
+			values = append(values, *(*int)(data))
+		}
+	}
+
+	newDiodes := func(size int, opts ...diodes.DiodeConfigOption) map[string]diodes.Diode {
+		opts = append(opts, diodes.WithImplementation(impl), diodes.WithStartIndex(start))
+		return map[string]diodes.Diode{
+			"OneToOne":  diodes.NewOneToOne(size, nil, opts...),
+			"ManyToOne": diodes.NewManyToOne(size, nil, opts...),
+		}
+	}
+
+	It("reads the values across the wrap in order", func() {
+		for name, d := range newDiodes(4) {
+			set(d, 0, 3)
+			Expect(d.(diodes.LagReporter).Lag()).To(Equal(uint64(3)), name)
+			Expect(readAll(d)).To(Equal([]int{0, 1, 2}), name)
+
+			set(d, 3, 6)
+			Expect(readAll(d)).To(Equal([]int{3, 4, 5}), name)
+
+			stats := d.(diodes.StatsReporter).Stats()
+			Expect(stats.Writes).To(Equal(uint64(6)), name)
+			Expect(stats.Reads).To(Equal(uint64(6)), name)
+			Expect(stats.Drops).To(BeZero(), name)
+			Expect(stats.Lag).To(BeZero(), name)
+		}
+	})
+
+	It("detects a lap across the wrap", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl), diodes.WithStartIndex(start))
+		set(d, 0, 10)
+
+		data, gap, ok := d.TryNextGap()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(8))
+		Expect(gap).To(Equal(diodes.Gap{Missed: 8, First: start, Last: 5}))
+		Expect(readAll(d)).To(Equal([]int{9}))
+		Expect(d.Stats().Drops).To(Equal(uint64(8)))
+	})
+
+	It("detects a lap of many writers across the wrap", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl), diodes.WithStartIndex(start))
+		set(d, 0, 10)
+
+		data, gap, ok := d.TryNextGap()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(8))
+		Expect(gap).To(Equal(diodes.Gap{Missed: 8, First: start, Last: 5}))
+	})
+
+	It("does not drop values of a reader that keeps up with a size that is not a power of two", func() {
+		for name, d := range newDiodes(3) {
+			for i := 0; i < 10; i++ {
+				set(d, i, i+1)
+				Expect(readAll(d)).To(Equal([]int{i}), name)
+			}
+			Expect(d.(diodes.StatsReporter).Stats().Drops).To(BeZero(), name)
+		}
+	})
+
+	It("reports the drops of a lagging reader with a size that is not a power of two", func() {
+		for name, d := range newDiodes(3) {
+			set(d, 0, 3)
+			values := readAll(d)
+			stats := d.(diodes.StatsReporter).Stats()
+
+			Expect(values).ToNot(BeEmpty(), name)
+			Expect(uint64(len(values))+stats.Drops).To(Equal(uint64(3)), name)
+		}
+	})
+
+	It("drains across the wrap", func() {
+		d := diodes.NewOneToOne(4, nil, diodes.WithImplementation(impl), diodes.WithStartIndex(start))
+		set(d, 0, 3)
+		readAll(d)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		Expect(d.Drain(ctx)).To(Succeed())
+	})
+
+	It("numbers the leaked values from the start index", func() {
+		leaks := make(chan diodes.Leak, 1)
+		d := diodes.NewOneToOne(4, nil,
+			diodes.WithImplementation(impl),
+			diodes.WithStartIndex(start),
+			diodes.WithLeakHook(func(l diodes.Leak) { leaks <- l }),
+		)
+		set(d, 0, 4)
+		d.TryNext()
+		d.Close()
+
+		Expect(leaks).To(Receive(Equal(diodes.Leak{First: math.MaxUint64, Last: 1})))
+	})
+
+	It("starts over at the start index when it is reopened", func() {
+		d := diodes.NewManyToOne(4, nil, diodes.WithImplementation(impl), diodes.WithStartIndex(start), diodes.WithStrictOrder())
+		set(d, 0, 3)
+		d.Close()
+		Expect(d.Reopen()).To(Succeed())
+
+		set(d, 0, 3)
+		data, gap, ok := d.TryNextGap()
+		Expect(ok).To(BeTrue())
+		Expect(*(*int)(data)).To(Equal(0))
+		Expect(gap).To(Equal(diodes.Gap{}))
+		Expect(d.Stats().Writes).To(Equal(uint64(3)))
+	})
+})
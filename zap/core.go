@@ -0,0 +1,176 @@
+// Package zap provides a zapcore.Core that never blocks the logging
+// go-routine.
+package zap
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"code.cloudfoundry.org/go-diodes"
+	"go.uber.org/zap/zapcore"
+)
+
+// Core is a zapcore.Core that sets the encoded entries on a diode. A
+// background go-routine writes them to the WriteSyncer. When the background
+// go-routine falls behind, entries are dropped and the number of dropped
+// entries is logged periodically.
+type Core struct {
+	zapcore.Core
+	w *worker
+}
+
+// CoreOption can be used to setup the core.
+type CoreOption func(*coreConfig)
+
+type coreConfig struct {
+	size         int
+	dropInterval time.Duration
+}
+
+// WithSize sets the number of entries the diode holds. The default is 1024.
+func WithSize(size int) CoreOption {
+	return CoreOption(func(c *coreConfig) {
+		c.size = size
+	})
+}
+
+// WithDropInterval sets the interval at which the number of dropped entries
+// is logged. Nothing is logged for an interval without drops. The default is
+// 10s.
+func WithDropInterval(interval time.Duration) CoreOption {
+	return CoreOption(func(c *coreConfig) {
+		c.dropInterval = interval
+	})
+}
+
+// worker is the background go-routine that writes the entries. It
+// implements zapcore.WriteSyncer for the wrapped core.
+type worker struct {
+	written uint64
+	handled uint64
+	dropped uint64
+
+	ws     zapcore.WriteSyncer
+	drops  zapcore.Core
+	waiter *diodes.Waiter
+	cancel context.CancelFunc
+	once   sync.Once
+	done   chan struct{}
+}
+
+// NewCore returns a new Core that encodes entries with enc and writes them
+// to ws on a background go-routine. Close must be called to stop the
+// go-routine.
+func NewCore(enc zapcore.Encoder, ws zapcore.WriteSyncer, enab zapcore.LevelEnabler, opts ...CoreOption) *Core {
+	c := coreConfig{
+		size:         1024,
+		dropInterval: 10 * time.Second,
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		ws:     ws,
+		drops:  zapcore.NewCore(enc.Clone(), ws, zapcore.DebugLevel),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	w.waiter = diodes.NewWaiter(
+		diodes.NewManyToOne(c.size, diodes.AlertFunc(func(missed int) {
+			atomic.AddUint64(&w.dropped, uint64(missed))
+		})),
+		diodes.WithWaiterContext(ctx),
+	)
+
+	go w.run(ctx, c.dropInterval)
+
+	return &Core{
+		Core: zapcore.NewCore(enc, w, enab),
+		w:    w,
+	}
+}
+
+// Close writes the entries that are still in the diode and any drops that
+// were not logged yet, syncs the WriteSyncer and stops the background
+// go-routine. Entries logged after Close are dropped. It is safe to call
+// Close more than once.
+func (c *Core) Close() error {
+	c.w.once.Do(c.w.cancel)
+	<-c.w.done
+
+	return c.w.ws.Sync()
+}
+
+// Write copies an encoded entry into the diode. It never blocks.
+func (w *worker) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	atomic.AddUint64(&w.written, 1)
+	w.waiter.Set(diodes.GenericDataType(&b))
+
+	return len(p), nil
+}
+
+// Sync waits until the entries written so far have been written or dropped
+// and syncs the WriteSyncer. Zap syncs before it exits for fatal entries.
+func (w *worker) Sync() error {
+	written := atomic.LoadUint64(&w.written)
+	for atomic.LoadUint64(&w.handled)+atomic.LoadUint64(&w.dropped) < written {
+		select {
+		case <-w.done:
+			return w.ws.Sync()
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	return w.ws.Sync()
+}
+
+func (w *worker) run(ctx context.Context, dropInterval time.Duration) {
+	defer close(w.done)
+
+	t := time.NewTicker(dropInterval)
+	defer t.Stop()
+
+	// Next keeps returning the entries that are in the diode until it is
+	// empty, even after the context is done.
+	for {
+		data := w.waiter.Next()
+		if data == nil {
+			break
+		}
+
+		_, _ = w.ws.Write(*(*[]byte)(unsafe.Pointer(data)))
+		atomic.AddUint64(&w.handled, 1)
+
+		select {
+		case <-t.C:
+			w.logDrops()
+		default:
+		}
+	}
+
+	w.logDrops()
+}
+
+// logDrops logs the number of entries dropped since it was last invoked.
+// Drops are only noticed by the reader, so they are logged after the next
+// entry that is read.
+func (w *worker) logDrops() {
+	dropped := atomic.SwapUint64(&w.dropped, 0)
+	if dropped == 0 {
+		return
+	}
+	atomic.AddUint64(&w.handled, dropped)
+
+	_ = w.drops.Write(zapcore.Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    time.Now(),
+		Message: "dropped log entries",
+	}, []zapcore.Field{{Key: "dropped", Type: zapcore.Uint64Type, Integer: int64(dropped)}})
+}
@@ -0,0 +1,131 @@
+package zap_test
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	diodeszap "code.cloudfoundry.org/go-diodes/zap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Core", func() {
+	var (
+		ws   *syncBuffer
+		enc  zapcore.Encoder
+		core *diodeszap.Core
+	)
+
+	BeforeEach(func() {
+		ws = &syncBuffer{}
+		enc = zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+			MessageKey:  "msg",
+			LevelKey:    "level",
+			EncodeLevel: zapcore.LowercaseLevelEncoder,
+		})
+	})
+
+	AfterEach(func() {
+		Expect(core.Close()).To(Succeed())
+	})
+
+	It("writes the entries on a background go-routine", func() {
+		core = diodeszap.NewCore(enc, ws, zapcore.InfoLevel)
+		logger := zap.New(core).With(zap.String("diode", "ingress"))
+
+		logger.Info("some message", zap.Int("id", 1))
+		logger.Debug("not enabled")
+
+		Eventually(ws.String).Should(Equal(`{"level":"info","msg":"some message","diode":"ingress","id":1}` + "\n"))
+	})
+
+	It("writes the remaining entries on Sync", func() {
+		core = diodeszap.NewCore(enc, ws, zapcore.InfoLevel)
+		logger := zap.New(core)
+		for i := 0; i < 3; i++ {
+			logger.Info("some message", zap.Int("i", i))
+		}
+		Expect(logger.Sync()).To(Succeed())
+
+		Expect(ws.String()).To(Equal(
+			`{"level":"info","msg":"some message","i":0}` + "\n" +
+				`{"level":"info","msg":"some message","i":1}` + "\n" +
+				`{"level":"info","msg":"some message","i":2}` + "\n",
+		))
+		Expect(ws.synced()).To(BeTrue())
+	})
+
+	It("logs the number of dropped entries", func() {
+		ws.block = make(chan struct{})
+		core = diodeszap.NewCore(enc, ws, zapcore.InfoLevel,
+			diodeszap.WithSize(2),
+			diodeszap.WithDropInterval(time.Millisecond),
+		)
+		logger := zap.New(core)
+
+		logger.Info("first")
+		Eventually(ws.blocked).Should(BeTrue())
+		for i := 0; i < 6; i++ {
+			logger.Info("some message", zap.Int("i", i))
+		}
+		close(ws.block)
+		Expect(core.Close()).To(Succeed())
+
+		Expect(ws.String()).To(ContainSubstring(`{"level":"warn","msg":"dropped log entries","dropped":4}` + "\n"))
+	})
+})
+
+// syncBuffer is a zapcore.WriteSyncer. If block is set, the first write
+// blocks until it is closed.
+type syncBuffer struct {
+	block chan struct{}
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	isBlocked bool
+	isSynced  bool
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	first := !b.isBlocked
+	b.isBlocked = true
+	b.mu.Unlock()
+
+	if first && b.block != nil {
+		<-b.block
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.isSynced = true
+	return nil
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) blocked() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.isBlocked
+}
+
+func (b *syncBuffer) synced() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.isSynced
+}
@@ -0,0 +1,13 @@
+package zap_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestZap(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Zap Suite")
+}